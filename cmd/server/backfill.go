@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"repub/internal/service"
+)
+
+// runBackfill stores the derived data of versions published before it was
+// computed at publish time, so read paths never write it: archive sizes and
+// rendered docs. It returns how many updates it made.
+func runBackfill(ctx context.Context, pubSvc service.PubService, args []string, out io.Writer) (int, error) {
+	fset := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fset.SetOutput(out)
+	if err := fset.Parse(args); err != nil {
+		return 0, err
+	}
+	if fset.NArg() != 0 {
+		return 0, fmt.Errorf("usage: repub backfill")
+	}
+
+	sized, err := pubSvc.BackfillSizes(ctx)
+	fmt.Fprintf(out, "Recorded the archive sizes of %d versions\n", sized)
+	if err != nil {
+		return sized, err
+	}
+
+	rendered, err := pubSvc.BackfillDocsHTML(ctx)
+	fmt.Fprintf(out, "Rendered the docs of %d versions\n", rendered)
+	return sized + rendered, err
+}
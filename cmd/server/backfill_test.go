@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestRunBackfill(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	ctx := context.Background()
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: legacy\nversion: 1.0.0",
+		"README.md":    "# Legacy",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	// Versions published before sizes and docs were stored at publish time
+	if _, err := repos.DB.DB.ExecContext(ctx, "UPDATE package_versions SET size_bytes = NULL, readme_html = NULL, changelog_html = NULL"); err != nil {
+		t.Fatalf("Failed to clear the derived data: %v", err)
+	}
+
+	t.Run("rejects arguments", func(t *testing.T) {
+		if _, err := runBackfill(ctx, pubSvc, []string{"extra"}, &bytes.Buffer{}); err == nil {
+			t.Error("Expected an error for an unexpected argument")
+		}
+	})
+
+	t.Run("stores missing data once", func(t *testing.T) {
+		var out bytes.Buffer
+		updated, err := runBackfill(ctx, pubSvc, nil, &out)
+		if err != nil {
+			t.Fatalf("runBackfill failed: %v", err)
+		}
+		if updated != 2 || !strings.Contains(out.String(), "sizes of 1 versions") || !strings.Contains(out.String(), "docs of 1 versions") {
+			t.Errorf("Expected the size and docs of 1 version to be backfilled, got %d: %s", updated, out.String())
+		}
+
+		detail, err := pubSvc.GetPackageDetail(ctx, "legacy")
+		if err != nil {
+			t.Fatalf("GetPackageDetail failed: %v", err)
+		}
+		if detail.Latest.ReadmeHTML == nil || !strings.Contains(*detail.Latest.ReadmeHTML, "Legacy") {
+			t.Errorf("Expected the README HTML to be stored, got %v", detail.Latest.ReadmeHTML)
+		}
+		if detail.Latest.SizeBytes == nil || *detail.Latest.SizeBytes != int64(len(archive)) {
+			t.Errorf("Expected the archive size %d to be stored, got %v", len(archive), detail.Latest.SizeBytes)
+		}
+
+		updated, err = runBackfill(ctx, pubSvc, nil, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("runBackfill failed: %v", err)
+		}
+		if updated != 0 {
+			t.Errorf("Expected a second run to update nothing, got %d", updated)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"repub/internal/repository/pubspec"
+	"repub/internal/service"
+)
+
+// runCheck validates archives the way a publish would, without a database or
+// storage, and returns how many of them failed
+func runCheck(ctx context.Context, args []string, out io.Writer) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("usage: repub check <archive.tar.gz>...")
+	}
+
+	parser := pubspec.NewParserRepository()
+	failed := 0
+	for _, path := range args {
+		archive, err := os.ReadFile(path)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", path, err)
+			continue
+		}
+
+		contents, err := service.ValidateArchive(ctx, parser, archive)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", path, err)
+			continue
+		}
+		fmt.Fprintf(out, "OK   %s: %s %s\n", path, contents.Pubspec.Name, contents.Pubspec.Version)
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	archives := map[string]map[string]string{
+		"valid.tar.gz":       {"pubspec.yaml": "name: foo\nversion: 1.0.0"},
+		"bad-version.tar.gz": {"pubspec.yaml": "name: foo\nversion: not-a-version"},
+		"no-pubspec.tar.gz":  {"README.md": "# No pubspec here"},
+	}
+	for name, files := range archives {
+		archive := testutil.CreateTestTarGzArchive(t, files)
+		if err := os.WriteFile(filepath.Join(dir, name), archive, 0644); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		archive        string
+		expectedFailed int
+		expectedOutput string
+	}{
+		{"valid archive", "valid.tar.gz", 0, "OK   " + filepath.Join(dir, "valid.tar.gz") + ": foo 1.0.0"},
+		{"invalid version", "bad-version.tar.gz", 1, "invalid pubspec"},
+		{"missing pubspec", "no-pubspec.tar.gz", 1, "pubspec.yaml not found"},
+		{"missing file", "missing.tar.gz", 1, "FAIL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			failed, err := runCheck(context.Background(), []string{filepath.Join(dir, tt.archive)}, &out)
+			if err != nil {
+				t.Fatalf("runCheck failed: %v", err)
+			}
+			if failed != tt.expectedFailed {
+				t.Errorf("Expected %d failures, got %d\n%s", tt.expectedFailed, failed, out.String())
+			}
+			if !strings.Contains(out.String(), tt.expectedOutput) {
+				t.Errorf("Expected output to contain %q, got %s", tt.expectedOutput, out.String())
+			}
+		})
+	}
+
+	if _, err := runCheck(context.Background(), nil, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error when no archive is given")
+	}
+}
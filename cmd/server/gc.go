@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"repub/internal/service"
+	"time"
+)
+
+// defaultGCAgeDays is how long ago a retracted version must have been published
+// before gc deletes it
+const defaultGCAgeDays = 90
+
+// runGC deletes retracted versions published more than -days ago, or only
+// lists them with -dry-run, and returns how many were (or would be) deleted
+func runGC(ctx context.Context, pubSvc service.PubService, args []string, out io.Writer) (int, error) {
+	fset := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fset.SetOutput(out)
+	days := fset.Int("days", defaultGCAgeDays, "minimum age in days of retracted versions to delete")
+	dryRun := fset.Bool("dry-run", false, "list the versions that would be deleted without deleting them")
+	if err := fset.Parse(args); err != nil {
+		return 0, err
+	}
+	if fset.NArg() != 0 || *days < 0 {
+		return 0, fmt.Errorf("usage: repub gc [-days n] [-dry-run]")
+	}
+
+	collected, err := pubSvc.CollectRetractedVersions(ctx, time.Duration(*days)*24*time.Hour, *dryRun)
+
+	action := "DELETE"
+	if *dryRun {
+		action = "WOULD DELETE"
+	}
+	deleted := 0
+	var reclaimed int64
+	for _, v := range collected {
+		if v.Skipped != "" {
+			fmt.Fprintf(out, "SKIP %s %s: %s\n", v.Package, v.Version, v.Skipped)
+			continue
+		}
+		deleted++
+		reclaimed += v.SizeBytes
+		fmt.Fprintf(out, "%s %s %s (published %s)\n", action, v.Package, v.Version, v.PublishedAt.Format("2006-01-02"))
+	}
+	if err != nil {
+		return deleted, err
+	}
+
+	if *dryRun {
+		fmt.Fprintf(out, "Would delete %d versions, %d bytes\n", deleted, reclaimed)
+	} else {
+		fmt.Fprintf(out, "Deleted %d versions, %d bytes\n", deleted, reclaimed)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunGC(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	ctx := context.Background()
+	deps := service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	}
+	publishSvc := service.NewPubService(deps)
+
+	publish := func(name, version string, retracted bool) {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: " + version,
+		})
+		if _, err := publishSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s %s: %v", name, version, err)
+		}
+		if retracted {
+			if _, err := publishSvc.SetVersionRetracted(ctx, name, version, true, domain.Actor{Admin: true}); err != nil {
+				t.Fatalf("Failed to retract %s %s: %v", name, version, err)
+			}
+		}
+	}
+	publish("foo", "1.0.0", true)
+	publish("foo", "1.1.0", true)
+	publish("foo", "2.0.0", false)
+	// Every version of bar is retracted, so none of them may be deleted
+	publish("bar", "1.0.0", true)
+
+	archivePath := func(name, version string) string {
+		t.Helper()
+		pkg, err := repos.DB.Repo.GetPackage(ctx, name)
+		if err != nil || pkg == nil {
+			t.Fatalf("Failed to get package %s: %v", name, err)
+		}
+		versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID)
+		if err != nil {
+			t.Fatalf("Failed to get versions: %v", err)
+		}
+		for _, v := range versions {
+			if v.Version == version {
+				return v.ArchivePath
+			}
+		}
+		return ""
+	}
+	retractedArchive := archivePath("foo", "1.0.0")
+
+	// Collect as if the versions were published 100 days ago
+	deps.Clock = clock.NewFake(time.Now().Add(100 * 24 * time.Hour))
+	gcSvc := service.NewPubService(deps)
+
+	t.Run("too recent", func(t *testing.T) {
+		var out bytes.Buffer
+		deleted, err := runGC(ctx, gcSvc, []string{"-days", "365"}, &out)
+		if err != nil {
+			t.Fatalf("runGC failed: %v", err)
+		}
+		if deleted != 0 {
+			t.Errorf("Expected nothing to be deleted, got %d\n%s", deleted, out.String())
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		var out bytes.Buffer
+		deleted, err := runGC(ctx, gcSvc, []string{"-days", "30", "--dry-run"}, &out)
+		if err != nil {
+			t.Fatalf("runGC failed: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("Expected 2 versions to be listed, got %d\n%s", deleted, out.String())
+		}
+		for _, line := range []string{"WOULD DELETE foo 1.0.0", "WOULD DELETE foo 1.1.0", "SKIP bar 1.0.0", "Would delete 2 versions"} {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+			}
+		}
+
+		if archivePath("foo", "1.0.0") == "" || !repos.StorageSvc.Exists(ctx, retractedArchive) {
+			t.Error("Dry run must not delete versions or archives")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		var out bytes.Buffer
+		deleted, err := runGC(ctx, gcSvc, []string{"-days", "30"}, &out)
+		if err != nil {
+			t.Fatalf("runGC failed: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("Expected 2 versions to be deleted, got %d\n%s", deleted, out.String())
+		}
+		if !strings.Contains(out.String(), "DELETE foo 1.0.0") || !strings.Contains(out.String(), "SKIP bar 1.0.0") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+
+		for _, version := range []string{"1.0.0", "1.1.0"} {
+			if archivePath("foo", version) != "" {
+				t.Errorf("Expected foo %s to be deleted", version)
+			}
+		}
+		if repos.StorageSvc.Exists(ctx, retractedArchive) {
+			t.Error("Expected the archive of foo 1.0.0 to be deleted")
+		}
+		if archivePath("foo", "2.0.0") == "" || archivePath("bar", "1.0.0") == "" {
+			t.Error("Expected foo 2.0.0 and bar 1.0.0 to be kept")
+		}
+
+		// A second run has nothing left to delete
+		out.Reset()
+		if deleted, err := runGC(ctx, gcSvc, []string{"-days", "30"}, &out); err != nil || deleted != 0 {
+			t.Errorf("Expected no further deletions, got %d, %v", deleted, err)
+		}
+	})
+}
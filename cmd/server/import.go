@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"strings"
+)
+
+// importResult summarises a bulk import run
+type importResult struct {
+	Imported int
+	Skipped  int
+	Failed   int
+}
+
+// runImport publishes every .tar.gz archive found under a directory through
+// the regular publish path, skipping versions that are already published.
+// Given a file instead of a directory, it restores a JSON Lines metadata export.
+func runImport(ctx context.Context, pubSvc service.PubService, args []string, out io.Writer) (*importResult, error) {
+	fset := flag.NewFlagSet("import", flag.ContinueOnError)
+	fset.SetOutput(out)
+	// Matches the uploader recorded by the publish API so imported packages stay publishable
+	uploader := fset.String("uploader", "authenticated-user", "uploader recorded for imported packages")
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+	if fset.NArg() != 1 {
+		return nil, fmt.Errorf("usage: repub import [-uploader name] <dir|export.jsonl>")
+	}
+	dir := fset.Arg(0)
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return importExport(ctx, pubSvc, dir, out)
+	}
+
+	var archives []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".tar.gz") {
+			archives = append(archives, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk import directory: %w", err)
+	}
+
+	result := &importResult{}
+	for _, path := range archives {
+		archive, err := os.ReadFile(path)
+		if err != nil {
+			result.Failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", path, err)
+			continue
+		}
+
+		_, err = pubSvc.PublishPackage(ctx, &domain.PublishRequest{
+			Archive:  archive,
+			Uploader: *uploader,
+		})
+		switch {
+		case err == nil:
+			result.Imported++
+			fmt.Fprintf(out, "OK   %s\n", path)
+		case errors.Is(err, service.ErrVersionExists):
+			result.Skipped++
+			fmt.Fprintf(out, "SKIP %s: %v\n", path, err)
+		default:
+			result.Failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", path, err)
+		}
+	}
+
+	fmt.Fprintf(out, "Imported %d, skipped %d, failed %d\n", result.Imported, result.Skipped, result.Failed)
+	return result, nil
+}
+
+// importExport restores package metadata from a JSON Lines file written by
+// GET /api/export. The archives it references must already be in storage.
+func importExport(ctx context.Context, pubSvc service.PubService, path string, out io.Writer) (*importResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	result := &importResult{}
+	decoder := json.NewDecoder(file)
+	for {
+		var pkg domain.ExportedPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return result, fmt.Errorf("failed to read export: %w", err)
+		}
+
+		created, err := pubSvc.ImportPackage(ctx, &pkg)
+		result.Imported += created
+		if err != nil {
+			result.Failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", pkg.Name, err)
+			continue
+		}
+		result.Skipped += len(pkg.Versions) - created
+		fmt.Fprintf(out, "OK   %s: %d of %d versions imported\n", pkg.Name, created, len(pkg.Versions))
+	}
+
+	fmt.Fprintf(out, "Imported %d, skipped %d, failed %d\n", result.Imported, result.Skipped, result.Failed)
+	return result, nil
+}
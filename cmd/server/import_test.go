@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"repub/internal/config"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestRunImport(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	dir := t.TempDir()
+	archives := map[string]string{
+		"foo-1.0.0.tar.gz":        "name: foo\nversion: 1.0.0",
+		"nested/foo-1.1.0.tar.gz": "name: foo\nversion: 1.1.0",
+		"bar-1.0.0.tar.gz":        "name: bar\nversion: 1.0.0",
+	}
+	for name, pubspec := range archives {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": pubspec})
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, archive, 0644); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.tar.gz"), []byte("not an archive"), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	var out bytes.Buffer
+	result, err := runImport(context.Background(), pubSvc, []string{dir}, &out)
+	if err != nil {
+		t.Fatalf("runImport failed: %v", err)
+	}
+	if result.Imported != 3 || result.Skipped != 0 || result.Failed != 1 {
+		t.Errorf("Unexpected first import result %+v\n%s", result, out.String())
+	}
+
+	// Re-running the import skips everything already published
+	out.Reset()
+	result, err = runImport(context.Background(), pubSvc, []string{"-uploader", "authenticated-user", dir}, &out)
+	if err != nil {
+		t.Fatalf("runImport failed: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 3 || result.Failed != 1 {
+		t.Errorf("Unexpected second import result %+v\n%s", result, out.String())
+	}
+
+	if _, err := runImport(context.Background(), pubSvc, nil, &out); err == nil {
+		t.Error("Expected error when no directory is given")
+	}
+}
+
+func TestRunImport_ExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	newService := func(repos *testutil.TestRepositories) service.PubService {
+		return service.NewPubService(service.PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			BaseURL: "http://localhost:9090",
+		})
+	}
+	authSvc := service.NewAuthService(
+		[]config.Token{{Name: "READER", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	export := func(t *testing.T, pubSvc service.PubService, authHeader string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/export", nil)
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		w := httptest.NewRecorder()
+		setupRouter(pubSvc, authSvc, routerDeps{}).ServeHTTP(w, req)
+		return w
+	}
+
+	source := testutil.SetupTestRepositories(t)
+	defer source.Close()
+	sourceSvc := newService(source)
+
+	for _, pubspec := range []string{
+		"name: foo\nversion: 1.0.0\ndescription: Foo",
+		"name: foo\nversion: 1.1.0\ndescription: Foo",
+		"name: bar\nversion: 0.1.0",
+	} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": pubspec,
+			"README.md":    "# Readme",
+		})
+		if _, err := sourceSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "alice"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+	}
+	if _, err := sourceSvc.SetVersionRetracted(ctx, "foo", "1.0.0", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("SetVersionRetracted failed: %v", err)
+	}
+	if _, err := sourceSvc.SetPackagePrivate(ctx, "bar", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("SetPackagePrivate failed: %v", err)
+	}
+	if _, err := sourceSvc.LikePackage(ctx, "foo", "alice"); err != nil {
+		t.Fatalf("LikePackage failed: %v", err)
+	}
+
+	if w := export(t, sourceSvc, "Bearer read-token"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected export to require an admin token, got %d", w.Code)
+	}
+
+	w := export(t, sourceSvc, "Bearer admin-token")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Export failed with status %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected JSON Lines content type, got %s", contentType)
+	}
+	exported := w.Body.String()
+	if lines := strings.Count(exported, "\n"); lines != 2 {
+		t.Fatalf("Expected one line per package, got %d:\n%s", lines, exported)
+	}
+	if !strings.Contains(exported, `"like_count":1`) || !strings.Contains(exported, `"created_at":"`) {
+		t.Errorf("Expected the export to keep like counts and creation times:\n%s", exported)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.jsonl")
+	if err := os.WriteFile(exportPath, []byte(exported), 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	// Restore into an empty registry
+	target := testutil.SetupTestRepositories(t)
+	defer target.Close()
+	targetSvc := newService(target)
+
+	var out bytes.Buffer
+	result, err := runImport(ctx, targetSvc, []string{exportPath}, &out)
+	if err != nil {
+		t.Fatalf("runImport failed: %v", err)
+	}
+	if result.Imported != 3 || result.Skipped != 0 || result.Failed != 0 {
+		t.Errorf("Unexpected import result %+v\n%s", result, out.String())
+	}
+
+	w = export(t, targetSvc, "Bearer admin-token")
+	if w.Body.String() != exported {
+		t.Errorf("Export after import differs\nbefore: %s\nafter:  %s", exported, w.Body.String())
+	}
+
+	// Importing again changes nothing
+	out.Reset()
+	result, err = runImport(ctx, targetSvc, []string{exportPath}, &out)
+	if err != nil {
+		t.Fatalf("runImport failed: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 3 || result.Failed != 0 {
+		t.Errorf("Unexpected second import result %+v\n%s", result, out.String())
+	}
+}
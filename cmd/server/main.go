@@ -1,27 +1,70 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
 	authmiddleware "repub/internal/auth/middleware"
+	"repub/internal/buildinfo"
+	"repub/internal/clock"
 	"repub/internal/config"
+	"repub/internal/domain"
 	"repub/internal/handlers"
 	"repub/internal/repository/pkg"
 	"repub/internal/repository/pkg/postgres"
 	"repub/internal/repository/pubspec"
 	"repub/internal/repository/storage"
 	"repub/internal/service"
+	"repub/internal/telemetry"
+	"repub/web/templates"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-version") {
+		fmt.Println(buildinfo.Get())
+		return
+	}
+
+	// Archive check subcommand: repub check <archive.tar.gz>..., needs no configuration
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		failed, err := runCheck(context.Background(), os.Args[2:], os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg := config.Load()
 
+	// Setup tracing (no-op unless an OTLP endpoint is configured)
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatal("Failed to setup tracing:", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Failed to shutdown tracing", "error", err)
+		}
+	}()
+
 	// Connect to database
 	dbConn, err := sql.Open("pgx", cfg.DatabaseURL)
 	if err != nil {
@@ -39,87 +82,466 @@ func main() {
 
 	// Initialize layers
 	queries := postgres.New(dbConn)
-	var storageRepo storage.Repository
-	if cfg.StorageBackend == "gcs" {
-		var err error
-		storageRepo, err = storage.NewGCSRepository(cfg.GCSBucket)
-		if err != nil {
-			log.Fatal("Failed to create GCS storage:", err)
-		}
-	} else {
-		storageRepo = storage.NewLocalRepository(cfg.StoragePath)
+	storageKeys, err := storage.ParseKeyTemplate(cfg.StorageKeyTemplate)
+	if err != nil {
+		log.Fatal("Invalid STORAGE_KEY_TEMPLATE:", err)
+	}
+	storagePrefix, err := storage.ParsePrefix(cfg.StoragePrefix)
+	if err != nil {
+		log.Fatal("Invalid STORAGE_PREFIX:", err)
+	}
+	transport, err := outboundTransport(cfg)
+	if err != nil {
+		log.Fatal("Invalid outbound connection settings:", err)
+	}
+	storageRepo, err := newStorageRepository(cfg.StorageBackend, cfg.StoragePath, cfg.GCSBucket, storagePrefix, storageKeys, transport)
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
 	}
 	pubspecRepo := pubspec.NewParserRepository()
 
 	// Repository layer
-	packageRepo := pkg.NewPostgresPackageRepository(queries)
+	packageRepo := pkg.NewTracedRepository(pkg.NewPostgresPackageRepository(queries))
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	for _, feature := range cfg.Features {
+		if !strings.EqualFold(feature, config.FeatureBatch) && !strings.EqualFold(feature, config.FeatureProxy) && !strings.EqualFold(feature, config.FeatureExport) {
+			log.Fatalf("Invalid FEATURES entry %q, expected batch, proxy or export", feature)
+		}
+	}
+
+	for _, sdk := range cfg.AllowedPublishSDKs {
+		if !strings.EqualFold(sdk, service.SDKDart) && !strings.EqualFold(sdk, service.SDKFlutter) {
+			log.Fatalf("Invalid ALLOWED_PUBLISH_SDKS entry %q, expected dart or flutter", sdk)
+		}
+	}
+
+	// Every component reads the time from one clock
+	clk := clock.Real()
+
+	// Publish notifications
+	var notifier *service.WebhookNotifier
+	if cfg.PublishWebhookURL != "" {
+		notifier = service.NewWebhookNotifier(service.WebhookConfig{
+			URL:       cfg.PublishWebhookURL,
+			Secret:    cfg.PublishWebhookSecret,
+			Transport: transport,
+		})
+	}
 
 	// Service layer
-	pubSvc := service.NewPubService(service.PackageDependencies{
+	deps := service.PackageDependencies{
+		Clock:   clk,
 		Storage: storageRepo,
 		Package: packageRepo,
 		Pubspec: pubspecRepo,
 		BaseURL: cfg.BaseURL,
-	})
-	authSvc := service.NewAuthService(cfg.ReadTokens, cfg.WriteTokens)
+
+		RequireIncreasingVersions: cfg.RequireIncreasingVersions,
+		MaxVersionsPerPackage:     cfg.MaxVersionsPerPackage,
+		MaxTotalBytesPerPackage:   cfg.MaxTotalBytesPerPackage,
+		ReservedPackageNames:      cfg.ReservedPackageNames,
+		BlockedPackageNames:       cfg.BlockedPackageNames,
+		AllowedSDKs:               cfg.AllowedPublishSDKs,
+		EnforcePublishTo:          cfg.EnforcePublishTo,
+		TarGzArchiveURLs:          cfg.TarGzArchiveURLs,
+		UploaderFromAuthor:        cfg.UploaderFromAuthor,
+		ArchiveLimits: service.ArchiveLimits{
+			MaxFiles:        cfg.ArchiveMaxFiles,
+			MaxFileBytes:    cfg.ArchiveMaxFileBytes,
+			RequireDartCode: cfg.ArchiveRequireDartCode,
+			MaxDocBytes:     cfg.MaxReadmeBytes,
+			RejectLargeDocs: cfg.RejectLargeReadmes,
+		},
+	}
+	if notifier != nil {
+		deps.Notifier = notifier
+		if cfg.PublishWebhookReports {
+			deps.ReportNotifier = notifier
+		}
+	}
+	if cfg.DownloadFlushInterval > 0 {
+		deps.Downloads = service.NewDownloadCounter(packageRepo)
+	}
+	switch {
+	case cfg.UpstreamURL != "" && !cfg.FeatureEnabled(config.FeatureProxy):
+		slog.Warn("UPSTREAM_URL is ignored, the proxy feature isn't enabled in FEATURES")
+	case cfg.UpstreamURL != "":
+		deps.Upstream = service.NewUpstreamProxy(service.UpstreamConfig{
+			URL:           cfg.UpstreamURL,
+			CacheArchives: cfg.UpstreamCacheArchives,
+			MetadataTTL:   cfg.UpstreamMetadataTTL,
+			Transport:     transport,
+			Clock:         clk,
+		})
+	}
+	if cfg.AdvisoriesURL != "" {
+		deps.Advisories = service.NewAdvisoryClient(service.AdvisoryConfig{
+			URL:       cfg.AdvisoriesURL,
+			TTL:       cfg.AdvisoriesTTL,
+			Transport: transport,
+			Clock:     clk,
+		})
+	}
+	deps.DownloadSigner = downloadSigner(cfg, clk)
+	if cfg.ReadmeRenderWorkers > 0 {
+		deps.Readmes = service.NewReadmeRenderer(packageRepo, cfg.ReadmeRenderWorkers)
+	}
+	pubSvc := service.NewPubService(deps)
+	authSvc := service.NewAuthService(cfg.ReadTokens, cfg.WriteTokens, cfg.AdminTokens, clk)
+	var tokenSvc service.TokenService
+	switch cfg.AuthBackend {
+	case config.AuthBackendDB:
+		dbAuthSvc := service.NewDBAuthService(packageRepo, authSvc, clk)
+		authSvc, tokenSvc = dbAuthSvc, dbAuthSvc
+	case config.AuthBackendOIDC:
+		if cfg.OIDCJWKSURL == "" || cfg.OIDCIssuer == "" || cfg.OIDCAudience == "" {
+			// Without an audience any token the issuer signs for another
+			// client would be accepted
+			log.Fatal("AUTH_BACKEND=oidc requires OIDC_JWKS_URL, OIDC_ISSUER and OIDC_AUDIENCE")
+		}
+		authSvc = service.NewJWTAuthService(service.JWTConfig{
+			JWKSURL:       cfg.OIDCJWKSURL,
+			Issuer:        cfg.OIDCIssuer,
+			Audience:      cfg.OIDCAudience,
+			IdentityClaim: cfg.OIDCIdentityClaim,
+			Transport:     transport,
+			Clock:         clk,
+		}, authSvc)
+	}
+
+	// Bulk import subcommand: repub import [-uploader name] <dir|export.jsonl>
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		result, err := runImport(context.Background(), pubSvc, os.Args[2:], os.Stdout)
+		if notifier != nil {
+			// Deliver the queued notifications before exiting
+			notifier.Close()
+		}
+		if deps.Readmes != nil {
+			deps.Readmes.Close()
+		}
+		if err != nil {
+			log.Fatal("Import failed:", err)
+		}
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Retracted version garbage collection: repub gc [-days n] [-dry-run]
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		if _, err := runGC(context.Background(), pubSvc, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Garbage collection failed:", err)
+		}
+		return
+	}
+
+	// Backfill of data derived at publish time: repub backfill
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if _, err := runBackfill(context.Background(), pubSvc, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Backfill failed:", err)
+		}
+		return
+	}
+
+	// Storage backend migration: repub migrate-storage -backend gcs|local [-gcs-bucket b] [-storage-path p] [-dry-run]
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		failed, err := runMigrateStorage(context.Background(), pubSvc, storagePrefix, storageKeys, transport, os.Args[2:], os.Stdout)
+		if err != nil {
+			log.Fatal("Storage migration failed:", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Background work stops and the server drains on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Periodically reclaim storage objects left behind by failed publishes
+	if cfg.StorageCleanupInterval > 0 {
+		go service.RunStorageCleanup(ctx, pubSvc, cfg.StorageCleanupInterval, cfg.StorageCleanupGracePeriod)
+	}
+
+	if deps.Downloads != nil {
+		go deps.Downloads.Run(ctx, cfg.DownloadFlushInterval)
+	}
+
+	// Drop uploads whose client never came back to finalize them
+	if cfg.PendingUploadTTL > 0 {
+		go handlers.RunPendingUploadJanitor(ctx, cfg.PendingUploadTTL, clk)
+	}
 
 	// Setup router
-	r := setupRouter(pubSvc, authSvc)
+	r := setupRouter(pubSvc, authSvc, routerDeps{Clock: clk, DownloadSigner: deps.DownloadSigner, TokenService: tokenSvc})
+	server := newHTTPServer(cfg, r)
+
+	// ListenAndServe returns as soon as Shutdown starts, drained is closed
+	// once the in-flight requests have finished
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		log.Printf("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shut down server", "error", err)
+		}
+	}()
+
+	if cfg.TLSEnabled() {
+		log.Printf("Server starting on port %s with TLS", cfg.Port)
+		err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		log.Printf("Server starting on port %s", cfg.Port)
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-drained
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
+	// Downloads served while draining are still counted
+	if deps.Downloads != nil {
+		if err := deps.Downloads.Flush(context.Background()); err != nil {
+			slog.Error("Failed to flush download counts", "error", err)
+		}
+	}
+	// Cache the upstream archives downloaded while draining
+	if deps.Upstream != nil {
+		deps.Upstream.Close()
+	}
+	// Store the READMEs of versions published while draining
+	if deps.Readmes != nil {
+		deps.Readmes.Close()
+	}
+	// Deliver the notifications of publishes and reports made while draining
+	if notifier != nil {
+		notifier.Close()
+	}
 }
 
-func setupRouter(pubSvc service.PubService, authSvc service.AuthService) *chi.Mux {
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
+// routerDeps are the components main builds once and shares with the
+// router's handlers; zero values select the defaults
+type routerDeps struct {
+	// Clock timestamps pending uploads, defaults to the system clock
+	Clock clock.Clock
+	// DownloadSigner verifies signed archive URLs, nil when downloads
+	// aren't signed. It must be the signer the service signs them with.
+	DownloadSigner *service.DownloadSigner
+	// TokenService manages the tokens stored in the database, nil when
+	// tokens come from the environment
+	TokenService service.TokenService
+}
+
+func setupRouter(pubSvc service.PubService, authSvc service.AuthService, rd routerDeps) *chi.Mux {
 	cfg := config.Load() // Get config for base URL
+	if rd.Clock == nil {
+		rd.Clock = clock.Real()
+	}
 	r := chi.NewRouter()
 
 	// Global middleware
+	r.Use(otelhttp.NewMiddleware("repub"))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
 	r.Use(authmiddleware.OptionalAuth(authSvc))
 
+	// Routes that change state are rejected with 503 in read-only mode
+	var writeGuard []func(http.Handler) http.Handler
+	if cfg.ReadOnly {
+		writeGuard = append(writeGuard, handlers.ReadOnlyMiddleware())
+	}
+
+	// Reads need a read token. With ANONYMOUS_READ the routes pub clients
+	// resolve and download public packages with don't, the service hides
+	// private packages from them. Listings and routes recording who acted
+	// keep requiring a token.
+	requireRead := authmiddleware.RequireAuthMiddleware(authSvc, cfg.AuthRealm, false) // false = read access sufficient
+	packageRead := requireRead
+	var tokenGuard []func(http.Handler) http.Handler
+	if cfg.AnonymousRead {
+		packageRead = func(next http.Handler) http.Handler { return next }
+		tokenGuard = append(tokenGuard, requireRead)
+	}
+
+	// Routes of experimental features 404 unless enabled with FEATURES
+	featureGuard := func(feature string) []func(http.Handler) http.Handler {
+		if cfg.FeatureEnabled(feature) {
+			return nil
+		}
+		return []func(http.Handler) http.Handler{handlers.FeatureDisabledMiddleware()}
+	}
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		// API handlers are bounded by the request timeout. Streamed package
+		// metadata, uploads, finalizes and exports are exempt; the ones moving
+		// whole archives get TransferTimeout instead.
+		r.Use(requestDeadline(cfg.RequestTimeout))
+
 		r.Route("/packages", func(r chi.Router) {
+			// Pub clients pin the protocol version with their Accept header
+			r.Use(handlers.PubContentNegotiation())
+
 			// Read-only routes (require read tokens)
 			r.Group(func(r chi.Router) {
-				r.Use(authmiddleware.RequireAuthMiddleware(authSvc, false)) // false = read access sufficient
-				r.Get("/{package}", handlers.GetPackageHandler(pubSvc))
+				r.Use(packageRead)
+				// A page of the package list, for mirrors to sync incrementally with ?since=
+				r.With(tokenGuard...).Get("/", handlers.ListPackagesHandler(pubSvc))
+				// Package metadata is streamed a page of versions at a time
+				r.With(withoutDeadline).Get("/{package}", handlers.GetPackageHandler(pubSvc))
+				r.With(featureGuard(config.FeatureBatch)...).Post("/batch", handlers.GetPackagesBatchHandler(pubSvc))
+				r.Get("/{package}/latest", handlers.GetLatestVersionHandler(pubSvc))
 				r.Get("/{package}/versions/{version}", handlers.GetPackageVersionHandler(pubSvc))
+				r.Get("/{package}/versions/{version}/pubspec.yaml", handlers.GetPubspecYAMLHandler(pubSvc))
+				r.Get("/{package}/versions/{version}/archive.sha256", handlers.GetArchiveSHA256Handler(pubSvc))
+				r.Get("/{package}/versions/{version}/readme", handlers.GetReadmeHandler(pubSvc))
+				r.Get("/{package}/versions/{version}/dependencies", handlers.GetVersionDependenciesHandler(pubSvc))
 				r.Get("/{package}/advisories", handlers.GetAdvisoriesHandler(pubSvc))
+				r.Get("/{package}/score", handlers.GetScoreHandler(pubSvc))
+				r.Get("/{package}/metrics", handlers.GetDownloadMetricsHandler(pubSvc))
+				r.Get("/{package}/options", handlers.GetPackageOptionsHandler(pubSvc))
+				r.Get("/{package}/uploaders", handlers.GetUploadersHandler(pubSvc, authSvc, cfg.UploadersPublic))
+				// Likes and reports come from readers, a write token is for
+				// publishing; they still change state, so read-only mode rejects them
+				r.With(tokenGuard...).With(writeGuard...).Post("/{package}/like", handlers.LikePackageHandler(pubSvc))
+				r.With(tokenGuard...).With(writeGuard...).Post("/{package}/versions/{version}/report", handlers.ReportVersionHandler(pubSvc))
 			})
 
 			// Write routes (require write tokens)
 			r.Group(func(r chi.Router) {
-				r.Use(authmiddleware.RequireAuthMiddleware(authSvc, true)) // true = write required
-				r.Get("/versions/new", handlers.NewPackageVersionHandler(pubSvc))
-				r.Post("/versions/new", handlers.UploadPackageHandler(pubSvc, cfg.BaseURL))
-				r.Get("/versions/newUploadFinish", handlers.FinalizeUploadHandler(pubSvc))
+				r.Use(authmiddleware.RequireAuthMiddleware(authSvc, cfg.AuthRealm, true)) // true = write required
+				r.Use(writeGuard...)
+				// Dry runs and finalizes share one limit so bursts can't overwhelm
+				// storage and the database. Uploads only buffer the archive, so
+				// slow ones don't hold a slot.
+				publishLimit := handlers.PublishLimitMiddleware(cfg.MaxConcurrentPublishes)
+				r.Get("/versions/new", handlers.NewPackageVersionHandler(pubSvc, cfg.BaseURL))
+				r.With(withoutDeadline, transferDeadline(cfg.TransferTimeout), limitBody(cfg.MaxUploadBytes)).
+					Post("/versions/new", handlers.UploadPackageHandler(pubSvc, cfg.BaseURL, rd.Clock))
+				r.With(withoutDeadline, transferDeadline(cfg.TransferTimeout), limitBody(cfg.MaxUploadBytes)).
+					Post("/versions/validate", handlers.ValidatePackageHandler(pubSvc, publishLimit))
+				r.With(withoutDeadline, publishLimit).Get("/versions/newUploadFinish", handlers.FinalizeUploadHandler(pubSvc))
+				r.Put("/{package}/privacy", handlers.SetPackagePrivacyHandler(pubSvc, authSvc))
+				r.Post("/{package}/versions/{version}/retract", handlers.RetractVersionHandler(pubSvc, authSvc))
+				r.Post("/{package}/versions/{version}/unretract", handlers.UnretractVersionHandler(pubSvc, authSvc))
+			})
+
+			// Consistency checks for operators (require admin tokens)
+			r.Group(func(r chi.Router) {
+				r.Use(authmiddleware.RequireAdminMiddleware(authSvc, cfg.AuthRealm))
+				r.Get("/{package}/versions/{version}/verify", handlers.VerifyVersionHandler(pubSvc))
+				r.Get("/{package}/versions/{version}/raw", handlers.VersionRecordHandler(pubSvc))
+				// Re-renders cached README HTML after manual database edits
+				r.With(writeGuard...).Post("/{package}/refresh", handlers.RefreshPackageHandler(pubSvc))
 			})
 		})
+
+		// Build metadata isn't sensitive, so it's served without authentication
+		r.Get("/version", handlers.VersionHandler())
+
+		// Upload constraints and read access for clients to probe before
+		// publishing
+		r.Get("/meta", handlers.MetaHandler(domain.ServerMeta{
+			ProtocolVersion:      "2",
+			MaxUploadBytes:       cfg.MaxUploadBytes,
+			AnonymousRead:        cfg.AnonymousRead,
+			RequireSignedUploads: cfg.RequireSignedUploads,
+			ReadOnly:             cfg.ReadOnly,
+		}))
+
+		// Totals for operators, cached briefly since they aggregate every table
+		r.With(requireRead).
+			Get("/stats", handlers.StatsHandler(pubSvc))
+
+		// Metadata export for backups and mirroring, restored with `repub import`.
+		// The feature check comes first so a disabled export is a 404 for everyone.
+		r.With(featureGuard(config.FeatureExport)...).
+			With(withoutDeadline, authmiddleware.RequireAdminMiddleware(authSvc, cfg.AuthRealm), transferDeadline(cfg.TransferTimeout)).
+			Get("/export", handlers.ExportHandler(pubSvc))
+
+		// Abuse reports filed against versions, for moderators
+		r.With(authmiddleware.RequireAdminMiddleware(authSvc, cfg.AuthRealm)).
+			Get("/admin/reports", handlers.ListReportsHandler(pubSvc))
+
+		// Token management, only available when tokens are stored in the database
+		if rd.TokenService != nil {
+			r.Route("/admin/tokens", func(r chi.Router) {
+				r.Use(authmiddleware.RequireAdminMiddleware(authSvc, cfg.AuthRealm))
+				r.Get("/", handlers.ListTokensHandler(rd.TokenService))
+				r.With(writeGuard...).Post("/", handlers.CreateTokenHandler(rd.TokenService))
+				r.With(writeGuard...).Delete("/{id}", handlers.RevokeTokenHandler(rd.TokenService))
+			})
+		}
 	})
 
 	// Package download routes
 
 	r.Group(func(r chi.Router) {
-		r.Use(authmiddleware.RequireAuthMiddleware(authSvc, false)) // false = read access sufficient
+		// Signed URLs from the package metadata download without a token
+		r.Use(handlers.SignedDownloadMiddleware(rd.DownloadSigner, packageRead))
+		r.Use(transferDeadline(cfg.TransferTimeout))
 		r.Get("/packages/{package}/versions/{version}/download", handlers.DownloadPackageHandler(pubSvc))
+		// Same archive under a .tar.gz name, for mirrors and proxies that key on the extension
+		r.Get("/packages/{package}/versions/{version}/archive.tar.gz", handlers.DownloadPackageHandler(pubSvc))
+		// HEAD checks that an archive exists and its size without downloading it
+		r.Head("/packages/{package}/versions/{version}/download", handlers.DownloadPackageHandler(pubSvc))
+		r.Head("/packages/{package}/versions/{version}/archive.tar.gz", handlers.DownloadPackageHandler(pubSvc))
 	})
 
-	// Web routes (SSR with templ)
-	r.Group(func(r chi.Router) {
-		r.Use(authmiddleware.RequireAuthMiddleware(authSvc, false)) // false = read access sufficient
-		r.Get("/", handlers.IndexHandler())
-		r.Get("/packages", handlers.PackagesListHandler(pubSvc))
-		r.Get("/packages/{package}", handlers.PackageDetailHandler(pubSvc))
-		r.Get("/packages/{package}/versions/{version}", handlers.VersionDetailHandler(pubSvc))
-	})
+	// Profiling, only mounted when explicitly enabled
+	if cfg.EnablePprof {
+		r.Route("/debug/pprof", func(r chi.Router) {
+			r.Use(authmiddleware.RequireAdminMiddleware(authSvc, cfg.AuthRealm))
+			r.HandleFunc("/*", pprof.Index)
+			r.HandleFunc("/cmdline", pprof.Cmdline)
+			r.HandleFunc("/profile", pprof.Profile)
+			r.HandleFunc("/symbol", pprof.Symbol)
+			r.HandleFunc("/trace", pprof.Trace)
+		})
+	}
+
+	// Gauges for monitoring, in the Prometheus text format. Scrapers get a
+	// token of their own rather than an admin token.
+	if cfg.MetricsToken != "" {
+		r.With(authmiddleware.RequireTokenMiddleware(cfg.MetricsToken, cfg.AuthRealm)).Get("/metrics", handlers.MetricsHandler(rd.Clock))
+	}
 
-	// Static files
-	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static/"))))
+	// The web UI can be turned off for API-only deployments, its paths then 404
+	if cfg.EnableWebUI {
+		// Web routes (SSR with templ)
+		r.Group(func(r chi.Router) {
+			r.Use(requireRead)
+			r.Get("/", handlers.IndexHandler(pubSvc, templates.Instance{
+				Name:        cfg.InstanceName,
+				Description: cfg.InstanceDescription,
+			}, cfg.CustomIndexHTML))
+			r.Get("/packages", handlers.PackagesListHandler(pubSvc))
+			r.Get("/packages/{package}", handlers.PackageDetailHandler(pubSvc))
+			r.Get("/packages/{package}/versions/{version}", handlers.VersionDetailHandler(pubSvc))
+			r.Get("/packages/{package}/versions/{version}/screenshots/*", handlers.ScreenshotHandler(pubSvc))
+		})
+
+		// Crawler routes, public so search engines can index public packages
+		r.Get("/sitemap.xml", handlers.SitemapHandler(pubSvc, cfg.BaseURL))
+		r.Get("/robots.txt", handlers.RobotsHandler(cfg.BaseURL))
+
+		// Static files
+		r.Handle("/static/*", http.StripPrefix("/static/", handlers.StaticHandler("./web/static/")))
+	}
 
 	return r
 }
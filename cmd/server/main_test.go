@@ -0,0 +1,671 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"repub/internal/buildinfo"
+	"repub/internal/clock"
+	"repub/internal/config"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetupRouter_Pprof(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(
+		[]config.Token{{Name: "READER", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	tests := []struct {
+		name           string
+		enabled        string
+		authHeader     string
+		expectedStatus int
+	}{
+		{"disabled by default", "", "Bearer admin-token", http.StatusNotFound},
+		{"enabled without auth", "true", "", http.StatusUnauthorized},
+		{"enabled with read token", "true", "Bearer read-token", http.StatusUnauthorized},
+		{"enabled with admin token", "true", "Bearer admin-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENABLE_PPROF", tt.enabled)
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_Metrics(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(
+		nil,
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	tests := []struct {
+		name           string
+		token          string
+		authHeader     string
+		expectedStatus int
+	}{
+		{"not served without a token", "", "Bearer admin-token", http.StatusNotFound},
+		{"metrics token", "scrape-token", "Bearer scrape-token", http.StatusOK},
+		{"admin token", "scrape-token", "Bearer admin-token", http.StatusUnauthorized},
+		{"no token", "scrape-token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_TOKEN", tt.token)
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_Features(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(
+		[]config.Token{{Name: "READER", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	tests := []struct {
+		name           string
+		features       *string
+		method         string
+		path           string
+		authHeader     string
+		expectedStatus int
+	}{
+		{"batch enabled by default", nil, "POST", "/api/packages/batch", "Bearer admin-token", http.StatusOK},
+		{"export enabled by default", nil, "GET", "/api/export", "Bearer admin-token", http.StatusOK},
+		{"batch disabled", ptr("export"), "POST", "/api/packages/batch", "Bearer admin-token", http.StatusNotFound},
+		{"export enabled", ptr("export"), "GET", "/api/export", "Bearer admin-token", http.StatusOK},
+		{"export enabled still requires an admin", ptr("export"), "GET", "/api/export", "", http.StatusUnauthorized},
+		{"everything disabled", ptr(""), "GET", "/api/export", "Bearer admin-token", http.StatusNotFound},
+		{"disabled export without a token", ptr(""), "GET", "/api/export", "", http.StatusNotFound},
+		{"other routes unaffected", ptr(""), "GET", "/api/meta", "Bearer admin-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.features != nil {
+				t.Setenv("FEATURES", *tt.features)
+			}
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"packages": []}`))
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestSetupRouter_AdminTokens(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	envAuthSvc := service.NewAuthService(
+		[]config.Token{{Name: "READER", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	dbAuthSvc := service.NewDBAuthService(repos.DB.Repo, envAuthSvc, nil)
+
+	tests := []struct {
+		name           string
+		authSvc        service.AuthService
+		tokenSvc       service.TokenService
+		authHeader     string
+		expectedStatus int
+	}{
+		{"env backend has no token endpoints", envAuthSvc, nil, "Bearer admin-token", http.StatusNotFound},
+		{"db backend without auth", dbAuthSvc, dbAuthSvc, "", http.StatusUnauthorized},
+		{"db backend with read token", dbAuthSvc, dbAuthSvc, "Bearer read-token", http.StatusUnauthorized},
+		{"db backend with admin token", dbAuthSvc, dbAuthSvc, "Bearer admin-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupRouter(pubSvc, tt.authSvc, routerDeps{TokenService: tt.tokenSvc})
+
+			req := httptest.NewRequest("GET", "/api/admin/tokens", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_ReadOnly(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(nil, []config.Token{{Name: "WRITER", Value: "write-token"}}, nil, nil)
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: frozen\nversion: 1.0.0"})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	t.Setenv("WRITE_TOKEN_WRITER", "write-token")
+
+	tests := []struct {
+		name           string
+		readOnly       string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"publish allowed normally", "", "GET", "/api/packages/versions/new", http.StatusOK},
+		{"publish blocked", "true", "GET", "/api/packages/versions/new", http.StatusServiceUnavailable},
+		{"upload blocked", "true", "POST", "/api/packages/versions/new", http.StatusServiceUnavailable},
+		{"finalize blocked", "true", "GET", "/api/packages/versions/newUploadFinish?upload_id=x", http.StatusServiceUnavailable},
+		{"retract blocked", "true", "POST", "/api/packages/frozen/versions/1.0.0/retract", http.StatusServiceUnavailable},
+		{"like blocked", "true", "POST", "/api/packages/frozen/like", http.StatusServiceUnavailable},
+		{"metadata still served", "true", "GET", "/api/packages/frozen", http.StatusOK},
+		{"download still served", "true", "GET", "/packages/frozen/versions/1.0.0/download", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("READ_ONLY", tt.readOnly)
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer write-token")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), "MAINTENANCE") {
+				t.Errorf("Expected a MAINTENANCE pub error, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetupRouter_WebUI(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService([]config.Token{{Name: "READER", Value: "read-token"}}, nil, nil, nil)
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: headless\nversion: 1.0.0"})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	tests := []struct {
+		name           string
+		enabled        string
+		path           string
+		expectedStatus int
+	}{
+		{"homepage served by default", "", "/", http.StatusOK},
+		{"homepage disabled", "false", "/", http.StatusNotFound},
+		{"package page disabled", "false", "/packages/headless", http.StatusNotFound},
+		{"sitemap disabled", "false", "/sitemap.xml", http.StatusNotFound},
+		{"static files disabled", "false", "/static/app.css", http.StatusNotFound},
+		{"API still served", "false", "/api/packages/headless", http.StatusOK},
+		{"download still served", "false", "/packages/headless/versions/1.0.0/download", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENABLE_WEB_UI", tt.enabled)
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer read-token")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetupRouter_SignedDownloads(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+	t.Setenv("SIGNED_DOWNLOADS", "true")
+	t.Setenv("DOWNLOAD_SIGNING_KEY", "signing-key")
+
+	signer := downloadSigner(config.Load(), clock.Real())
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package:        repos.DB.Repo,
+		Storage:        repos.StorageSvc,
+		Pubspec:        repos.PubspecSvc,
+		BaseURL:        "http://localhost:9090",
+		DownloadSigner: signer,
+	})
+	authSvc := service.NewAuthService([]config.Token{{Name: "READER", Value: "read-token"}}, nil, nil, nil)
+	r := setupRouter(pubSvc, authSvc, routerDeps{DownloadSigner: signer})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: signed\nversion: 1.0.0"})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/packages/signed", nil)
+	req.Header.Set("Authorization", "Bearer read-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var pkg domain.PackageResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &pkg); err != nil {
+		t.Fatalf("Failed to decode package metadata: %v", err)
+	}
+	signed, err := url.Parse(pkg.Latest.ArchiveURL)
+	if err != nil || !signed.Query().Has("sig") || !signed.Query().Has("exp") {
+		t.Fatalf("Expected a signed archive URL, got %q", pkg.Latest.ArchiveURL)
+	}
+
+	// Signed by the same key an hour and a half ago, an hour TTL
+	expiredSigner := service.NewDownloadSigner("signing-key", time.Hour, clock.NewFake(time.Now().Add(-90*time.Minute)))
+	expired, _ := url.Parse(expiredSigner.SignURL("http://localhost:9090/packages/signed/versions/1.0.0/download", "signed", "1.0.0"))
+
+	tampered := signed.Query()
+	tampered.Set("exp", strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10))
+
+	tests := []struct {
+		name           string
+		target         string
+		token          string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"valid signature", signed.RequestURI(), "", http.StatusOK, ""},
+		{"expired signature", expired.RequestURI(), "", http.StatusForbidden, "SIGNATURE_EXPIRED"},
+		{"tampered signature", signed.Path + "?" + tampered.Encode(), "", http.StatusForbidden, "INVALID_SIGNATURE"},
+		{"signature for another version", "/packages/signed/versions/2.0.0/download?" + signed.RawQuery, "", http.StatusForbidden, "INVALID_SIGNATURE"},
+		{"unsigned without a token", signed.Path, "", http.StatusUnauthorized, ""},
+		{"unsigned with a token", signed.Path, "read-token", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedCode != "" && !strings.Contains(w.Body.String(), tt.expectedCode) {
+				t.Errorf("Expected error code %s, got %s", tt.expectedCode, w.Body.String())
+			}
+			if w.Code == http.StatusOK && w.Body.String() != string(archive) {
+				t.Error("Expected the archive")
+			}
+		})
+	}
+}
+
+func TestSetupRouter_Reports(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+	t.Setenv("ADMIN_TOKEN_ADMIN", "admin-token")
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(
+		[]config.Token{{Name: "READER", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+	r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: reported\nversion: 1.0.0"})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	serve := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("submit", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			target         string
+			token          string
+			body           string
+			expectedStatus int
+			expectedCode   string
+		}{
+			{"without a token", "/api/packages/reported/versions/1.0.0/report", "", `{"reason":"malware"}`, http.StatusUnauthorized, ""},
+			{"missing reason", "/api/packages/reported/versions/1.0.0/report", "read-token", `{"reason":"  "}`, http.StatusBadRequest, "INVALID_REPORT"},
+			{"invalid body", "/api/packages/reported/versions/1.0.0/report", "read-token", `reason`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{"unknown version", "/api/packages/reported/versions/2.0.0/report", "read-token", `{"reason":"malware"}`, http.StatusNotFound, "NOT_FOUND"},
+			{"unknown package", "/api/packages/missing/versions/1.0.0/report", "read-token", `{"reason":"malware"}`, http.StatusNotFound, "NOT_FOUND"},
+			{"reported", "/api/packages/reported/versions/1.0.0/report", "read-token", `{"reason":"Ships a crypto miner"}`, http.StatusCreated, ""},
+			{"reported again", "/api/packages/reported/versions/1.0.0/report", "read-token", `{"reason":"Still ships a crypto miner"}`, http.StatusConflict, "ALREADY_REPORTED"},
+			{"another reporter", "/api/packages/reported/versions/1.0.0/report", "admin-token", `{"reason":"Confirmed"}`, http.StatusCreated, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := serve("POST", tt.target, tt.token, tt.body)
+				if w.Code != tt.expectedStatus {
+					t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+				}
+				if tt.expectedCode != "" && !strings.Contains(w.Body.String(), tt.expectedCode) {
+					t.Errorf("Expected error code %s, got %s", tt.expectedCode, w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		if w := serve("GET", "/api/admin/reports", "read-token", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected read tokens to be refused, got %d", w.Code)
+		}
+
+		w := serve("GET", "/api/admin/reports", "admin-token", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var reports []domain.Report
+		if err := json.Unmarshal(w.Body.Bytes(), &reports); err != nil {
+			t.Fatalf("Failed to decode reports: %v", err)
+		}
+		if len(reports) != 2 || reports[0].Reason != "Confirmed" {
+			t.Fatalf("Expected 2 reports, newest first, got %+v", reports)
+		}
+		report := reports[1]
+		if report.Package != "reported" || report.Version != "1.0.0" || report.Reason != "Ships a crypto miner" {
+			t.Errorf("Unexpected report %+v", report)
+		}
+		if report.Reporter == "" || strings.Contains(report.Reporter, "read-token") {
+			t.Errorf("Expected the reporter to identify the token without exposing it, got %q", report.Reporter)
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		w := serve("GET", "/api/admin/reports?limit=1", "admin-token", "")
+		var first []domain.Report
+		if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil || len(first) != 1 || first[0].Reason != "Confirmed" {
+			t.Fatalf("Expected the newest report, got %s", w.Body.String())
+		}
+
+		w = serve("GET", fmt.Sprintf("/api/admin/reports?limit=1&before=%d", first[0].ID), "admin-token", "")
+		var second []domain.Report
+		if err := json.Unmarshal(w.Body.Bytes(), &second); err != nil || len(second) != 1 || second[0].Reason != "Ships a crypto miner" {
+			t.Fatalf("Expected the older report, got %s", w.Body.String())
+		}
+
+		for _, query := range []string{"limit=0", "limit=1001", "before=abc"} {
+			if w := serve("GET", "/api/admin/reports?"+query, "admin-token", ""); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+			}
+		}
+	})
+}
+
+func TestSetupRouter_Version(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService([]config.Token{{Name: "READER", Value: "read-token"}}, nil, nil, nil)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+	r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+	// No Authorization header, build metadata is public
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var info buildinfo.Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode version response: %v", err)
+	}
+	if info != buildinfo.Get() {
+		t.Errorf("Expected %+v, got %+v", buildinfo.Get(), info)
+	}
+}
+
+func TestSetupRouter_Meta(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService([]config.Token{{Name: "READER", Value: "read-token"}}, nil, nil, nil)
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+	t.Setenv("MAX_UPLOAD_BYTES", "1048576")
+	t.Setenv("READ_ONLY", "true")
+	r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+	// No Authorization header, clients probe it before authenticating
+	req := httptest.NewRequest("GET", "/api/meta", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var meta domain.ServerMeta
+	if err := json.NewDecoder(w.Body).Decode(&meta); err != nil {
+		t.Fatalf("Failed to decode meta response: %v", err)
+	}
+	expected := domain.ServerMeta{ProtocolVersion: "2", MaxUploadBytes: 1048576, ReadOnly: true}
+	if meta != expected {
+		t.Errorf("Expected %+v, got %+v", expected, meta)
+	}
+
+	t.Setenv("ANONYMOUS_READ", "true")
+	w = httptest.NewRecorder()
+	setupRouter(pubSvc, authSvc, routerDeps{}).ServeHTTP(w, httptest.NewRequest("GET", "/api/meta", nil))
+	if err := json.NewDecoder(w.Body).Decode(&meta); err != nil {
+		t.Fatalf("Failed to decode meta response: %v", err)
+	}
+	if !meta.AnonymousRead || meta.RequireSignedUploads {
+		t.Errorf("Expected anonymous reads and no signed uploads to be advertised, got %+v", meta)
+	}
+}
+
+func TestSetupRouter_AnonymousRead(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService([]config.Token{{Name: "READER", Value: "read-token"}}, nil, nil, nil)
+
+	ctx := context.Background()
+	for _, name := range []string{"open", "secret"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: " + name + "\nversion: 1.0.0"})
+		if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "alice"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+	}
+	if _, err := pubSvc.SetPackagePrivate(ctx, "secret", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("SetPackagePrivate failed: %v", err)
+	}
+
+	t.Setenv("READ_TOKEN_READER", "read-token")
+
+	tests := []struct {
+		name           string
+		anonymousRead  string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"metadata needs a token by default", "", "GET", "/api/packages/open", http.StatusUnauthorized},
+		{"downloads need a token by default", "", "GET", "/packages/open/versions/1.0.0/download", http.StatusUnauthorized},
+		{"public metadata", "true", "GET", "/api/packages/open", http.StatusOK},
+		{"public download", "true", "GET", "/packages/open/versions/1.0.0/download", http.StatusOK},
+		{"private metadata stays hidden", "true", "GET", "/api/packages/secret", http.StatusNotFound},
+		{"private download stays hidden", "true", "GET", "/packages/secret/versions/1.0.0/download", http.StatusNotFound},
+		{"listing still needs a token", "true", "GET", "/api/packages", http.StatusUnauthorized},
+		{"web UI still needs a token", "true", "GET", "/packages", http.StatusUnauthorized},
+		{"stats still need a token", "true", "GET", "/api/stats", http.StatusUnauthorized},
+		{"likes still need a token", "true", "POST", "/api/packages/open/like", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ANONYMOUS_READ", tt.anonymousRead)
+			r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
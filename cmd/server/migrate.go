@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"repub/internal/repository/storage"
+	"repub/internal/service"
+)
+
+// newStorageRepository creates the storage backend named by backend, gcs or
+// anything else for local storage under path. Keys are namespaced by prefix,
+// which for local storage is a directory below path. GCS requests go through
+// transport, nil for the default one. Every operation is traced.
+func newStorageRepository(backend, path, bucket, prefix string, keys storage.KeyTemplate, transport http.RoundTripper) (storage.Repository, error) {
+	if backend == "gcs" {
+		repo, err := storage.NewGCSRepository(bucket, prefix, keys, transport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS storage: %w", err)
+		}
+		return storage.NewTracedRepository(repo), nil
+	}
+	return storage.NewTracedRepository(storage.NewLocalRepository(filepath.Join(path, filepath.FromSlash(prefix)), keys)), nil
+}
+
+// runMigrateStorage copies every archive from the configured storage backend
+// to the one given by the flags, or only lists them with -dry-run, and returns
+// how many archives couldn't be migrated. The target uses the configured
+// storage prefix, key layout and outbound transport.
+func runMigrateStorage(ctx context.Context, pubSvc service.PubService, prefix string, keys storage.KeyTemplate, transport http.RoundTripper, args []string, out io.Writer) (int, error) {
+	fset := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	fset.SetOutput(out)
+	backend := fset.String("backend", "", "target storage backend, local or gcs")
+	storagePath := fset.String("storage-path", "", "directory of the target local storage")
+	bucket := fset.String("gcs-bucket", "", "bucket of the target GCS storage")
+	dryRun := fset.Bool("dry-run", false, "list the archives that would be migrated without copying them")
+	if err := fset.Parse(args); err != nil {
+		return 0, err
+	}
+	if fset.NArg() != 0 || (*backend != "local" && *backend != "gcs") || (*backend == "local" && *storagePath == "") {
+		return 0, fmt.Errorf("usage: repub migrate-storage -backend gcs|local [-gcs-bucket b] [-storage-path p] [-dry-run]")
+	}
+
+	target, err := newStorageRepository(*backend, *storagePath, *bucket, prefix, keys, transport)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated, err := pubSvc.MigrateStorage(ctx, target, *dryRun)
+
+	copied, failed, already := 0, 0, 0
+	var total int64
+	for _, m := range migrated {
+		switch {
+		case m.AlreadyMigrated:
+			already++
+		case m.Skipped != "":
+			failed++
+			fmt.Fprintf(out, "SKIP %s %s: %s\n", m.Package, m.Version, m.Skipped)
+		case *dryRun:
+			copied++
+			total += m.SizeBytes
+			fmt.Fprintf(out, "WOULD MIGRATE %s %s (%s)\n", m.Package, m.Version, m.From)
+		default:
+			copied++
+			total += m.SizeBytes
+			fmt.Fprintf(out, "MIGRATE %s %s: %s -> %s\n", m.Package, m.Version, m.From, m.To)
+		}
+	}
+	if err != nil {
+		return failed, err
+	}
+
+	if *dryRun {
+		fmt.Fprintf(out, "Would migrate %d archives, %d bytes; %d already migrated, %d skipped\n", copied, total, already, failed)
+	} else {
+		fmt.Fprintf(out, "Migrated %d archives, %d bytes; %d already migrated, %d skipped\n", copied, total, already, failed)
+	}
+	return failed, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"repub/internal/domain"
+	"repub/internal/repository/storage"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+// publishForMigration publishes two versions into the local test storage and
+// returns the service and the archives by version
+func publishForMigration(t *testing.T, repos *testutil.TestRepositories) (service.PubService, map[string][]byte) {
+	t.Helper()
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+
+	archives := make(map[string][]byte)
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: moving\nversion: " + version,
+		})
+		if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", version, err)
+		}
+		archives[version] = archive
+	}
+	return pubSvc, archives
+}
+
+// archivePaths returns the archive path of every version of the moving package
+func archivePaths(t *testing.T, repos *testutil.TestRepositories) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	pkg, err := repos.DB.Repo.GetPackage(ctx, "moving")
+	if err != nil || pkg == nil {
+		t.Fatalf("Failed to get package: %v", err)
+	}
+	versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		t.Fatalf("Failed to get versions: %v", err)
+	}
+	paths := make(map[string]string)
+	for _, v := range versions {
+		paths[v.Version] = v.ArchivePath
+	}
+	return paths
+}
+
+func TestRunMigrateStorage(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	pubSvc, archives := publishForMigration(t, repos)
+	before := archivePaths(t, repos)
+	targetDir := t.TempDir()
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	failed, err := runMigrateStorage(ctx, pubSvc, "", storage.DefaultKeyTemplate, nil, []string{"-backend", "local", "-storage-path", targetDir, "-dry-run"}, &out)
+	if err != nil || failed != 0 {
+		t.Fatalf("Dry run failed: %d, %v\n%s", failed, err, out.String())
+	}
+	if !strings.Contains(out.String(), "WOULD MIGRATE moving 1.0.0") || !strings.Contains(out.String(), "Would migrate 2 archives") {
+		t.Errorf("Unexpected dry run output:\n%s", out.String())
+	}
+	if after := archivePaths(t, repos); after["1.0.0"] != before["1.0.0"] {
+		t.Errorf("Dry run changed the archive path to %s", after["1.0.0"])
+	}
+
+	out.Reset()
+	failed, err = runMigrateStorage(ctx, pubSvc, "", storage.DefaultKeyTemplate, nil, []string{"-backend", "local", "-storage-path", targetDir}, &out)
+	if err != nil || failed != 0 {
+		t.Fatalf("Migration failed: %d, %v\n%s", failed, err, out.String())
+	}
+	if !strings.Contains(out.String(), "Migrated 2 archives") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	target := storage.NewLocalRepository(targetDir, storage.DefaultKeyTemplate)
+	for version, path := range archivePaths(t, repos) {
+		if !strings.HasPrefix(path, targetDir) {
+			t.Errorf("Expected %s to be under %s", path, targetDir)
+		}
+		data, err := target.Get(ctx, path)
+		if err != nil {
+			t.Fatalf("Failed to read migrated archive: %v", err)
+		}
+		if !bytes.Equal(data, archives[version]) {
+			t.Errorf("Migrated archive of %s differs from the published one", version)
+		}
+	}
+}
+
+func TestNewStorageRepository_Prefix(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := newStorageRepository("local", dir, "", "prod/", storage.DefaultKeyTemplate, nil)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	path, err := repo.Store(ctx, "spaced", "1.0.0", []byte("prefixed"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if expected := filepath.Join(dir, "prod", "spaced", "1.0.0", "spaced-1.0.0.tar.gz"); path != expected {
+		t.Errorf("Expected path %s, got %s", expected, path)
+	}
+	if data, err := repo.Get(ctx, path); err != nil || string(data) != "prefixed" {
+		t.Errorf("Get = %q, %v, want the stored archive", data, err)
+	}
+
+	// Archives stored before the prefix was configured still resolve
+	legacyPath, err := storage.NewLocalRepository(dir, storage.DefaultKeyTemplate).Store(ctx, "spaced", "0.9.0", []byte("legacy"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if data, err := repo.Get(ctx, legacyPath); err != nil || string(data) != "legacy" {
+		t.Errorf("Get = %q, %v, want the unprefixed archive", data, err)
+	}
+
+	// Listing stays inside the prefix
+	paths, err := repo.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != path {
+		t.Errorf("Expected only %s to be listed, got %v", path, paths)
+	}
+}
+
+func TestRunMigrateStorage_Usage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-backend", "s3"},
+		{"-backend", "local"},
+	} {
+		if _, err := runMigrateStorage(context.Background(), nil, "", storage.DefaultKeyTemplate, nil, args, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Errorf("Expected a usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+// TestRunMigrateStorage_GCS migrates to a GCS emulator such as
+// fsouza/fake-gcs-server, run with STORAGE_EMULATOR_HOST pointing at it
+func TestRunMigrateStorage_GCS(t *testing.T) {
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		t.Skip("STORAGE_EMULATOR_HOST not set")
+	}
+	ctx := context.Background()
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create GCS client: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	const bucket = "migrate-test"
+	_ = client.Bucket(bucket).Create(ctx, "test-project", nil)
+
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	pubSvc, archives := publishForMigration(t, repos)
+
+	args := []string{"-backend", "gcs", "-gcs-bucket", bucket}
+	var out bytes.Buffer
+	if failed, err := runMigrateStorage(ctx, pubSvc, "", storage.DefaultKeyTemplate, nil, args, &out); err != nil || failed != 0 {
+		t.Fatalf("Migration failed: %d, %v\n%s", failed, err, out.String())
+	}
+
+	target, err := storage.NewGCSRepository(bucket, "", storage.DefaultKeyTemplate, nil)
+	if err != nil {
+		t.Fatalf("Failed to create GCS storage: %v", err)
+	}
+	paths := archivePaths(t, repos)
+	if expected := "moving/1.0.0/moving-1.0.0.tar.gz"; paths["1.0.0"] != expected {
+		t.Errorf("Expected archive path %s, got %s", expected, paths["1.0.0"])
+	}
+	for version, path := range paths {
+		data, err := target.Get(ctx, path)
+		if err != nil {
+			t.Fatalf("Failed to read migrated archive: %v", err)
+		}
+		if !bytes.Equal(data, archives[version]) {
+			t.Errorf("Migrated archive of %s differs from the published one", version)
+		}
+	}
+
+	// Running again finds everything already migrated
+	out.Reset()
+	if failed, err := runMigrateStorage(ctx, pubSvc, "", storage.DefaultKeyTemplate, nil, args, &out); err != nil || failed != 0 {
+		t.Fatalf("Second migration failed: %d, %v\n%s", failed, err, out.String())
+	}
+	if !strings.Contains(out.String(), "Migrated 0 archives, 0 bytes; 2 already migrated") {
+		t.Errorf("Unexpected output of the second run:\n%s", out.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"repub/internal/clock"
+	"repub/internal/config"
+	"repub/internal/service"
+	"time"
+)
+
+// newHTTPServer builds the server with the configured timeouts so slow or
+// stalled clients can't hold connections open indefinitely
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// transferDeadline extends the connection deadlines of archive uploads and
+// downloads beyond the server-wide read and write timeouts
+func transferDeadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout > 0 {
+				deadline := time.Now().Add(timeout)
+				rc := http.NewResponseController(w)
+				if err := rc.SetReadDeadline(deadline); err != nil {
+					slog.Debug("Failed to extend read deadline", "error", err)
+				}
+				if err := rc.SetWriteDeadline(deadline); err != nil {
+					slog.Debug("Failed to extend write deadline", "error", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestContextKey holds the request context from before requestDeadline
+type requestContextKey struct{}
+
+// requestDeadline cancels the context of a handler running longer than
+// timeout, so a stuck database query can't hold a connection open
+// indefinitely; handlers answer 503 when their context runs out. Routes that
+// stream their response or move whole archives opt out with withoutDeadline.
+// Zero disables it.
+func requestDeadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), requestContextKey{}, r.Context()), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// withoutDeadline lifts the deadline set by requestDeadline. The handler
+// keeps the values added since and is still cancelled when the client goes
+// away.
+func withoutDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, ok := r.Context().Value(requestContextKey{}).(context.Context)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		defer cancel()
+		stop := context.AfterFunc(parent, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// limitBody rejects request bodies larger than limit bytes, zero means unlimited
+func limitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// downloadSigner returns the signer for archive URLs, nil unless
+// SIGNED_DOWNLOADS is set. main builds it once and shares it between the
+// service signing metadata URLs and the router verifying downloads.
+func downloadSigner(cfg *config.Config, clk clock.Clock) *service.DownloadSigner {
+	if !cfg.SignedDownloads {
+		return nil
+	}
+	return service.NewDownloadSigner(cfg.DownloadSigningKey, cfg.SignedDownloadTTL, clk)
+}
+
+// outboundTransport returns the transport shared by clients calling other
+// services, with OUTBOUND_CA_FILE added to the trusted roots and requests
+// sent through OUTBOUND_PROXY. It is nil when neither is set, so the clients
+// keep their defaults.
+func outboundTransport(cfg *config.Config) (http.RoundTripper, error) {
+	if cfg.OutboundCAFile == "" && cfg.OutboundProxy == "" {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.OutboundCAFile != "" {
+		pem, err := os.ReadFile(cfg.OutboundCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OUTBOUND_CA_FILE: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("OUTBOUND_CA_FILE %s contains no PEM certificates", cfg.OutboundCAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	if cfg.OutboundProxy != "" {
+		proxy, err := url.Parse(cfg.OutboundProxy)
+		if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid OUTBOUND_PROXY %q, expected a URL like http://proxy:3128", cfg.OutboundProxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}
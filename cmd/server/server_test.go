@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"repub/internal/config"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	cfg := &config.Config{
+		Port:              "9090",
+		ReadHeaderTimeout: config.DefaultReadHeaderTimeout,
+		ReadTimeout:       config.DefaultReadTimeout,
+		WriteTimeout:      config.DefaultWriteTimeout,
+		IdleTimeout:       config.DefaultIdleTimeout,
+	}
+
+	srv := newHTTPServer(cfg, http.NotFoundHandler())
+
+	if srv.Addr != ":9090" {
+		t.Errorf("Expected addr :9090, got %s", srv.Addr)
+	}
+	if srv.ReadHeaderTimeout != 10*time.Second || srv.ReadTimeout != 30*time.Second ||
+		srv.WriteTimeout != 60*time.Second || srv.IdleTimeout != 120*time.Second {
+		t.Errorf("Unexpected timeouts: header=%s read=%s write=%s idle=%s",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestTransferDeadline(t *testing.T) {
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("archive"))
+	})
+
+	tests := []struct {
+		name      string
+		handler   http.Handler
+		expectErr bool
+	}{
+		{"write timeout applies", slowHandler, true},
+		{"transfer routes get longer", transferDeadline(5 * time.Second)(slowHandler), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(tt.handler)
+			ts.Config = newHTTPServer(&config.Config{WriteTimeout: 50 * time.Millisecond}, tt.handler)
+			ts.Start()
+			defer ts.Close()
+
+			resp, err := http.Get(ts.URL)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if tt.expectErr && err == nil {
+				t.Error("Expected the response to be cut off by the write timeout")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected the response to complete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	cancelled := make(chan error, 1)
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for a database query stuck until its context is cancelled
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-time.After(200 * time.Millisecond):
+			cancelled <- nil
+			_, _ = w.Write([]byte("metadata"))
+		}
+	})
+
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		handler        http.Handler
+		expectedStatus int
+		expectedErr    error
+	}{
+		{"slow handler times out", 20 * time.Millisecond, slowHandler, http.StatusServiceUnavailable, context.DeadlineExceeded},
+		{"exempt routes run on", 20 * time.Millisecond, withoutDeadline(slowHandler), http.StatusOK, nil},
+		{"zero disables the timeout", 0, slowHandler, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			requestDeadline(tt.timeout)(tt.handler).ServeHTTP(w, httptest.NewRequest("GET", "/api/packages/slow", nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if err := <-cancelled; !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected the handler's context error to be %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	t.Run("exempt routes are cancelled with the client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		handler := withoutDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("Expected the deadline to be lifted")
+			}
+			cancel()
+			<-r.Context().Done()
+		}))
+		req := httptest.NewRequest("GET", "/api/packages/streamed", nil).WithContext(ctx)
+		requestDeadline(time.Hour)(handler).ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
+
+func TestSetupRouter_UploadSizeLimit(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(nil, []config.Token{{Name: "WRITER", Value: "write-token"}}, nil, nil)
+
+	t.Setenv("WRITE_TOKEN_WRITER", "write-token")
+	t.Setenv("MAX_UPLOAD_BYTES", "64")
+	r := setupRouter(pubSvc, authSvc, routerDeps{})
+
+	var multipartBody bytes.Buffer
+	writer := multipart.NewWriter(&multipartBody)
+	part, err := writer.CreateFormFile("file", "package.tar.gz")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	_, _ = part.Write(bytes.Repeat([]byte("x"), 128))
+	_ = writer.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"raw body", "application/octet-stream", bytes.Repeat([]byte("x"), 128)},
+		{"multipart", writer.FormDataContentType(), multipartBody.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/packages/versions/new", bytes.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer write-token")
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), "ARCHIVE_TOO_LARGE") {
+				t.Errorf("Expected ARCHIVE_TOO_LARGE error, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOutboundTransport(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	metadata := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/query" {
+			_, _ = w.Write([]byte(`{"vulns":[{"id":"GHSA-1234","summary":"Remote code execution","modified":"2024-01-01T00:00:00Z"}]}`))
+			return
+		}
+		if r.URL.Path != "/api/packages/remote" {
+			http.NotFound(w, r)
+			return
+		}
+		version := domain.VersionResponse{Version: "1.0.0", Pubspec: map[string]any{"name": "remote", "version": "1.0.0"}}
+		_ = json.NewEncoder(w).Encode(domain.PackageResponse{Name: "remote", Latest: version, Versions: []domain.VersionResponse{version}})
+	})
+	// The upstream's certificate is signed by a CA only known through OUTBOUND_CA_FILE
+	upstream := httptest.NewTLSServer(metadata)
+	defer upstream.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatalf("Failed to write the CA bundle: %v", err)
+	}
+	// A plain HTTP proxy answering for any host
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		metadata.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	fetch := func(t *testing.T, upstreamURL string, cfg *config.Config) error {
+		t.Helper()
+		transport, err := outboundTransport(cfg)
+		if err != nil {
+			t.Fatalf("outboundTransport failed: %v", err)
+		}
+		pubSvc := service.NewPubService(service.PackageDependencies{
+			Package:  repos.DB.Repo,
+			Storage:  repos.StorageSvc,
+			Pubspec:  repos.PubspecSvc,
+			BaseURL:  "http://localhost:9090",
+			Upstream: service.NewUpstreamProxy(service.UpstreamConfig{URL: upstreamURL, Transport: transport}),
+		})
+		pkg, err := pubSvc.GetPackage(context.Background(), "remote")
+		if err == nil && pkg == nil {
+			t.Fatal("Expected the upstream package")
+		}
+		return err
+	}
+
+	t.Run("unknown CA", func(t *testing.T) {
+		if err := fetch(t, upstream.URL, &config.Config{}); !errors.Is(err, service.ErrUpstreamUnavailable) {
+			t.Errorf("Expected the upstream certificate to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("CA file", func(t *testing.T) {
+		if err := fetch(t, upstream.URL, &config.Config{OutboundCAFile: caFile}); err != nil {
+			t.Errorf("Expected the configured CA to be trusted, got %v", err)
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		if err := fetch(t, "http://upstream.invalid", &config.Config{OutboundProxy: proxy.URL}); err != nil {
+			t.Fatalf("Expected the request to go through the proxy, got %v", err)
+		}
+		if len(proxied) != 1 || proxied[0] != "http://upstream.invalid/api/packages/remote" {
+			t.Errorf("Unexpected proxied requests %v", proxied)
+		}
+	})
+
+	t.Run("advisories", func(t *testing.T) {
+		proxied = nil
+		transport, err := outboundTransport(&config.Config{OutboundProxy: proxy.URL})
+		if err != nil {
+			t.Fatalf("outboundTransport failed: %v", err)
+		}
+		pubSvc := service.NewPubService(service.PackageDependencies{
+			Package:    repos.DB.Repo,
+			Storage:    repos.StorageSvc,
+			Pubspec:    repos.PubspecSvc,
+			Advisories: service.NewAdvisoryClient(service.AdvisoryConfig{URL: "http://osv.invalid", Transport: transport}),
+		})
+		advisories, err := pubSvc.GetAdvisories(context.Background(), "remote")
+		if err != nil {
+			t.Fatalf("Expected the OSV query to go through the proxy, got %v", err)
+		}
+		if len(advisories.Advisories) != 1 || advisories.Advisories[0].ID != "GHSA-1234" {
+			t.Errorf("Expected the OSV advisory, got %+v", advisories.Advisories)
+		}
+		if len(proxied) != 1 || proxied[0] != "http://osv.invalid/v1/query" {
+			t.Errorf("Unexpected proxied requests %v", proxied)
+		}
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		notPEM := filepath.Join(t.TempDir(), "ca.txt")
+		if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		for _, cfg := range []*config.Config{
+			{OutboundCAFile: notPEM},
+			{OutboundCAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			{OutboundProxy: "proxy:3128"},
+		} {
+			if _, err := outboundTransport(cfg); err == nil {
+				t.Errorf("Expected %+v to be rejected", cfg)
+			}
+		}
+		if transport, err := outboundTransport(&config.Config{}); transport != nil || err != nil {
+			t.Errorf("Expected the default transport without settings, got %v, %v", transport, err)
+		}
+	})
+}
@@ -2,6 +2,9 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 )
 
 // contextKey is used for context keys to avoid collisions
@@ -10,6 +13,12 @@ type contextKey string
 // AuthContextKey is the key used to store authentication status in request context
 const AuthContextKey contextKey = "authenticated"
 
+// SubjectContextKey is the key used to store the authenticated subject in request context
+const SubjectContextKey contextKey = "subject"
+
+// IdentityContextKey is the key used to store the authenticated caller's identity in request context
+const IdentityContextKey contextKey = "identity"
+
 // IsAuthenticated checks if the current request is authenticated
 func IsAuthenticated(ctx context.Context) bool {
 	auth, ok := ctx.Value(AuthContextKey).(bool)
@@ -22,4 +31,36 @@ func IsAuthenticated(ctx context.Context) bool {
 // SetAuthenticated marks the request as authenticated in the context
 func SetAuthenticated(ctx context.Context, authenticated bool) context.Context {
 	return context.WithValue(ctx, AuthContextKey, authenticated)
-}
\ No newline at end of file
+}
+
+// SetSubject stores an identifier for the authenticated caller in the context
+func SetSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, SubjectContextKey, subject)
+}
+
+// Subject returns the authenticated caller identifier, or "" if there is none
+func Subject(ctx context.Context) string {
+	subject, _ := ctx.Value(SubjectContextKey).(string)
+	return subject
+}
+
+// SetIdentity stores the identity credentials name the caller by, such as
+// the email of an OIDC token, in the context
+func SetIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, IdentityContextKey, identity)
+}
+
+// Identity returns the identity of the authenticated caller, or "" if the
+// credentials don't carry one
+func Identity(ctx context.Context) string {
+	identity, _ := ctx.Value(IdentityContextKey).(string)
+	return identity
+}
+
+// TokenSubject derives a stable identifier from a bearer Authorization header
+// without exposing the token itself
+func TokenSubject(authHeader string) string {
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:8])
+}
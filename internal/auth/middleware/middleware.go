@@ -2,21 +2,31 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"repub/internal/auth"
 	"repub/internal/service"
 )
 
+// DefaultRealm is the WWW-Authenticate realm used when none is configured
+const DefaultRealm = "pub"
+
 // RequireAuthMiddleware creates middleware that requires authentication
 // writeRequired: if true, requires write tokens; if false, accepts read or write tokens
-func RequireAuthMiddleware(authSvc service.AuthService, writeRequired bool) func(http.Handler) http.Handler {
+func RequireAuthMiddleware(authSvc service.AuthService, realm string, writeRequired bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 
+			// Services whose credentials name the caller report it while
+			// authenticating, so the token is only checked once
+			var identity string
 			var err error
-			if writeRequired {
+			if provider, ok := authSvc.(service.IdentityProvider); ok {
+				identity, err = provider.AuthenticateIdentity(r.Context(), authHeader, writeRequired)
+			} else if writeRequired {
 				err = authSvc.AuthenticateWriteRequest(r.Context(), authHeader)
 			} else {
 				err = authSvc.AuthenticateReadRequest(r.Context(), authHeader)
@@ -24,24 +34,73 @@ func RequireAuthMiddleware(authSvc service.AuthService, writeRequired bool) func
 
 			if err != nil {
 				authType := "read"
+				message := "A read or write token is required to access this repository."
 				if writeRequired {
 					authType = "write"
+					message = "A write token is required to publish to this repository."
 				}
 				slog.Debug("Authentication failed", "type", authType, "error", err, "path", r.URL.Path)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				writeUnauthorized(w, realm, message)
 				return
 			}
 
 			// Add authentication status to context
 			ctx := auth.SetAuthenticated(r.Context(), true)
+			ctx = auth.SetSubject(ctx, auth.TokenSubject(authHeader))
+			if identity != "" {
+				ctx = auth.SetIdentity(ctx, identity)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAdminMiddleware creates middleware that only accepts admin tokens
+func RequireAdminMiddleware(authSvc service.AuthService, realm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+
+			if err := authSvc.AuthenticateAdminRequest(r.Context(), authHeader); err != nil {
+				slog.Debug("Authentication failed", "type", "admin", "error", err, "path", r.URL.Path)
+				writeUnauthorized(w, realm, "An admin token is required.")
+				return
+			}
+
+			ctx := auth.SetAuthenticated(r.Context(), true)
+			ctx = auth.SetSubject(ctx, auth.TokenSubject(authHeader))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireTokenMiddleware creates middleware that only accepts the given bearer
+// token, for machine clients like metrics scrapers that shouldn't hold
+// repository tokens
+func RequireTokenMiddleware(token, realm string) func(http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				slog.Debug("Authentication failed", "type", "token", "path", r.URL.Path)
+				writeUnauthorized(w, realm, "A metrics token is required.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeUnauthorized sends a 401 with the WWW-Authenticate challenge the Dart client
+// uses to prompt for credentials
+func writeUnauthorized(w http.ResponseWriter, realm, message string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, message=%q", realm, message))
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
 // RequireAuth wraps a handler to require read authentication (for compatibility)
 func RequireAuth(authSvc service.AuthService, handler http.HandlerFunc) http.HandlerFunc {
-	middleware := RequireAuthMiddleware(authSvc, false) // false = read access sufficient
+	middleware := RequireAuthMiddleware(authSvc, DefaultRealm, false) // false = read access sufficient
 	return middleware(handler).ServeHTTP
 }
 
@@ -62,6 +121,7 @@ func OptionalAuth(authSvc service.AuthService) func(http.Handler) http.Handler {
 				if err == nil {
 					// Add authentication status to context if authentication succeeds
 					ctx := auth.SetAuthenticated(r.Context(), true)
+					ctx = auth.SetSubject(ctx, auth.TokenSubject(authHeader))
 					r = r.WithContext(ctx)
 				}
 				// If authentication fails, continue without authentication (don't error)
@@ -18,7 +18,7 @@ func TestRequireReadAuthMiddleware(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if middleware.IsAuthenticated(r.Context()) {
@@ -30,7 +30,7 @@ func TestRequireReadAuthMiddleware(t *testing.T) {
 		}
 	})
 
-	middleware := middleware.RequireAuthMiddleware(authSvc, false)
+	middleware := middleware.RequireAuthMiddleware(authSvc, middleware.DefaultRealm, false)
 	handler := middleware(testHandler)
 
 	tests := []struct {
@@ -100,7 +100,7 @@ func TestRequireWriteAuthMiddleware(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if middleware.IsAuthenticated(r.Context()) {
@@ -112,7 +112,7 @@ func TestRequireWriteAuthMiddleware(t *testing.T) {
 		}
 	})
 
-	middleware := middleware.RequireAuthMiddleware(authSvc, true)
+	middleware := middleware.RequireAuthMiddleware(authSvc, middleware.DefaultRealm, true)
 	handler := middleware(testHandler)
 
 	tests := []struct {
@@ -169,6 +169,116 @@ func TestRequireWriteAuthMiddleware(t *testing.T) {
 	}
 }
 
+// identityAuth is an auth service whose tokens name the caller
+type identityAuth struct {
+	service.AuthService
+}
+
+func (a identityAuth) AuthenticateIdentity(ctx context.Context, authHeader string, write bool) (string, error) {
+	authenticate := a.AuthenticateReadRequest
+	if write {
+		authenticate = a.AuthenticateWriteRequest
+	}
+	if err := authenticate(ctx, authHeader); err != nil {
+		return "", err
+	}
+	if authHeader == "Bearer write-token" {
+		return "dev@example.com", nil
+	}
+	return "", nil
+}
+
+func TestRequireAuthMiddleware_Identity(t *testing.T) {
+	authSvc := identityAuth{service.NewAuthService(nil, []config.Token{
+		{Name: "WRITER", Value: "write-token"},
+		{Name: "ANONYMOUS", Value: "anonymous-token"},
+	}, nil, nil)}
+
+	var identity string
+	handler := middleware.RequireAuthMiddleware(authSvc, middleware.DefaultRealm, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = auth.Identity(r.Context())
+	}))
+
+	for token, expected := range map[string]string{"write-token": "dev@example.com", "anonymous-token": ""} {
+		identity = "unset"
+		req := httptest.NewRequest("POST", "/api/packages/versions/newUpload", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", token, w.Code)
+		}
+		if identity != expected {
+			t.Errorf("Expected identity %q for %s, got %q", expected, token, identity)
+		}
+	}
+}
+
+func TestRequireAuthMiddleware_WWWAuthenticate(t *testing.T) {
+	readTokens := []config.Token{
+		{Name: "READER", Value: "read-token"},
+	}
+	authSvc := service.NewAuthService(readTokens, nil, nil, nil)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		authHeader     string
+		expectedHeader string
+	}{
+		{
+			name:           "missing read token",
+			handler:        middleware.RequireAuthMiddleware(authSvc, "my-registry", false)(testHandler),
+			expectedHeader: `Bearer realm="my-registry", message="A read or write token is required to access this repository."`,
+		},
+		{
+			name:           "read token used for write",
+			handler:        middleware.RequireAuthMiddleware(authSvc, "my-registry", true)(testHandler),
+			authHeader:     "Bearer read-token",
+			expectedHeader: `Bearer realm="my-registry", message="A write token is required to publish to this repository."`,
+		},
+		{
+			name:           "missing admin token",
+			handler:        middleware.RequireAdminMiddleware(authSvc, middleware.DefaultRealm)(testHandler),
+			authHeader:     "Bearer read-token",
+			expectedHeader: `Bearer realm="pub", message="An admin token is required."`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			tt.handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", w.Code)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.expectedHeader {
+				t.Errorf("Expected WWW-Authenticate %q, got %q", tt.expectedHeader, got)
+			}
+		})
+	}
+
+	// Successful requests don't carry a challenge
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer read-token")
+	w := httptest.NewRecorder()
+	middleware.RequireAuthMiddleware(authSvc, "my-registry", false)(testHandler).ServeHTTP(w, req)
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("Expected no WWW-Authenticate header on success, got %q", got)
+	}
+}
+
 func TestRequireAuth(t *testing.T) {
 	readTokens := []config.Token{
 		{Name: "READER", Value: "read-token"},
@@ -176,7 +286,7 @@ func TestRequireAuth(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	handler := middleware.RequireAuth(authSvc, func(w http.ResponseWriter, r *http.Request) {
 		if !middleware.IsAuthenticated(r.Context()) {
@@ -209,6 +319,38 @@ func TestRequireAuth(t *testing.T) {
 	}
 }
 
+func TestRequireTokenMiddleware(t *testing.T) {
+	handler := middleware.RequireTokenMiddleware("metrics-token", middleware.DefaultRealm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		authHeader     string
+		expectedStatus int
+	}{
+		{"configured token", "Bearer metrics-token", http.StatusOK},
+		{"other token", "Bearer read-token", http.StatusUnauthorized},
+		{"token prefix", "Bearer metrics", http.StatusUnauthorized},
+		{"missing bearer", "metrics-token", http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
 func TestOptionalAuth(t *testing.T) {
 	readTokens := []config.Token{
 		{Name: "READER", Value: "read-token"},
@@ -216,7 +358,7 @@ func TestOptionalAuth(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if middleware.IsAuthenticated(r.Context()) {
@@ -0,0 +1,56 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit are set at build time, e.g.
+//
+//	go build -ldflags "-X repub/internal/buildinfo.Version=1.2.0 -X repub/internal/buildinfo.Commit=$(git rev-parse HEAD)"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info, falling back to the VCS revision the Go
+// toolchain embeds when Commit wasn't set with -ldflags
+func Get() Info {
+	commit := Commit
+	if commit == "" {
+		commit = vcsRevision()
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	return Info{
+		Version:   Version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String formats the info for `repub --version`
+func (i Info) String() string {
+	return "repub " + i.Version + " (commit " + i.Commit + ", " + i.GoVersion + ")"
+}
+
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package buildinfo
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	originalVersion, originalCommit := Version, Commit
+	defer func() { Version, Commit = originalVersion, originalCommit }()
+
+	Version, Commit = "1.2.0", "abc123"
+	info := Get()
+
+	if info.Version != "1.2.0" || info.Commit != "abc123" {
+		t.Errorf("Expected ldflags values, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if s := info.String(); !strings.Contains(s, "1.2.0") || !strings.Contains(s, "abc123") {
+		t.Errorf("Unexpected version string %q", s)
+	}
+
+	// Without ldflags the commit falls back to the embedded VCS revision, or unknown
+	Commit = ""
+	if info := Get(); info.Commit == "" {
+		t.Error("Expected a fallback commit")
+	}
+}
@@ -0,0 +1,36 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, got)
+	}
+
+	c.Advance(90 * time.Minute)
+	if got, expected := c.Now(), start.Add(90*time.Minute); !got.Equal(expected) {
+		t.Errorf("Expected %v after Advance, got %v", expected, got)
+	}
+
+	later := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c.Set(later)
+	if got := c.Now(); !got.Equal(later) {
+		t.Errorf("Expected %v after Set, got %v", later, got)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real().Now()
+	after := time.Now()
+
+	if got.Before(before) || got.After(after) {
+		t.Errorf("Expected real clock time between %v and %v, got %v", before, after, got)
+	}
+}
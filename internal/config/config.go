@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,48 +16,409 @@ import (
 const (
 	readTokenPrefix  = "READ_TOKEN_"
 	writeTokenPrefix = "WRITE_TOKEN_"
+	adminTokenPrefix = "ADMIN_TOKEN_"
 )
 
+// Token backends selectable with AUTH_BACKEND
+const (
+	AuthBackendEnv  = "env"
+	AuthBackendDB   = "db"
+	AuthBackendOIDC = "oidc"
+)
+
+// Experimental features that can be toggled with FEATURES
+const (
+	FeatureBatch  = "batch"  // POST /api/packages/batch
+	FeatureProxy  = "proxy"  // serving packages from UPSTREAM_URL
+	FeatureExport = "export" // GET /api/export
+)
+
+// DefaultFeatures are enabled when FEATURES is unset; the upstream proxy has
+// to be enabled explicitly
+var DefaultFeatures = []string{FeatureBatch, FeatureExport}
+
+// Default HTTP server limits, overridable with the HTTP_* variables
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultTransferTimeout   = 10 * time.Minute
+	DefaultRequestTimeout    = 30 * time.Second
+	DefaultPendingUploadTTL  = time.Hour
+	DefaultSignedDownloadTTL = time.Hour
+	DefaultMaxUploadBytes    = 100 << 20
+)
+
+// DefaultMinTokenLength is the length below which env tokens are reported as weak
+const DefaultMinTokenLength = 16
+
+// weakTokens are values common enough to be guessed, compared case-insensitively
+var weakTokens = []string{"admin", "changeme", "default", "letmein", "password", "secret", "test", "token", "123456", "12345678"}
+
+// DefaultDownloadFlushInterval is how often batched download counts are written
+const DefaultDownloadFlushInterval = 10 * time.Second
+
 type Config struct {
 	DatabaseURL    string
 	StoragePath    string
 	StorageBackend string
 	GCSBucket      string
+	OTLPEndpoint   string
 	Port           string
 	BaseURL        string
 	LogLevel       slog.Level
+	AuthRealm      string
+	AuthBackend    string
 	ReadTokens     []Token
 	WriteTokens    []Token
+	AdminTokens    []Token
+
+	// RequireIncreasingVersions rejects publishing versions lower than the current highest
+	RequireIncreasingVersions bool
+
+	// Per-package quotas, zero means unlimited
+	MaxVersionsPerPackage   int
+	MaxTotalBytesPerPackage int64
+
+	// Limits on the files in published archives, zero means unlimited
+	ArchiveMaxFiles        int
+	ArchiveMaxFileBytes    int64
+	ArchiveRequireDartCode bool
+
+	// MaxReadmeBytes caps the stored README and CHANGELOG of each version,
+	// longer ones are truncated or, with RejectLargeReadmes, rejected
+	MaxReadmeBytes     int64
+	RejectLargeReadmes bool
+
+	// AllowedPublishSDKs limits publishes to "dart" and/or "flutter" packages, empty allows both
+	AllowedPublishSDKs []string
+
+	// ReservedPackageNames can't be published by anyone
+	ReservedPackageNames []string
+
+	// BlockedPackageNames are glob patterns of names no new package may take
+	BlockedPackageNames []string
+
+	// EnablePprof mounts the admin-only /debug/pprof handlers
+	EnablePprof bool
+
+	// Orphaned storage cleanup, a zero interval disables it
+	StorageCleanupInterval    time.Duration
+	StorageCleanupGracePeriod time.Duration
+
+	// StorageKeyTemplate lays out archive keys, empty means the default layout
+	StorageKeyTemplate string
+	// StoragePrefix namespaces all storage keys, e.g. "prod/", so environments
+	// can share a bucket
+	StoragePrefix string
+
+	// HTTP server timeouts; uploads and downloads get TransferTimeout instead
+	// of the read/write timeouts so large archives aren't cut off
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	TransferTimeout   time.Duration
+	// RequestTimeout bounds how long API handlers other than streamed
+	// metadata, uploads and exports may run before their context is
+	// cancelled, zero means unbounded
+	RequestTimeout time.Duration
+
+	// MaxUploadBytes caps the size of an uploaded archive, zero means unlimited
+	MaxUploadBytes int64
+
+	// Publish webhook, disabled when the URL is empty; payloads are signed
+	// with the secret when one is set
+	PublishWebhookURL    string
+	PublishWebhookSecret string
+	// PublishWebhookReports also sends abuse reports to the publish webhook
+	PublishWebhookReports bool
+
+	// UploaderFromAuthor attributes publishes to the pubspec author's email
+	UploaderFromAuthor bool
+
+	// DownloadFlushInterval is how often batched download counts are written,
+	// zero writes every download immediately
+	DownloadFlushInterval time.Duration
+
+	// TarGzArchiveURLs makes package metadata link archives as .../archive.tar.gz
+	TarGzArchiveURLs bool
+
+	// ReadOnly rejects publishes and other changes with 503 while reads keep working
+	ReadOnly bool
+
+	// AnonymousRead lets pub clients fetch the metadata and archives of public
+	// packages without a token; private packages, listings and the web UI
+	// still need one
+	AnonymousRead bool
+
+	// RequireSignedUploads is advertised in /api/meta. Pub archives carry no
+	// signature, so it can't be enabled yet.
+	RequireSignedUploads bool
+
+	// TLS certificate and key, the server speaks HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Outbound connections to GCS, upstream, OSV, webhooks and the OIDC
+	// provider also trust the PEM certificates in OutboundCAFile and go through
+	// OutboundProxy; by default the system roots and HTTPS_PROXY are used
+	OutboundCAFile string
+	OutboundProxy  string
+
+	// OIDC token validation for AUTH_BACKEND=oidc; OIDCIdentityClaim names
+	// the claim recorded as the uploader
+	OIDCJWKSURL       string
+	OIDCIssuer        string
+	OIDCAudience      string
+	OIDCIdentityClaim string
+
+	// Landing page branding; CustomIndexHTML replaces the page with an HTML file
+	InstanceName        string
+	InstanceDescription string
+	CustomIndexHTML     string
+
+	// MaxConcurrentPublishes caps publishes in progress at once, further
+	// ones get 429; zero means unlimited
+	MaxConcurrentPublishes int
+
+	// UpstreamURL is a pub server proxied for packages not hosted locally,
+	// disabled when empty; UpstreamCacheArchives records downloaded upstream
+	// archives as proxied versions so they are served locally afterwards.
+	// UpstreamMetadataTTL is how long upstream package metadata, including
+	// packages upstream doesn't have, is reused.
+	UpstreamURL           string
+	UpstreamCacheArchives bool
+	UpstreamMetadataTTL   time.Duration
+
+	// AdvisoriesURL is the OSV API security advisories are looked up in,
+	// e.g. https://api.osv.dev; package names are sent to it. Advisories are
+	// reused for AdvisoriesTTL. Disabled when empty.
+	AdvisoriesURL string
+	AdvisoriesTTL time.Duration
+
+	// ReadmeRenderWorkers renders README HTML in the background after
+	// publishing; zero renders it during the publish request
+	ReadmeRenderWorkers int
+
+	// Features lists the enabled experimental features, see FeatureEnabled
+	Features []string
+
+	// Env tokens shorter than MinTokenLength or on the weak token list are
+	// logged at startup; StrictTokens refuses to start instead
+	MinTokenLength int
+	StrictTokens   bool
+
+	// MetricsToken is the bearer token scrapers send to /metrics, which isn't
+	// served without one
+	MetricsToken string
+
+	// PendingUploadTTL expires uploads that aren't finalized in time, zero
+	// keeps them until finalized
+	PendingUploadTTL time.Duration
+
+	// EnforcePublishTo rejects pubspecs whose publish_to isn't BaseURL
+	EnforcePublishTo bool
+
+	// UploadersPublic lets any reader list a package's uploaders, otherwise
+	// only its uploaders and admins can
+	UploadersPublic bool
+
+	// EnableWebUI serves the HTML pages, static assets and crawler routes;
+	// without it only the API and archive downloads are served
+	EnableWebUI bool
+
+	// SignedDownloads advertises archive URLs signed with DownloadSigningKey,
+	// which download without a token until SignedDownloadTTL has passed
+	SignedDownloads    bool
+	DownloadSigningKey string
+	SignedDownloadTTL  time.Duration
+}
+
+// FeatureEnabled reports whether an experimental feature is listed in FEATURES
+func (c *Config) FeatureEnabled(feature string) bool {
+	return slices.ContainsFunc(c.Features, func(f string) bool { return strings.EqualFold(f, feature) })
+}
+
+// TLSEnabled reports whether both a TLS certificate and key are configured
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
 }
 
 type Token struct {
 	Name  string
 	Value string
+	// ExpiresAt is nil for tokens that never expire
+	ExpiresAt *time.Time
 }
 
+// tokenExpirySeparator separates a token value from its optional expiry,
+// e.g. WRITE_TOKEN_CI=value|2025-12-31
+const tokenExpirySeparator = "|"
+
 func Load() *Config {
 	// Load .env file if it exists (ignore error if file doesn't exist)
 	_ = godotenv.Load()
 
 	readTokens := parseTokensFromEnv(readTokenPrefix)
 	writeTokens := parseTokensFromEnv(writeTokenPrefix)
+	adminTokens := parseTokensFromEnv(adminTokenPrefix)
+	authBackend := getEnv("AUTH_BACKEND", AuthBackendEnv)
 
-	if len(readTokens) == 0 && len(writeTokens) == 0 {
+	// With the database backend tokens are created at runtime and with OIDC
+	// they are issued by the identity provider, env tokens are optional
+	if authBackend != AuthBackendDB && authBackend != AuthBackendOIDC && len(readTokens) == 0 && len(writeTokens) == 0 {
 		fmt.Fprintln(os.Stderr, "ERROR: At least one READ_TOKEN_* or WRITE_TOKEN_* environment variable is required")
 		os.Exit(1)
 	}
 
-	return &Config{
+	cfg := &Config{
 		DatabaseURL:    getEnv("DATABASE_URL", "postgres://localhost/repub?sslmode=disable"),
 		StoragePath:    getEnv("STORAGE_PATH", "/tmp/storage"),
 		StorageBackend: getEnv("STORAGE_BACKEND", "local"),
 		GCSBucket:      getEnv("GCS_BUCKET", ""),
+		OTLPEndpoint:   getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
 		Port:           getEnv("PORT", "9090"),
 		BaseURL:        getEnv("BASE_URL", "http://localhost:9090"),
 		LogLevel:       parseLogLevel(getEnv("LOG_LEVEL", "info")),
+		AuthRealm:      getEnv("AUTH_REALM", "pub"),
+		AuthBackend:    authBackend,
 		ReadTokens:     readTokens,
 		WriteTokens:    writeTokens,
+		AdminTokens:    adminTokens,
+
+		RequireIncreasingVersions: getEnvBool("REQUIRE_INCREASING_VERSIONS", false),
+		MaxVersionsPerPackage:     int(getEnvInt("MAX_VERSIONS_PER_PACKAGE", 0)),
+		MaxTotalBytesPerPackage:   getEnvInt("MAX_TOTAL_BYTES_PER_PACKAGE", 0),
+		ReservedPackageNames:      getEnvList("RESERVED_PACKAGE_NAMES"),
+		BlockedPackageNames:       getEnvList("BLOCKED_PACKAGE_NAMES"),
+		AllowedPublishSDKs:        getEnvList("ALLOWED_PUBLISH_SDKS"),
+		ArchiveMaxFiles:           int(getEnvInt("ARCHIVE_MAX_FILES", 0)),
+		ArchiveMaxFileBytes:       getEnvInt("ARCHIVE_MAX_FILE_BYTES", 0),
+		ArchiveRequireDartCode:    getEnvBool("ARCHIVE_REQUIRE_DART_CODE", false),
+		MaxReadmeBytes:            getEnvInt("MAX_README_BYTES", 0),
+		RejectLargeReadmes:        getEnvBool("REJECT_LARGE_READMES", false),
+		EnablePprof:               getEnvBool("ENABLE_PPROF", false),
+		StorageCleanupInterval:    getEnvDuration("STORAGE_CLEANUP_INTERVAL", 0),
+		StorageCleanupGracePeriod: getEnvDuration("STORAGE_CLEANUP_GRACE_PERIOD", 24*time.Hour),
+		StorageKeyTemplate:        getEnv("STORAGE_KEY_TEMPLATE", ""),
+		StoragePrefix:             getEnv("STORAGE_PREFIX", ""),
+		ReadHeaderTimeout:         getEnvDuration("HTTP_READ_HEADER_TIMEOUT", DefaultReadHeaderTimeout),
+		ReadTimeout:               getEnvDuration("HTTP_READ_TIMEOUT", DefaultReadTimeout),
+		WriteTimeout:              getEnvDuration("HTTP_WRITE_TIMEOUT", DefaultWriteTimeout),
+		IdleTimeout:               getEnvDuration("HTTP_IDLE_TIMEOUT", DefaultIdleTimeout),
+		TransferTimeout:           getEnvDuration("HTTP_TRANSFER_TIMEOUT", DefaultTransferTimeout),
+		RequestTimeout:            getEnvDuration("HTTP_REQUEST_TIMEOUT", DefaultRequestTimeout),
+		MaxUploadBytes:            getEnvInt("MAX_UPLOAD_BYTES", DefaultMaxUploadBytes),
+		PublishWebhookURL:         getEnv("PUBLISH_WEBHOOK_URL", ""),
+		PublishWebhookSecret:      getEnv("PUBLISH_WEBHOOK_SECRET", ""),
+		PublishWebhookReports:     getEnvBool("PUBLISH_WEBHOOK_REPORTS", false),
+		ReadOnly:                  getEnvBool("READ_ONLY", false),
+		AnonymousRead:             getEnvBool("ANONYMOUS_READ", false),
+		TarGzArchiveURLs:          getEnvBool("ARCHIVE_URL_TAR_GZ", false),
+		UploaderFromAuthor:        getEnvBool("UPLOADER_FROM_PUBSPEC_AUTHOR", false),
+		DownloadFlushInterval:     getEnvDuration("DOWNLOAD_COUNT_FLUSH_INTERVAL", DefaultDownloadFlushInterval),
+		TLSCertFile:               getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:                getEnv("TLS_KEY_FILE", ""),
+		OutboundCAFile:            getEnv("OUTBOUND_CA_FILE", ""),
+		OutboundProxy:             getEnv("OUTBOUND_PROXY", ""),
+		OIDCJWKSURL:               getEnv("OIDC_JWKS_URL", ""),
+		OIDCIssuer:                getEnv("OIDC_ISSUER", ""),
+		OIDCAudience:              getEnv("OIDC_AUDIENCE", ""),
+		OIDCIdentityClaim:         getEnv("OIDC_IDENTITY_CLAIM", "email"),
+		InstanceName:              getEnv("INSTANCE_NAME", ""),
+		InstanceDescription:       getEnv("INSTANCE_DESCRIPTION", ""),
+		CustomIndexHTML:           getEnv("CUSTOM_INDEX_HTML", ""),
+		MaxConcurrentPublishes:    int(getEnvInt("MAX_CONCURRENT_PUBLISHES", 0)),
+		UpstreamURL:               getEnv("UPSTREAM_URL", ""),
+		UpstreamCacheArchives:     getEnvBool("UPSTREAM_CACHE_ARCHIVES", false),
+		UpstreamMetadataTTL:       getEnvDuration("UPSTREAM_METADATA_TTL", time.Minute),
+		AdvisoriesURL:             getEnv("ADVISORIES_OSV_URL", ""),
+		AdvisoriesTTL:             getEnvDuration("ADVISORIES_TTL", 10*time.Minute),
+		ReadmeRenderWorkers:       int(getEnvInt("README_RENDER_WORKERS", 2)),
+		Features:                  getEnvListOr("FEATURES", DefaultFeatures),
+		MinTokenLength:            int(getEnvInt("MIN_TOKEN_LENGTH", DefaultMinTokenLength)),
+		StrictTokens:              getEnvBool("STRICT_TOKENS", false),
+		PendingUploadTTL:          getEnvDuration("PENDING_UPLOAD_TTL", DefaultPendingUploadTTL),
+		MetricsToken:              getEnv("METRICS_TOKEN", ""),
+		EnforcePublishTo:          getEnvBool("ENFORCE_PUBLISH_TO", false),
+		UploadersPublic:           getEnvBool("UPLOADERS_PUBLIC", false),
+		EnableWebUI:               getEnvBool("ENABLE_WEB_UI", true),
+		SignedDownloads:           getEnvBool("SIGNED_DOWNLOADS", false),
+		DownloadSigningKey:        getEnv("DOWNLOAD_SIGNING_KEY", ""),
+		SignedDownloadTTL:         getEnvDuration("SIGNED_DOWNLOAD_TTL", DefaultSignedDownloadTTL),
+	}
+
+	if err := cfg.checkTokens(); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	for _, pattern := range cfg.BlockedPackageNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: BLOCKED_PACKAGE_NAMES pattern %q is malformed\n", pattern)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.SignedDownloads && cfg.DownloadSigningKey == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: SIGNED_DOWNLOADS requires DOWNLOAD_SIGNING_KEY")
+		os.Exit(1)
+	}
+
+	// Generated URLs append paths to BaseURL, so it must not end in a slash
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	// Generated URLs must match the scheme the server is reached on
+	if cfg.TLSEnabled() {
+		cfg.BaseURL = httpsURL(cfg.BaseURL)
+	}
+
+	return cfg
+}
+
+// checkTokens reports env tokens that are too short or easily guessed. They
+// are only logged unless StrictTokens is set, in which case an error is
+// returned.
+func (c *Config) checkTokens() error {
+	var problems []string
+	for _, group := range []struct {
+		prefix string
+		tokens []Token
+	}{
+		{readTokenPrefix, c.ReadTokens},
+		{writeTokenPrefix, c.WriteTokens},
+		{adminTokenPrefix, c.AdminTokens},
+	} {
+		for _, token := range group.tokens {
+			switch {
+			case slices.Contains(weakTokens, strings.ToLower(token.Value)):
+				problems = append(problems, fmt.Sprintf("%s%s is a commonly used value", group.prefix, token.Name))
+			case len(token.Value) < c.MinTokenLength:
+				problems = append(problems, fmt.Sprintf("%s%s is shorter than %d characters", group.prefix, token.Name, c.MinTokenLength))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if c.StrictTokens {
+		return fmt.Errorf("weak tokens are not allowed with STRICT_TOKENS=true: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		slog.Warn("Weak token configured", "problem", problem)
 	}
+	return nil
+}
+
+// httpsURL switches an http:// URL to https://, other URLs are returned as is
+func httpsURL(url string) string {
+	if rest, ok := strings.CutPrefix(url, "http://"); ok {
+		return "https://" + rest
+	}
+	return url
 }
 
 func parseLogLevel(level string) slog.Level {
@@ -78,6 +443,50 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvListOr is getEnvList with defaults for an unset variable; set to an
+// empty value, it yields no entries
+func getEnvListOr(key string, defaultValues []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaultValues
+	}
+	return getEnvList(key)
+}
+
 func parseTokensFromEnv(prefix string) []Token {
 	var tokens []Token
 
@@ -86,11 +495,16 @@ func parseTokensFromEnv(prefix string) []Token {
 			parts := strings.SplitN(env, "=", 2)
 			if len(parts) == 2 {
 				envName := parts[0]
-				envValue := parts[1]
 				name := strings.TrimPrefix(envName, prefix)
+				value, expiresAt, err := parseTokenValue(parts[1])
+				if err != nil {
+					slog.Warn("Ignoring token with invalid expiry", "env", envName, "error", err)
+					continue
+				}
 				tokens = append(tokens, Token{
-					Name:  name,
-					Value: envValue,
+					Name:      name,
+					Value:     value,
+					ExpiresAt: expiresAt,
 				})
 			}
 		}
@@ -98,3 +512,23 @@ func parseTokensFromEnv(prefix string) []Token {
 
 	return tokens
 }
+
+// parseTokenValue splits an env token into its value and optional expiry. The
+// expiry is either a date, valid through the end of that day in UTC, or an
+// RFC 3339 timestamp.
+func parseTokenValue(raw string) (string, *time.Time, error) {
+	value, expiry, found := strings.Cut(raw, tokenExpirySeparator)
+	if !found {
+		return raw, nil, nil
+	}
+
+	if day, err := time.Parse("2006-01-02", expiry); err == nil {
+		expiresAt := day.AddDate(0, 0, 1)
+		return value, &expiresAt, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return "", nil, fmt.Errorf("expiry %q must be a date (2006-01-02) or RFC 3339 timestamp", expiry)
+	}
+	return value, &expiresAt, nil
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -42,6 +44,30 @@ func TestLoad(t *testing.T) {
 		t.Errorf("Expected default log level info, got %v", cfg.LogLevel)
 	}
 
+	if cfg.AuthBackend != AuthBackendEnv {
+		t.Errorf("Expected default auth backend env, got %s", cfg.AuthBackend)
+	}
+
+	if cfg.ReadHeaderTimeout != DefaultReadHeaderTimeout || cfg.WriteTimeout != DefaultWriteTimeout || cfg.TransferTimeout != DefaultTransferTimeout || cfg.RequestTimeout != DefaultRequestTimeout {
+		t.Errorf("Expected default HTTP timeouts, got header=%s write=%s transfer=%s request=%s", cfg.ReadHeaderTimeout, cfg.WriteTimeout, cfg.TransferTimeout, cfg.RequestTimeout)
+	}
+
+	if cfg.PendingUploadTTL != DefaultPendingUploadTTL {
+		t.Errorf("Expected default pending upload TTL, got %s", cfg.PendingUploadTTL)
+	}
+
+	if cfg.SignedDownloads || cfg.SignedDownloadTTL != DefaultSignedDownloadTTL {
+		t.Errorf("Expected signed downloads off with the default TTL, got %v %s", cfg.SignedDownloads, cfg.SignedDownloadTTL)
+	}
+
+	if cfg.MaxUploadBytes != DefaultMaxUploadBytes {
+		t.Errorf("Expected default max upload bytes, got %d", cfg.MaxUploadBytes)
+	}
+
+	if !cfg.FeatureEnabled(FeatureBatch) || !cfg.FeatureEnabled(FeatureExport) || cfg.FeatureEnabled(FeatureProxy) {
+		t.Errorf("Expected the default features, got %v", cfg.Features)
+	}
+
 	if len(cfg.ReadTokens) != 1 || cfg.ReadTokens[0].Name != "ALICE" || cfg.ReadTokens[0].Value != "read-token-123" {
 		t.Errorf("Expected ReadTokens to contain ALICE token, got %v", cfg.ReadTokens)
 	}
@@ -114,6 +140,110 @@ func TestLoadWithEnvVars(t *testing.T) {
 	}
 }
 
+func TestLoadDBAuthBackend(t *testing.T) {
+	// No READ_TOKEN_* or WRITE_TOKEN_* is set, which is only allowed with the db backend
+	t.Setenv("AUTH_BACKEND", "db")
+
+	cfg := Load()
+
+	if cfg.AuthBackend != AuthBackendDB {
+		t.Errorf("Expected db auth backend, got %s", cfg.AuthBackend)
+	}
+	if len(cfg.ReadTokens) != 0 || len(cfg.WriteTokens) != 0 {
+		t.Errorf("Expected no env tokens, got %v and %v", cfg.ReadTokens, cfg.WriteTokens)
+	}
+}
+
+func TestLoadFeatures(t *testing.T) {
+	t.Setenv("READ_TOKEN_ALICE", "read-token-123")
+
+	t.Setenv("FEATURES", "Proxy, export")
+	cfg := Load()
+	if !cfg.FeatureEnabled(FeatureProxy) || !cfg.FeatureEnabled(FeatureExport) || cfg.FeatureEnabled(FeatureBatch) {
+		t.Errorf("Expected proxy and export, got %v", cfg.Features)
+	}
+
+	// Set but empty disables every feature
+	t.Setenv("FEATURES", "")
+	if cfg := Load(); len(cfg.Features) != 0 {
+		t.Errorf("Expected no features, got %v", cfg.Features)
+	}
+}
+
+func TestLoadTLS(t *testing.T) {
+	t.Setenv("READ_TOKEN_ALICE", "read-token-123")
+	t.Setenv("BASE_URL", "http://pub.example.com")
+
+	tests := []struct {
+		name            string
+		certFile        string
+		keyFile         string
+		expectedEnabled bool
+		expectedBaseURL string
+	}{
+		{"plain HTTP by default", "", "", false, "http://pub.example.com"},
+		{"cert and key switch to https", "/etc/repub/cert.pem", "/etc/repub/key.pem", true, "https://pub.example.com"},
+		{"cert without key stays disabled", "/etc/repub/cert.pem", "", false, "http://pub.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TLS_CERT_FILE", tt.certFile)
+			t.Setenv("TLS_KEY_FILE", tt.keyFile)
+
+			cfg := Load()
+
+			if cfg.TLSEnabled() != tt.expectedEnabled {
+				t.Errorf("Expected TLSEnabled %v, got %v", tt.expectedEnabled, cfg.TLSEnabled())
+			}
+			if cfg.BaseURL != tt.expectedBaseURL {
+				t.Errorf("Expected base URL %s, got %s", tt.expectedBaseURL, cfg.BaseURL)
+			}
+		})
+	}
+
+	t.Run("trailing slash is dropped", func(t *testing.T) {
+		t.Setenv("BASE_URL", "http://pub.example.com/")
+		t.Setenv("TLS_CERT_FILE", "")
+		t.Setenv("TLS_KEY_FILE", "")
+
+		if cfg := Load(); cfg.BaseURL != "http://pub.example.com" {
+			t.Errorf("Expected base URL http://pub.example.com, got %s", cfg.BaseURL)
+		}
+	})
+}
+
+func TestCheckTokens(t *testing.T) {
+	strong := Token{Name: "CI", Value: "f3a9c1e07b5d4c2a8e6f"}
+	tests := []struct {
+		name      string
+		tokens    []Token
+		strict    bool
+		wantError string
+	}{
+		{"strong token", []Token{strong}, true, ""},
+		{"too short", []Token{strong, {Name: "ALICE", Value: "short"}}, true, "WRITE_TOKEN_ALICE is shorter than 16 characters"},
+		{"commonly used", []Token{{Name: "BOB", Value: "ChangeMe"}}, true, "WRITE_TOKEN_BOB is a commonly used value"},
+		{"only warned without strict mode", []Token{{Name: "ALICE", Value: "short"}}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{WriteTokens: tt.tokens, MinTokenLength: DefaultMinTokenLength, StrictTokens: tt.strict}
+			err := cfg.checkTokens()
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
 func TestParseTokensFromEnv(t *testing.T) {
 	// Clean up any existing tokens
 	for _, env := range os.Environ() {
@@ -171,6 +301,66 @@ func TestParseTokensFromEnv(t *testing.T) {
 	}
 }
 
+func TestParseTokenValue(t *testing.T) {
+	endOf2025 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	noon := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		raw            string
+		expectedValue  string
+		expectedExpiry *time.Time
+		expectError    bool
+	}{
+		{"plain-token", "plain-token", nil, false},
+		{"ci-token|2025-12-31", "ci-token", &endOf2025, false},
+		{"ci-token|2025-06-01T12:00:00Z", "ci-token", &noon, false},
+		{"ci-token|next-year", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		value, expiresAt, err := parseTokenValue(tt.raw)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("parseTokenValue(%q) expected error", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTokenValue(%q) unexpected error: %v", tt.raw, err)
+			continue
+		}
+		if value != tt.expectedValue {
+			t.Errorf("parseTokenValue(%q) value = %q, expected %q", tt.raw, value, tt.expectedValue)
+		}
+		if (expiresAt == nil) != (tt.expectedExpiry == nil) || (expiresAt != nil && !expiresAt.Equal(*tt.expectedExpiry)) {
+			t.Errorf("parseTokenValue(%q) expiry = %v, expected %v", tt.raw, expiresAt, tt.expectedExpiry)
+		}
+	}
+}
+
+func TestParseTokensFromEnv_Expiry(t *testing.T) {
+	t.Setenv("EXPIRY_TOKEN_EXPIRED", "expired-token|2020-01-01")
+	t.Setenv("EXPIRY_TOKEN_FOREVER", "forever-token")
+	t.Setenv("EXPIRY_TOKEN_BROKEN", "broken-token|someday")
+
+	tokens := parseTokensFromEnv("EXPIRY_TOKEN_")
+
+	byName := make(map[string]Token)
+	for _, token := range tokens {
+		byName[token.Name] = token
+	}
+
+	if len(byName) != 2 {
+		t.Fatalf("Expected the token with an invalid expiry to be skipped, got %v", tokens)
+	}
+	if expired := byName["EXPIRED"]; expired.Value != "expired-token" || expired.ExpiresAt == nil {
+		t.Errorf("Expected EXPIRED token with an expiry, got %+v", expired)
+	}
+	if forever := byName["FOREVER"]; forever.Value != "forever-token" || forever.ExpiresAt != nil {
+		t.Errorf("Expected FOREVER token without expiry, got %+v", forever)
+	}
+}
+
 func TestParseLogLevel(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -196,3 +386,87 @@ func TestParseLogLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestGetEnvBool(t *testing.T) {
+	tests := []struct {
+		value        string
+		defaultValue bool
+		expected     bool
+	}{
+		{"true", false, true},
+		{"1", false, true},
+		{"false", true, false},
+		{"", false, false},
+		{"", true, true},
+		{"invalid", true, true},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_BOOL", test.value)
+		result := getEnvBool("TEST_BOOL", test.defaultValue)
+		if result != test.expected {
+			t.Errorf("getEnvBool(%q, %t) = %t, expected %t", test.value, test.defaultValue, result, test.expected)
+		}
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		value        string
+		defaultValue time.Duration
+		expected     time.Duration
+	}{
+		{"1h", 0, time.Hour},
+		{"90s", 0, 90 * time.Second},
+		{"", time.Minute, time.Minute},
+		{"invalid", time.Minute, time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_DURATION", test.value)
+		result := getEnvDuration("TEST_DURATION", test.defaultValue)
+		if result != test.expected {
+			t.Errorf("getEnvDuration(%q, %v) = %v, expected %v", test.value, test.defaultValue, result, test.expected)
+		}
+	}
+}
+
+func TestGetEnvList(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{"", nil},
+		{"flutter", []string{"flutter"}},
+		{"flutter, dart ,,sky_engine", []string{"flutter", "dart", "sky_engine"}},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_LIST", test.value)
+		result := getEnvList("TEST_LIST")
+		if !slices.Equal(result, test.expected) {
+			t.Errorf("getEnvList(%q) = %v, expected %v", test.value, result, test.expected)
+		}
+	}
+}
+
+func TestGetEnvInt(t *testing.T) {
+	tests := []struct {
+		value        string
+		defaultValue int64
+		expected     int64
+	}{
+		{"10", 0, 10},
+		{"1073741824", 0, 1 << 30},
+		{"", 5, 5},
+		{"invalid", 5, 5},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_INT", test.value)
+		result := getEnvInt("TEST_INT", test.defaultValue)
+		if result != test.expected {
+			t.Errorf("getEnvInt(%q, %d) = %d, expected %d", test.value, test.defaultValue, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// ExportedPackage is one line of the JSON Lines metadata export. Archives are
+// referenced by path rather than embedded, and ids are not exported.
+type ExportedPackage struct {
+	Name          string            `json:"name"`
+	Private       bool              `json:"private"`
+	CreatedAt     time.Time         `json:"created_at"`
+	LikeCount     int64             `json:"like_count"`
+	DownloadCount int64             `json:"download_count"`
+	Uploaders     []string          `json:"uploaders"`
+	Versions      []ExportedVersion `json:"versions"` // lowest version first
+}
+
+// ExportedVersion is the exported metadata of a single version
+type ExportedVersion struct {
+	Version       string       `json:"version"`
+	Description   *string      `json:"description,omitempty"`
+	PubspecYaml   string       `json:"pubspec_yaml"`
+	Readme        *string      `json:"readme,omitempty"`
+	Changelog     *string      `json:"changelog,omitempty"`
+	ArchivePath   string       `json:"archive_path"`
+	ArchiveSha256 *string      `json:"archive_sha256,omitempty"`
+	Uploader      *string      `json:"uploader,omitempty"`
+	Retracted     bool         `json:"retracted,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Platforms     []string     `json:"platforms,omitempty"`
+	SizeBytes     *int64       `json:"size_bytes,omitempty"`
+	Funding       []string     `json:"funding,omitempty"`
+	Screenshots   []Screenshot `json:"screenshots,omitempty"`
+	Proxied       bool         `json:"proxied,omitempty"`
+}
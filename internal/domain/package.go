@@ -10,10 +10,29 @@ type Package struct {
 	Homepage      *string   `json:"homepage"`
 	Repository    *string   `json:"repository"`
 	Documentation *string   `json:"documentation"`
+	LikeCount     int64     `json:"like_count"`
+	DownloadCount int64     `json:"download_count"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// PackageSort orders package listings
+type PackageSort string
+
+const (
+	PackageSortName      PackageSort = "name"
+	PackageSortUpdated   PackageSort = "updated"
+	PackageSortDownloads PackageSort = "downloads"
+)
+
+// DefaultPackageSort lists the most recently published packages first
+const DefaultPackageSort = PackageSortUpdated
+
+// Valid reports whether s is one of the known orderings
+func (s PackageSort) Valid() bool {
+	return s == PackageSortName || s == PackageSortUpdated || s == PackageSortDownloads
+}
+
 type PackageVersion struct {
 	ID            int32     `json:"id"`
 	PackageID     int32     `json:"package_id"`
@@ -27,6 +46,18 @@ type PackageVersion struct {
 	Uploader      *string   `json:"uploader"`
 	Retracted     bool      `json:"retracted"`
 	CreatedAt     time.Time `json:"created_at"`
+	Platforms     []string  `json:"platforms"`
+	SizeBytes     *int64    `json:"size_bytes"` // nil until recorded or backfilled
+	// Funding and Screenshots are declared by the pubspec; only screenshots
+	// found in the archive are kept
+	Funding     []string     `json:"funding"`
+	Screenshots []Screenshot `json:"screenshots"`
+	// Proxied versions were cached from the upstream registry, not published here
+	Proxied bool `json:"proxied"`
+	// ReadmeHTML is Readme rendered to sanitized HTML, nil until rendered
+	ReadmeHTML *string `json:"readme_html,omitempty"`
+	// ChangelogHTML is Changelog rendered to sanitized HTML, nil until rendered
+	ChangelogHTML *string `json:"changelog_html,omitempty"`
 }
 
 type PackageResponse struct {
@@ -36,11 +67,32 @@ type PackageResponse struct {
 	Versions       []VersionResponse `json:"versions"`
 }
 
+// BatchPackagesRequest lists the packages to fetch metadata for in one request
+type BatchPackagesRequest struct {
+	Packages []string `json:"packages"`
+}
+
+// BatchPackagesResponse maps each known package to its metadata; requested
+// packages that don't exist or aren't visible are listed in NotFound
+type BatchPackagesResponse struct {
+	Packages map[string]*PackageResponse `json:"packages"`
+	NotFound []string                    `json:"not_found"`
+}
+
+// PackageListResponse is a page of the package list; NextURL is nil on the
+// last page
+type PackageListResponse struct {
+	Packages []*Package `json:"packages"`
+	NextURL  *string    `json:"next_url"`
+}
+
 // Extended package info for UI display
 type PackageDetail struct {
 	Package  *Package          `json:"package"`
 	Latest   *PackageVersion   `json:"latest"`
 	Versions []*PackageVersion `json:"versions"`
+	// VersionDownloads is the total download count of each version
+	VersionDownloads map[string]int64 `json:"version_downloads,omitempty"`
 }
 
 type VersionResponse struct {
@@ -48,12 +100,164 @@ type VersionResponse struct {
 	Retracted     bool           `json:"retracted,omitempty"`
 	ArchiveURL    string         `json:"archive_url"`
 	ArchiveSha256 string         `json:"archive_sha256,omitempty"`
+	Published     time.Time      `json:"published,omitzero"`
+	Platforms     []string       `json:"platforms,omitempty"`
+	SizeBytes     int64          `json:"size_bytes,omitempty"`
+	Proxied       bool           `json:"proxied,omitempty"`
 	Pubspec       map[string]any `json:"pubspec"`
 }
 
+// VersionDependencies lists the normalized dependencies declared by a version
+type VersionDependencies struct {
+	Package         string                 `json:"package"`
+	Version         string                 `json:"version"`
+	Dependencies    map[string]*Dependency `json:"dependencies"`
+	DevDependencies map[string]*Dependency `json:"dev_dependencies"`
+}
+
+// VersionVerification reports whether a version's row agrees with its stored archive
+type VersionVerification struct {
+	Package    string   `json:"package"`
+	Version    string   `json:"version"`
+	Consistent bool     `json:"consistent"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// CollectedVersion is a retracted version considered for deletion by garbage collection
+type CollectedVersion struct {
+	Package     string
+	Version     string
+	PublishedAt time.Time
+	SizeBytes   int64
+	// Skipped explains why the version was kept, empty if it was deleted
+	Skipped string
+}
+
+// MigratedVersion is a version whose archive was copied to another storage backend
+type MigratedVersion struct {
+	Package string
+	Version string
+	From    string
+	// To is the archive path in the target backend, empty on a dry run
+	To        string
+	SizeBytes int64
+	// AlreadyMigrated is set when an earlier run moved the archive
+	AlreadyMigrated bool
+	// Skipped explains why the version wasn't migrated, empty if it was
+	Skipped string
+}
+
+// ScoreResponse is a minimal pub.dev-style package score
+type ScoreResponse struct {
+	GrantedPoints int   `json:"grantedPoints"`
+	MaxPoints     int   `json:"maxPoints"`
+	LikeCount     int64 `json:"likeCount"`
+	DownloadCount int64 `json:"downloadCount"`
+}
+
+type LikeResponse struct {
+	Package   string `json:"package"`
+	LikeCount int64  `json:"likeCount"`
+}
+
+// PackageOptions mirrors pub.dev's package options response
+type PackageOptions struct {
+	IsDiscontinued bool    `json:"isDiscontinued"`
+	ReplacedBy     *string `json:"replacedBy"`
+	IsUnlisted     bool    `json:"isUnlisted"`
+}
+
+// UploadersResponse lists the identities allowed to publish a package
+type UploadersResponse struct {
+	Package   string   `json:"package"`
+	Uploaders []string `json:"uploaders"`
+}
+
+// InstanceStats are totals over every package hosted by the instance
+type InstanceStats struct {
+	Packages     int64 `json:"packages"`
+	Versions     int64 `json:"versions"`
+	StorageBytes int64 `json:"storage_bytes"`
+	Downloads    int64 `json:"downloads"`
+	// Publishers counts distinct uploaders
+	Publishers int64 `json:"publishers"`
+}
+
+// SitemapPackage is a public package as listed in the sitemap, with its
+// versions newest first
+type SitemapPackage struct {
+	Name      string
+	UpdatedAt time.Time
+	Versions  []SitemapVersion
+}
+
+// SitemapVersion is a version as listed in the sitemap
+type SitemapVersion struct {
+	Version   string
+	CreatedAt time.Time
+}
+
+// VersionDownloads is the number of downloads of one version on one day
+type VersionDownloads struct {
+	Day     time.Time
+	Version string
+	Count   int64
+}
+
+// DownloadKey identifies the daily download bucket of one package version
+type DownloadKey struct {
+	PackageID int32
+	Version   string
+	Day       time.Time
+}
+
+// DownloadMetrics is a daily download time series for a package
+type DownloadMetrics struct {
+	Package string           `json:"package"`
+	Days    int              `json:"days"`
+	Total   int64            `json:"total"`
+	Series  []DailyDownloads `json:"series"`
+}
+
+type DailyDownloads struct {
+	Date      string           `json:"date"`
+	Downloads int64            `json:"downloads"`
+	Versions  map[string]int64 `json:"versions,omitempty"`
+}
+
+// PublishPreflight holds optional hints a client can send before uploading
+// an archive, so that doomed publishes are rejected early
+type PublishPreflight struct {
+	Package  string
+	Size     int64
+	Uploader string
+}
+
+// Actor is the caller of a change to a package, allowed when it is one of
+// the package's uploaders or holds an admin token
+type Actor struct {
+	Uploader string
+	Admin    bool
+}
+
+type PrivacyRequest struct {
+	Private bool `json:"private"`
+}
+
+type PrivacyResponse struct {
+	Package string `json:"package"`
+	Private bool   `json:"private"`
+}
+
 type PublishRequest struct {
 	Archive  []byte
 	Uploader string
+	// ExpectedPackage, when set, must match the name in the archive's pubspec
+	ExpectedPackage string
+	// AllowRetry makes publishing the archive of an already published version
+	// succeed when the bytes are identical, for clients retrying a publish
+	// whose response they lost
+	AllowRetry bool
 }
 
 type PublishResponse struct {
@@ -61,6 +265,26 @@ type PublishResponse struct {
 	Fields map[string]string `json:"fields"`
 }
 
+// PublishValidation describes the version a validated archive would publish
+type PublishValidation struct {
+	Package       string `json:"package"`
+	Version       string `json:"version"`
+	Uploader      string `json:"uploader"`
+	NewPackage    bool   `json:"new_package"`
+	SizeBytes     int64  `json:"size_bytes"`
+	ArchiveSha256 string `json:"archive_sha256"`
+}
+
+// PublishEvent describes a newly published version, as sent to publish
+// webhooks. Event is always "publish", telling it apart from report payloads.
+type PublishEvent struct {
+	Event       string    `json:"event"`
+	Package     string    `json:"package"`
+	Version     string    `json:"version"`
+	Uploader    string    `json:"uploader"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
 type AdvisoriesResponse struct {
 	Advisories        []Advisory `json:"advisories"`
 	AdvisoriesUpdated string     `json:"advisoriesUpdated"`
@@ -91,3 +315,17 @@ type Advisory struct {
 		Versions []string `json:"versions,omitempty"`
 	} `json:"affected,omitempty"`
 }
+
+// ServerMeta describes the upload constraints and features of the server so
+// clients can discover them before publishing
+type ServerMeta struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	// MaxUploadBytes is the largest accepted archive, 0 means unlimited
+	MaxUploadBytes int64 `json:"maxUploadBytes"`
+	// AnonymousRead is true when packages can be read without a token
+	AnonymousRead bool `json:"anonymousRead"`
+	// RequireSignedUploads is true when archives must carry a signature
+	RequireSignedUploads bool `json:"requireSignedUploads"`
+	// ReadOnly is true while publishing is disabled for maintenance
+	ReadOnly bool `json:"readOnly"`
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "slices"
+
 // Pubspec represents a parsed pubspec.yaml file
 type Pubspec struct {
 	Name            string                 `json:"name" yaml:"name"`
@@ -24,6 +26,32 @@ type Pubspec struct {
 	Extra map[string]interface{} `json:",inline" yaml:",inline"`
 }
 
+// SupportedPlatforms returns the sorted platforms declared in the top-level
+// platforms section or in a Flutter plugin's flutter.plugin.platforms section
+func (p *Pubspec) SupportedPlatforms() []string {
+	seen := make(map[string]struct{})
+	for name := range p.Platforms {
+		seen[name] = struct{}{}
+	}
+
+	if flutter, ok := p.Extra["flutter"].(map[string]interface{}); ok {
+		if plugin, ok := flutter["plugin"].(map[string]interface{}); ok {
+			if platforms, ok := plugin["platforms"].(map[string]interface{}); ok {
+				for name := range platforms {
+					seen[name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	platforms := make([]string, 0, len(seen))
+	for name := range seen {
+		platforms = append(platforms, name)
+	}
+	slices.Sort(platforms)
+	return platforms
+}
+
 type Environment struct {
 	SDK     string `json:"sdk,omitempty" yaml:"sdk,omitempty"`
 	Flutter string `json:"flutter,omitempty" yaml:"flutter,omitempty"`
@@ -34,14 +62,29 @@ type Screenshot struct {
 	Path        string `json:"path" yaml:"path"`
 }
 
+// ScreenshotImage is the content of a screenshot in a package archive
+type ScreenshotImage struct {
+	ContentType string
+	Data        []byte
+}
+
+// Dependency sources, see Dependency.Source
+const (
+	DependencySourceHosted = "hosted"
+	DependencySourceGit    = "git"
+	DependencySourcePath   = "path"
+	DependencySourceSDK    = "sdk"
+)
+
 // Dependency represents a package dependency
 type Dependency struct {
+	Source      string                 `json:"source"`
 	Version     string                 `json:"version,omitempty"`
 	Hosted      string                 `json:"hosted,omitempty"`
 	Git         *GitDependency         `json:"git,omitempty"`
 	Path        string                 `json:"path,omitempty"`
 	SDK         string                 `json:"sdk,omitempty"`
-	Extra       map[string]interface{} `json:",inline"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
 }
 
 type GitDependency struct {
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Report is an abuse report filed against a package version
+type Report struct {
+	ID        int32     `json:"id"`
+	PackageID int32     `json:"-"`
+	Package   string    `json:"package"`
+	Version   string    `json:"version"`
+	Reporter  string    `json:"reporter"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportRequest is the body of the report endpoint
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportEvent describes a new abuse report, as sent to publish webhooks when
+// reports are forwarded. Event tells it apart from publish payloads.
+type ReportEvent struct {
+	Event      string    `json:"event"`
+	Package    string    `json:"package"`
+	Version    string    `json:"version"`
+	Reporter   string    `json:"reporter"`
+	Reason     string    `json:"reason"`
+	ReportedAt time.Time `json:"reported_at"`
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// TokenScope is the access class granted by an API token
+type TokenScope string
+
+const (
+	TokenScopeRead  TokenScope = "read"
+	TokenScopeWrite TokenScope = "write"
+	TokenScopeAdmin TokenScope = "admin"
+)
+
+// Allows reports whether a token with this scope may perform actions requiring required;
+// write tokens can also read, and admin tokens can do everything
+func (s TokenScope) Allows(required TokenScope) bool {
+	rank := map[TokenScope]int{TokenScopeRead: 1, TokenScopeWrite: 2, TokenScopeAdmin: 3}
+	return rank[s] != 0 && rank[s] >= rank[required]
+}
+
+// Valid reports whether s is one of the known scopes
+func (s TokenScope) Valid() bool {
+	return s == TokenScopeRead || s == TokenScopeWrite || s == TokenScopeAdmin
+}
+
+// Token is a stored API token; only a hash of the secret is kept
+type Token struct {
+	ID        int32      `json:"id"`
+	Name      string     `json:"name"`
+	TokenHash string     `json:"-"`
+	Scope     TokenScope `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateTokenRequest is the body of the admin create token endpoint
+type CreateTokenRequest struct {
+	Name      string     `json:"name"`
+	Scope     TokenScope `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateTokenResponse returns the new token; the secret is only ever shown here
+type CreateTokenResponse struct {
+	Token  string `json:"token"`
+	Record *Token `json:"record"`
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two semantic versions and returns -1, 0 or 1
+// Build metadata is ignored and pre-releases sort before the release
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		var x, y int
+		if i < len(aCore) {
+			x = aCore[i]
+		}
+		if i < len(bCore) {
+			y = bCore[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+
+	// A version without a pre-release is greater than one with it
+	switch {
+	case aPre == "" && bPre == "":
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	return comparePreRelease(aPre, bPre)
+}
+
+// IsPrerelease reports whether version has a pre-release suffix, e.g. 2.0.0-dev.1
+func IsPrerelease(version string) bool {
+	_, preRelease := splitVersion(version)
+	return preRelease != ""
+}
+
+// splitVersion returns the numeric core parts and the pre-release suffix
+func splitVersion(version string) ([]int, string) {
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var preRelease string
+	if i := strings.Index(version, "-"); i >= 0 {
+		version, preRelease = version[:i], version[i+1:]
+	}
+
+	parts := strings.Split(version, ".")
+	core := make([]int, len(parts))
+	for i, part := range parts {
+		// Non-numeric parts are treated as zero
+		n, _ := strconv.Atoi(part)
+		core[i] = n
+	}
+
+	return core, preRelease
+}
+
+func comparePreRelease(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return cmp.Compare(aNum, bNum)
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return cmp.Compare(len(aParts), len(bParts))
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.9.9", 1},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0", "1.0.0-beta", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-beta", "1.0.0-beta.1", -1},
+		{"1.0.0+1", "1.0.0+2", 0},
+	}
+
+	for _, test := range tests {
+		result := CompareVersions(test.a, test.b)
+		if result != test.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", test.a, test.b, result, test.expected)
+		}
+	}
+}
+
+func TestIsPrerelease(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.0.0", false},
+		{"1.0.0+build.1", false},
+		{"2.0.0-dev.1", true},
+		{"1.0.0-beta+build", true},
+	}
+
+	for _, test := range tests {
+		if result := IsPrerelease(test.version); result != test.expected {
+			t.Errorf("IsPrerelease(%q) = %v, expected %v", test.version, result, test.expected)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"repub/internal/auth"
+	"repub/internal/domain"
+	"repub/internal/repository/pubspec"
 	"repub/internal/service"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,9 +27,9 @@ func GetPackageHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		packageName := chi.URLParam(r, "package")
 
-		pkg, err := pubSvc.GetPackage(r.Context(), packageName)
+		pkg, versions, err := pubSvc.StreamPackage(r.Context(), packageName)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
 			return
 		}
 
@@ -28,14 +38,173 @@ func GetPackageHandler(pubSvc service.PubService) http.HandlerFunc {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
-		if err := json.NewEncoder(w).Encode(pkg); err != nil {
-			slog.Error("Failed to encode package response", "error", err)
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := encodePackage(w, pkg, versions); err != nil {
+			// The status has usually been sent already, a truncated body is all we can signal
+			slog.Error("Failed to encode package response", "package", packageName, "error", err)
+		}
+	}
+}
+
+// encodePackage writes the same JSON as encoding pkg with all of its versions,
+// encoding one version at a time as versions yields them
+func encodePackage(w io.Writer, pkg *domain.PackageResponse, versions service.VersionStream) error {
+	// The fields before versions, in PackageResponse's order, then the
+	// versions array is opened in place of the object's closing brace
+	head, err := json.Marshal(struct {
+		Name           string                 `json:"name"`
+		IsDiscontinued bool                   `json:"isDiscontinued,omitempty"`
+		Latest         domain.VersionResponse `json:"latest"`
+	}{pkg.Name, pkg.IsDiscontinued, pkg.Latest})
+	if err != nil {
+		return err
+	}
+	head = append(head[:len(head)-1], `,"versions":[`...)
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	first := true
+	err = versions(func(v *domain.VersionResponse) error {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if !first {
+			encoded = append([]byte(","), encoded...)
+		}
+		first = false
+		_, err = w.Write(encoded)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// maxBatchPackages bounds the number of packages fetched by one batch request
+const maxBatchPackages = 100
+
+// GetPackagesBatchHandler returns the metadata of several packages at once so
+// tooling resolving a large dependency tree can save round-trips
+func GetPackagesBatchHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req domain.BatchPackagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+		if len(req.Packages) > maxBatchPackages {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("At most %d packages can be requested at once", maxBatchPackages))
+			return
+		}
+
+		resp := domain.BatchPackagesResponse{
+			Packages: make(map[string]*domain.PackageResponse, len(req.Packages)),
+			NotFound: []string{},
+		}
+		seen := make(map[string]bool, len(req.Packages))
+		for _, name := range req.Packages {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			pkg, err := pubSvc.GetPackage(r.Context(), name)
+			if err != nil && !errors.Is(err, service.ErrNotFound) {
+				writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+				return
+			}
+			if pkg == nil {
+				resp.NotFound = append(resp.NotFound, name)
+				continue
+			}
+			resp.Packages[name] = pkg
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("Failed to encode batch package response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// packageListPageSize is the number of packages listed per page of the API
+const packageListPageSize = 100
+
+// ListPackagesHandler lists a page (?page=N) of packages, ordered by ?sort=
+// or, with ?since=, only those changed after an RFC 3339 timestamp
+func ListPackagesHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sort, since, err := parsePackageListQuery(r)
+		if err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+		page := 1
+		if pageParam := r.URL.Query().Get("page"); pageParam != "" {
+			page, err = strconv.Atoi(pageParam)
+			if err != nil || page < 1 {
+				writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "page must be a positive number")
+				return
+			}
+		}
+
+		var packages []*domain.Package
+		if since.IsZero() {
+			packages, err = pubSvc.ListPackages(r.Context(), sort, page, packageListPageSize)
+		} else {
+			packages, err = pubSvc.ListPackagesUpdatedSince(r.Context(), since, page, packageListPageSize)
+		}
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		resp := domain.PackageListResponse{Packages: packages}
+		if resp.Packages == nil {
+			resp.Packages = []*domain.Package{}
+		}
+		if len(packages) == packageListPageSize {
+			query := r.URL.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next := "/api/packages?" + query.Encode()
+			resp.NextURL = &next
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("Failed to encode package list response", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 	}
 }
 
+// parsePackageListQuery reads the ?sort= and ?since= parameters of a package
+// listing. Packages changed since a timestamp are always listed oldest change
+// first, so since can't be combined with sort.
+func parsePackageListQuery(r *http.Request) (domain.PackageSort, time.Time, error) {
+	since, err := parseSince(r)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	sort := domain.PackageSort(r.URL.Query().Get("sort"))
+	if sort != "" && !since.IsZero() {
+		return "", time.Time{}, fmt.Errorf("since lists packages oldest change first and can't be combined with sort")
+	}
+	if sort == "" {
+		sort = domain.DefaultPackageSort
+	}
+	if !sort.Valid() {
+		return "", time.Time{}, fmt.Errorf("sort must be one of name, updated or downloads")
+	}
+	return sort, since, nil
+}
+
 func GetPackageVersionHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		packageName := chi.URLParam(r, "package")
@@ -52,7 +221,32 @@ func GetPackageVersionHandler(pubSvc service.PubService) http.HandlerFunc {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(versionResp); err != nil {
+			slog.Error("Failed to encode version response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// GetLatestVersionHandler returns only the latest version of a package,
+// skipping retracted versions and preferring stable releases
+func GetLatestVersionHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		versionResp, err := pubSvc.GetLatestVersion(r.Context(), packageName)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if versionResp == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
 		if err := json.NewEncoder(w).Encode(versionResp); err != nil {
 			slog.Error("Failed to encode version response", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -60,17 +254,207 @@ func GetPackageVersionHandler(pubSvc service.PubService) http.HandlerFunc {
 	}
 }
 
+// GetPubspecYAMLHandler returns the original pubspec.yaml of a version as uploaded
+func GetPubspecYAMLHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		pubspecYAML, err := pubSvc.GetPubspecYAML(r.Context(), packageName, version)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if pubspecYAML == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/yaml")
+		if _, err := w.Write([]byte(*pubspecYAML)); err != nil {
+			slog.Error("Failed to write pubspec response", "error", err)
+		}
+	}
+}
+
+// GetArchiveSHA256Handler returns the checksum of a version's archive as
+// text, so mirrors can verify downloads without fetching the version metadata
+func GetArchiveSHA256Handler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		versionResp, err := pubSvc.GetPackageVersion(r.Context(), packageName, version)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if versionResp == nil || versionResp.ArchiveSha256 == "" {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("No archive checksum for version %s of package %s", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(versionResp.ArchiveSha256)); err != nil {
+			slog.Error("Failed to write checksum response", "error", err)
+		}
+	}
+}
+
+// GetVersionDependenciesHandler returns the dependencies and dev dependencies
+// of a version with their source and constraint
+func GetVersionDependenciesHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		deps, err := pubSvc.GetVersionDependencies(r.Context(), packageName, version)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if deps == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deps); err != nil {
+			slog.Error("Failed to encode dependencies response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// VerifyVersionHandler checks a version's row against its stored archive and
+// reports any mismatch, a consistency check for operators
+func VerifyVersionHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		result, err := pubSvc.VerifyVersion(r.Context(), packageName, version)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if result == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.Error("Failed to encode verification response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// VersionRecordHandler returns a version's stored row, archive path and
+// uploader included, so operators can inspect it without database access
+func VersionRecordHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		record, err := pubSvc.GetVersionRecord(r.Context(), packageName, version)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if record == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(record); err != nil {
+			slog.Error("Failed to encode version record", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// RefreshPackageHandler re-renders a package's cached README HTML and
+// returns its refreshed metadata
+func RefreshPackageHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		pkg, err := pubSvc.RefreshPackage(r.Context(), packageName)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if pkg == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(pkg); err != nil {
+			slog.Error("Failed to encode package response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// GetReadmeHandler returns the README of a version as markdown, or as sanitized
+// HTML with ?format=html
+func GetReadmeHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "markdown" && format != "html" {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "format must be markdown or html")
+			return
+		}
+
+		getReadme := pubSvc.GetReadme
+		if format == "html" {
+			getReadme = pubSvc.GetReadmeHTML
+		}
+		readme, err := getReadme(r.Context(), packageName, version)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if readme == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s has no README", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		if format == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		if _, err := w.Write([]byte(*readme)); err != nil {
+			slog.Error("Failed to write readme response", "error", err)
+		}
+	}
+}
+
 func GetAdvisoriesHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		packageName := chi.URLParam(r, "package")
 
 		advisories, err := pubSvc.GetAdvisories(r.Context(), packageName)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+		w.Header().Set("Content-Type", pubContentType(r))
 		if err := json.NewEncoder(w).Encode(advisories); err != nil {
 			slog.Error("Failed to encode advisories response", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -78,47 +462,523 @@ func GetAdvisoriesHandler(pubSvc service.PubService) http.HandlerFunc {
 	}
 }
 
+func GetScoreHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		score, err := pubSvc.GetScore(r.Context(), packageName)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if score == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(score); err != nil {
+			slog.Error("Failed to encode score response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+const (
+	defaultMetricsDays = 30
+	maxMetricsDays     = 365
+)
+
+// GetDownloadMetricsHandler returns the daily download time series of a package,
+// covering the last ?days=N days (default 30)
+func GetDownloadMetricsHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		days := defaultMetricsDays
+		if daysParam := r.URL.Query().Get("days"); daysParam != "" {
+			parsed, err := strconv.Atoi(daysParam)
+			if err != nil || parsed < 1 || parsed > maxMetricsDays {
+				writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("days must be between 1 and %d", maxMetricsDays))
+				return
+			}
+			days = parsed
+		}
+
+		metrics, err := pubSvc.GetDownloadMetrics(r.Context(), packageName, days)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if metrics == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(metrics); err != nil {
+			slog.Error("Failed to encode metrics response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// GetPackageOptionsHandler returns the pub.dev-style package options
+func GetPackageOptionsHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		options, err := pubSvc.GetPackageOptions(r.Context(), packageName)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if options == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(options); err != nil {
+			slog.Error("Failed to encode options response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// GetUploadersHandler lists the uploaders of a package. Unless public, only
+// admins and the package's own uploaders may see them, as they are often emails.
+func GetUploadersHandler(pubSvc service.PubService, authSvc service.AuthService, public bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		uploaders, err := pubSvc.GetUploaders(r.Context(), packageName)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if uploaders == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		if !public && !canListUploaders(r, authSvc, uploaders.Uploaders) {
+			writePubError(w, http.StatusForbidden, "FORBIDDEN", fmt.Sprintf("Only uploaders of package %s can list its uploaders", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(uploaders); err != nil {
+			slog.Error("Failed to encode uploaders response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// canListUploaders reports whether the caller is an admin or one of uploaders.
+// Credentials that don't name the caller only qualify with an admin token.
+func canListUploaders(r *http.Request, authSvc service.AuthService, uploaders []string) bool {
+	if identity := auth.Identity(r.Context()); identity != "" && slices.Contains(uploaders, identity) {
+		return true
+	}
+	return authSvc.AuthenticateAdminRequest(r.Context(), r.Header.Get("Authorization")) == nil
+}
+
+// LikePackageHandler records a like from the calling token, repeated likes are ignored
+func LikePackageHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		liker := auth.Subject(r.Context())
+		if liker == "" {
+			writePubError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+			return
+		}
+
+		like, err := pubSvc.LikePackage(r.Context(), packageName, liker)
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if like == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(like); err != nil {
+			slog.Error("Failed to encode like response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// actorFor returns the caller of a change to a package, see domain.Actor
+func actorFor(r *http.Request, authSvc service.AuthService) domain.Actor {
+	return domain.Actor{
+		Uploader: uploaderFor(r.Context()),
+		Admin:    authSvc.AuthenticateAdminRequest(r.Context(), r.Header.Get("Authorization")) == nil,
+	}
+}
+
+// SetPackagePrivacyHandler marks a package as private or public, for its
+// uploaders and admins
+func SetPackagePrivacyHandler(pubSvc service.PubService, authSvc service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+
+		var req domain.PrivacyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+
+		privacy, err := pubSvc.SetPackagePrivate(r.Context(), packageName, req.Private, actorFor(r, authSvc))
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if privacy == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Package %s not found", packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(privacy); err != nil {
+			slog.Error("Failed to encode privacy response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// RetractVersionHandler marks a version as retracted, for the package's
+// uploaders and admins
+func RetractVersionHandler(pubSvc service.PubService, authSvc service.AuthService) http.HandlerFunc {
+	return setVersionRetractedHandler(pubSvc, authSvc, true)
+}
+
+// UnretractVersionHandler clears the retracted flag of a version, for the
+// package's uploaders and admins
+func UnretractVersionHandler(pubSvc service.PubService, authSvc service.AuthService) http.HandlerFunc {
+	return setVersionRetractedHandler(pubSvc, authSvc, false)
+}
+
+func setVersionRetractedHandler(pubSvc service.PubService, authSvc service.AuthService, retracted bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		versionResp, err := pubSvc.SetVersionRetracted(r.Context(), packageName, version, retracted, actorFor(r, authSvc))
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		if versionResp == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		if err := json.NewEncoder(w).Encode(versionResp); err != nil {
+			slog.Error("Failed to encode version response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// archiveCacheControl is sent with archive downloads, which are immutable once published
+const archiveCacheControl = "public, max-age=31536000, immutable"
+
+// DownloadPackageHandler serves a version's archive. The archive SHA256 is its
+// ETag, so clients revalidating with If-None-Match get a 304 without a body.
 func DownloadPackageHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		packageName := chi.URLParam(r, "package")
 		version := chi.URLParam(r, "version")
 
-		data, err := pubSvc.DownloadPackage(r.Context(), packageName, version)
+		versionResp, err := pubSvc.GetPackageVersion(r.Context(), packageName, version)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+		if versionResp == nil {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Version %s of package %s not found", version, packageName))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+packageName+"-"+version+".tar.gz\"")
+		// Archives published before hashes were recorded have no ETag
+		if versionResp.ArchiveSha256 != "" {
+			etag := `"` + versionResp.ArchiveSha256 + `"`
+			w.Header().Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				// A revalidation isn't counted as a download
+				w.Header().Set("Cache-Control", archiveCacheControl)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		// HEAD checks existence and size from the version row, without
+		// opening the archive; only versions predating recorded sizes need it
+		if r.Method == http.MethodHead && versionResp.SizeBytes > 0 {
+			setArchiveHeaders(w, packageName, versionResp)
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.FormatInt(versionResp.SizeBytes, 10))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-		if _, err := w.Write(data); err != nil {
-			slog.Error("Failed to write download response", "error", err)
+		countDownload := r.Method != http.MethodHead && !resumesDownload(r)
+		archive, err := pubSvc.OpenPackageArchive(r.Context(), packageName, version, countDownload)
+		if err != nil {
+			w.Header().Del("ETag")
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
 		}
+		defer func() { _ = archive.Close() }()
+
+		setArchiveHeaders(w, packageName, versionResp)
+
+		// ServeContent answers Range requests with 206 partial content and
+		// HEAD without a body. If-Range is validated against the ETag, or
+		// the publish time for archives without one.
+		http.ServeContent(w, r, packageName+"-"+version+".tar.gz", versionResp.Published, archive)
+	}
+}
+
+// setArchiveHeaders sets the headers GET and HEAD of an archive share
+func setArchiveHeaders(w http.ResponseWriter, packageName string, v *domain.VersionResponse) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", attachmentDisposition(packageName+"-"+v.Version+".tar.gz"))
+	w.Header().Set("Cache-Control", archiveCacheControl)
+	if !v.Published.IsZero() {
+		w.Header().Set("Last-Modified", v.Published.UTC().Format(http.TimeFormat))
 	}
 }
 
-// NewPackageVersionHandler returns the initial upload form for pub protocol
-func NewPackageVersionHandler(pubSvc service.PubService) http.HandlerFunc {
+// attachmentDisposition formats a Content-Disposition header for filename
+// per RFC 6266: an ASCII filename, quoted as needed and with control
+// characters, quotes and backslashes replaced, and the exact name as a
+// percent-encoded filename* for clients that support it
+func attachmentDisposition(filename string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	var encoded strings.Builder
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fallback}) + "; filename*=UTF-8''" + encoded.String()
+}
+
+// isAttrChar reports whether b may appear unencoded in an RFC 5987 value
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// ScreenshotHandler serves a screenshot declared in a version's pubspec from
+// its archive. Like the archive, it never changes once published.
+func ScreenshotHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		screenshot, err := pubSvc.GetScreenshot(r.Context(), packageName, version, chi.URLParam(r, "*"))
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		w.Header().Set("Content-Type", screenshot.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", archiveCacheControl)
+		if _, err := w.Write(screenshot.Data); err != nil {
+			slog.Error("Failed to write screenshot", "error", err, "package", packageName, "version", version)
+		}
+	}
+}
+
+// resumesDownload reports whether r asks for a range past the start of the
+// archive, continuing a download that was already counted
+func resumesDownload(r *http.Request) bool {
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightPublish checks the optional publish hints of a /versions/new request.
+// It writes the rejection and returns false if the publish would fail.
+func preflightPublish(w http.ResponseWriter, r *http.Request, pubSvc service.PubService) bool {
+	query := r.URL.Query()
+	preflight := &domain.PublishPreflight{
+		Package:  query.Get("package"),
+		Uploader: uploaderFor(r.Context()),
+	}
+
+	if sizeParam := query.Get("size"); sizeParam != "" {
+		size, err := strconv.ParseInt(sizeParam, 10, 64)
+		if err != nil || size < 0 {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "size must be a non-negative number of bytes")
+			return false
+		}
+		preflight.Size = size
+	}
+
+	if err := pubSvc.PreflightPublish(r.Context(), preflight); err != nil {
+		slog.Error("Publish preflight failed", "error", err)
+		writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+		return false
+	}
+	return true
+}
+
+// NewPackageVersionHandler returns the initial upload form for pub protocol.
+// The URLs are built from baseURL rather than the request's Host, which is
+// the proxy's upstream address when running behind one.
+func NewPackageVersionHandler(pubSvc service.PubService, baseURL string) http.HandlerFunc {
+	baseURL = strings.TrimSuffix(baseURL, "/")
 	return func(w http.ResponseWriter, r *http.Request) {
 		// According to pub protocol, this endpoint should return upload URL and fields
-		// Default to https, use http only for localhost
-		scheme := "https"
-		if strings.Contains(r.Host, "localhost") || strings.Contains(r.Host, "127.0.0.1") {
-			scheme = "http"
+		// Optional ?package=<name>&size=<bytes> hints let clients learn that a
+		// publish will be rejected before uploading the archive
+		if !preflightPublish(w, r, pubSvc) {
+			return
+		}
+
+		// The client posts fields back with the upload, so a package hint is
+		// checked against the archive when the upload is finalized
+		fields := map[string]string{}
+		if packageName := r.URL.Query().Get("package"); packageName != "" {
+			fields["package"] = packageName
 		}
-		baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
-		slog.Info("Base URL", "url", baseURL)
 
+		// validate_url advertises the publish dry run, so clients validating
+		// an archive can check it against the server without publishing it.
+		// Nothing is recorded until an archive is uploaded.
 		response := map[string]interface{}{
-			"url":    baseURL + "/api/packages/versions/new",
-			"fields": map[string]string{},
+			"url":          baseURL + "/api/packages/versions/new",
+			"fields":       fields,
+			"validate_url": baseURL + "/api/packages/versions/validate",
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+		w.Header().Set("Content-Type", pubContentType(r))
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			slog.Error("Failed to encode new version response", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 	}
 }
+
+// storageRetryAfter is the Retry-After, in seconds, sent when storage is unavailable
+const storageRetryAfter = "30"
+
+// writeServiceError maps a service error to its HTTP status and pub error code,
+// falling back to the given status and code for errors without a known sentinel
+func writeServiceError(w http.ResponseWriter, err error, status int, code string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		status, code = http.StatusNotFound, "NOT_FOUND"
+	case errors.Is(err, service.ErrPackageReserved):
+		status, code = http.StatusForbidden, "PACKAGE_RESERVED"
+	case errors.Is(err, service.ErrPackageBlocked):
+		status, code = http.StatusForbidden, "PACKAGE_BLOCKED"
+	case errors.Is(err, service.ErrUnauthorized):
+		// The client is authenticated but not an uploader; 401 would make the
+		// Dart client ask for a new token
+		status, code = http.StatusForbidden, "FORBIDDEN"
+	case errors.Is(err, service.ErrVersionExists):
+		status, code = http.StatusConflict, "VERSION_EXISTS"
+	case errors.Is(err, service.ErrPubspecInvalid):
+		status, code = http.StatusBadRequest, "INVALID_PUBSPEC"
+	case errors.Is(err, service.ErrQuotaExceeded):
+		status, code = http.StatusBadRequest, "QUOTA_EXCEEDED"
+	case errors.Is(err, service.ErrPackageMismatch):
+		status, code = http.StatusBadRequest, "PACKAGE_MISMATCH"
+	case errors.Is(err, service.ErrArchiveInvalid):
+		status, code = http.StatusBadRequest, "INVALID_ARCHIVE"
+	case errors.Is(err, service.ErrSDKNotAllowed):
+		status, code = http.StatusBadRequest, "SDK_NOT_ALLOWED"
+	case errors.Is(err, service.ErrPublishToMismatch):
+		status, code = http.StatusBadRequest, "PUBLISH_TO_MISMATCH"
+	case errors.Is(err, service.ErrInvalidReport):
+		status, code = http.StatusBadRequest, "INVALID_REPORT"
+	case errors.Is(err, service.ErrReportExists):
+		status, code = http.StatusConflict, "ALREADY_REPORTED"
+	case errors.Is(err, errArchiveTooLarge):
+		status, code = http.StatusRequestEntityTooLarge, "ARCHIVE_TOO_LARGE"
+	case errors.Is(err, errUploadMalformed):
+		status, code = http.StatusBadRequest, "INVALID_UPLOAD"
+	case errors.Is(err, service.ErrStorageUnavailable):
+		w.Header().Set("Retry-After", storageRetryAfter)
+		status, code = http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE"
+	case errors.Is(err, service.ErrUpstreamUnavailable):
+		status, code = http.StatusBadGateway, "UPSTREAM_UNAVAILABLE"
+	case errors.Is(err, service.ErrAdvisoriesUnavailable):
+		status, code = http.StatusBadGateway, "ADVISORIES_UNAVAILABLE"
+	case errors.Is(err, context.DeadlineExceeded):
+		// The request outlived HTTP_REQUEST_TIMEOUT
+		status, code = http.StatusServiceUnavailable, "TIMEOUT"
+	}
+
+	// Pubspec problems are also listed one by one for tooling
+	var invalid *pubspec.ValidationError
+	if errors.As(err, &invalid) {
+		writePubErrorBody(w, status, map[string]any{
+			"code":     code,
+			"message":  err.Error(),
+			"problems": invalid.Problems,
+		})
+		return
+	}
+	writePubError(w, status, code, err.Error())
+}
+
+// writePubError writes an error response in the pub JSON error format
+func writePubError(w http.ResponseWriter, status int, code, message string) {
+	writePubErrorBody(w, status, map[string]any{
+		"code":    code,
+		"message": message,
+	})
+}
+
+// writePubErrorBody writes body as the "error" object of a pub JSON error
+func writePubErrorBody(w http.ResponseWriter, status int, body map[string]any) {
+	response := map[string]interface{}{
+		"error": body,
+	}
+	w.Header().Set("Content-Type", pubV2ContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
@@ -0,0 +1,2513 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"repub/internal/auth"
+	"repub/internal/clock"
+	"repub/internal/config"
+	"repub/internal/domain"
+	"repub/internal/repository/storage"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"repub/web/templates"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGetPubspecYAMLHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, "test_package", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	pubspecYAML := "name: test_package\nversion: 1.0.0\n"
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: pubspecYAML,
+		ArchivePath: "/storage/test_package/1.0.0/test_package-1.0.0.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/pubspec.yaml", GetPubspecYAMLHandler(pubSvc))
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+	}{
+		{
+			name:           "existing version",
+			path:           "/api/packages/test_package/versions/1.0.0/pubspec.yaml",
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/yaml",
+			expectedBody:   pubspecYAML,
+		},
+		{
+			name:           "missing version",
+			path:           "/api/packages/test_package/versions/9.9.9/pubspec.yaml",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "NOT_FOUND",
+		},
+		{
+			name:           "missing package",
+			path:           "/api/packages/nonexistent/versions/1.0.0/pubspec.yaml",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedType, contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetArchiveSHA256Handler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: checked_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	sum := sha256.Sum256(archive)
+
+	// Versions recorded without a checksum have nothing to serve
+	pkg, err := repos.DB.CreateTestPackage(ctx, "unchecked_package", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: "name: unchecked_package\nversion: 1.0.0\n",
+		ArchivePath: "/storage/unchecked_package/1.0.0/unchecked_package-1.0.0.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/archive.sha256", GetArchiveSHA256Handler(pubSvc))
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+	}{
+		{
+			name:           "published version",
+			path:           "/api/packages/checked_package/versions/1.0.0/archive.sha256",
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/plain; charset=utf-8",
+			expectedBody:   hex.EncodeToString(sum[:]),
+		},
+		{
+			name:           "version without a checksum",
+			path:           "/api/packages/unchecked_package/versions/1.0.0/archive.sha256",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "NOT_FOUND",
+		},
+		{
+			name:           "missing version",
+			path:           "/api/packages/checked_package/versions/9.9.9/archive.sha256",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedType, contentType)
+			}
+			if tt.expectedStatus == http.StatusOK && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetVersionDependenciesHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, "mixed_deps", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	pubspecYAML := `name: mixed_deps
+version: 1.0.0
+dependencies:
+  flutter:
+    sdk: flutter
+  http: ^1.0.0
+  internal_client:
+    hosted: https://pub.example.com
+    version: ">=2.0.0 <3.0.0"
+  forked:
+    git:
+      url: https://github.com/example/forked.git
+      ref: v2
+      path: packages/forked
+  shorthand:
+    git: https://github.com/example/shorthand.git
+  local:
+    path: ../local
+dev_dependencies:
+  test: ^1.24.0
+`
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: pubspecYAML,
+		ArchivePath: "/storage/mixed_deps/1.0.0/mixed_deps-1.0.0.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/dependencies", GetVersionDependenciesHandler(pubSvc))
+
+	t.Run("mixed sources", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/packages/mixed_deps/versions/1.0.0/dependencies", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", contentType)
+		}
+
+		var resp domain.VersionDependencies
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Package != "mixed_deps" || resp.Version != "1.0.0" {
+			t.Errorf("Unexpected package/version %s/%s", resp.Package, resp.Version)
+		}
+
+		expected := map[string]domain.Dependency{
+			"flutter":         {Source: "sdk", SDK: "flutter"},
+			"http":            {Source: "hosted", Version: "^1.0.0"},
+			"internal_client": {Source: "hosted", Hosted: "https://pub.example.com", Version: ">=2.0.0 <3.0.0"},
+			"forked": {Source: "git", Git: &domain.GitDependency{
+				URL: "https://github.com/example/forked.git", Ref: "v2", Path: "packages/forked",
+			}},
+			"shorthand": {Source: "git", Git: &domain.GitDependency{URL: "https://github.com/example/shorthand.git"}},
+			"local":     {Source: "path", Path: "../local"},
+		}
+		if len(resp.Dependencies) != len(expected) {
+			t.Errorf("Expected %d dependencies, got %d", len(expected), len(resp.Dependencies))
+		}
+		for name, want := range expected {
+			got := resp.Dependencies[name]
+			if got == nil {
+				t.Errorf("Missing dependency %s", name)
+				continue
+			}
+			if got.Source != want.Source || got.Version != want.Version || got.Hosted != want.Hosted ||
+				got.Path != want.Path || got.SDK != want.SDK {
+				t.Errorf("Dependency %s: expected %+v, got %+v", name, want, *got)
+			}
+			if (got.Git == nil) != (want.Git == nil) || (got.Git != nil && *got.Git != *want.Git) {
+				t.Errorf("Dependency %s: expected git %+v, got %+v", name, want.Git, got.Git)
+			}
+		}
+
+		if len(resp.DevDependencies) != 1 {
+			t.Fatalf("Expected 1 dev dependency, got %d", len(resp.DevDependencies))
+		}
+		if dep := resp.DevDependencies["test"]; dep == nil || dep.Source != "hosted" || dep.Version != "^1.24.0" {
+			t.Errorf("Unexpected dev dependency test: %+v", dep)
+		}
+		if _, ok := resp.Dependencies["test"]; ok {
+			t.Error("Dev dependencies must not be listed as dependencies")
+		}
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/packages/mixed_deps/versions/9.9.9/dependencies", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestGetReadmeHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, "test_package", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	readme := "# Test Package\n\nUse it **wisely**.\n\n<script>alert('xss')</script>\n"
+	for version, versionReadme := range map[string]*string{"1.0.0": &readme, "0.1.0": nil} {
+		_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+			Version:     version,
+			PubspecYaml: "name: test_package\nversion: " + version + "\n",
+			Readme:      versionReadme,
+			ArchivePath: "/storage/test_package/" + version + "/test_package-" + version + ".tar.gz",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/readme", GetReadmeHandler(pubSvc))
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+		forbiddenBody  string
+	}{
+		{
+			name:           "markdown",
+			path:           "/api/packages/test_package/versions/1.0.0/readme",
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/markdown; charset=utf-8",
+			expectedBody:   readme,
+		},
+		{
+			name:           "html",
+			path:           "/api/packages/test_package/versions/1.0.0/readme?format=html",
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/html; charset=utf-8",
+			expectedBody:   "<strong>wisely</strong>",
+			forbiddenBody:  "<script",
+		},
+		{
+			name:           "version without readme",
+			path:           "/api/packages/test_package/versions/0.1.0/readme",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "has no README",
+		},
+		{
+			name:           "missing version",
+			path:           "/api/packages/test_package/versions/9.9.9/readme",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "NOT_FOUND",
+		},
+		{
+			name:           "unknown format",
+			path:           "/api/packages/test_package/versions/1.0.0/readme?format=pdf",
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "application/vnd.pub.v2+json",
+			expectedBody:   "INVALID_REQUEST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedType, contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, w.Body.String())
+			}
+			if tt.forbiddenBody != "" && strings.Contains(w.Body.String(), tt.forbiddenBody) {
+				t.Errorf("Expected body not to contain %q, got %s", tt.forbiddenBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetLatestVersionHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	versions := map[string][]string{
+		"test_package":   {"1.0.0", "1.2.0", "2.0.0-dev.1"},
+		"retracted_only": {"1.0.0"},
+	}
+	for name, packageVersions := range versions {
+		pkg, err := repos.DB.CreateTestPackage(ctx, name, false)
+		if err != nil {
+			t.Fatalf("Failed to create package: %v", err)
+		}
+		for _, version := range packageVersions {
+			_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+				Version:     version,
+				PubspecYaml: "name: " + name + "\nversion: " + version + "\n",
+				ArchivePath: "/storage/" + name + "/" + version + "/" + name + "-" + version + ".tar.gz",
+			})
+			if err != nil {
+				t.Fatalf("Failed to create version: %v", err)
+			}
+		}
+	}
+	for name, version := range map[string]string{"test_package": "1.2.0", "retracted_only": "1.0.0"} {
+		if _, err := pubSvc.SetVersionRetracted(ctx, name, version, true, domain.Actor{Admin: true}); err != nil {
+			t.Fatalf("Failed to retract version: %v", err)
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/latest", GetLatestVersionHandler(pubSvc))
+
+	tests := []struct {
+		name            string
+		path            string
+		expectedStatus  int
+		expectedVersion string
+		expectedBody    string
+	}{
+		{
+			name:            "skips retracted and pre-release versions",
+			path:            "/api/packages/test_package/latest",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: "1.0.0",
+		},
+		{
+			name:           "no unretracted versions",
+			path:           "/api/packages/retracted_only/latest",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "NOT_FOUND",
+		},
+		{
+			name:           "missing package",
+			path:           "/api/packages/nonexistent/latest",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/vnd.pub.v2+json" {
+				t.Errorf("Expected pub content type, got %s", contentType)
+			}
+			if tt.expectedVersion != "" {
+				var resp domain.VersionResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if resp.Version != tt.expectedVersion {
+					t.Errorf("Expected version %s, got %s", tt.expectedVersion, resp.Version)
+				}
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLikeAndScoreHandlers(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	if _, err := repos.DB.CreateTestPackage(context.Background(), "test_package", false); err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/score", GetScoreHandler(pubSvc))
+	router.Post("/api/packages/{package}/like", LikePackageHandler(pubSvc))
+
+	like := func(subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/packages/test_package/like", nil)
+		if subject != "" {
+			req = req.WithContext(auth.SetSubject(req.Context(), subject))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := like(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without subject, got %d", w.Code)
+	}
+
+	// Same token liking twice only counts once
+	for _, subject := range []string{"token-a", "token-a", "token-b"} {
+		if w := like(subject); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/packages/test_package/score", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var score domain.ScoreResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &score); err != nil {
+		t.Fatalf("Failed to decode score: %v", err)
+	}
+	if score.LikeCount != 2 {
+		t.Errorf("Expected like count 2, got %d", score.LikeCount)
+	}
+
+	req = httptest.NewRequest("GET", "/api/packages/nonexistent/score", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing package, got %d", w.Code)
+	}
+}
+
+func TestGetDownloadMetricsHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	if _, err := repos.DB.CreateTestPackage(context.Background(), "test_package", false); err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/metrics", GetDownloadMetricsHandler(pubSvc))
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedDays int
+	}{
+		{"default window", "/api/packages/test_package/metrics", http.StatusOK, 30},
+		{"custom window", "/api/packages/test_package/metrics?days=7", http.StatusOK, 7},
+		{"invalid days", "/api/packages/test_package/metrics?days=0", http.StatusBadRequest, 0},
+		{"too many days", "/api/packages/test_package/metrics?days=1000", http.StatusBadRequest, 0},
+		{"missing package", "/api/packages/nonexistent/metrics", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var metrics domain.DownloadMetrics
+			if err := json.Unmarshal(w.Body.Bytes(), &metrics); err != nil {
+				t.Fatalf("Failed to decode metrics: %v", err)
+			}
+			if len(metrics.Series) != tt.expectedDays {
+				t.Errorf("Expected %d days, got %d", tt.expectedDays, len(metrics.Series))
+			}
+		})
+	}
+}
+
+func TestPrivatePackageAccess(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: secret_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	authSvc := service.NewAuthService(nil, nil, []config.Token{{Name: "ADMIN", Value: "admin-token"}}, nil)
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+	router.Put("/api/packages/{package}/privacy", SetPackagePrivacyHandler(pubSvc, authSvc))
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+
+	do := func(method, path, body string, authenticated bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if authenticated {
+			req = addAuthToContext(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/packages/secret_package", "", false); w.Code != http.StatusOK {
+		t.Errorf("Expected public package to be readable anonymously, got %d", w.Code)
+	}
+
+	if w := do("PUT", "/api/packages/secret_package/privacy", `{"private": true}`, true); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 marking package private, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		authenticated  bool
+		expectedStatus int
+	}{
+		{"anonymous metadata", "/api/packages/secret_package", false, http.StatusNotFound},
+		{"anonymous download", "/packages/secret_package/versions/1.0.0/download", false, http.StatusNotFound},
+		{"authenticated metadata", "/api/packages/secret_package", true, http.StatusOK},
+		{"authenticated download", "/packages/secret_package/versions/1.0.0/download", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do("GET", tt.path, "", tt.authenticated); w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if w := do("PUT", "/api/packages/secret_package/privacy", "not json", true); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid body, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/packages/nonexistent/privacy", `{"private": true}`, true); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing package, got %d", w.Code)
+	}
+	if w := do("GET", "/packages/secret_package/versions/9.9.9/download", "", true); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NOT_FOUND") {
+		t.Errorf("Expected NOT_FOUND for missing version, got %d: %s", w.Code, w.Body.String())
+	}
+
+	asOther := func(token string) *httptest.ResponseRecorder {
+		req := addAuthToContext(httptest.NewRequest("PUT", "/api/packages/secret_package/privacy", strings.NewReader(`{"private": false}`)))
+		req = req.WithContext(auth.SetIdentity(req.Context(), "mallory@example.com"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := asOther("write-token"); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for a non-uploader, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/packages/secret_package", "", false); w.Code != http.StatusNotFound {
+		t.Errorf("Expected package to stay private after a rejected change, got %d", w.Code)
+	}
+	if w := asOther("admin-token"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for an admin, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/packages/secret_package", "", false); w.Code != http.StatusOK {
+		t.Errorf("Expected admin to make the package public, got %d", w.Code)
+	}
+
+	if w := do("PUT", "/api/packages/secret_package/privacy", `{"private": false}`, true); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 marking package public, got %d", w.Code)
+	}
+	if w := do("GET", "/api/packages/secret_package", "", false); w.Code != http.StatusOK {
+		t.Errorf("Expected package to be public again, got %d", w.Code)
+	}
+}
+
+func TestCacheControlHeaders(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	staticDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(staticDir, "styles.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatalf("Failed to write static file: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+	router.Handle("/static/*", http.StripPrefix("/static/", StaticHandler(staticDir)))
+
+	tests := []struct {
+		name                 string
+		path                 string
+		expectedStatus       int
+		expectedCacheControl string
+	}{
+		{"archive download", "/packages/test_package/versions/1.0.0/download", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"missing archive is not cached", "/packages/test_package/versions/9.9.9/download", http.StatusNotFound, ""},
+		{"static file", "/static/styles.css", http.StatusOK, "public, max-age=86400"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.expectedCacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.expectedCacheControl, got)
+			}
+		})
+	}
+}
+
+func TestDownloadPackageHandler_TarGzAlias(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package:          repos.DB.Repo,
+		Storage:          repos.StorageSvc,
+		Pubspec:          repos.PubspecSvc,
+		BaseURL:          "http://localhost:9090",
+		TarGzArchiveURLs: true,
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+	router.Get("/packages/{package}/versions/{version}/archive.tar.gz", DownloadPackageHandler(pubSvc))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/api/packages/test_package")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var pkg domain.PackageResponse
+	if err := json.NewDecoder(w.Body).Decode(&pkg); err != nil {
+		t.Fatalf("Failed to decode package response: %v", err)
+	}
+	expectedURL := "http://localhost:9090/packages/test_package/versions/1.0.0/archive.tar.gz"
+	if pkg.Latest.ArchiveURL != expectedURL {
+		t.Fatalf("Expected archive URL %s, got %s", expectedURL, pkg.Latest.ArchiveURL)
+	}
+
+	alias := get(strings.TrimPrefix(pkg.Latest.ArchiveURL, "http://localhost:9090"))
+	download := get("/packages/test_package/versions/1.0.0/download")
+	if alias.Code != http.StatusOK || download.Code != http.StatusOK {
+		t.Fatalf("Expected both routes to return 200, got %d and %d", alias.Code, download.Code)
+	}
+	if alias.Body.String() != download.Body.String() {
+		t.Error("Expected both routes to serve the same archive")
+	}
+	for _, header := range []string{"Content-Type", "Content-Disposition", "Cache-Control"} {
+		if alias.Header().Get(header) != download.Header().Get(header) {
+			t.Errorf("Expected matching %s headers, got %q and %q", header, alias.Header().Get(header), download.Header().Get(header))
+		}
+	}
+
+	if w := get("/packages/test_package/versions/9.9.9/archive.tar.gz"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing version, got %d", w.Code)
+	}
+}
+
+func TestDownloadPackageHandler_ETag(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+
+	download := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/packages/test_package/versions/1.0.0/download", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := download("")
+	if first.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	sum := sha256.Sum256(archive)
+	if expected := `"` + hex.EncodeToString(sum[:]) + `"`; etag != expected {
+		t.Fatalf("Expected ETag %s, got %s", expected, etag)
+	}
+
+	tests := []struct {
+		name           string
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{"matching etag", etag, http.StatusNotModified},
+		{"weak matching etag", "W/" + etag, http.StatusNotModified},
+		{"etag in list", `"other", ` + etag, http.StatusNotModified},
+		{"wildcard", "*", http.StatusNotModified},
+		{"stale etag", `"other"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := download(tt.ifNoneMatch)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, w.Header().Get("ETag"))
+			}
+			if tt.expectedStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected no body with 304, got %d bytes", w.Body.Len())
+			}
+			if tt.expectedStatus == http.StatusOK && w.Body.String() != string(archive) {
+				t.Error("Expected the archive body")
+			}
+		})
+	}
+}
+
+func TestDownloadPackageHandler_Head(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	// A version recorded before archive sizes were, HEAD has to open its archive
+	legacy, err := repos.DB.CreateTestPackage(ctx, "legacy_package", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	legacyArchive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: legacy_package\nversion: 1.0.0",
+	})
+	_, err = repos.DB.CreateTestPackageVersion(ctx, legacy.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: "name: legacy_package\nversion: 1.0.0",
+		ArchivePath: repos.CreateTestArchive(t, "legacy_package", "1.0.0", legacyArchive),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+	router.Head("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+
+	tests := []struct {
+		name    string
+		pkg     string
+		archive []byte
+	}{
+		{"recorded size", "test_package", archive},
+		{"legacy version", "legacy_package", legacyArchive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/packages/" + tt.pkg + "/versions/1.0.0/download"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("HEAD", path, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %d bytes", w.Body.Len())
+			}
+			if expected := strconv.Itoa(len(tt.archive)); w.Header().Get("Content-Length") != expected {
+				t.Errorf("Expected Content-Length %s, got %q", expected, w.Header().Get("Content-Length"))
+			}
+
+			// The headers match those of a download
+			get := httptest.NewRecorder()
+			router.ServeHTTP(get, httptest.NewRequest("GET", path, nil))
+			for _, header := range []string{"Content-Type", "ETag", "Last-Modified", "Cache-Control"} {
+				// Legacy versions have no recorded checksum and so no ETag
+				if header != "ETag" && get.Header().Get(header) == "" {
+					t.Errorf("Expected GET to set %s", header)
+				}
+				if w.Header().Get(header) != get.Header().Get(header) {
+					t.Errorf("Expected %s %q, got %q", header, get.Header().Get(header), w.Header().Get(header))
+				}
+			}
+		})
+	}
+
+	// Only the GETs above count as downloads
+	score, err := pubSvc.GetScore(ctx, "test_package")
+	if err != nil {
+		t.Fatalf("GetScore failed: %v", err)
+	}
+	if score.DownloadCount != 1 {
+		t.Errorf("Expected 1 download, got %d", score.DownloadCount)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/packages/test_package/versions/9.9.9/download", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing version, got %d", w.Code)
+	}
+}
+
+func TestDownloadPackageHandler_ContentDisposition(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0+build.1",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(pubSvc))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/packages/test_package/versions/1.0.0+build.1/download", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	disposition, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+	if err != nil {
+		t.Fatalf("Failed to parse Content-Disposition %q: %v", w.Header().Get("Content-Disposition"), err)
+	}
+	if disposition != "attachment" || params["filename"] != "test_package-1.0.0+build.1.tar.gz" {
+		t.Errorf("Expected the archive as an attachment, got %s %v", disposition, params)
+	}
+
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"pkg-1.0.0+build.1.tar.gz", "attachment; filename=pkg-1.0.0+build.1.tar.gz; filename*=UTF-8''pkg-1.0.0+build.1.tar.gz"},
+		{"a b.tar.gz", `attachment; filename="a b.tar.gz"; filename*=UTF-8''a%20b.tar.gz`},
+		{"evil\"\r\nSet-Cookie: x.tar.gz", `attachment; filename="evil___Set-Cookie: x.tar.gz"; filename*=UTF-8''evil%22%0D%0ASet-Cookie%3A%20x.tar.gz`},
+		{"caf\u00e9.tar.gz", "attachment; filename=caf_.tar.gz; filename*=UTF-8''caf%C3%A9.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := attachmentDisposition(tt.filename); got != tt.expected {
+			t.Errorf("attachmentDisposition(%q) = %s, expected %s", tt.filename, got, tt.expected)
+		}
+	}
+}
+
+func TestScreenshotHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml":         "name: pictured\nversion: 1.0.0\nscreenshots:\n  - description: Home\n    path: screenshots/home.png",
+		"screenshots/home.png": "fake png bytes",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages/{package}/versions/{version}/screenshots/*", ScreenshotHandler(pubSvc))
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"declared screenshot", "/packages/pictured/versions/1.0.0/screenshots/screenshots/home.png", http.StatusOK},
+		{"other archive file", "/packages/pictured/versions/1.0.0/screenshots/pubspec.yaml", http.StatusNotFound},
+		{"unknown version", "/packages/pictured/versions/2.0.0/screenshots/screenshots/home.png", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if w.Header().Get("Content-Type") != "image/png" || w.Body.String() != "fake png bytes" {
+				t.Errorf("Unexpected screenshot response %s %q", w.Header().Get("Content-Type"), w.Body.String())
+			}
+			if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Error("Expected nosniff header")
+			}
+		})
+	}
+}
+
+func TestDownloadPackageHandler_Range(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	deps := service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	}
+	pubSvc := service.NewPubService(deps)
+
+	// Random content keeps the compressed archive well over 100 bytes
+	padding := make([]byte, 512)
+	if _, err := rand.Read(padding); err != nil {
+		t.Fatalf("Failed to generate padding: %v", err)
+	}
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+		"lib/data.txt": hex.EncodeToString(padding),
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	// Storage that can't seek is served from memory
+	unseekable := deps
+	unseekable.Storage = struct{ storage.Repository }{repos.StorageSvc}
+
+	sum := sha256.Sum256(archive)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	tests := []struct {
+		name           string
+		pubSvc         service.PubService
+		ifRange        string
+		expectedStatus int
+		expectedBody   []byte
+	}{
+		{"seekable storage", pubSvc, "", http.StatusPartialContent, archive[:100]},
+		{"unseekable storage", service.NewPubService(unseekable), "", http.StatusPartialContent, archive[:100]},
+		{"matching if-range", pubSvc, etag, http.StatusPartialContent, archive[:100]},
+		{"stale if-range", pubSvc, `"other"`, http.StatusOK, archive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := chi.NewRouter()
+			router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(tt.pubSvc))
+
+			req := httptest.NewRequest("GET", "/packages/test_package/versions/1.0.0/download", nil)
+			req.Header.Set("Range", "bytes=0-99")
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Body.String() != string(tt.expectedBody) {
+				t.Errorf("Expected %d bytes of the archive, got %d bytes", len(tt.expectedBody), w.Body.Len())
+			}
+			if tt.expectedStatus == http.StatusPartialContent {
+				if contentRange := w.Header().Get("Content-Range"); contentRange != fmt.Sprintf("bytes 0-99/%d", len(archive)) {
+					t.Errorf("Unexpected Content-Range %q", contentRange)
+				}
+			}
+			if w.Header().Get("Content-Type") != "application/octet-stream" {
+				t.Errorf("Expected octet-stream content type, got %s", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+// unavailableStorage fails every read as if the backend were down
+type unavailableStorage struct {
+	storage.Repository
+}
+
+func (unavailableStorage) Get(ctx context.Context, path string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestDownloadPackageHandler_StorageErrors(t *testing.T) {
+	ctx := context.Background()
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	deps := service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	}
+	pubSvc := service.NewPubService(deps)
+
+	for _, spec := range []string{"name: kept\nversion: 1.0.0", "name: lost\nversion: 1.0.0"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": spec})
+		if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+			t.Fatalf("Failed to publish package: %v", err)
+		}
+	}
+
+	// Remove one archive from storage behind the database's back
+	lost, err := pubSvc.GetPackageDetail(context.Background(), "lost")
+	if err != nil {
+		t.Fatalf("Failed to get package detail: %v", err)
+	}
+	if err := repos.StorageSvc.Delete(ctx, lost.Latest.ArchivePath); err != nil {
+		t.Fatalf("Failed to delete archive: %v", err)
+	}
+
+	unavailable := deps
+	unavailable.Storage = unavailableStorage{repos.StorageSvc}
+
+	tests := []struct {
+		name               string
+		pubSvc             service.PubService
+		path               string
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{"storage down", service.NewPubService(unavailable), "/packages/kept/versions/1.0.0/download", http.StatusServiceUnavailable, "30"},
+		{"archive missing from storage", pubSvc, "/packages/lost/versions/1.0.0/download", http.StatusNotFound, ""},
+		{"unknown version", pubSvc, "/packages/kept/versions/9.9.9/download", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := chi.NewRouter()
+			router.Get("/packages/{package}/versions/{version}/download", DownloadPackageHandler(tt.pubSvc))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.expectedRetryAfter {
+				t.Errorf("Expected Retry-After %q, got %q", tt.expectedRetryAfter, got)
+			}
+			if got := w.Header().Get("ETag"); got != "" {
+				t.Errorf("Expected no ETag on an error, got %q", got)
+			}
+		})
+	}
+}
+
+// createVersions adds count versions to a new package directly in the
+// database, faster than publishing archives
+func createVersions(tb testing.TB, repos *testutil.TestRepositories, name string, count int) {
+	tb.Helper()
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, name, false)
+	if err != nil {
+		tb.Fatalf("Failed to create package: %v", err)
+	}
+	for i := range count {
+		version := "1.0." + strconv.Itoa(i)
+		size := int64(1024)
+		_, err := repos.DB.Repo.CreateVersion(ctx, &domain.PackageVersion{
+			PackageID:   pkg.ID,
+			Version:     version,
+			Description: testutil.StringPtr("Version " + version + " of " + name),
+			PubspecYaml: "name: " + name + "\nversion: " + version + "\ndescription: <b>streamed</b>",
+			ArchivePath: name + "/" + version + ".tar.gz",
+			SizeBytes:   &size,
+		})
+		if err != nil {
+			tb.Fatalf("Failed to create version %s: %v", version, err)
+		}
+	}
+}
+
+func TestGetPackageHandler_Streamed(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	createVersions(t, repos, "streamed", 3)
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/packages/streamed", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The body is byte for byte what encoding the whole response gives
+	pkg, err := pubSvc.GetPackage(context.Background(), "streamed")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	var expected bytes.Buffer
+	if err := json.NewEncoder(&expected).Encode(pkg); err != nil {
+		t.Fatalf("Failed to encode package: %v", err)
+	}
+	if w.Body.String() != expected.String() {
+		t.Errorf("Expected body\n%s\ngot\n%s", expected.String(), w.Body.String())
+	}
+}
+
+func TestEncodePackage(t *testing.T) {
+	versions := []domain.VersionResponse{{Version: "2.0.0"}, {Version: "1.0.0", Retracted: true}}
+	for _, pkg := range []domain.PackageResponse{
+		{Name: "plain", Latest: versions[0], Versions: versions},
+		{Name: "discontinued", IsDiscontinued: true, Latest: versions[0], Versions: versions},
+		{Name: "empty", Latest: versions[0], Versions: []domain.VersionResponse{}},
+	} {
+		var expected bytes.Buffer
+		if err := json.NewEncoder(&expected).Encode(pkg); err != nil {
+			t.Fatalf("Failed to encode package: %v", err)
+		}
+
+		all := pkg.Versions
+		var got bytes.Buffer
+		err := encodePackage(&got, &pkg, func(fn func(*domain.VersionResponse) error) error {
+			for i := range all {
+				if err := fn(&all[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("encodePackage failed: %v", err)
+		}
+		if got.String() != expected.String() {
+			t.Errorf("Expected\n%s\ngot\n%s", expected.String(), got.String())
+		}
+	}
+}
+
+// peakHeapWriter is a ResponseWriter discarding the body that records the
+// highest heap size seen while the response is written
+type peakHeapWriter struct {
+	header   http.Header
+	unsample int
+	peak     uint64
+}
+
+func (w *peakHeapWriter) Header() http.Header { return w.header }
+
+func (w *peakHeapWriter) WriteHeader(int) {}
+
+func (w *peakHeapWriter) Write(p []byte) (int, error) {
+	// Reading memory stats stops the world, so only sample every 64 KiB
+	if w.unsample -= len(p); w.unsample <= 0 {
+		w.sample()
+		w.unsample = 64 << 10
+	}
+	return len(p), nil
+}
+
+func (w *peakHeapWriter) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	w.peak = max(w.peak, stats.HeapAlloc)
+}
+
+// BenchmarkGetPackageHandler compares the peak heap of encoding a package
+// with 5000 versions at once against streaming its versions a page at a time
+func BenchmarkGetPackageHandler(b *testing.B) {
+	repos := testutil.SetupTestRepositories(b)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	createVersions(b, repos, "huge", 5000)
+
+	streamed := chi.NewRouter()
+	streamed.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+	buffered := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pkg, err := pubSvc.GetPackage(r.Context(), "huge")
+		if err != nil {
+			b.Fatalf("GetPackage failed: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(pkg)
+	})
+
+	for _, bm := range []struct {
+		name    string
+		handler http.Handler
+	}{
+		{"buffered", buffered},
+		{"streamed", streamed},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var peak uint64
+			for b.Loop() {
+				runtime.GC()
+				w := &peakHeapWriter{header: make(http.Header)}
+				bm.handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/packages/huge", nil))
+				w.sample()
+				peak = max(peak, w.peak)
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
+
+func TestGetPackagesBatchHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	for _, spec := range []string{"name: first\nversion: 1.0.0", "name: second\nversion: 2.0.0"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": spec})
+		if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+			t.Fatalf("Failed to publish package: %v", err)
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Post("/api/packages/batch", GetPackagesBatchHandler(pubSvc))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/packages/batch", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"packages": ["first", "missing", "second", "first"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp domain.BatchPackagesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode batch response: %v", err)
+	}
+	if len(resp.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(resp.Packages))
+	}
+	if pkg := resp.Packages["first"]; pkg == nil || pkg.Latest.Version != "1.0.0" {
+		t.Errorf("Expected first at 1.0.0, got %+v", pkg)
+	}
+	if pkg := resp.Packages["second"]; pkg == nil || pkg.Latest.Version != "2.0.0" {
+		t.Errorf("Expected second at 2.0.0, got %+v", pkg)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != "missing" {
+		t.Errorf("Expected not_found [missing], got %v", resp.NotFound)
+	}
+
+	tooMany, _ := json.Marshal(domain.BatchPackagesRequest{Packages: make([]string, maxBatchPackages+1)})
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", "not json"},
+		{"too many packages", string(tooMany)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post(tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestNewPackageVersionHandler_Preflight(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package:                 repos.DB.Repo,
+		Storage:                 repos.StorageSvc,
+		Pubspec:                 repos.PubspecSvc,
+		BaseURL:                 "http://localhost:9090",
+		MaxTotalBytesPerPackage: 1 << 20,
+		ReservedPackageNames:    []string{"flutter"},
+		BlockedPackageNames:     []string{"evil_*"},
+	})
+
+	ctx := context.Background()
+	for name, uploader := range map[string]string{"own_package": "authenticated-user", "other_package": "someone-else"} {
+		pkg, err := repos.DB.CreateTestPackage(ctx, name, false)
+		if err != nil {
+			t.Fatalf("Failed to create package: %v", err)
+		}
+		if err := repos.DB.Repo.AddUploader(ctx, pkg.ID, uploader); err != nil {
+			t.Fatalf("Failed to add uploader: %v", err)
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/versions/new", NewPackageVersionHandler(pubSvc, "http://localhost:9090"))
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"no hints", "", http.StatusOK, ""},
+		{"new package", "?package=new_package&size=1024", http.StatusOK, ""},
+		{"own package", "?package=own_package&size=1024", http.StatusOK, ""},
+		{"reserved name", "?package=Flutter", http.StatusForbidden, "PACKAGE_RESERVED"},
+		{"blocked name", "?package=evil_http", http.StatusForbidden, "PACKAGE_BLOCKED"},
+		{"other uploader's package", "?package=other_package", http.StatusForbidden, "FORBIDDEN"},
+		{"over quota", "?package=own_package&size=2097152", http.StatusBadRequest, "QUOTA_EXCEEDED"},
+		{"invalid size", "?package=own_package&size=big", http.StatusBadRequest, "INVALID_REQUEST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/packages/versions/new"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedCode != "" && !strings.Contains(w.Body.String(), tt.expectedCode) {
+				t.Errorf("Expected error code %s, got %s", tt.expectedCode, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(w.Body.String(), "/api/packages/versions/new") {
+				t.Errorf("Expected upload URL in response, got %s", w.Body.String())
+			}
+		})
+	}
+
+	t.Run("package hint is echoed as an upload field", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/packages/versions/new?package=new_package", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Fields map[string]string `json:"fields"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Fields["package"] != "new_package" {
+			t.Errorf("Expected package field new_package, got %v", resp.Fields)
+		}
+	})
+
+	t.Run("dry runs are advertised and nothing is created", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/packages/versions/new?package=dry_run_package&size=1024", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			ValidateURL string `json:"validate_url"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.ValidateURL != "http://localhost:9090/api/packages/versions/validate" {
+			t.Errorf("Expected the validate URL, got %q", resp.ValidateURL)
+		}
+
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "dry_run_package")
+		if err != nil || pkg != nil {
+			t.Errorf("Expected no package to be created, got %+v, %v", pkg, err)
+		}
+	})
+}
+
+func TestGeneratedURLs_UseBaseURL(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	// Behind a proxy requests arrive for an internal host, and a trailing
+	// slash on the base URL must not double up
+	const baseURL = "https://pub.example.com/"
+	const internalHost = "10.0.0.5:9090"
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: baseURL,
+	})
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/versions/new", NewPackageVersionHandler(pubSvc, baseURL))
+	router.Post("/api/packages/versions/new", UploadPackageHandler(pubSvc, baseURL, clock.Real()))
+	router.Get("/api/packages/versions/newUploadFinish", FinalizeUploadHandler(pubSvc))
+	router.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+
+	do := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req := addAuthToContext(httptest.NewRequest(method, path, bytes.NewReader(body)))
+		req.Host = internalHost
+		if body != nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var urls []string
+
+	w := do("GET", "/api/packages/versions/new", nil)
+	var form struct {
+		URL         string `json:"url"`
+		ValidateURL string `json:"validate_url"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&form); err != nil {
+		t.Fatalf("Failed to decode new version response: %v", err)
+	}
+	urls = append(urls, form.URL, form.ValidateURL)
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: proxied_host\nversion: 1.0.0",
+	})
+	w = do("POST", form.URL, archive)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected upload status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	finalizeURL := w.Header().Get("Location")
+	urls = append(urls, finalizeURL)
+
+	if w := do("GET", finalizeURL, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected finalize status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var pkg domain.PackageResponse
+	if err := json.NewDecoder(do("GET", "/api/packages/proxied_host", nil).Body).Decode(&pkg); err != nil {
+		t.Fatalf("Failed to decode package: %v", err)
+	}
+	urls = append(urls, pkg.Latest.ArchiveURL)
+	for _, v := range pkg.Versions {
+		urls = append(urls, v.ArchiveURL)
+	}
+
+	published, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{
+		Archive:  testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: proxied_host\nversion: 1.1.0"}),
+		Uploader: "authenticated-user",
+	})
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	urls = append(urls, published.URL)
+
+	for _, u := range urls {
+		rest, ok := strings.CutPrefix(u, baseURL)
+		if !ok || strings.HasPrefix(rest, "/") {
+			t.Errorf("Expected %q to start with the base URL %s", u, baseURL)
+		}
+	}
+}
+
+func TestGetPackageOptionsHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	if _, err := repos.DB.CreateTestPackage(context.Background(), "test_package", false); err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/options", GetPackageOptionsHandler(pubSvc))
+
+	req := httptest.NewRequest("GET", "/api/packages/test_package/options", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode options: %v", err)
+	}
+
+	expected := map[string]any{
+		"isDiscontinued": false,
+		"replacedBy":     nil,
+		"isUnlisted":     false,
+	}
+	if len(body) != len(expected) {
+		t.Errorf("Expected keys %v, got %v", expected, body)
+	}
+	for key, value := range expected {
+		got, ok := body[key]
+		if !ok {
+			t.Errorf("Expected key %q in options response", key)
+		} else if got != value {
+			t.Errorf("Expected %q to be %v, got %v", key, value, got)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/packages/nonexistent/options", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing package, got %d", w.Code)
+	}
+}
+
+func TestGetUploadersHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+	authSvc := service.NewAuthService(
+		[]config.Token{{Name: "READ", Value: "read-token"}},
+		nil,
+		[]config.Token{{Name: "ADMIN", Value: "admin-token"}},
+		nil,
+	)
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: owned_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice@example.com"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	do := func(public bool, path, token, identity string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Get("/api/packages/{package}/uploaders", GetUploadersHandler(pubSvc, authSvc, public))
+
+		req := addAuthToContext(httptest.NewRequest("GET", path, nil))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if identity != "" {
+			req = req.WithContext(auth.SetIdentity(req.Context(), identity))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		public         bool
+		path           string
+		token          string
+		identity       string
+		expectedStatus int
+	}{
+		{"uploader", false, "/api/packages/owned_package/uploaders", "read-token", "alice@example.com", http.StatusOK},
+		{"admin", false, "/api/packages/owned_package/uploaders", "admin-token", "", http.StatusOK},
+		{"other caller", false, "/api/packages/owned_package/uploaders", "read-token", "bob@example.com", http.StatusForbidden},
+		{"caller without identity", false, "/api/packages/owned_package/uploaders", "read-token", "", http.StatusForbidden},
+		{"public", true, "/api/packages/owned_package/uploaders", "read-token", "bob@example.com", http.StatusOK},
+		{"missing package", false, "/api/packages/nonexistent/uploaders", "admin-token", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.public, tt.path, tt.token, tt.identity)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			switch w.Code {
+			case http.StatusOK:
+				var body domain.UploadersResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Failed to decode uploaders: %v", err)
+				}
+				if body.Package != "owned_package" || len(body.Uploaders) != 1 || body.Uploaders[0] != "alice@example.com" {
+					t.Errorf("Expected alice@example.com as the only uploader, got %+v", body)
+				}
+			case http.StatusForbidden:
+				if !strings.Contains(w.Body.String(), "FORBIDDEN") || strings.Contains(w.Body.String(), "alice@example.com") {
+					t.Errorf("Expected a FORBIDDEN error without the uploaders, got %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRetractUnretractRoundTrip(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "authenticated-user"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	authSvc := service.NewAuthService(nil, nil, []config.Token{{Name: "ADMIN", Value: "admin-token"}}, nil)
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}", GetPackageHandler(pubSvc))
+	router.Post("/api/packages/{package}/versions/{version}/retract", RetractVersionHandler(pubSvc, authSvc))
+	router.Post("/api/packages/{package}/versions/{version}/unretract", UnretractVersionHandler(pubSvc, authSvc))
+
+	retracted := func() bool {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/packages/test_package", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var pkg domain.PackageResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &pkg); err != nil {
+			t.Fatalf("Failed to decode package: %v", err)
+		}
+		return pkg.Versions[0].Retracted
+	}
+
+	post := func(path string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if retracted() {
+		t.Fatal("Expected version to start unretracted")
+	}
+
+	if code := post("/api/packages/test_package/versions/1.0.0/retract"); code != http.StatusOK {
+		t.Fatalf("Expected status 200 retracting, got %d", code)
+	}
+	if !retracted() {
+		t.Error("Expected version to be retracted")
+	}
+
+	if code := post("/api/packages/test_package/versions/1.0.0/unretract"); code != http.StatusOK {
+		t.Fatalf("Expected status 200 unretracting, got %d", code)
+	}
+	if retracted() {
+		t.Error("Expected version to be unretracted")
+	}
+
+	if code := post("/api/packages/test_package/versions/9.9.9/unretract"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing version, got %d", code)
+	}
+
+	asOther := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req = req.WithContext(auth.SetIdentity(req.Context(), "mallory@example.com"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	for _, path := range []string{"/api/packages/test_package/versions/1.0.0/retract", "/api/packages/test_package/versions/1.0.0/unretract"} {
+		if w := asOther(path, "write-token"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a non-uploader on %s, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+	if retracted() {
+		t.Error("Expected a non-uploader not to retract the version")
+	}
+	if w := asOther("/api/packages/test_package/versions/1.0.0/retract", "admin-token"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for an admin, got %d: %s", w.Code, w.Body.String())
+	}
+	if !retracted() {
+		t.Error("Expected an admin to retract the version")
+	}
+}
+
+func TestVerifyVersionHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: intact\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	// A row whose archive actually contains a different package and version
+	pkg, err := repos.DB.CreateTestPackage(ctx, "tampered", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	pubspecYAML := "name: tampered\nversion: 1.0.0"
+	otherArchive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: other\nversion: 2.0.0",
+	})
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: pubspecYAML,
+		ArchivePath: repos.CreateTestArchive(t, "tampered", "1.0.0", otherArchive),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.1.0",
+		PubspecYaml: "name: tampered\nversion: 1.1.0",
+		ArchivePath: "tampered/1.1.0/missing.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/verify", VerifyVersionHandler(pubSvc))
+
+	tests := []struct {
+		name               string
+		path               string
+		expectedStatus     int
+		expectedConsistent bool
+		expectedMismatches []string
+	}{
+		{
+			name:               "consistent version",
+			path:               "/api/packages/intact/versions/1.0.0/verify",
+			expectedStatus:     http.StatusOK,
+			expectedConsistent: true,
+		},
+		{
+			name:           "mismatched archive",
+			path:           "/api/packages/tampered/versions/1.0.0/verify",
+			expectedStatus: http.StatusOK,
+			expectedMismatches: []string{
+				`archive declares package "other", recorded "tampered"`,
+				`archive declares version "2.0.0", recorded "1.0.0"`,
+				"stored pubspec.yaml differs from the archive's",
+			},
+		},
+		{
+			name:               "missing archive",
+			path:               "/api/packages/tampered/versions/1.1.0/verify",
+			expectedStatus:     http.StatusOK,
+			expectedMismatches: []string{"archive tampered/1.1.0/missing.tar.gz not found in storage"},
+		},
+		{
+			name:           "missing version",
+			path:           "/api/packages/tampered/versions/9.9.9/verify",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var result domain.VersionVerification
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result.Consistent != tt.expectedConsistent {
+				t.Errorf("Expected consistent=%v, got %+v", tt.expectedConsistent, result)
+			}
+			if strings.Join(result.Mismatches, "\n") != strings.Join(tt.expectedMismatches, "\n") {
+				t.Errorf("Expected mismatches %q, got %q", tt.expectedMismatches, result.Mismatches)
+			}
+		})
+	}
+}
+
+func TestVersionRecordHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: inspected\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+	// Retracted versions of private packages are still inspectable
+	if _, err := pubSvc.SetPackagePrivate(ctx, "inspected", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("Failed to make package private: %v", err)
+	}
+	if _, err := pubSvc.SetVersionRetracted(ctx, "inspected", "1.0.0", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("Failed to retract version: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages/{package}/versions/{version}/raw", VersionRecordHandler(pubSvc))
+
+	req := httptest.NewRequest("GET", "/api/packages/inspected/versions/1.0.0/raw", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var record domain.PackageVersion
+	if err := json.Unmarshal(w.Body.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	sum := sha256.Sum256(archive)
+	if record.Version != "1.0.0" || record.ArchivePath == "" || record.CreatedAt.IsZero() || !record.Retracted {
+		t.Errorf("Expected the stored version row, got %+v", record)
+	}
+	if record.Uploader == nil || *record.Uploader != "ci" {
+		t.Errorf("Expected uploader ci, got %v", record.Uploader)
+	}
+	if record.ArchiveSha256 == nil || *record.ArchiveSha256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the archive checksum, got %v", record.ArchiveSha256)
+	}
+
+	for _, path := range []string{"/api/packages/inspected/versions/9.9.9/raw", "/api/packages/missing/versions/1.0.0/raw"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRefreshPackageHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: edited\nversion: 1.0.0",
+		"README.md":    "# Fresh readme",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	// Simulate a stale render left behind by a manual database edit
+	pkg, err := repos.DB.Repo.GetPackage(ctx, "edited")
+	if err != nil || pkg == nil {
+		t.Fatalf("Failed to get package: %v", err)
+	}
+	version, err := repos.DB.Repo.GetLatestVersion(ctx, pkg.ID)
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if err := repos.DB.Repo.SetVersionDocsHTML(ctx, version.ID, "<p>Stale readme</p>", ""); err != nil {
+		t.Fatalf("Failed to store README HTML: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Post("/api/packages/{package}/refresh", RefreshPackageHandler(pubSvc))
+	router.Get("/packages/{package}", PackageDetailHandler(pubSvc))
+	page := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/packages/edited", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	if !strings.Contains(page(), "Stale readme") {
+		t.Fatal("Expected the stale README HTML to be served before refreshing")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/packages/edited/refresh", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var refreshed domain.PackageResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &refreshed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if refreshed.Name != "edited" || refreshed.Latest.Version != "1.0.0" {
+		t.Errorf("Expected the refreshed metadata, got %+v", refreshed)
+	}
+
+	body := page()
+	if strings.Contains(body, "Stale readme") || !strings.Contains(body, "Fresh readme") {
+		t.Error("Expected the refreshed README HTML to replace the stale one")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/packages/missing/refresh", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing package, got %d", w.Code)
+	}
+}
+
+func TestPackagesListHandler_Sort(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	downloads := map[string]int{"alpha": 1, "bravo": 5, "charlie": 3}
+	for name, count := range downloads {
+		pkg, err := repos.DB.CreateTestPackage(ctx, name, false)
+		if err != nil {
+			t.Fatalf("Failed to create package: %v", err)
+		}
+		for range count {
+			if err := repos.DB.Repo.IncrementDownloadCount(ctx, pkg.ID); err != nil {
+				t.Fatalf("Failed to count download: %v", err)
+			}
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/packages", PackagesListHandler(pubSvc))
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedOrder  []string
+	}{
+		{"by downloads", "?sort=downloads", http.StatusOK, []string{"bravo", "charlie", "alpha"}},
+		{"by name", "?sort=name", http.StatusOK, []string{"alpha", "bravo", "charlie"}},
+		{"default", "", http.StatusOK, nil},
+		{"unknown sort", "?sort=likes", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/packages"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			body := w.Body.String()
+			last := -1
+			for _, name := range tt.expectedOrder {
+				i := strings.Index(body, `href="/packages/`+name+`"`)
+				if i < 0 {
+					t.Fatalf("Expected %s to be listed", name)
+				}
+				if i < last {
+					t.Errorf("Expected %s after the previous packages, order %v", name, tt.expectedOrder)
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func TestPackagesListHandler_Since(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+		Clock:   clk,
+	})
+
+	ctx := context.Background()
+	publish := func(name string) {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+		if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", name, err)
+		}
+	}
+	publish("before")
+	clk.Advance(2 * time.Hour)
+	publish("after")
+
+	router := chi.NewRouter()
+	router.Get("/packages", PackagesListHandler(pubSvc))
+	list := func(query string) (int, string) {
+		req := httptest.NewRequest("GET", "/packages"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+	listed := func(body, name string) bool {
+		return strings.Contains(body, `href="/packages/`+name+`"`)
+	}
+
+	since := "?since=" + start.Add(time.Hour).Format(time.RFC3339)
+	code, body := list(since)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if !listed(body, "after") || listed(body, "before") {
+		t.Errorf("Expected only the package published after the timestamp to be listed")
+	}
+
+	// Retracting a version counts as a change of its package
+	clk.Advance(time.Hour)
+	if _, err := pubSvc.SetVersionRetracted(ctx, "before", "1.0.0", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("Failed to retract: %v", err)
+	}
+	_, body = list("?since=" + start.Add(150*time.Minute).Format(time.RFC3339))
+	if !listed(body, "before") || listed(body, "after") {
+		t.Errorf("Expected only the retracted package to be listed")
+	}
+
+	if code, _ := list("?since=yesterday"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid timestamp, got %d", code)
+	}
+	if code, _ := list(since + "&sort=name"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for since combined with sort, got %d", code)
+	}
+}
+
+func TestListPackagesHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+		Clock:   clk,
+	})
+
+	for _, name := range []string{"before", "after"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+		if _, err := pubSvc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", name, err)
+		}
+		clk.Advance(2 * time.Hour)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/packages", ListPackagesHandler(pubSvc))
+	list := func(query string) (*httptest.ResponseRecorder, []string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/packages"+query, nil))
+		if w.Code != http.StatusOK {
+			return w, nil
+		}
+		var resp domain.PackageListResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode package list: %v", err)
+		}
+		if resp.NextURL != nil {
+			t.Errorf("Expected a single page, got next_url %s", *resp.NextURL)
+		}
+		var names []string
+		for _, pkg := range resp.Packages {
+			names = append(names, pkg.Name)
+		}
+		return w, names
+	}
+
+	if _, names := list("?sort=name"); !slices.Equal(names, []string{"after", "before"}) {
+		t.Errorf("Expected packages by name, got %v", names)
+	}
+
+	since := "?since=" + start.Add(time.Hour).Format(time.RFC3339)
+	if _, names := list(since); !slices.Equal(names, []string{"after"}) {
+		t.Errorf("Expected only the package published after the timestamp, got %v", names)
+	}
+
+	for _, query := range []string{since + "&sort=name", "?since=yesterday", "?page=0"} {
+		if w, _ := list(query); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestPackagesListHandler_Empty(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+
+	w := httptest.NewRecorder()
+	PackagesListHandler(pubSvc)(w, httptest.NewRequest("GET", "/packages", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "No packages yet") {
+		t.Error("Expected the empty state on the packages page")
+	}
+
+	// The homepage stats show zeros rather than failing
+	w = httptest.NewRecorder()
+	IndexHandler(pubSvc, templates.Instance{}, "")(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	for _, stat := range []string{">0</div><div class=\"text-blue-100\">Packages Published", ">0 B</div>"} {
+		if !strings.Contains(w.Body.String(), stat) {
+			t.Errorf("Expected the homepage to contain %q", stat)
+		}
+	}
+}
+
+func TestIndexHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+
+	customIndex := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(customIndex, []byte("<html><body>Acme internal packages</body></html>"), 0644); err != nil {
+		t.Fatalf("Failed to write custom index: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		instance        templates.Instance
+		customIndexPath string
+		expected        []string
+		unexpected      []string
+	}{
+		{
+			name:     "default branding",
+			expected: []string{"Fast, reliable, and fully compatible with pub.dev"},
+		},
+		{
+			name:     "instance name and description",
+			instance: templates.Instance{Name: "Acme Packages", Description: "Dart packages shared across <Acme> teams"},
+			expected: []string{">Acme Packages</h1>", "Dart packages shared across &lt;Acme&gt; teams"},
+		},
+		{
+			name:            "custom index page",
+			instance:        templates.Instance{Name: "Acme Packages"},
+			customIndexPath: customIndex,
+			expected:        []string{"<html><body>Acme internal packages</body></html>"},
+			unexpected:      []string{"Acme Packages"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			IndexHandler(pubSvc, tt.instance, tt.customIndexPath)(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, s := range tt.expected {
+				if !strings.Contains(body, s) {
+					t.Errorf("Expected page to contain %q", s)
+				}
+			}
+			for _, s := range tt.unexpected {
+				if strings.Contains(body, s) {
+					t.Errorf("Expected page not to contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+
+	ctx := context.Background()
+	var storageBytes int64
+	for _, published := range []struct{ name, version, uploader string }{
+		{"first", "1.0.0", "alice"},
+		{"first", "1.1.0", "alice"},
+		{"second", "1.0.0", "bob"},
+	} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + published.name + "\nversion: " + published.version,
+		})
+		if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: published.uploader}); err != nil {
+			t.Fatalf("Failed to publish %s %s: %v", published.name, published.version, err)
+		}
+		storageBytes += int64(len(archive))
+	}
+	if _, err := pubSvc.DownloadPackage(ctx, "first", "1.0.0"); err != nil {
+		t.Fatalf("DownloadPackage failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	w := httptest.NewRecorder()
+	StatsHandler(pubSvc)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats domain.InstanceStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := domain.InstanceStats{Packages: 2, Versions: 3, StorageBytes: storageBytes, Downloads: 1, Publishers: 2}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+
+	// The homepage shows the same numbers
+	req = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	IndexHandler(pubSvc, templates.Instance{}, "")(w, req)
+	for _, s := range []string{">2</div>", ">3</div>", ">1</div>"} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Expected homepage to contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"time"
+)
+
+// ExportHandler streams the metadata of every package as JSON Lines, one
+// package per line, in the format accepted by `repub import`. `?since=`
+// limits the export to packages changed after an RFC 3339 timestamp.
+func ExportHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since, err := parseSince(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+
+		err = pubSvc.ExportPackages(r.Context(), since, func(pkg *domain.ExportedPackage) error {
+			if err := encoder.Encode(pkg); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			// The status has usually been sent already, a truncated body is all we can signal
+			slog.Error("Failed to export packages", "error", err)
+		}
+	}
+}
+
+// parseSince reads the optional RFC 3339 `since` query parameter, returning
+// the zero time when it is absent
+func parseSince(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be an RFC 3339 timestamp")
+	}
+	return since, nil
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "net/http"
+
+// ReadOnlyMiddleware rejects every request with 503, it is mounted on the
+// write routes while the registry is in read-only mode
+func ReadOnlyMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writePubError(w, http.StatusServiceUnavailable, "MAINTENANCE",
+				"The registry is in read-only mode for maintenance, changes are temporarily disabled.")
+		})
+	}
+}
+
+// FeatureDisabledMiddleware answers 404, as if the route didn't exist, it is
+// mounted on the routes of experimental features that aren't enabled
+func FeatureDisabledMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"repub/internal/clock"
+)
+
+// MetricsHandler exposes process gauges in the Prometheus text format
+func MetricsHandler(clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		count, oldest := PendingUploads(clk.Now())
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeGauge(w, "repub_pending_uploads", "Uploads awaiting finalization.", float64(count))
+		writeGauge(w, "repub_pending_upload_oldest_age_seconds", "Age of the oldest upload awaiting finalization.", oldest.Seconds())
+	}
+}
+
+// writeGauge writes a single unlabelled gauge sample with its metadata
+func writeGauge(w http.ResponseWriter, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+}
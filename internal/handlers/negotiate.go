@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pubV2ContentType is the media type of pub repository API v2 responses
+	pubV2ContentType = "application/vnd.pub.v2+json"
+	// pubMediaTypePrefix is shared by the media types of all pub API versions
+	pubMediaTypePrefix = "application/vnd.pub."
+)
+
+// supportedPubContentTypes are the pub API versions served, preferred first
+var supportedPubContentTypes = []string{pubV2ContentType}
+
+// negotiatePubContentType picks the pub API media type for a response to r.
+// Requests that don't pin a pub version, with wildcards, other media types or
+// no Accept header at all, get the preferred version. ok is false when the
+// Accept header only names pub versions this server doesn't speak.
+func negotiatePubContentType(r *http.Request) (contentType string, ok bool) {
+	askedForPub, acceptsOther := false, false
+	for _, header := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			// q=0 marks a media type as not acceptable
+			q, err := strconv.ParseFloat(params["q"], 64)
+			acceptable := err != nil || q > 0
+
+			if !strings.HasPrefix(mediaType, pubMediaTypePrefix) {
+				acceptsOther = acceptsOther || acceptable
+				continue
+			}
+			askedForPub = true
+			if acceptable && slices.Contains(supportedPubContentTypes, mediaType) {
+				return mediaType, true
+			}
+		}
+	}
+
+	if askedForPub && !acceptsOther {
+		return "", false
+	}
+	return supportedPubContentTypes[0], true
+}
+
+// pubContentType returns the negotiated pub API media type for a response to r
+func pubContentType(r *http.Request) string {
+	if contentType, ok := negotiatePubContentType(r); ok {
+		return contentType
+	}
+	return supportedPubContentTypes[0]
+}
+
+// PubContentNegotiation answers 406 to requests that only accept pub API
+// versions this server doesn't speak, e.g. a future v3-only client
+func PubContentNegotiation() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			if _, ok := negotiatePubContentType(r); !ok {
+				writePubError(w, http.StatusNotAcceptable, "NOT_ACCEPTABLE", fmt.Sprintf(
+					"Unsupported Accept header %q, this server responds with %s",
+					r.Header.Get("Accept"), strings.Join(supportedPubContentTypes, ", ")))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPubContentNegotiation(t *testing.T) {
+	handler := PubContentNegotiation()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", pubContentType(r))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		accept         string
+		expectedStatus int
+	}{
+		{"pub v2", "application/vnd.pub.v2+json", http.StatusOK},
+		{"no accept header", "", http.StatusOK},
+		{"any type", "*/*", http.StatusOK},
+		{"plain json", "application/json", http.StatusOK},
+		{"v2 among unsupported versions", "application/vnd.pub.v3+json, application/vnd.pub.v2+json;q=0.5", http.StatusOK},
+		{"unsupported version with fallback", "application/vnd.pub.v3+json, */*;q=0.1", http.StatusOK},
+		{"unsupported version", "application/vnd.pub.v3+json", http.StatusNotAcceptable},
+		{"v2 refused", "application/vnd.pub.v2+json;q=0", http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/packages/test_package", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != pubV2ContentType {
+				t.Errorf("Expected Content-Type %s, got %s", pubV2ContentType, contentType)
+			}
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", w.Header().Get("Vary"))
+			}
+			if tt.expectedStatus == http.StatusNotAcceptable && !strings.Contains(w.Body.String(), "NOT_ACCEPTABLE") {
+				t.Errorf("Expected NOT_ACCEPTABLE error, got %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -1,69 +1,166 @@
 package handlers
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"repub/internal/auth"
+	"repub/internal/clock"
 	"repub/internal/domain"
 	"repub/internal/service"
 	"strings"
 	"sync"
+	"time"
 )
 
 // In-memory storage for pending uploads (development implementation)
 var (
-	pendingUploads = make(map[string]*domain.PublishRequest)
+	pendingUploads = make(map[string]*pendingUpload)
 	uploadMutex    = sync.RWMutex{}
 )
 
+// Errors reading an uploaded archive, answered by writeServiceError
+var (
+	errArchiveTooLarge = errors.New("archive exceeds the maximum upload size")
+	errUploadMalformed = errors.New("malformed archive upload")
+)
+
+// pendingUpload is an uploaded archive awaiting finalization by the token
+// that uploaded it
+type pendingUpload struct {
+	request  *domain.PublishRequest
+	subject  string
+	received time.Time
+}
+
+// newUploadID returns an unguessable id for a pending upload
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "upload_" + hex.EncodeToString(b), nil
+}
+
+// PendingUploads reports how many uploads await finalization and the age of
+// the oldest one at now
+func PendingUploads(now time.Time) (count int, oldest time.Duration) {
+	uploadMutex.RLock()
+	defer uploadMutex.RUnlock()
+	for _, upload := range pendingUploads {
+		oldest = max(oldest, now.Sub(upload.received))
+	}
+	return len(pendingUploads), oldest
+}
+
+// expirePendingUploads drops the uploads received before cutoff, returning
+// how many were dropped. Archives are only held in memory, so dropping the
+// entry frees everything the upload used.
+func expirePendingUploads(cutoff time.Time) int {
+	uploadMutex.Lock()
+	defer uploadMutex.Unlock()
+	expired := 0
+	for id, upload := range pendingUploads {
+		if upload.received.Before(cutoff) {
+			delete(pendingUploads, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// RunPendingUploadJanitor expires uploads that weren't finalized within ttl,
+// e.g. because a client crashed between upload and finalize, until ctx is
+// cancelled
+func RunPendingUploadJanitor(ctx context.Context, ttl time.Duration, clk clock.Clock) {
+	ticker := time.NewTicker(max(ttl/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired := expirePendingUploads(clk.Now().Add(-ttl)); expired > 0 {
+				slog.Warn("Expired pending uploads that were never finalized", "count", expired, "ttl", ttl)
+			}
+		}
+	}
+}
+
+// uploaderName is recorded as the uploader of packages published over HTTP
+// with credentials that don't name the caller
+const uploaderName = "authenticated-user"
+
+// uploaderFor returns the uploader recorded for an authenticated request
+func uploaderFor(ctx context.Context) string {
+	if identity := auth.Identity(ctx); identity != "" {
+		return identity
+	}
+	return uploaderName
+}
+
 // UploadPackageHandler handles package upload (step 2 of the workflow)
-func UploadPackageHandler(pubSvc service.PubService, baseURL string) http.HandlerFunc {
+func UploadPackageHandler(pubSvc service.PubService, baseURL string, clk clock.Clock) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !auth.IsAuthenticated(r.Context()) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Parse multipart form (dart pub client sends the archive as a file)
-		err := r.ParseMultipartForm(32 << 20) // 32MB max memory
+		archiveData, err := readUploadedArchive(r)
 		if err != nil {
-			slog.Error("Failed to parse multipart form", "error", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
+			slog.Error("Failed to read uploaded archive", "error", err)
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
 			return
 		}
 
-		// Get the uploaded file
-		file, _, err := r.FormFile("file")
-		if err != nil {
-			slog.Error("Failed to get uploaded file", "error", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
+		// Reject obviously broken archives now rather than at finalize;
+		// pubspec validation still happens when the upload is finalized
+		if err := sniffArchive(archiveData); err != nil {
+			slog.Error("Rejected invalid archive", "error", err)
+			writePubError(w, http.StatusBadRequest, "INVALID_ARCHIVE", err.Error())
 			return
 		}
-		defer func() { _ = file.Close() }()
 
-		// Read the archive data
-		archiveData, err := io.ReadAll(file)
-		if err != nil {
-			slog.Error("Failed to read archive data", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		// Record which package the upload is for, declared by the client or
+		// sniffed from the archive, so finalize publishes nothing else. An
+		// unreadable pubspec is left for finalize to report.
+		expectedPackage := r.FormValue("package")
+		if expectedPackage == "" {
+			expectedPackage, _ = service.ArchivePackageName(archiveData)
 		}
 
 		// Create publish request and store it temporarily
 		publishReq := &domain.PublishRequest{
-			Archive:  archiveData,
-			Uploader: "authenticated-user",
+			Archive:         archiveData,
+			Uploader:        uploaderFor(r.Context()),
+			ExpectedPackage: expectedPackage,
+			// Clients that lost a finalize response publish the same bytes again
+			AllowRetry: true,
 		}
 
 		// Generate a unique finalize token
-		finalizeToken := fmt.Sprintf("upload_%d", len(archiveData)) // Simple token generation
+		finalizeToken, err := newUploadID()
+		if err != nil {
+			slog.Error("Failed to generate upload id", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 		
-		// Store the upload for finalization
+		// Store the upload for finalization by the same token
 		uploadMutex.Lock()
-		pendingUploads[finalizeToken] = publishReq
+		pendingUploads[finalizeToken] = &pendingUpload{request: publishReq, subject: auth.Subject(r.Context()), received: clk.Now()}
 		uploadMutex.Unlock()
 
 		// Return 204 with finalize URL as per pub spec
@@ -76,6 +173,106 @@ func UploadPackageHandler(pubSvc service.PubService, baseURL string) http.Handle
 	}
 }
 
+// ValidatePackageHandler runs every publish check on an uploaded archive
+// without storing it, answering with what would be published or the error
+// the publish would fail with. Only the checks run within limit, a slow
+// upload of the archive doesn't hold a publish slot.
+func ValidatePackageHandler(pubSvc service.PubService, limit func(http.Handler) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		archiveData, err := readUploadedArchive(r)
+		if err != nil {
+			slog.Error("Failed to read uploaded archive", "error", err)
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := pubSvc.ValidatePublish(r.Context(), &domain.PublishRequest{
+				Archive:         archiveData,
+				Uploader:        uploaderFor(r.Context()),
+				ExpectedPackage: r.FormValue("package"),
+			})
+			if err != nil {
+				writeServiceError(w, err, http.StatusBadRequest, "PUBLISH_FAILED")
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(result); err != nil {
+				slog.Error("Failed to encode validation response", "error", err)
+			}
+		})).ServeHTTP(w, r)
+	}
+}
+
+// sniffArchive checks that data is a gzip stream whose first tar header is
+// readable, without decompressing the rest of the archive
+func sniffArchive(data []byte) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("archive is not gzip compressed: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	if _, err := tar.NewReader(gz).Next(); err != nil {
+		return fmt.Errorf("archive is not a valid tar file: %w", err)
+	}
+	return nil
+}
+
+// readUploadedArchive reads the archive from either a raw gzip body or a
+// multipart form. Uploads over the size limit fail with errArchiveTooLarge,
+// requests without an archive with errUploadMalformed.
+func readUploadedArchive(r *http.Request) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/octet-stream", "application/gzip", "application/x-gzip":
+		// Some publishing tools send the archive as the raw request body
+		archiveData, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, readError(err)
+		}
+		if len(archiveData) == 0 {
+			return nil, fmt.Errorf("%w: empty archive body", errUploadMalformed)
+		}
+		return archiveData, nil
+	}
+
+	// Parse multipart form (dart pub client sends the archive as a file)
+	err := r.ParseMultipartForm(32 << 20) // 32MB max memory
+	if err != nil {
+		if err := readError(err); errors.Is(err, errArchiveTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", errUploadMalformed, err)
+	}
+
+	// Get the uploaded file
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUploadMalformed, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// Read the archive data
+	archiveData, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive data: %w", err)
+	}
+	return archiveData, nil
+}
+
+// readError wraps a body read error, telling bodies cut off by the upload
+// size limit apart
+func readError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Errorf("%w: %w", errArchiveTooLarge, err)
+	}
+	return fmt.Errorf("failed to read archive data: %w", err)
+}
+
 // FinalizeUploadHandler handles the finalization of package upload (step 3 of the workflow)
 func FinalizeUploadHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -91,9 +288,11 @@ func FinalizeUploadHandler(pubSvc service.PubService) http.HandlerFunc {
 			return
 		}
 
-		// Retrieve the pending upload
+		// Retrieve the pending upload; another token's upload is reported
+		// as missing and left for its owner to finalize
 		uploadMutex.Lock()
-		publishReq, exists := pendingUploads[uploadID]
+		upload, exists := pendingUploads[uploadID]
+		exists = exists && upload.subject == auth.Subject(r.Context())
 		if exists {
 			delete(pendingUploads, uploadID) // Remove from pending
 		}
@@ -106,25 +305,17 @@ func FinalizeUploadHandler(pubSvc service.PubService) http.HandlerFunc {
 					"message": "Upload not found or already processed",
 				},
 			}
-			w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+			w.Header().Set("Content-Type", pubContentType(r))
 			w.WriteHeader(http.StatusBadRequest)
 			_ = json.NewEncoder(w).Encode(response)
 			return
 		}
 
 		// Now actually publish the package
-		_, err := pubSvc.PublishPackage(r.Context(), publishReq)
+		_, err := pubSvc.PublishPackage(r.Context(), upload.request)
 		if err != nil {
 			slog.Error("Failed to publish package", "error", err)
-			response := map[string]interface{}{
-				"error": map[string]string{
-					"code":    "PUBLISH_FAILED",
-					"message": err.Error(),
-				},
-			}
-			w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
-			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(response)
+			writeServiceError(w, err, http.StatusBadRequest, "PUBLISH_FAILED")
 			return
 		}
 
@@ -135,7 +326,7 @@ func FinalizeUploadHandler(pubSvc service.PubService) http.HandlerFunc {
 			},
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+		w.Header().Set("Content-Type", pubContentType(r))
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			slog.Error("Failed to encode success response", "error", err)
@@ -3,15 +3,21 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"repub/internal/auth"
+	"repub/internal/clock"
+	"repub/internal/domain"
 	"repub/internal/service"
 	"repub/internal/testutil"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Helper function to add authentication to context
@@ -68,7 +74,7 @@ description: A test package`,
 		req = addAuthToContext(req)
 
 		w := httptest.NewRecorder()
-		handler := UploadPackageHandler(pubSvc, "http://localhost:9090")
+		handler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
 		handler(w, req)
 
 		if w.Code != http.StatusNoContent {
@@ -107,7 +113,7 @@ description: A test package`,
 		req.Header.Set("Content-Type", "application/json")
 
 		w := httptest.NewRecorder()
-		handler := UploadPackageHandler(pubSvc, "http://localhost:9090")
+		handler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
 		handler(w, req)
 
 		if w.Code != http.StatusUnauthorized {
@@ -134,7 +140,7 @@ description: A test package`,
 		req = addAuthToContext(req)
 
 		w := httptest.NewRecorder()
-		handler := UploadPackageHandler(pubSvc, "http://localhost:9090")
+		handler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
 		handler(w, req)
 
 		if w.Code != http.StatusBadRequest {
@@ -166,11 +172,11 @@ description: A test package`,
 		req = addAuthToContext(req)
 
 		w := httptest.NewRecorder()
-		handler := UploadPackageHandler(pubSvc, "http://localhost:9090")
+		handler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
 		handler(w, req)
 
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("Expected status 400, got %d", w.Code)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_UPLOAD") {
+			t.Errorf("Expected status 400 with INVALID_UPLOAD, got %d: %s", w.Code, w.Body.String())
 		}
 	})
 
@@ -205,28 +211,65 @@ description: A test package`,
 			t.Fatalf("Failed to close writer: %v", err)
 		}
 
-		// Step 1: Upload the invalid archive (should succeed)
+		// Non-gzip data is rejected at upload without a finalize round trip
 		req := httptest.NewRequest("POST", "/api/packages/versions/new", &body)
 		req.Header.Set("Content-Type", writer.FormDataContentType())
 		req = addAuthToContext(req)
 
 		w := httptest.NewRecorder()
-		uploadHandler := UploadPackageHandler(pubSvc, "http://localhost:9090")
+		uploadHandler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
+		uploadHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected upload to fail with status 400, got %d", w.Code)
+		}
+
+		if w.Header().Get("Location") != "" {
+			t.Error("Expected no finalize URL for a rejected upload")
+		}
+
+		// Should return proper error JSON
+		if !strings.Contains(w.Body.String(), "INVALID_ARCHIVE") {
+			t.Errorf("Expected INVALID_ARCHIVE error, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("archive without pubspec fails at finalize", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		pubSvc := service.NewPubService(service.PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			BaseURL: "http://localhost:8080",
+		})
+
+		// A well-formed tar.gz passes the upload check, pubspec validation happens at finalize
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"README.md": "# No pubspec here",
+		})
+
+		// Step 1: Upload the archive (should succeed)
+		req := httptest.NewRequest("POST", "/api/packages/versions/new", bytes.NewReader(archive))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req = addAuthToContext(req)
+
+		w := httptest.NewRecorder()
+		uploadHandler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
 		uploadHandler(w, req)
 
 		if w.Code != http.StatusNoContent {
-			t.Errorf("Expected upload to succeed with status 204, got %d", w.Code)
+			t.Fatalf("Expected upload to succeed with status 204, got %d", w.Code)
 		}
 
-		location := w.Header().Get("Location")
-		if location == "" {
-			t.Fatal("Expected Location header with finalize URL")
+		locationURL, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Failed to parse Location header: %v", err)
 		}
 
-		// Step 2: Extract upload_id from location and test finalization (should fail)
-		// Parse the URL to get upload_id parameter
-		finalizePath := "/api/packages/versions/newUploadFinish?upload_id=upload_34" // Simple hardcoded ID for test
-		finalizeReq := httptest.NewRequest("GET", finalizePath, nil)
+		// Step 2: Finalize (should fail)
+		finalizeReq := httptest.NewRequest("GET", "/api/packages/versions/newUploadFinish?"+locationURL.RawQuery, nil)
 		finalizeReq = addAuthToContext(finalizeReq)
 
 		finalizeW := httptest.NewRecorder()
@@ -242,4 +285,526 @@ description: A test package`,
 			t.Error("Expected error response in finalize step")
 		}
 	})
+
+	t.Run("raw gzip body upload", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		pubSvc := service.NewPubService(service.PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			BaseURL: "http://localhost:9090",
+		})
+
+		files := map[string]string{
+			"raw_package-1.0.0/pubspec.yaml": `name: raw_package
+version: 1.0.0
+description: A package uploaded as a raw body`,
+		}
+		archive := testutil.CreateTestTarGzArchive(t, files)
+
+		// Step 1: Upload the archive as the raw request body
+		req := httptest.NewRequest("POST", "/api/packages/versions/new", bytes.NewReader(archive))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req = addAuthToContext(req)
+
+		w := httptest.NewRecorder()
+		uploadHandler := UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())
+		uploadHandler(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+		}
+
+		location := w.Header().Get("Location")
+		locationURL, err := url.Parse(location)
+		if err != nil || locationURL.Query().Get("upload_id") == "" {
+			t.Fatalf("Expected Location header with upload_id, got %q", location)
+		}
+
+		// Step 2: Finalize the upload using the returned upload_id
+		finalizeReq := httptest.NewRequest("GET", "/api/packages/versions/newUploadFinish?"+locationURL.RawQuery, nil)
+		finalizeReq = addAuthToContext(finalizeReq)
+
+		finalizeW := httptest.NewRecorder()
+		finalizeHandler := FinalizeUploadHandler(pubSvc)
+		finalizeHandler(finalizeW, finalizeReq)
+
+		if finalizeW.Code != http.StatusOK {
+			t.Fatalf("Expected finalize status 200, got %d: %s", finalizeW.Code, finalizeW.Body.String())
+		}
+
+		pkg, err := repos.DB.Repo.GetPackage(context.Background(), "raw_package")
+		if err != nil || pkg == nil {
+			t.Errorf("Expected raw_package to be published, got err %v", err)
+		}
+	})
+}
+
+// uploadAndFinalize runs the upload and finalize steps for an archive,
+// returning the finalize response
+func uploadAndFinalize(t *testing.T, pubSvc service.PubService, archive []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadAndFinalizeDeclared(t, pubSvc, archive, "")
+}
+
+// uploadAndFinalizeDeclared uploads archive declaring it is for declaredPackage
+func uploadAndFinalizeDeclared(t *testing.T, pubSvc service.PubService, archive []byte, declaredPackage string) *httptest.ResponseRecorder {
+	t.Helper()
+	return finalizeUpload(pubSvc, uploadArchive(t, pubSvc, archive, declaredPackage))
+}
+
+// uploadArchive runs the upload step for an archive, returning the query of
+// its finalize URL
+func uploadArchive(t *testing.T, pubSvc service.PubService, archive []byte, declaredPackage string) string {
+	t.Helper()
+
+	target := "/api/packages/versions/new"
+	if declaredPackage != "" {
+		target += "?package=" + url.QueryEscape(declaredPackage)
+	}
+	req := httptest.NewRequest("POST", target, bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req = addAuthToContext(req)
+
+	w := httptest.NewRecorder()
+	UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected upload status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	locationURL, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse Location header: %v", err)
+	}
+	return locationURL.RawQuery
+}
+
+// finalizeUpload runs the finalize step for the upload with the given query
+func finalizeUpload(pubSvc service.PubService, query string) *httptest.ResponseRecorder {
+	finalizeReq := httptest.NewRequest("GET", "/api/packages/versions/newUploadFinish?"+query, nil)
+	finalizeReq = addAuthToContext(finalizeReq)
+
+	finalizeW := httptest.NewRecorder()
+	FinalizeUploadHandler(pubSvc)(finalizeW, finalizeReq)
+	return finalizeW
+}
+
+func TestFinalizeUploadHandler_OtherToken(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	withSubject := func(req *http.Request, subject string) *http.Request {
+		return addAuthToContext(req.WithContext(auth.SetSubject(req.Context(), subject)))
+	}
+	upload := func(archive []byte, subject string) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/packages/versions/new", bytes.NewReader(archive))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		w := httptest.NewRecorder()
+		UploadPackageHandler(pubSvc, "http://localhost:9090", clock.Real())(w, withSubject(req, subject))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected upload status 204, got %d: %s", w.Code, w.Body.String())
+		}
+		locationURL, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Failed to parse Location header: %v", err)
+		}
+		return locationURL.RawQuery
+	}
+	finalize := func(query, subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/packages/versions/newUploadFinish?"+query, nil)
+		w := httptest.NewRecorder()
+		FinalizeUploadHandler(pubSvc)(w, withSubject(req, subject))
+		return w
+	}
+
+	archiveA := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: package_a\nversion: 1.0.0"})
+	archiveB := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: package_b\nversion: 1.0.0"})
+	if len(archiveA) != len(archiveB) {
+		t.Fatalf("Expected archives of the same size, got %d and %d", len(archiveA), len(archiveB))
+	}
+
+	queryA := upload(archiveA, "token-a")
+	queryB := upload(archiveB, "token-b")
+	if queryA == queryB {
+		t.Fatalf("Expected distinct upload ids, both got %q", queryA)
+	}
+
+	for _, tt := range []struct{ query, subject string }{{queryA, "token-b"}, {queryB, "token-a"}} {
+		if w := finalize(tt.query, tt.subject); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "UPLOAD_NOT_FOUND") {
+			t.Errorf("Expected UPLOAD_NOT_FOUND finalizing another token's upload, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := finalize("upload_id=upload_unknown", "token-a"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "UPLOAD_NOT_FOUND") {
+		t.Errorf("Expected UPLOAD_NOT_FOUND for an unknown upload, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Each owner can still finalize its own upload
+	for _, tt := range []struct{ query, subject, name string }{{queryA, "token-a", "package_a"}, {queryB, "token-b", "package_b"}} {
+		if w := finalize(tt.query, tt.subject); w.Code != http.StatusOK {
+			t.Fatalf("Expected finalize status 200 for %s, got %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		if pkg, err := repos.DB.Repo.GetPackage(context.Background(), tt.name); err != nil || pkg == nil {
+			t.Errorf("Expected %s to be published, got %v, %v", tt.name, pkg, err)
+		}
+	}
+}
+
+func TestFinalizeUploadHandler_ErrorStatus(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, "other_package", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	if err := repos.DB.Repo.AddUploader(ctx, pkg.ID, "someone-else"); err != nil {
+		t.Fatalf("Failed to add uploader: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "other uploader's package",
+			files:          map[string]string{"pubspec.yaml": "name: other_package\nversion: 1.0.0"},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "FORBIDDEN",
+		},
+		{
+			name:           "malformed pubspec",
+			files:          map[string]string{"pubspec.yaml": "name: [unclosed"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "INVALID_PUBSPEC",
+		},
+		{
+			name:           "missing pubspec",
+			files:          map[string]string{"README.md": "# No pubspec here"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "INVALID_PUBSPEC",
+		},
+	}
+
+	t.Run("version already exists", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: twice_package\nversion: 1.0.0",
+		})
+
+		if w := uploadAndFinalize(t, pubSvc, archive); w.Code != http.StatusOK {
+			t.Fatalf("Expected first finalize status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		// Identical bytes are a retry, so the version is republished from changed ones
+		changed := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: twice_package\nversion: 1.0.0",
+			"README.md":    "# Changed",
+		})
+		w := uploadAndFinalize(t, pubSvc, changed)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected second finalize status 409, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "VERSION_EXISTS") {
+			t.Errorf("Expected VERSION_EXISTS error code, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("every pubspec problem is listed", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: bad-name\nversion: one\nenvironment:\n  sdk: '>=banana'",
+		})
+
+		w := uploadAndFinalize(t, pubSvc, archive)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected finalize status 400, got %d: %s", w.Code, w.Body.String())
+		}
+		var response struct {
+			Error struct {
+				Code     string   `json:"code"`
+				Message  string   `json:"message"`
+				Problems []string `json:"problems"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		expected := []string{
+			"invalid package name format: bad-name",
+			"invalid version format: one",
+			"invalid SDK constraint: >=banana",
+		}
+		if response.Error.Code != "INVALID_PUBSPEC" || !slices.Equal(response.Error.Problems, expected) {
+			t.Errorf("Expected INVALID_PUBSPEC with problems %q, got %+v", expected, response.Error)
+		}
+		// The pub client only shows the message
+		for _, problem := range expected {
+			if !strings.Contains(response.Error.Message, problem) {
+				t.Errorf("Expected the message to contain %q, got %s", problem, response.Error.Message)
+			}
+		}
+	})
+
+	t.Run("archive for a different package than declared", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: swapped_package\nversion: 1.0.0",
+		})
+
+		w := uploadAndFinalizeDeclared(t, pubSvc, archive, "declared_package")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected finalize status 400, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "PACKAGE_MISMATCH") || !strings.Contains(w.Body.String(), "declared_package") {
+			t.Errorf("Expected PACKAGE_MISMATCH naming the declared package, got %s", w.Body.String())
+		}
+
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "swapped_package")
+		if err != nil {
+			t.Fatalf("GetPackage failed: %v", err)
+		}
+		if pkg != nil {
+			t.Error("Mismatched archive should not have been published")
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := uploadAndFinalize(t, pubSvc, testutil.CreateTestTarGzArchive(t, tt.files))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedCode) {
+				t.Errorf("Expected error code %s, got %s", tt.expectedCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFinalizeUploadHandler_Retry(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: retried_package\nversion: 1.0.0",
+	})
+	if w := uploadAndFinalize(t, pubSvc, archive); w.Code != http.StatusOK {
+		t.Fatalf("Expected first finalize status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The client lost the response and publishes the same bytes again
+	w := uploadAndFinalize(t, pubSvc, archive)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the retried finalize to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "success") {
+		t.Errorf("Expected a success response, got %s", w.Body.String())
+	}
+
+	data, err := pubSvc.DownloadPackage(context.Background(), "retried_package", "1.0.0")
+	if err != nil {
+		t.Fatalf("DownloadPackage failed: %v", err)
+	}
+	if !bytes.Equal(data, archive) {
+		t.Error("Expected the published archive to be kept")
+	}
+}
+
+func TestPendingUploadExpiry(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: abandoned_package\nversion: 1.0.0",
+	})
+	// Uploads left pending by other tests are dropped so only this one counts
+	received := time.Now().Add(time.Hour)
+	expirePendingUploads(received)
+	clk := clock.NewFake(received)
+	req := httptest.NewRequest("POST", "/api/packages/versions/new", bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	UploadPackageHandler(pubSvc, "http://localhost:9090", clk)(w, addAuthToContext(req))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected upload status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse Location header: %v", err)
+	}
+	query := location.RawQuery
+
+	// Ages are measured on the injected clock
+	clk.Advance(90 * time.Second)
+	if count, oldest := PendingUploads(clk.Now()); count == 0 || oldest != 90*time.Second {
+		t.Fatalf("Expected the upload to be pending for 90s, got %d aged %s", count, oldest)
+	}
+	w = httptest.NewRecorder()
+	MetricsHandler(clk)(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "# TYPE repub_pending_uploads gauge") || !strings.Contains(w.Body.String(), "repub_pending_upload_oldest_age_seconds 90\n") {
+		t.Errorf("Expected the pending upload gauges, got %s", w.Body.String())
+	}
+
+	// Everything received before the cutoff is expired
+	if expired := expirePendingUploads(clk.Now()); expired == 0 {
+		t.Fatal("Expected the pending upload to expire")
+	}
+	if count, oldest := PendingUploads(clk.Now()); count != 0 || oldest != 0 {
+		t.Errorf("Expected no pending uploads, got %d aged %s", count, oldest)
+	}
+
+	w = finalizeUpload(pubSvc, query)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "UPLOAD_NOT_FOUND") {
+		t.Errorf("Expected UPLOAD_NOT_FOUND for the expired upload, got %d: %s", w.Code, w.Body.String())
+	}
+	if pkg, err := repos.DB.Repo.GetPackage(context.Background(), "abandoned_package"); err != nil || pkg != nil {
+		t.Errorf("Expected the expired upload not to be published, got %+v, %v", pkg, err)
+	}
+}
+
+func TestValidatePackageHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	published := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: checked_package\nversion: 1.0.0",
+	})
+	if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: published, Uploader: uploaderName}); err != nil {
+		t.Fatalf("Failed to publish package: %v", err)
+	}
+
+	limit := PublishLimitMiddleware(0)
+	validate := func(archive []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", "package.tar.gz")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write(archive); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Failed to close writer: %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "/api/packages/versions/validate", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		ValidatePackageHandler(pubSvc, limit)(w, addAuthToContext(req))
+		return w
+	}
+
+	t.Run("valid archive", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: fresh_package\nversion: 0.1.0",
+		})
+		w := validate(archive)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var result domain.PublishValidation
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Package != "fresh_package" || result.Version != "0.1.0" || !result.NewPackage || result.Uploader != uploaderName {
+			t.Errorf("Unexpected validation result %+v", result)
+		}
+		if result.SizeBytes != int64(len(archive)) {
+			t.Errorf("Expected size %d, got %d", len(archive), result.SizeBytes)
+		}
+
+		// Nothing is created or stored
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "fresh_package")
+		if err != nil || pkg != nil {
+			t.Errorf("Expected no package to be created, got %+v, %v", pkg, err)
+		}
+		paths, err := repos.StorageSvc.List(ctx, "fresh_package")
+		if err != nil || len(paths) != 0 {
+			t.Errorf("Expected no archive to be stored, got %v, %v", paths, err)
+		}
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		w := validate(published)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "VERSION_EXISTS") {
+			t.Errorf("Expected VERSION_EXISTS error code, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("next version", func(t *testing.T) {
+		w := validate(testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: checked_package\nversion: 1.1.0",
+		}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), `"new_package":true`) {
+			t.Errorf("Expected an existing package, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("limit applies once the archive is read", func(t *testing.T) {
+		var bodyRead bool
+		limit = func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bodyRead = r.MultipartForm != nil
+				http.Error(w, "Too many publishes", http.StatusTooManyRequests)
+			})
+		}
+		defer func() { limit = PublishLimitMiddleware(0) }()
+
+		w := validate(published)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429, got %d", w.Code)
+		}
+		if !bodyRead {
+			t.Error("Expected the archive to be read before taking a publish slot")
+		}
+	})
 }
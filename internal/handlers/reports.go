@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"repub/internal/auth"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ReportVersionHandler files an abuse report against a version. The reporter
+// is the identity the token names, or the token's subject when it names none.
+func ReportVersionHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := chi.URLParam(r, "package")
+		version := chi.URLParam(r, "version")
+
+		reporter := auth.Identity(r.Context())
+		if reporter == "" {
+			reporter = auth.Subject(r.Context())
+		}
+		if reporter == "" {
+			writePubError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+			return
+		}
+
+		var req domain.ReportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+
+		report, err := pubSvc.ReportVersion(r.Context(), packageName, version, reporter, req.Reason)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError, "INTERNAL_ERROR")
+			return
+		}
+
+		w.Header().Set("Content-Type", pubContentType(r))
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			slog.Error("Failed to encode report response", "error", err)
+		}
+	}
+}
+
+const (
+	defaultReportsLimit = 100
+	maxReportsLimit     = 1000
+)
+
+// ListReportsHandler lists abuse reports, newest first, ?limit=N (default
+// 100) at a time. The next page is requested with ?before=<id of the last
+// report>.
+func ListReportsHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultReportsLimit
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			parsed, err := strconv.Atoi(limitParam)
+			if err != nil || parsed < 1 || parsed > maxReportsLimit {
+				writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxReportsLimit))
+				return
+			}
+			limit = parsed
+		}
+		before := int32(math.MaxInt32)
+		if beforeParam := r.URL.Query().Get("before"); beforeParam != "" {
+			parsed, err := strconv.ParseInt(beforeParam, 10, 32)
+			if err != nil || parsed < 1 {
+				writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "before must be a report id")
+				return
+			}
+			before = int32(parsed)
+		}
+
+		reports, err := pubSvc.ListReports(r.Context(), before, int32(limit))
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if reports == nil {
+			reports = []*domain.Report{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reports); err != nil {
+			slog.Error("Failed to encode reports response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"repub/internal/auth"
+	"repub/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SignedDownloadMiddleware serves archive downloads carrying a valid signature
+// without a token, and passes every other request through requireAuth. A
+// signed URL that is expired or tampered with is rejected with 403 rather
+// than falling back to token auth, so clients see why it stopped working.
+func SignedDownloadMiddleware(signer *service.DownloadSigner, requireAuth func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authenticated := requireAuth(next)
+		if signer == nil {
+			return authenticated
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if !query.Has("sig") {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			err := signer.Verify(chi.URLParam(r, "package"), chi.URLParam(r, "version"), query.Get("exp"), query.Get("sig"))
+			switch {
+			case errors.Is(err, service.ErrSignatureExpired):
+				writePubError(w, http.StatusForbidden, "SIGNATURE_EXPIRED", "The download URL has expired, fetch the package metadata again.")
+				return
+			case err != nil:
+				writePubError(w, http.StatusForbidden, "INVALID_SIGNATURE", "The download URL signature is invalid.")
+				return
+			}
+
+			// The signature grants read access to this archive, including
+			// archives of private packages
+			next.ServeHTTP(w, r.WithContext(auth.SetAuthenticated(r.Context(), true)))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"repub/internal/service"
+	"strconv"
+	"time"
+)
+
+// Sitemap and robots.txt handlers for crawlers of the web UI
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// sitemapMaxURLs is the sitemap protocol limit of URLs per file
+var sitemapMaxURLs = 50000
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// SitemapHandler lists public package and version pages. When there are more
+// URLs than fit in one sitemap, it serves a sitemap index of ?page=N sitemaps.
+func SitemapHandler(pubSvc service.PubService, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		urls, err := collectSitemapURLs(r, pubSvc, baseURL)
+		if err != nil {
+			slog.Error("Failed to build sitemap", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		pages := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+
+		var doc any
+		if pageParam := r.URL.Query().Get("page"); pageParam != "" {
+			page, err := strconv.Atoi(pageParam)
+			if err != nil || page < 1 || page > pages {
+				http.Error(w, "Sitemap page not found", http.StatusNotFound)
+				return
+			}
+			start := (page - 1) * sitemapMaxURLs
+			end := min(start+sitemapMaxURLs, len(urls))
+			doc = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls[start:end]}
+		} else if pages > 1 {
+			index := sitemapIndex{XMLNS: sitemapNamespace}
+			for page := 1; page <= pages; page++ {
+				index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", baseURL, page)})
+			}
+			doc = index
+		} else {
+			doc = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls}
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		if _, err := w.Write([]byte(xml.Header)); err != nil {
+			slog.Error("Failed to write sitemap response", "error", err)
+			return
+		}
+		if err := xml.NewEncoder(w).Encode(doc); err != nil {
+			slog.Error("Failed to encode sitemap response", "error", err)
+		}
+	}
+}
+
+// collectSitemapURLs lists each public package followed by its versions
+func collectSitemapURLs(r *http.Request, pubSvc service.PubService, baseURL string) ([]sitemapURL, error) {
+	packages, err := pubSvc.GetSitemapPackages(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []sitemapURL
+	for _, pkg := range packages {
+		urls = append(urls, sitemapURL{
+			Loc:     fmt.Sprintf("%s/packages/%s", baseURL, pkg.Name),
+			LastMod: pkg.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+		for _, v := range pkg.Versions {
+			urls = append(urls, sitemapURL{
+				Loc:     fmt.Sprintf("%s/packages/%s/versions/%s", baseURL, pkg.Name, v.Version),
+				LastMod: v.CreatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return urls, nil
+}
+
+// RobotsHandler serves robots.txt, keeping crawlers off the API and pointing them at the sitemap
+func RobotsHandler(baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := fmt.Fprintf(w, "User-agent: *\nDisallow: /api/\nAllow: /\n\nSitemap: %s/sitemap.xml\n", baseURL); err != nil {
+			slog.Error("Failed to write robots.txt response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestSitemapHandler(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	pubSvc := service.NewPubService(service.PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:9090",
+	})
+
+	ctx := context.Background()
+	for _, name := range []string{"public_package", "secret_package"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+		if _, err := pubSvc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+			t.Fatalf("Failed to publish package: %v", err)
+		}
+	}
+	if _, err := pubSvc.SetPackagePrivate(ctx, "secret_package", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("Failed to mark package private: %v", err)
+	}
+
+	handler := SitemapHandler(pubSvc, "https://pub.example.com")
+
+	t.Run("lists public packages only", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/sitemap.xml", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		body := w.Body.String()
+		for _, want := range []string{
+			"<urlset",
+			"<loc>https://pub.example.com/packages/public_package</loc>",
+			"<loc>https://pub.example.com/packages/public_package/versions/1.0.0</loc>",
+			"<lastmod>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected sitemap to contain %q, got %s", want, body)
+			}
+		}
+		if strings.Contains(body, "secret_package") {
+			t.Errorf("Expected private package to be absent from sitemap, got %s", body)
+		}
+	})
+
+	t.Run("splits large sitemaps into an index", func(t *testing.T) {
+		defer func(max int) { sitemapMaxURLs = max }(sitemapMaxURLs)
+		sitemapMaxURLs = 1
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/sitemap.xml", nil))
+		body := w.Body.String()
+		if !strings.Contains(body, "<sitemapindex") || !strings.Contains(body, "https://pub.example.com/sitemap.xml?page=2") {
+			t.Errorf("Expected sitemap index with two pages, got %s", body)
+		}
+
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/sitemap.xml?page=2", nil))
+		if !strings.Contains(w.Body.String(), "/packages/public_package/versions/1.0.0") {
+			t.Errorf("Expected second page to contain the version URL, got %s", w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/sitemap.xml?page=3", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for out of range page, got %d", w.Code)
+		}
+	})
+}
+
+func TestRobotsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	RobotsHandler("https://pub.example.com")(w, httptest.NewRequest("GET", "/robots.txt", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Sitemap: https://pub.example.com/sitemap.xml") {
+		t.Errorf("Expected robots.txt to reference the sitemap, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import "net/http"
+
+// publishRetryAfter is the Retry-After, in seconds, sent when too many
+// publishes are in progress
+const publishRetryAfter = "5"
+
+// PublishLimitMiddleware allows at most max requests through at once across
+// every route it is mounted on, rejecting the rest with 429 instead of
+// queueing them. Zero means unlimited.
+func PublishLimitMiddleware(max int) func(http.Handler) http.Handler {
+	if max <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	slots := make(chan struct{}, max)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", publishRetryAfter)
+				writePubError(w, http.StatusTooManyRequests, "TOO_MANY_PUBLISHES",
+					"Too many publishes are in progress, please retry shortly.")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPublishLimitMiddleware(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	limited := PublishLimitMiddleware(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	publish := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, httptest.NewRequest("GET", "/api/packages/versions/newUploadFinish", nil))
+		return w
+	}
+
+	// Fill both slots with publishes that stay in progress
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := publish(); w.Code != http.StatusOK {
+				t.Errorf("Expected in-flight publish to succeed, got %d", w.Code)
+			}
+		}()
+		<-entered
+	}
+
+	// Further publishes are rejected immediately rather than queued
+	for range 3 {
+		w := publish()
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429, got %d", w.Code)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != publishRetryAfter {
+			t.Errorf("Expected Retry-After %s, got %q", publishRetryAfter, retryAfter)
+		}
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Finished publishes free their slots
+	go func() { <-entered }()
+	if w := publish(); w.Code != http.StatusOK {
+		t.Errorf("Expected publish to succeed once slots are free, got %d", w.Code)
+	}
+
+	unlimited := PublishLimitMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	unlimited.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected no limit when max is 0, got %d", w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Admin handlers for managing database tokens
+
+// ListTokensHandler lists all database tokens, including revoked and expired ones
+func ListTokensHandler(tokenSvc service.TokenService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := tokenSvc.ListTokens(r.Context())
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if tokens == nil {
+			tokens = []*domain.Token{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tokens); err != nil {
+			slog.Error("Failed to encode tokens response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// CreateTokenHandler creates a token and returns its secret, which can't be retrieved later
+func CreateTokenHandler(tokenSvc service.TokenService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req domain.CreateTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+
+		created, err := tokenSvc.CreateToken(r.Context(), &req)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidTokenRequest) {
+				writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+				return
+			}
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(created); err != nil {
+			slog.Error("Failed to encode token response", "error", err)
+		}
+	}
+}
+
+// RevokeTokenHandler revokes a token so it is no longer accepted
+func RevokeTokenHandler(tokenSvc service.TokenService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "id")
+		tokenID, err := strconv.ParseInt(idParam, 10, 32)
+		if err != nil {
+			writePubError(w, http.StatusBadRequest, "INVALID_REQUEST", "Token id must be a number")
+			return
+		}
+
+		revoked, err := tokenSvc.RevokeToken(r.Context(), int32(tokenID))
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		if !revoked {
+			writePubError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Active token %s not found", idParam))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTokenHandlers(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	authSvc := service.NewDBAuthService(repos.DB.Repo, nil, clock.Real())
+
+	router := chi.NewRouter()
+	router.Get("/api/admin/tokens", ListTokensHandler(authSvc))
+	router.Post("/api/admin/tokens", CreateTokenHandler(authSvc))
+	router.Delete("/api/admin/tokens/{id}", RevokeTokenHandler(authSvc))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/admin/tokens", `{"name": "ci", "scope": "write"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.CreateTokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Token == "" || created.Record == nil || created.Record.Scope != domain.TokenScopeWrite {
+		t.Fatalf("Expected a write token, got %+v", created)
+	}
+	if err := authSvc.ValidateWriteToken(context.Background(), created.Token); err != nil {
+		t.Errorf("Expected created token to be valid, got %v", err)
+	}
+
+	w = do("GET", "/api/admin/tokens", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 listing tokens, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), created.Token) {
+		t.Error("Expected token secret not to be listed")
+	}
+	if !strings.Contains(w.Body.String(), `"name":"ci"`) {
+		t.Errorf("Expected ci token in list, got %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"invalid body", "POST", "/api/admin/tokens", "not json", http.StatusBadRequest},
+		{"unknown scope", "POST", "/api/admin/tokens", `{"name": "x", "scope": "owner"}`, http.StatusBadRequest},
+		{"revoke", "DELETE", "/api/admin/tokens/1", "", http.StatusNoContent},
+		{"revoke again", "DELETE", "/api/admin/tokens/1", "", http.StatusNotFound},
+		{"revoke unknown", "DELETE", "/api/admin/tokens/99", "", http.StatusNotFound},
+		{"revoke invalid id", "DELETE", "/api/admin/tokens/abc", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(tt.method, tt.path, tt.body); w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if err := authSvc.ValidateReadToken(context.Background(), created.Token); err == nil {
+		t.Error("Expected revoked token to be rejected")
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"repub/internal/buildinfo"
+	"repub/internal/domain"
+	"repub/internal/service"
+)
+
+// VersionHandler reports the version, commit and Go version of the running build
+func VersionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(buildinfo.Get()); err != nil {
+			slog.Error("Failed to encode version response", "error", err)
+		}
+	}
+}
+
+// MetaHandler reports the server's upload constraints and features
+func MetaHandler(meta domain.ServerMeta) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(meta); err != nil {
+			slog.Error("Failed to encode meta response", "error", err)
+		}
+	}
+}
+
+// StatsHandler reports totals over every package hosted by the instance
+func StatsHandler(pubSvc service.PubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := pubSvc.GetInstanceStats(r.Context())
+		if err != nil {
+			writePubError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			slog.Error("Failed to encode stats response", "error", err)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"os"
+	"repub/internal/domain"
 	"repub/internal/service"
 	"repub/web/templates"
 
@@ -11,10 +14,47 @@ import (
 
 // Web handlers for server-side rendered pages
 
-func IndexHandler() http.HandlerFunc {
+// staticCacheControl lets browsers reuse static assets for a day; they are
+// not fingerprinted, so a deploy must be picked up within a reasonable time
+const staticCacheControl = "public, max-age=86400"
+
+// StaticHandler serves files from dir with caching headers
+func StaticHandler(dir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", staticCacheControl)
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// IndexHandler renders the landing page branded with instance, or serves the
+// HTML file at customIndexPath instead when one is configured. The file is read
+// on every request so it can be edited without a restart.
+func IndexHandler(pubSvc service.PubService, instance templates.Instance, customIndexPath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if customIndexPath != "" {
+			page, err := os.ReadFile(customIndexPath)
+			if err != nil {
+				slog.Error("Failed to read custom index page", "path", customIndexPath, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if _, err := w.Write(page); err != nil {
+				slog.Error("Failed to write custom index page", "error", err)
+			}
+			return
+		}
+
+		// The page still renders, with zeros, when the stats can't be computed
+		stats, err := pubSvc.GetInstanceStats(r.Context())
+		if err != nil {
+			slog.Warn("Failed to get instance stats", "error", err)
+			stats = &domain.InstanceStats{}
+		}
+
 		w.Header().Set("Content-Type", "text/html")
-		if err := templates.Index().Render(r.Context(), w); err != nil {
+		if err := templates.Index(instance, stats).Render(r.Context(), w); err != nil {
 			slog.Error("Failed to render template", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -23,7 +63,18 @@ func IndexHandler() http.HandlerFunc {
 
 func PackagesListHandler(pubSvc service.PubService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		packages, err := pubSvc.ListPackages(r.Context(), 1, 20)
+		sort, since, err := parsePackageListQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var packages []*domain.Package
+		if since.IsZero() {
+			packages, err = pubSvc.ListPackages(r.Context(), sort, 1, 20)
+		} else {
+			packages, err = pubSvc.ListPackagesUpdatedSince(r.Context(), since, 1, 20)
+		}
 		if err != nil {
 			slog.Error("Error listing packages", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -31,7 +82,7 @@ func PackagesListHandler(pubSvc service.PubService) http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "text/html")
-		if err := templates.PackagesList(packages).Render(r.Context(), w); err != nil {
+		if err := templates.PackagesList(packages, sort).Render(r.Context(), w); err != nil {
 			slog.Error("Failed to render template", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -43,6 +94,10 @@ func PackageDetailHandler(pubSvc service.PubService) http.HandlerFunc {
 		packageName := chi.URLParam(r, "package")
 
 		detail, err := pubSvc.GetPackageDetail(r.Context(), packageName)
+		if errors.Is(err, service.ErrNotFound) {
+			http.Error(w, "Package not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			slog.Error("Error getting package detail", "error", err, "package", packageName)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -0,0 +1,51 @@
+// Package markdown renders READMEs and CHANGELOGs from uploaded archives to
+// sanitized HTML
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var parser = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		html.WithXHTML(),
+		html.WithUnsafe(), // Allow raw HTML in markdown; it is sanitized after rendering
+	),
+)
+
+// policy strips scripts, iframes and event handler attributes from rendered
+// READMEs and CHANGELOGs, which come from untrusted uploads
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	// Keep fenced code block languages and GFM task list checkboxes
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	return policy
+}
+
+// Render converts markdown text to sanitized HTML
+func Render(markdown string) string {
+	if markdown == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := parser.Convert([]byte(markdown), &buf); err != nil {
+		// Return the raw markdown if parsing fails
+		return "<pre>" + template.HTMLEscapeString(markdown) + "</pre>"
+	}
+
+	return policy.Sanitize(buf.String())
+}
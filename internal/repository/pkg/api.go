@@ -2,30 +2,168 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"math"
 	"repub/internal/domain"
 	"repub/internal/repository/pkg/postgres"
+	"time"
 )
 
 type Queries interface {
 	GetPackage(ctx context.Context, name string) (postgres.Package, error)
 	CreatePackage(ctx context.Context, params postgres.CreatePackageParams) (postgres.Package, error)
+	CreatePackageIfNotExists(ctx context.Context, params postgres.CreatePackageIfNotExistsParams) (postgres.Package, error)
 	ListPackages(ctx context.Context, params postgres.ListPackagesParams) ([]postgres.Package, error)
+	ListPackagesByUpdated(ctx context.Context, params postgres.ListPackagesByUpdatedParams) ([]postgres.Package, error)
+	ListPackagesByDownloads(ctx context.Context, params postgres.ListPackagesByDownloadsParams) ([]postgres.Package, error)
+	ListPackagesUpdatedSince(ctx context.Context, params postgres.ListPackagesUpdatedSinceParams) ([]postgres.Package, error)
+	ListPackagesPage(ctx context.Context, params postgres.ListPackagesPageParams) ([]postgres.Package, error)
+	ListPublicVersions(ctx context.Context) ([]postgres.ListPublicVersionsRow, error)
 	GetPackageVersions(ctx context.Context, packageID int32) ([]postgres.PackageVersion, error)
+	GetPackageVersionsPage(ctx context.Context, params postgres.GetPackageVersionsPageParams) ([]postgres.PackageVersion, error)
 	GetLatestPackageVersion(ctx context.Context, packageID int32) (postgres.PackageVersion, error)
+	GetPackageVersion(ctx context.Context, params postgres.GetPackageVersionParams) (postgres.PackageVersion, error)
+	ListPackageVersionNumbers(ctx context.Context, packageID int32) ([]postgres.ListPackageVersionNumbersRow, error)
+	GetVersionByArchiveSha256(ctx context.Context, params postgres.GetVersionByArchiveSha256Params) (postgres.PackageVersion, error)
 	CreatePackageVersion(ctx context.Context, params postgres.CreatePackageVersionParams) (postgres.PackageVersion, error)
 	GetPackageUploaders(ctx context.Context, packageID int32) ([]string, error)
 	AddPackageUploader(ctx context.Context, params postgres.AddPackageUploaderParams) error
+	ClaimPackage(ctx context.Context, params postgres.ClaimPackageParams) (string, error)
+	AddPackageLike(ctx context.Context, params postgres.AddPackageLikeParams) (int64, error)
+	IncrementDownloadCount(ctx context.Context, id int32) error
+	SetPackagePrivate(ctx context.Context, params postgres.SetPackagePrivateParams) error
+	TouchPackage(ctx context.Context, params postgres.TouchPackageParams) error
+	RestorePackageHistory(ctx context.Context, params postgres.RestorePackageHistoryParams) error
+	SetPackageVersionRetracted(ctx context.Context, params postgres.SetPackageVersionRetractedParams) error
+	SetPackageVersionSize(ctx context.Context, params postgres.SetPackageVersionSizeParams) error
+	SetPackageVersionArchivePath(ctx context.Context, params postgres.SetPackageVersionArchivePathParams) error
+	SetPackageVersionCreatedAt(ctx context.Context, params postgres.SetPackageVersionCreatedAtParams) error
+	SetPackageVersionDocsHTML(ctx context.Context, params postgres.SetPackageVersionDocsHTMLParams) error
+	DeletePackageVersion(ctx context.Context, id int32) error
+	IncrementDownloadEvent(ctx context.Context, params postgres.IncrementDownloadEventParams) error
+	GetDownloadEvents(ctx context.Context, params postgres.GetDownloadEventsParams) ([]postgres.GetDownloadEventsRow, error)
+	GetInstanceStats(ctx context.Context) (postgres.GetInstanceStatsRow, error)
+	AddDownloadCounts(ctx context.Context, counts json.RawMessage) error
+	CreateToken(ctx context.Context, params postgres.CreateTokenParams) (postgres.Token, error)
+	GetTokenByHash(ctx context.Context, tokenHash string) (postgres.Token, error)
+	ListTokens(ctx context.Context) ([]postgres.Token, error)
+	RevokeToken(ctx context.Context, id int32) (int64, error)
+	CreateReport(ctx context.Context, params postgres.CreateReportParams) (postgres.Report, error)
+	ListReports(ctx context.Context, params postgres.ListReportsParams) ([]postgres.ListReportsRow, error)
+}
+
+// ErrVersionExists is returned, wrapped, by CreateVersion when the package
+// already has the version, e.g. because a concurrent publish recorded it first
+var ErrVersionExists = errors.New("version already exists")
+
+// ErrReportExists is returned, wrapped, by CreateReport when the reporter
+// already reported the version
+var ErrReportExists = errors.New("report already exists")
+
+// VersionPageCursor returns the (created_at, id) position a page of versions
+// starts below: that of after, or one above every version when after is nil
+func VersionPageCursor(after *domain.PackageVersion) (time.Time, int32) {
+	if after == nil {
+		return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), math.MaxInt32
+	}
+	return after.CreatedAt, after.ID
+}
+
+// PackagePageCursor returns the (updated_at, id) position a page of packages
+// starts above: that of after, or past every package changed at since when
+// after is nil
+func PackagePageCursor(since time.Time, after *domain.Package) (time.Time, int32) {
+	if after == nil {
+		return since, math.MaxInt32
+	}
+	return after.UpdatedAt, after.ID
 }
 
 type Repository interface {
 	GetPackage(ctx context.Context, name string) (*domain.Package, error)
 	CreatePackage(ctx context.Context, name string, private bool) (*domain.Package, error)
+	// GetOrCreatePackage returns the named package, creating it if needed; safe
+	// against concurrent creation of the same package
+	GetOrCreatePackage(ctx context.Context, name string, private bool) (*domain.Package, error)
+	SetPackagePrivate(ctx context.Context, packageID int32, private bool) error
+	// TouchPackage records that a package changed at the given time
+	TouchPackage(ctx context.Context, packageID int32, at time.Time) error
+	// RestorePackageHistory merges imported history into a package, keeping
+	// the earlier creation time and the higher like and download counts
+	RestorePackageHistory(ctx context.Context, packageID int32, createdAt time.Time, likeCount, downloadCount int64) error
 	ListPackages(ctx context.Context, limit, offset int32) ([]*domain.Package, error)
+	// ListPackagesSorted lists packages in the given order, ties broken by name
+	ListPackagesSorted(ctx context.Context, sort domain.PackageSort, limit, offset int32) ([]*domain.Package, error)
+	// ListPackagesUpdatedSince lists packages changed after since, oldest change first
+	ListPackagesUpdatedSince(ctx context.Context, since time.Time, limit, offset int32) ([]*domain.Package, error)
+	// ListPackagesPage returns up to limit of the packages changed after
+	// since, oldest change first, starting after the package after or with
+	// the oldest change when it is nil
+	ListPackagesPage(ctx context.Context, since time.Time, after *domain.Package, limit int32) ([]*domain.Package, error)
+	// ListSitemapPackages returns every public package by name with its
+	// versions, in a single query
+	ListSitemapPackages(ctx context.Context) ([]*domain.SitemapPackage, error)
 
 	GetPackageVersions(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error)
+	// GetPackageVersionsPage returns up to limit of a package's versions in
+	// GetPackageVersions order, newest first, starting after the version
+	// after or with the newest when it is nil
+	GetPackageVersionsPage(ctx context.Context, packageID int32, after *domain.PackageVersion, limit int32) ([]*domain.PackageVersion, error)
 	GetLatestVersion(ctx context.Context, packageID int32) (*domain.PackageVersion, error)
+	// GetVersion returns a version of a package, nil if it doesn't exist
+	GetVersion(ctx context.Context, packageID int32, version string) (*domain.PackageVersion, error)
+	// GetVersionNumbers returns the ID, version and retraction of each of a
+	// package's versions without loading their pubspecs and docs
+	GetVersionNumbers(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error)
+	// GetVersionByArchiveSha256 returns the first version of a package whose
+	// archive has the given SHA-256, nil if none has
+	GetVersionByArchiveSha256(ctx context.Context, packageID int32, sha256 string) (*domain.PackageVersion, error)
+	// CreateVersion fails with ErrVersionExists if the version was already created
 	CreateVersion(ctx context.Context, version *domain.PackageVersion) (*domain.PackageVersion, error)
+	SetVersionRetracted(ctx context.Context, versionID int32, retracted bool) error
+	SetVersionSize(ctx context.Context, versionID int32, sizeBytes int64) error
+	// SetVersionArchivePath points a version at an archive in another location
+	SetVersionArchivePath(ctx context.Context, versionID int32, archivePath string) error
+	// SetVersionCreatedAt backdates a version to when it was first published
+	SetVersionCreatedAt(ctx context.Context, versionID int32, createdAt time.Time) error
+	// SetVersionDocsHTML stores the rendered README and CHANGELOG of a version
+	SetVersionDocsHTML(ctx context.Context, versionID int32, readmeHTML, changelogHTML string) error
+	// DeleteVersion removes a version row, its archive is left to the caller
+	DeleteVersion(ctx context.Context, versionID int32) error
 
 	GetUploaders(ctx context.Context, packageID int32) ([]string, error)
 	AddUploader(ctx context.Context, packageID int32, uploader string) error
+	// ClaimPackage records uploader as the first owner of a package without
+	// uploaders and reports whether the claim is uploader's. A claim is only
+	// ever recorded once, so of concurrent claimants exactly one wins.
+	ClaimPackage(ctx context.Context, packageID int32, uploader string) (bool, error)
+
+	// LikePackage records a like and reports whether it was new for this liker
+	LikePackage(ctx context.Context, packageID int32, liker string) (bool, error)
+	IncrementDownloadCount(ctx context.Context, packageID int32) error
+
+	// RecordDownload adds a download of version to the daily bucket for day
+	RecordDownload(ctx context.Context, packageID int32, version string, day time.Time) error
+	// AddDownloadCounts adds batched download counts to the daily buckets and
+	// package totals, all of them or, on error, none
+	AddDownloadCounts(ctx context.Context, counts map[domain.DownloadKey]int64) error
+	// GetDownloadHistory returns the daily per-version download counts since the given day
+	GetDownloadHistory(ctx context.Context, packageID int32, since time.Time) ([]*domain.VersionDownloads, error)
+	// GetInstanceStats totals packages, versions, archive sizes and downloads
+	GetInstanceStats(ctx context.Context) (*domain.InstanceStats, error)
+
+	CreateToken(ctx context.Context, token *domain.Token) (*domain.Token, error)
+	// GetTokenByHash returns nil if no token has the given hash
+	GetTokenByHash(ctx context.Context, tokenHash string) (*domain.Token, error)
+	ListTokens(ctx context.Context) ([]*domain.Token, error)
+	// RevokeToken reports whether an unrevoked token with the given id existed
+	RevokeToken(ctx context.Context, tokenID int32) (bool, error)
+
+	// CreateReport fails with ErrReportExists if the reporter already
+	// reported the version
+	CreateReport(ctx context.Context, report *domain.Report) (*domain.Report, error)
+	// ListReports returns up to limit reports with an id below before, newest
+	// first
+	ListReports(ctx context.Context, before, limit int32) ([]*domain.Report, error)
 }
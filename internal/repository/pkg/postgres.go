@@ -3,10 +3,19 @@ package pkg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"repub/internal/domain"
 	"repub/internal/repository/pkg/postgres"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the SQLSTATE of inserts that break a unique constraint
+const uniqueViolation = "23505"
+
 type postgresPackageRepository struct {
 	queries Queries
 }
@@ -32,6 +41,8 @@ func (r *postgresPackageRepository) GetPackage(ctx context.Context, name string)
 		Homepage:      nullStringToPtr(pkg.Homepage),
 		Repository:    nullStringToPtr(pkg.Repository),
 		Documentation: nullStringToPtr(pkg.Documentation),
+		LikeCount:     pkg.LikeCount,
+		DownloadCount: pkg.DownloadCount,
 		CreatedAt:     pkg.CreatedAt,
 		UpdatedAt:     pkg.UpdatedAt,
 	}, nil
@@ -54,11 +65,56 @@ func (r *postgresPackageRepository) CreatePackage(ctx context.Context, name stri
 		Homepage:      nullStringToPtr(pkg.Homepage),
 		Repository:    nullStringToPtr(pkg.Repository),
 		Documentation: nullStringToPtr(pkg.Documentation),
+		LikeCount:     pkg.LikeCount,
+		DownloadCount: pkg.DownloadCount,
 		CreatedAt:     pkg.CreatedAt,
 		UpdatedAt:     pkg.UpdatedAt,
 	}, nil
 }
 
+func (r *postgresPackageRepository) GetOrCreatePackage(ctx context.Context, name string, private bool) (*domain.Package, error) {
+	_, err := r.queries.CreatePackageIfNotExists(ctx, postgres.CreatePackageIfNotExistsParams{
+		Name:    name,
+		Private: private,
+	})
+	// No row is returned when the package already exists
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	pkg, err := r.GetPackage(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("package %s not found after create", name)
+	}
+	return pkg, nil
+}
+
+func (r *postgresPackageRepository) SetPackagePrivate(ctx context.Context, packageID int32, private bool) error {
+	return r.queries.SetPackagePrivate(ctx, postgres.SetPackagePrivateParams{
+		ID:      packageID,
+		Private: private,
+	})
+}
+
+func (r *postgresPackageRepository) TouchPackage(ctx context.Context, packageID int32, at time.Time) error {
+	return r.queries.TouchPackage(ctx, postgres.TouchPackageParams{
+		ID:        packageID,
+		UpdatedAt: at,
+	})
+}
+
+func (r *postgresPackageRepository) RestorePackageHistory(ctx context.Context, packageID int32, createdAt time.Time, likeCount, downloadCount int64) error {
+	return r.queries.RestorePackageHistory(ctx, postgres.RestorePackageHistoryParams{
+		CreatedAt:     createdAt,
+		LikeCount:     likeCount,
+		DownloadCount: downloadCount,
+		ID:            packageID,
+	})
+}
+
 func (r *postgresPackageRepository) ListPackages(ctx context.Context, limit, offset int32) ([]*domain.Package, error) {
 	packages, err := r.queries.ListPackages(ctx, postgres.ListPackagesParams{
 		Limit:  limit,
@@ -67,7 +123,73 @@ func (r *postgresPackageRepository) ListPackages(ctx context.Context, limit, off
 	if err != nil {
 		return nil, err
 	}
+	return packagesToDomain(packages), nil
+}
+
+func (r *postgresPackageRepository) ListPackagesSorted(ctx context.Context, sort domain.PackageSort, limit, offset int32) ([]*domain.Package, error) {
+	var packages []postgres.Package
+	var err error
+	switch sort {
+	case domain.PackageSortName:
+		return r.ListPackages(ctx, limit, offset)
+	case domain.PackageSortUpdated:
+		packages, err = r.queries.ListPackagesByUpdated(ctx, postgres.ListPackagesByUpdatedParams{Limit: limit, Offset: offset})
+	case domain.PackageSortDownloads:
+		packages, err = r.queries.ListPackagesByDownloads(ctx, postgres.ListPackagesByDownloadsParams{Limit: limit, Offset: offset})
+	default:
+		return nil, fmt.Errorf("unknown package sort %q", sort)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return packagesToDomain(packages), nil
+}
+
+func (r *postgresPackageRepository) ListPackagesUpdatedSince(ctx context.Context, since time.Time, limit, offset int32) ([]*domain.Package, error) {
+	packages, err := r.queries.ListPackagesUpdatedSince(ctx, postgres.ListPackagesUpdatedSinceParams{
+		UpdatedAt: since,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return packagesToDomain(packages), nil
+}
 
+func (r *postgresPackageRepository) ListPackagesPage(ctx context.Context, since time.Time, after *domain.Package, limit int32) ([]*domain.Package, error) {
+	lastUpdated, lastID := PackagePageCursor(since, after)
+	packages, err := r.queries.ListPackagesPage(ctx, postgres.ListPackagesPageParams{
+		LastUpdated: lastUpdated,
+		LastID:      lastID,
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return packagesToDomain(packages), nil
+}
+
+func (r *postgresPackageRepository) ListSitemapPackages(ctx context.Context) ([]*domain.SitemapPackage, error) {
+	rows, err := r.queries.ListPublicVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var packages []*domain.SitemapPackage
+	for _, row := range rows {
+		if len(packages) == 0 || packages[len(packages)-1].Name != row.Name {
+			packages = append(packages, &domain.SitemapPackage{Name: row.Name, UpdatedAt: row.UpdatedAt})
+		}
+		// A package without versions comes back once with a NULL version
+		if row.Version.Valid {
+			pkg := packages[len(packages)-1]
+			pkg.Versions = append(pkg.Versions, domain.SitemapVersion{Version: row.Version.String, CreatedAt: row.VersionCreatedAt.Time})
+		}
+	}
+	return packages, nil
+}
+
+func packagesToDomain(packages []postgres.Package) []*domain.Package {
 	result := make([]*domain.Package, len(packages))
 	for i, pkg := range packages {
 		result[i] = &domain.Package{
@@ -78,12 +200,13 @@ func (r *postgresPackageRepository) ListPackages(ctx context.Context, limit, off
 			Homepage:      nullStringToPtr(pkg.Homepage),
 			Repository:    nullStringToPtr(pkg.Repository),
 			Documentation: nullStringToPtr(pkg.Documentation),
+			LikeCount:     pkg.LikeCount,
+			DownloadCount: pkg.DownloadCount,
 			CreatedAt:     pkg.CreatedAt,
 			UpdatedAt:     pkg.UpdatedAt,
 		}
 	}
-
-	return result, nil
+	return result
 }
 
 func (r *postgresPackageRepository) GetPackageVersions(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error) {
@@ -92,25 +215,22 @@ func (r *postgresPackageRepository) GetPackageVersions(ctx context.Context, pack
 		return nil, err
 	}
 
-	result := make([]*domain.PackageVersion, len(versions))
-	for i, v := range versions {
-		result[i] = &domain.PackageVersion{
-			ID:            v.ID,
-			PackageID:     v.PackageID,
-			Version:       v.Version,
-			Description:   nullStringToPtr(v.Description),
-			PubspecYaml:   v.PubspecYaml,
-			Readme:        nullStringToPtr(v.Readme),
-			Changelog:     nullStringToPtr(v.Changelog),
-			ArchivePath:   v.ArchivePath,
-			ArchiveSha256: nullStringToPtr(v.ArchiveSha256),
-			Uploader:      nullStringToPtr(v.Uploader),
-			Retracted:     v.Retracted,
-			CreatedAt:     v.CreatedAt,
-		}
+	return versionsFromRows(versions), nil
+}
+
+func (r *postgresPackageRepository) GetPackageVersionsPage(ctx context.Context, packageID int32, after *domain.PackageVersion, limit int32) ([]*domain.PackageVersion, error) {
+	lastCreated, lastID := VersionPageCursor(after)
+	versions, err := r.queries.GetPackageVersionsPage(ctx, postgres.GetPackageVersionsPageParams{
+		PackageID:   packageID,
+		LastCreated: lastCreated,
+		LastID:      lastID,
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return versionsFromRows(versions), nil
 }
 
 func (r *postgresPackageRepository) GetLatestVersion(ctx context.Context, packageID int32) (*domain.PackageVersion, error) {
@@ -135,6 +255,74 @@ func (r *postgresPackageRepository) GetLatestVersion(ctx context.Context, packag
 		Uploader:      nullStringToPtr(version.Uploader),
 		Retracted:     version.Retracted,
 		CreatedAt:     version.CreatedAt,
+		Platforms:     listFromJSON[string](version.Platforms),
+		SizeBytes:     nullInt64ToPtr(version.SizeBytes),
+		Funding:       listFromJSON[string](version.Funding),
+		Screenshots:   listFromJSON[domain.Screenshot](version.Screenshots),
+		Proxied:       version.Proxied,
+		ReadmeHTML:    nullStringToPtr(version.ReadmeHtml),
+		ChangelogHTML: nullStringToPtr(version.ChangelogHtml),
+	}, nil
+}
+
+func (r *postgresPackageRepository) GetVersion(ctx context.Context, packageID int32, version string) (*domain.PackageVersion, error) {
+	row, err := r.queries.GetPackageVersion(ctx, postgres.GetPackageVersionParams{
+		PackageID: packageID,
+		Version:   version,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return versionsFromRows([]postgres.PackageVersion{row})[0], nil
+}
+
+func (r *postgresPackageRepository) GetVersionNumbers(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error) {
+	rows, err := r.queries.ListPackageVersionNumbers(ctx, packageID)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*domain.PackageVersion, len(rows))
+	for i, row := range rows {
+		versions[i] = &domain.PackageVersion{ID: row.ID, PackageID: packageID, Version: row.Version, Retracted: row.Retracted}
+	}
+	return versions, nil
+}
+
+func (r *postgresPackageRepository) GetVersionByArchiveSha256(ctx context.Context, packageID int32, sha256 string) (*domain.PackageVersion, error) {
+	version, err := r.queries.GetVersionByArchiveSha256(ctx, postgres.GetVersionByArchiveSha256Params{
+		PackageID:     packageID,
+		ArchiveSha256: sql.NullString{String: sha256, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &domain.PackageVersion{
+		ID:            version.ID,
+		PackageID:     version.PackageID,
+		Version:       version.Version,
+		Description:   nullStringToPtr(version.Description),
+		PubspecYaml:   version.PubspecYaml,
+		Readme:        nullStringToPtr(version.Readme),
+		Changelog:     nullStringToPtr(version.Changelog),
+		ArchivePath:   version.ArchivePath,
+		ArchiveSha256: nullStringToPtr(version.ArchiveSha256),
+		Uploader:      nullStringToPtr(version.Uploader),
+		Retracted:     version.Retracted,
+		CreatedAt:     version.CreatedAt,
+		Platforms:     listFromJSON[string](version.Platforms),
+		SizeBytes:     nullInt64ToPtr(version.SizeBytes),
+		Funding:       listFromJSON[string](version.Funding),
+		Screenshots:   listFromJSON[domain.Screenshot](version.Screenshots),
+		Proxied:       version.Proxied,
+		ReadmeHTML:    nullStringToPtr(version.ReadmeHtml),
+		ChangelogHTML: nullStringToPtr(version.ChangelogHtml),
 	}, nil
 }
 
@@ -164,6 +352,11 @@ func (r *postgresPackageRepository) CreateVersion(ctx context.Context, version *
 		changelog = sql.NullString{String: *version.Changelog, Valid: true}
 	}
 
+	var sizeBytes sql.NullInt64
+	if version.SizeBytes != nil {
+		sizeBytes = sql.NullInt64{Int64: *version.SizeBytes, Valid: true}
+	}
+
 	created, err := r.queries.CreatePackageVersion(ctx, postgres.CreatePackageVersionParams{
 		PackageID:     version.PackageID,
 		Version:       version.Version,
@@ -174,8 +367,17 @@ func (r *postgresPackageRepository) CreateVersion(ctx context.Context, version *
 		ArchivePath:   version.ArchivePath,
 		ArchiveSha256: archiveSha256,
 		Uploader:      uploader,
+		Platforms:     listToJSON(version.Platforms),
+		SizeBytes:     sizeBytes,
+		Funding:       listToJSON(version.Funding),
+		Screenshots:   listToJSON(version.Screenshots),
+		Proxied:       version.Proxied,
 	})
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("%w: %s", ErrVersionExists, version.Version)
+		}
 		return nil, err
 	}
 
@@ -192,9 +394,56 @@ func (r *postgresPackageRepository) CreateVersion(ctx context.Context, version *
 		Uploader:      nullStringToPtr(created.Uploader),
 		Retracted:     created.Retracted,
 		CreatedAt:     created.CreatedAt,
+		Platforms:     listFromJSON[string](created.Platforms),
+		SizeBytes:     nullInt64ToPtr(created.SizeBytes),
+		Funding:       listFromJSON[string](created.Funding),
+		Screenshots:   listFromJSON[domain.Screenshot](created.Screenshots),
+		Proxied:       created.Proxied,
+		ReadmeHTML:    nullStringToPtr(created.ReadmeHtml),
+		ChangelogHTML: nullStringToPtr(created.ChangelogHtml),
 	}, nil
 }
 
+func (r *postgresPackageRepository) SetVersionRetracted(ctx context.Context, versionID int32, retracted bool) error {
+	return r.queries.SetPackageVersionRetracted(ctx, postgres.SetPackageVersionRetractedParams{
+		ID:        versionID,
+		Retracted: retracted,
+	})
+}
+
+func (r *postgresPackageRepository) DeleteVersion(ctx context.Context, versionID int32) error {
+	return r.queries.DeletePackageVersion(ctx, versionID)
+}
+
+func (r *postgresPackageRepository) SetVersionSize(ctx context.Context, versionID int32, sizeBytes int64) error {
+	return r.queries.SetPackageVersionSize(ctx, postgres.SetPackageVersionSizeParams{
+		ID:        versionID,
+		SizeBytes: sql.NullInt64{Int64: sizeBytes, Valid: true},
+	})
+}
+
+func (r *postgresPackageRepository) SetVersionArchivePath(ctx context.Context, versionID int32, archivePath string) error {
+	return r.queries.SetPackageVersionArchivePath(ctx, postgres.SetPackageVersionArchivePathParams{
+		ID:          versionID,
+		ArchivePath: archivePath,
+	})
+}
+
+func (r *postgresPackageRepository) SetVersionCreatedAt(ctx context.Context, versionID int32, createdAt time.Time) error {
+	return r.queries.SetPackageVersionCreatedAt(ctx, postgres.SetPackageVersionCreatedAtParams{
+		ID:        versionID,
+		CreatedAt: createdAt,
+	})
+}
+
+func (r *postgresPackageRepository) SetVersionDocsHTML(ctx context.Context, versionID int32, readmeHTML, changelogHTML string) error {
+	return r.queries.SetPackageVersionDocsHTML(ctx, postgres.SetPackageVersionDocsHTMLParams{
+		ID:            versionID,
+		ReadmeHtml:    sql.NullString{String: readmeHTML, Valid: true},
+		ChangelogHtml: sql.NullString{String: changelogHTML, Valid: true},
+	})
+}
+
 func (r *postgresPackageRepository) GetUploaders(ctx context.Context, packageID int32) ([]string, error) {
 	uploaders, err := r.queries.GetPackageUploaders(ctx, packageID)
 	return uploaders, err
@@ -207,9 +456,282 @@ func (r *postgresPackageRepository) AddUploader(ctx context.Context, packageID i
 	})
 }
 
+func (r *postgresPackageRepository) ClaimPackage(ctx context.Context, packageID int32, uploader string) (bool, error) {
+	claimant, err := r.queries.ClaimPackage(ctx, postgres.ClaimPackageParams{
+		PackageID: packageID,
+		Uploader:  uploader,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// The package already has uploaders
+			return false, nil
+		}
+		return false, err
+	}
+	return claimant == uploader, nil
+}
+
+// LikePackage records the like and counts it in one statement, so a failure
+// can't leave a like uncounted
+func (r *postgresPackageRepository) LikePackage(ctx context.Context, packageID int32, liker string) (bool, error) {
+	counted, err := r.queries.AddPackageLike(ctx, postgres.AddPackageLikeParams{
+		PackageID: packageID,
+		Liker:     liker,
+	})
+	if err != nil {
+		return false, err
+	}
+	return counted > 0, nil
+}
+
+func (r *postgresPackageRepository) IncrementDownloadCount(ctx context.Context, packageID int32) error {
+	return r.queries.IncrementDownloadCount(ctx, packageID)
+}
+
+func (r *postgresPackageRepository) RecordDownload(ctx context.Context, packageID int32, version string, day time.Time) error {
+	return r.queries.IncrementDownloadEvent(ctx, postgres.IncrementDownloadEventParams{
+		PackageID: packageID,
+		Version:   version,
+		Day:       day,
+	})
+}
+
+// downloadCount is a row of the counts AddDownloadCounts writes in one statement
+type downloadCount struct {
+	PackageID int32  `json:"package_id"`
+	Version   string `json:"version"`
+	Day       string `json:"day"`
+	Count     int64  `json:"count"`
+}
+
+// AddDownloadCounts writes every count in one statement. Counts of packages
+// deleted since they were counted are dropped rather than failing the rest.
+func (r *postgresPackageRepository) AddDownloadCounts(ctx context.Context, counts map[domain.DownloadKey]int64) error {
+	rows := make([]downloadCount, 0, len(counts))
+	for key, count := range counts {
+		rows = append(rows, downloadCount{
+			PackageID: key.PackageID,
+			Version:   key.Version,
+			Day:       key.Day.Format(time.DateOnly),
+			Count:     count,
+		})
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	return r.queries.AddDownloadCounts(ctx, data)
+}
+
+func (r *postgresPackageRepository) GetDownloadHistory(ctx context.Context, packageID int32, since time.Time) ([]*domain.VersionDownloads, error) {
+	rows, err := r.queries.GetDownloadEvents(ctx, postgres.GetDownloadEventsParams{
+		PackageID: packageID,
+		Day:       since,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.VersionDownloads, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.VersionDownloads{
+			Day:     row.Day,
+			Version: row.Version,
+			Count:   row.Count,
+		}
+	}
+	return result, nil
+}
+
+func (r *postgresPackageRepository) GetInstanceStats(ctx context.Context) (*domain.InstanceStats, error) {
+	row, err := r.queries.GetInstanceStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.InstanceStats{
+		Packages:     row.PackageCount,
+		Versions:     row.VersionCount,
+		StorageBytes: row.StorageBytes,
+		Downloads:    row.DownloadCount,
+		Publishers:   row.PublisherCount,
+	}, nil
+}
+
+func (r *postgresPackageRepository) CreateToken(ctx context.Context, token *domain.Token) (*domain.Token, error) {
+	created, err := r.queries.CreateToken(ctx, postgres.CreateTokenParams{
+		Name:      token.Name,
+		TokenHash: token.TokenHash,
+		Scope:     string(token.Scope),
+		ExpiresAt: ptrToNullTime(token.ExpiresAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokenFromRow(created), nil
+}
+
+func (r *postgresPackageRepository) GetTokenByHash(ctx context.Context, tokenHash string) (*domain.Token, error) {
+	token, err := r.queries.GetTokenByHash(ctx, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return tokenFromRow(token), nil
+}
+
+func (r *postgresPackageRepository) ListTokens(ctx context.Context) ([]*domain.Token, error) {
+	tokens, err := r.queries.ListTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Token, len(tokens))
+	for i, token := range tokens {
+		result[i] = tokenFromRow(token)
+	}
+	return result, nil
+}
+
+func (r *postgresPackageRepository) RevokeToken(ctx context.Context, tokenID int32) (bool, error) {
+	revoked, err := r.queries.RevokeToken(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+	return revoked > 0, nil
+}
+
+func (r *postgresPackageRepository) CreateReport(ctx context.Context, report *domain.Report) (*domain.Report, error) {
+	created, err := r.queries.CreateReport(ctx, postgres.CreateReportParams{
+		PackageID: report.PackageID,
+		Version:   report.Version,
+		Reporter:  report.Reporter,
+		Reason:    report.Reason,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("%w: %s %s by %s", ErrReportExists, report.Package, report.Version, report.Reporter)
+		}
+		return nil, err
+	}
+	return &domain.Report{
+		ID:        created.ID,
+		PackageID: created.PackageID,
+		Package:   report.Package,
+		Version:   created.Version,
+		Reporter:  created.Reporter,
+		Reason:    created.Reason,
+		CreatedAt: created.CreatedAt,
+	}, nil
+}
+
+func (r *postgresPackageRepository) ListReports(ctx context.Context, before, limit int32) ([]*domain.Report, error) {
+	rows, err := r.queries.ListReports(ctx, postgres.ListReportsParams{Before: before, MaxReports: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Report, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.Report{
+			ID:        row.ID,
+			Package:   row.PackageName,
+			Version:   row.Version,
+			Reporter:  row.Reporter,
+			Reason:    row.Reason,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+func versionsFromRows(versions []postgres.PackageVersion) []*domain.PackageVersion {
+	result := make([]*domain.PackageVersion, len(versions))
+	for i, v := range versions {
+		result[i] = &domain.PackageVersion{
+			ID:            v.ID,
+			PackageID:     v.PackageID,
+			Version:       v.Version,
+			Description:   nullStringToPtr(v.Description),
+			PubspecYaml:   v.PubspecYaml,
+			Readme:        nullStringToPtr(v.Readme),
+			Changelog:     nullStringToPtr(v.Changelog),
+			ArchivePath:   v.ArchivePath,
+			ArchiveSha256: nullStringToPtr(v.ArchiveSha256),
+			Uploader:      nullStringToPtr(v.Uploader),
+			Retracted:     v.Retracted,
+			CreatedAt:     v.CreatedAt,
+			Platforms:     listFromJSON[string](v.Platforms),
+			SizeBytes:     nullInt64ToPtr(v.SizeBytes),
+			Funding:       listFromJSON[string](v.Funding),
+			Screenshots:   listFromJSON[domain.Screenshot](v.Screenshots),
+			Proxied:       v.Proxied,
+			ReadmeHTML:    nullStringToPtr(v.ReadmeHtml),
+			ChangelogHTML: nullStringToPtr(v.ChangelogHtml),
+		}
+	}
+
+	return result
+}
+
+func tokenFromRow(token postgres.Token) *domain.Token {
+	return &domain.Token{
+		ID:        token.ID,
+		Name:      token.Name,
+		TokenHash: token.TokenHash,
+		Scope:     domain.TokenScope(token.Scope),
+		ExpiresAt: nullTimeToPtr(token.ExpiresAt),
+		RevokedAt: nullTimeToPtr(token.RevokedAt),
+		CreatedAt: token.CreatedAt,
+	}
+}
+
 func nullStringToPtr(ns sql.NullString) *string {
 	if ns.Valid {
 		return &ns.String
 	}
 	return nil
 }
+
+func nullInt64ToPtr(ni sql.NullInt64) *int64 {
+	if ni.Valid {
+		return &ni.Int64
+	}
+	return nil
+}
+
+func nullTimeToPtr(nt sql.NullTime) *time.Time {
+	if nt.Valid {
+		return &nt.Time
+	}
+	return nil
+}
+
+func ptrToNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// listFromJSON decodes a stored JSON array column such as platforms,
+// ignoring malformed values
+func listFromJSON[T any](data json.RawMessage) []T {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+	return items
+}
+
+func listToJSON[T any](items []T) json.RawMessage {
+	if len(items) == 0 {
+		return json.RawMessage("[]")
+	}
+	// The stored element types are plain strings and structs of strings,
+	// marshalling them cannot fail
+	data, _ := json.Marshal(items)
+	return data
+}
@@ -6,9 +6,17 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
+type DownloadEvent struct {
+	PackageID int32     `json:"package_id"`
+	Version   string    `json:"version"`
+	Day       time.Time `json:"day"`
+	Count     int64     `json:"count"`
+}
+
 type Package struct {
 	ID            int32          `json:"id"`
 	Name          string         `json:"name"`
@@ -19,6 +27,19 @@ type Package struct {
 	Documentation sql.NullString `json:"documentation"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
+	LikeCount     int64          `json:"like_count"`
+	DownloadCount int64          `json:"download_count"`
+}
+
+type PackageClaim struct {
+	PackageID int32  `json:"package_id"`
+	Uploader  string `json:"uploader"`
+}
+
+type PackageLike struct {
+	PackageID int32     `json:"package_id"`
+	Liker     string    `json:"liker"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type PackageUploader struct {
@@ -27,16 +48,42 @@ type PackageUploader struct {
 }
 
 type PackageVersion struct {
-	ID            int32          `json:"id"`
-	PackageID     int32          `json:"package_id"`
-	Version       string         `json:"version"`
-	Description   sql.NullString `json:"description"`
-	PubspecYaml   string         `json:"pubspec_yaml"`
-	Readme        sql.NullString `json:"readme"`
-	Changelog     sql.NullString `json:"changelog"`
-	ArchivePath   string         `json:"archive_path"`
-	ArchiveSha256 sql.NullString `json:"archive_sha256"`
-	Uploader      sql.NullString `json:"uploader"`
-	Retracted     bool           `json:"retracted"`
-	CreatedAt     time.Time      `json:"created_at"`
+	ID            int32           `json:"id"`
+	PackageID     int32           `json:"package_id"`
+	Version       string          `json:"version"`
+	Description   sql.NullString  `json:"description"`
+	PubspecYaml   string          `json:"pubspec_yaml"`
+	Readme        sql.NullString  `json:"readme"`
+	Changelog     sql.NullString  `json:"changelog"`
+	ArchivePath   string          `json:"archive_path"`
+	ArchiveSha256 sql.NullString  `json:"archive_sha256"`
+	Uploader      sql.NullString  `json:"uploader"`
+	Retracted     bool            `json:"retracted"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Platforms     json.RawMessage `json:"platforms"`
+	SizeBytes     sql.NullInt64   `json:"size_bytes"`
+	Funding       json.RawMessage `json:"funding"`
+	Screenshots   json.RawMessage `json:"screenshots"`
+	Proxied       bool            `json:"proxied"`
+	ReadmeHtml    sql.NullString  `json:"readme_html"`
+	ChangelogHtml sql.NullString  `json:"changelog_html"`
+}
+
+type Report struct {
+	ID        int32     `json:"id"`
+	PackageID int32     `json:"package_id"`
+	Version   string    `json:"version"`
+	Reporter  string    `json:"reporter"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Token struct {
+	ID        int32        `json:"id"`
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Scope     string       `json:"scope"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	CreatedAt time.Time    `json:"created_at"`
 }
@@ -8,8 +8,54 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+const addDownloadCounts = `-- name: AddDownloadCounts :exec
+WITH counts AS (
+    SELECT c.package_id, c.version, c.day, c.count
+    FROM jsonb_to_recordset($1::jsonb) AS c(package_id INTEGER, version TEXT, day DATE, count BIGINT)
+    JOIN packages ON packages.id = c.package_id
+), buckets AS (
+    INSERT INTO download_events (package_id, version, day, count)
+    SELECT package_id, version, day, count FROM counts
+    ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + EXCLUDED.count
+)
+UPDATE packages SET download_count = download_count + totals.count
+FROM (SELECT package_id, SUM(count)::BIGINT AS count FROM counts GROUP BY package_id) AS totals
+WHERE packages.id = totals.package_id
+`
+
+func (q *Queries) AddDownloadCounts(ctx context.Context, counts json.RawMessage) error {
+	_, err := q.db.ExecContext(ctx, addDownloadCounts, counts)
+	return err
+}
+
+const addPackageLike = `-- name: AddPackageLike :execrows
+WITH inserted AS (
+    INSERT INTO package_likes (package_id, liker)
+    VALUES ($1, $2)
+    ON CONFLICT (package_id, liker) DO NOTHING
+    RETURNING package_id
+)
+UPDATE packages SET like_count = like_count + 1
+WHERE id IN (SELECT package_id FROM inserted)
+`
+
+type AddPackageLikeParams struct {
+	PackageID int32  `json:"package_id"`
+	Liker     string `json:"liker"`
+}
+
+func (q *Queries) AddPackageLike(ctx context.Context, arg AddPackageLikeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addPackageLike, arg.PackageID, arg.Liker)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const addPackageUploader = `-- name: AddPackageUploader :exec
 INSERT INTO package_uploaders (package_id, uploader)
 VALUES ($1, $2)
@@ -26,10 +72,30 @@ func (q *Queries) AddPackageUploader(ctx context.Context, arg AddPackageUploader
 	return err
 }
 
+const claimPackage = `-- name: ClaimPackage :one
+INSERT INTO package_claims (package_id, uploader)
+SELECT $1, $2
+WHERE NOT EXISTS (SELECT 1 FROM package_uploaders WHERE package_id = $1)
+ON CONFLICT (package_id) DO UPDATE SET uploader = package_claims.uploader
+RETURNING uploader
+`
+
+type ClaimPackageParams struct {
+	PackageID int32  `json:"package_id"`
+	Uploader  string `json:"uploader"`
+}
+
+func (q *Queries) ClaimPackage(ctx context.Context, arg ClaimPackageParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, claimPackage, arg.PackageID, arg.Uploader)
+	var uploader string
+	err := row.Scan(&uploader)
+	return uploader, err
+}
+
 const createPackage = `-- name: CreatePackage :one
 INSERT INTO packages (name, private, description, homepage, repository, documentation)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count
 `
 
 type CreatePackageParams struct {
@@ -61,6 +127,39 @@ func (q *Queries) CreatePackage(ctx context.Context, arg CreatePackageParams) (P
 		&i.Documentation,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
+	)
+	return i, err
+}
+
+const createPackageIfNotExists = `-- name: CreatePackageIfNotExists :one
+INSERT INTO packages (name, private)
+VALUES ($1, $2)
+ON CONFLICT (name) DO NOTHING
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count
+`
+
+type CreatePackageIfNotExistsParams struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+}
+
+func (q *Queries) CreatePackageIfNotExists(ctx context.Context, arg CreatePackageIfNotExistsParams) (Package, error) {
+	row := q.db.QueryRowContext(ctx, createPackageIfNotExists, arg.Name, arg.Private)
+	var i Package
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Private,
+		&i.Description,
+		&i.Homepage,
+		&i.Repository,
+		&i.Documentation,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
 	)
 	return i, err
 }
@@ -68,21 +167,26 @@ func (q *Queries) CreatePackage(ctx context.Context, arg CreatePackageParams) (P
 const createPackageVersion = `-- name: CreatePackageVersion :one
 INSERT INTO package_versions (
     package_id, version, description, pubspec_yaml, readme, changelog,
-    archive_path, archive_sha256, uploader
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at
+    archive_path, archive_sha256, uploader, platforms, size_bytes, funding, screenshots, proxied
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html
 `
 
 type CreatePackageVersionParams struct {
-	PackageID     int32          `json:"package_id"`
-	Version       string         `json:"version"`
-	Description   sql.NullString `json:"description"`
-	PubspecYaml   string         `json:"pubspec_yaml"`
-	Readme        sql.NullString `json:"readme"`
-	Changelog     sql.NullString `json:"changelog"`
-	ArchivePath   string         `json:"archive_path"`
-	ArchiveSha256 sql.NullString `json:"archive_sha256"`
-	Uploader      sql.NullString `json:"uploader"`
+	PackageID     int32           `json:"package_id"`
+	Version       string          `json:"version"`
+	Description   sql.NullString  `json:"description"`
+	PubspecYaml   string          `json:"pubspec_yaml"`
+	Readme        sql.NullString  `json:"readme"`
+	Changelog     sql.NullString  `json:"changelog"`
+	ArchivePath   string          `json:"archive_path"`
+	ArchiveSha256 sql.NullString  `json:"archive_sha256"`
+	Uploader      sql.NullString  `json:"uploader"`
+	Platforms     json.RawMessage `json:"platforms"`
+	SizeBytes     sql.NullInt64   `json:"size_bytes"`
+	Funding       json.RawMessage `json:"funding"`
+	Screenshots   json.RawMessage `json:"screenshots"`
+	Proxied       bool            `json:"proxied"`
 }
 
 func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVersionParams) (PackageVersion, error) {
@@ -96,6 +200,11 @@ func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVer
 		arg.ArchivePath,
 		arg.ArchiveSha256,
 		arg.Uploader,
+		arg.Platforms,
+		arg.SizeBytes,
+		arg.Funding,
+		arg.Screenshots,
+		arg.Proxied,
 	)
 	var i PackageVersion
 	err := row.Scan(
@@ -111,12 +220,163 @@ func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVer
 		&i.Uploader,
 		&i.Retracted,
 		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (package_id, version, reporter, reason)
+VALUES ($1, $2, $3, $4)
+RETURNING id, package_id, version, reporter, reason, created_at
+`
+
+type CreateReportParams struct {
+	PackageID int32  `json:"package_id"`
+	Version   string `json:"version"`
+	Reporter  string `json:"reporter"`
+	Reason    string `json:"reason"`
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.PackageID,
+		arg.Version,
+		arg.Reporter,
+		arg.Reason,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Reporter,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createToken = `-- name: CreateToken :one
+INSERT INTO tokens (name, token_hash, scope, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, name, token_hash, scope, expires_at, revoked_at, created_at
+`
+
+type CreateTokenParams struct {
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Scope     string       `json:"scope"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+}
+
+func (q *Queries) CreateToken(ctx context.Context, arg CreateTokenParams) (Token, error) {
+	row := q.db.QueryRowContext(ctx, createToken,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scope,
+		arg.ExpiresAt,
+	)
+	var i Token
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deletePackageVersion = `-- name: DeletePackageVersion :exec
+DELETE FROM package_versions WHERE id = $1
+`
+
+func (q *Queries) DeletePackageVersion(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, deletePackageVersion, id)
+	return err
+}
+
+const getDownloadEvents = `-- name: GetDownloadEvents :many
+SELECT day, version, count FROM download_events
+WHERE package_id = $1 AND day >= $2
+ORDER BY day, version
+`
+
+type GetDownloadEventsParams struct {
+	PackageID int32     `json:"package_id"`
+	Day       time.Time `json:"day"`
+}
+
+type GetDownloadEventsRow struct {
+	Day     time.Time `json:"day"`
+	Version string    `json:"version"`
+	Count   int64     `json:"count"`
+}
+
+func (q *Queries) GetDownloadEvents(ctx context.Context, arg GetDownloadEventsParams) ([]GetDownloadEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDownloadEvents, arg.PackageID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDownloadEventsRow
+	for rows.Next() {
+		var i GetDownloadEventsRow
+		if err := rows.Scan(&i.Day, &i.Version, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInstanceStats = `-- name: GetInstanceStats :one
+SELECT
+    (SELECT COUNT(*) FROM packages)::BIGINT AS package_count,
+    (SELECT COUNT(*) FROM package_versions)::BIGINT AS version_count,
+    (SELECT COALESCE(SUM(size_bytes), 0) FROM package_versions)::BIGINT AS storage_bytes,
+    (SELECT COALESCE(SUM(download_count), 0) FROM packages)::BIGINT AS download_count,
+    (SELECT COUNT(DISTINCT uploader) FROM package_versions)::BIGINT AS publisher_count
+`
+
+type GetInstanceStatsRow struct {
+	PackageCount   int64 `json:"package_count"`
+	VersionCount   int64 `json:"version_count"`
+	StorageBytes   int64 `json:"storage_bytes"`
+	DownloadCount  int64 `json:"download_count"`
+	PublisherCount int64 `json:"publisher_count"`
+}
+
+func (q *Queries) GetInstanceStats(ctx context.Context) (GetInstanceStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getInstanceStats)
+	var i GetInstanceStatsRow
+	err := row.Scan(
+		&i.PackageCount,
+		&i.VersionCount,
+		&i.StorageBytes,
+		&i.DownloadCount,
+		&i.PublisherCount,
 	)
 	return i, err
 }
 
 const getLatestPackageVersion = `-- name: GetLatestPackageVersion :one
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = $1 AND retracted = false
 ORDER BY created_at DESC 
 LIMIT 1
@@ -138,12 +398,19 @@ func (q *Queries) GetLatestPackageVersion(ctx context.Context, packageID int32)
 		&i.Uploader,
 		&i.Retracted,
 		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
 	)
 	return i, err
 }
 
 const getPackage = `-- name: GetPackage :one
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages WHERE name = $1
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages WHERE name = $1
 `
 
 func (q *Queries) GetPackage(ctx context.Context, name string) (Package, error) {
@@ -159,6 +426,8 @@ func (q *Queries) GetPackage(ctx context.Context, name string) (Package, error)
 		&i.Documentation,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
 	)
 	return i, err
 }
@@ -190,10 +459,47 @@ func (q *Queries) GetPackageUploaders(ctx context.Context, packageID int32) ([]s
 	return items, nil
 }
 
+const getPackageVersion = `-- name: GetPackageVersion :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = $1 AND version = $2
+`
+
+type GetPackageVersionParams struct {
+	PackageID int32  `json:"package_id"`
+	Version   string `json:"version"`
+}
+
+func (q *Queries) GetPackageVersion(ctx context.Context, arg GetPackageVersionParams) (PackageVersion, error) {
+	row := q.db.QueryRowContext(ctx, getPackageVersion, arg.PackageID, arg.Version)
+	var i PackageVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Description,
+		&i.PubspecYaml,
+		&i.Readme,
+		&i.Changelog,
+		&i.ArchivePath,
+		&i.ArchiveSha256,
+		&i.Uploader,
+		&i.Retracted,
+		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
 const getPackageVersions = `-- name: GetPackageVersions :many
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = $1 
-ORDER BY created_at DESC
+ORDER BY created_at DESC, id DESC
 `
 
 func (q *Queries) GetPackageVersions(ctx context.Context, packageID int32) ([]PackageVersion, error) {
@@ -218,6 +524,75 @@ func (q *Queries) GetPackageVersions(ctx context.Context, packageID int32) ([]Pa
 			&i.Uploader,
 			&i.Retracted,
 			&i.CreatedAt,
+			&i.Platforms,
+			&i.SizeBytes,
+			&i.Funding,
+			&i.Screenshots,
+			&i.Proxied,
+			&i.ReadmeHtml,
+			&i.ChangelogHtml,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPackageVersionsPage = `-- name: GetPackageVersionsPage :many
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = $1 AND (created_at, id) < ($2::timestamptz, $3::integer)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetPackageVersionsPageParams struct {
+	PackageID   int32     `json:"package_id"`
+	LastCreated time.Time `json:"last_created"`
+	LastID      int32     `json:"last_id"`
+	Limit       int32     `json:"limit"`
+}
+
+func (q *Queries) GetPackageVersionsPage(ctx context.Context, arg GetPackageVersionsPageParams) ([]PackageVersion, error) {
+	rows, err := q.db.QueryContext(ctx, getPackageVersionsPage,
+		arg.PackageID,
+		arg.LastCreated,
+		arg.LastID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackageVersion
+	for rows.Next() {
+		var i PackageVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackageID,
+			&i.Version,
+			&i.Description,
+			&i.PubspecYaml,
+			&i.Readme,
+			&i.Changelog,
+			&i.ArchivePath,
+			&i.ArchiveSha256,
+			&i.Uploader,
+			&i.Retracted,
+			&i.CreatedAt,
+			&i.Platforms,
+			&i.SizeBytes,
+			&i.Funding,
+			&i.Screenshots,
+			&i.Proxied,
+			&i.ReadmeHtml,
+			&i.ChangelogHtml,
 		); err != nil {
 			return nil, err
 		}
@@ -232,8 +607,126 @@ func (q *Queries) GetPackageVersions(ctx context.Context, packageID int32) ([]Pa
 	return items, nil
 }
 
+const getTokenByHash = `-- name: GetTokenByHash :one
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens WHERE token_hash = $1
+`
+
+func (q *Queries) GetTokenByHash(ctx context.Context, tokenHash string) (Token, error) {
+	row := q.db.QueryRowContext(ctx, getTokenByHash, tokenHash)
+	var i Token
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getVersionByArchiveSha256 = `-- name: GetVersionByArchiveSha256 :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = $1 AND archive_sha256 = $2
+ORDER BY created_at
+LIMIT 1
+`
+
+type GetVersionByArchiveSha256Params struct {
+	PackageID     int32          `json:"package_id"`
+	ArchiveSha256 sql.NullString `json:"archive_sha256"`
+}
+
+func (q *Queries) GetVersionByArchiveSha256(ctx context.Context, arg GetVersionByArchiveSha256Params) (PackageVersion, error) {
+	row := q.db.QueryRowContext(ctx, getVersionByArchiveSha256, arg.PackageID, arg.ArchiveSha256)
+	var i PackageVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Description,
+		&i.PubspecYaml,
+		&i.Readme,
+		&i.Changelog,
+		&i.ArchivePath,
+		&i.ArchiveSha256,
+		&i.Uploader,
+		&i.Retracted,
+		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
+const incrementDownloadCount = `-- name: IncrementDownloadCount :exec
+UPDATE packages SET download_count = download_count + 1 WHERE id = $1
+`
+
+func (q *Queries) IncrementDownloadCount(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, incrementDownloadCount, id)
+	return err
+}
+
+const incrementDownloadEvent = `-- name: IncrementDownloadEvent :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + 1
+`
+
+type IncrementDownloadEventParams struct {
+	PackageID int32     `json:"package_id"`
+	Version   string    `json:"version"`
+	Day       time.Time `json:"day"`
+}
+
+func (q *Queries) IncrementDownloadEvent(ctx context.Context, arg IncrementDownloadEventParams) error {
+	_, err := q.db.ExecContext(ctx, incrementDownloadEvent, arg.PackageID, arg.Version, arg.Day)
+	return err
+}
+
+const listPackageVersionNumbers = `-- name: ListPackageVersionNumbers :many
+SELECT id, version, retracted FROM package_versions
+WHERE package_id = $1
+`
+
+type ListPackageVersionNumbersRow struct {
+	ID        int32  `json:"id"`
+	Version   string `json:"version"`
+	Retracted bool   `json:"retracted"`
+}
+
+func (q *Queries) ListPackageVersionNumbers(ctx context.Context, packageID int32) ([]ListPackageVersionNumbersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPackageVersionNumbers, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPackageVersionNumbersRow
+	for rows.Next() {
+		var i ListPackageVersionNumbersRow
+		if err := rows.Scan(&i.ID, &i.Version, &i.Retracted); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPackages = `-- name: ListPackages :many
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages 
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages 
 ORDER BY name
 LIMIT $1 OFFSET $2
 `
@@ -262,6 +755,8 @@ func (q *Queries) ListPackages(ctx context.Context, arg ListPackagesParams) ([]P
 			&i.Documentation,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
 		); err != nil {
 			return nil, err
 		}
@@ -276,6 +771,461 @@ func (q *Queries) ListPackages(ctx context.Context, arg ListPackagesParams) ([]P
 	return items, nil
 }
 
+const listPackagesByDownloads = `-- name: ListPackagesByDownloads :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY download_count DESC, name
+LIMIT $1 OFFSET $2
+`
+
+type ListPackagesByDownloadsParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListPackagesByDownloads(ctx context.Context, arg ListPackagesByDownloadsParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesByDownloads, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesByUpdated = `-- name: ListPackagesByUpdated :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY COALESCE((SELECT MAX(created_at) FROM package_versions WHERE package_id = packages.id), updated_at) DESC, name
+LIMIT $1 OFFSET $2
+`
+
+type ListPackagesByUpdatedParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListPackagesByUpdated(ctx context.Context, arg ListPackagesByUpdatedParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesByUpdated, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesPage = `-- name: ListPackagesPage :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE (updated_at, id) > ($1::timestamptz, $2::integer)
+ORDER BY updated_at, id
+LIMIT $3
+`
+
+type ListPackagesPageParams struct {
+	LastUpdated time.Time `json:"last_updated"`
+	LastID      int32     `json:"last_id"`
+	Limit       int32     `json:"limit"`
+}
+
+func (q *Queries) ListPackagesPage(ctx context.Context, arg ListPackagesPageParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesPage, arg.LastUpdated, arg.LastID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesUpdatedSince = `-- name: ListPackagesUpdatedSince :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE updated_at > $1
+ORDER BY updated_at, name
+LIMIT $2 OFFSET $3
+`
+
+type ListPackagesUpdatedSinceParams struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Limit     int32     `json:"limit"`
+	Offset    int32     `json:"offset"`
+}
+
+func (q *Queries) ListPackagesUpdatedSince(ctx context.Context, arg ListPackagesUpdatedSinceParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesUpdatedSince, arg.UpdatedAt, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPublicVersions = `-- name: ListPublicVersions :many
+SELECT p.name, p.updated_at, v.version, v.created_at AS version_created_at
+FROM packages p
+LEFT JOIN package_versions v ON v.package_id = p.id
+WHERE NOT p.private
+ORDER BY p.name, v.created_at DESC, v.id DESC
+`
+
+type ListPublicVersionsRow struct {
+	Name             string         `json:"name"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Version          sql.NullString `json:"version"`
+	VersionCreatedAt sql.NullTime   `json:"version_created_at"`
+}
+
+func (q *Queries) ListPublicVersions(ctx context.Context) ([]ListPublicVersionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPublicVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPublicVersionsRow
+	for rows.Next() {
+		var i ListPublicVersionsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.VersionCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReports = `-- name: ListReports :many
+SELECT r.id, p.name AS package_name, r.version, r.reporter, r.reason, r.created_at
+FROM reports r
+JOIN packages p ON p.id = r.package_id
+WHERE r.id < $1::integer
+ORDER BY r.id DESC
+LIMIT $2
+`
+
+type ListReportsParams struct {
+	Before     int32 `json:"before"`
+	MaxReports int32 `json:"max_reports"`
+}
+
+type ListReportsRow struct {
+	ID          int32     `json:"id"`
+	PackageName string    `json:"package_name"`
+	Version     string    `json:"version"`
+	Reporter    string    `json:"reporter"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (q *Queries) ListReports(ctx context.Context, arg ListReportsParams) ([]ListReportsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReports, arg.Before, arg.MaxReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReportsRow
+	for rows.Next() {
+		var i ListReportsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackageName,
+			&i.Version,
+			&i.Reporter,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTokens = `-- name: ListTokens :many
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens ORDER BY id
+`
+
+func (q *Queries) ListTokens(ctx context.Context) ([]Token, error) {
+	rows, err := q.db.QueryContext(ctx, listTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Token
+	for rows.Next() {
+		var i Token
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scope,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restorePackageHistory = `-- name: RestorePackageHistory :exec
+UPDATE packages
+SET created_at = LEAST(created_at, $1::timestamptz),
+    like_count = GREATEST(like_count, $2::bigint),
+    download_count = GREATEST(download_count, $3::bigint)
+WHERE id = $4
+`
+
+type RestorePackageHistoryParams struct {
+	CreatedAt     time.Time `json:"created_at"`
+	LikeCount     int64     `json:"like_count"`
+	DownloadCount int64     `json:"download_count"`
+	ID            int32     `json:"id"`
+}
+
+func (q *Queries) RestorePackageHistory(ctx context.Context, arg RestorePackageHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, restorePackageHistory,
+		arg.CreatedAt,
+		arg.LikeCount,
+		arg.DownloadCount,
+		arg.ID,
+	)
+	return err
+}
+
+const revokeToken = `-- name: RevokeToken :execrows
+UPDATE tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeToken(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setPackagePrivate = `-- name: SetPackagePrivate :exec
+UPDATE packages SET private = $2, updated_at = NOW() WHERE id = $1
+`
+
+type SetPackagePrivateParams struct {
+	ID      int32 `json:"id"`
+	Private bool  `json:"private"`
+}
+
+func (q *Queries) SetPackagePrivate(ctx context.Context, arg SetPackagePrivateParams) error {
+	_, err := q.db.ExecContext(ctx, setPackagePrivate, arg.ID, arg.Private)
+	return err
+}
+
+const setPackageVersionArchivePath = `-- name: SetPackageVersionArchivePath :exec
+UPDATE package_versions SET archive_path = $2 WHERE id = $1
+`
+
+type SetPackageVersionArchivePathParams struct {
+	ID          int32  `json:"id"`
+	ArchivePath string `json:"archive_path"`
+}
+
+func (q *Queries) SetPackageVersionArchivePath(ctx context.Context, arg SetPackageVersionArchivePathParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionArchivePath, arg.ID, arg.ArchivePath)
+	return err
+}
+
+const setPackageVersionCreatedAt = `-- name: SetPackageVersionCreatedAt :exec
+UPDATE package_versions SET created_at = $2 WHERE id = $1
+`
+
+type SetPackageVersionCreatedAtParams struct {
+	ID        int32     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) SetPackageVersionCreatedAt(ctx context.Context, arg SetPackageVersionCreatedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionCreatedAt, arg.ID, arg.CreatedAt)
+	return err
+}
+
+const setPackageVersionDocsHTML = `-- name: SetPackageVersionDocsHTML :exec
+UPDATE package_versions SET readme_html = $2, changelog_html = $3 WHERE id = $1
+`
+
+type SetPackageVersionDocsHTMLParams struct {
+	ID            int32          `json:"id"`
+	ReadmeHtml    sql.NullString `json:"readme_html"`
+	ChangelogHtml sql.NullString `json:"changelog_html"`
+}
+
+func (q *Queries) SetPackageVersionDocsHTML(ctx context.Context, arg SetPackageVersionDocsHTMLParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionDocsHTML, arg.ID, arg.ReadmeHtml, arg.ChangelogHtml)
+	return err
+}
+
+const setPackageVersionRetracted = `-- name: SetPackageVersionRetracted :exec
+UPDATE package_versions SET retracted = $2 WHERE id = $1
+`
+
+type SetPackageVersionRetractedParams struct {
+	ID        int32 `json:"id"`
+	Retracted bool  `json:"retracted"`
+}
+
+func (q *Queries) SetPackageVersionRetracted(ctx context.Context, arg SetPackageVersionRetractedParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionRetracted, arg.ID, arg.Retracted)
+	return err
+}
+
+const setPackageVersionSize = `-- name: SetPackageVersionSize :exec
+UPDATE package_versions SET size_bytes = $2 WHERE id = $1
+`
+
+type SetPackageVersionSizeParams struct {
+	ID        int32         `json:"id"`
+	SizeBytes sql.NullInt64 `json:"size_bytes"`
+}
+
+func (q *Queries) SetPackageVersionSize(ctx context.Context, arg SetPackageVersionSizeParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionSize, arg.ID, arg.SizeBytes)
+	return err
+}
+
+const touchPackage = `-- name: TouchPackage :exec
+UPDATE packages SET updated_at = $2 WHERE id = $1
+`
+
+type TouchPackageParams struct {
+	ID        int32     `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) TouchPackage(ctx context.Context, arg TouchPackageParams) error {
+	_, err := q.db.ExecContext(ctx, touchPackage, arg.ID, arg.UpdatedAt)
+	return err
+}
+
 const updatePackageMetadata = `-- name: UpdatePackageMetadata :exec
 UPDATE packages 
 SET description = $2, homepage = $3, repository = $4, documentation = $5, updated_at = NOW()
@@ -1,13 +1,18 @@
 package pkg
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"repub/internal/domain"
 	"repub/internal/repository/pkg/postgres"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "modernc.org/sqlite"
 )
 
@@ -16,6 +21,11 @@ type mockQueries struct {
 	packages  map[string]*postgres.Package
 	versions  map[int32][]*postgres.PackageVersion
 	uploaders map[int32][]string
+	claims    map[int32]string
+	likes     map[int32]map[string]bool
+	downloads []*postgres.DownloadEvent
+	tokens    []*postgres.Token
+	reports   []*postgres.Report
 }
 
 func newMockQueries() *mockQueries {
@@ -23,9 +33,20 @@ func newMockQueries() *mockQueries {
 		packages:  make(map[string]*postgres.Package),
 		versions:  make(map[int32][]*postgres.PackageVersion),
 		uploaders: make(map[int32][]string),
+		claims:    make(map[int32]string),
+		likes:     make(map[int32]map[string]bool),
 	}
 }
 
+func (m *mockQueries) packageByID(id int32) *postgres.Package {
+	for _, pkg := range m.packages {
+		if pkg.ID == id {
+			return pkg
+		}
+	}
+	return nil
+}
+
 func (m *mockQueries) GetPackage(ctx context.Context, name string) (postgres.Package, error) {
 	pkg, exists := m.packages[name]
 	if !exists {
@@ -46,6 +67,13 @@ func (m *mockQueries) CreatePackage(ctx context.Context, params postgres.CreateP
 	return pkg, nil
 }
 
+func (m *mockQueries) CreatePackageIfNotExists(ctx context.Context, params postgres.CreatePackageIfNotExistsParams) (postgres.Package, error) {
+	if _, exists := m.packages[params.Name]; exists {
+		return postgres.Package{}, sql.ErrNoRows
+	}
+	return m.CreatePackage(ctx, postgres.CreatePackageParams{Name: params.Name, Private: params.Private})
+}
+
 func (m *mockQueries) ListPackages(ctx context.Context, params postgres.ListPackagesParams) ([]postgres.Package, error) {
 	var result []postgres.Package
 	for _, pkg := range m.packages {
@@ -54,6 +82,60 @@ func (m *mockQueries) ListPackages(ctx context.Context, params postgres.ListPack
 	return result, nil
 }
 
+func (m *mockQueries) ListPackagesByUpdated(ctx context.Context, params postgres.ListPackagesByUpdatedParams) ([]postgres.Package, error) {
+	result, _ := m.ListPackages(ctx, postgres.ListPackagesParams(params))
+	slices.SortFunc(result, func(a, b postgres.Package) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return result, nil
+}
+
+func (m *mockQueries) ListPackagesByDownloads(ctx context.Context, params postgres.ListPackagesByDownloadsParams) ([]postgres.Package, error) {
+	result, _ := m.ListPackages(ctx, postgres.ListPackagesParams(params))
+	slices.SortFunc(result, func(a, b postgres.Package) int { return cmp.Compare(b.DownloadCount, a.DownloadCount) })
+	return result, nil
+}
+
+func (m *mockQueries) ListPackagesUpdatedSince(ctx context.Context, params postgres.ListPackagesUpdatedSinceParams) ([]postgres.Package, error) {
+	result, _ := m.ListPackagesByUpdated(ctx, postgres.ListPackagesByUpdatedParams{Limit: params.Limit, Offset: params.Offset})
+	result = slices.DeleteFunc(result, func(pkg postgres.Package) bool { return !pkg.UpdatedAt.After(params.UpdatedAt) })
+	slices.Reverse(result)
+	return result, nil
+}
+
+func (m *mockQueries) ListPackagesPage(ctx context.Context, params postgres.ListPackagesPageParams) ([]postgres.Package, error) {
+	result, _ := m.ListPackages(ctx, postgres.ListPackagesParams{})
+	result = slices.DeleteFunc(result, func(pkg postgres.Package) bool {
+		return pkg.UpdatedAt.Before(params.LastUpdated) || pkg.UpdatedAt.Equal(params.LastUpdated) && pkg.ID <= params.LastID
+	})
+	slices.SortFunc(result, func(a, b postgres.Package) int {
+		return cmp.Or(a.UpdatedAt.Compare(b.UpdatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return result[:min(int(params.Limit), len(result))], nil
+}
+
+func (m *mockQueries) ListPublicVersions(ctx context.Context) ([]postgres.ListPublicVersionsRow, error) {
+	packages, _ := m.ListPackages(ctx, postgres.ListPackagesParams{})
+	slices.SortFunc(packages, func(a, b postgres.Package) int { return cmp.Compare(a.Name, b.Name) })
+	var result []postgres.ListPublicVersionsRow
+	for _, pkg := range packages {
+		if pkg.Private {
+			continue
+		}
+		versions := m.versions[pkg.ID]
+		if len(versions) == 0 {
+			result = append(result, postgres.ListPublicVersionsRow{Name: pkg.Name, UpdatedAt: pkg.UpdatedAt})
+		}
+		for _, v := range slices.Backward(versions) {
+			result = append(result, postgres.ListPublicVersionsRow{
+				Name:             pkg.Name,
+				UpdatedAt:        pkg.UpdatedAt,
+				Version:          sql.NullString{String: v.Version, Valid: true},
+				VersionCreatedAt: sql.NullTime{Time: v.CreatedAt, Valid: true},
+			})
+		}
+	}
+	return result, nil
+}
+
 func (m *mockQueries) GetPackageVersions(ctx context.Context, packageID int32) ([]postgres.PackageVersion, error) {
 	versions := m.versions[packageID]
 	var result []postgres.PackageVersion
@@ -71,9 +153,59 @@ func (m *mockQueries) GetLatestPackageVersion(ctx context.Context, packageID int
 	return *versions[0], nil
 }
 
+func (m *mockQueries) GetPackageVersionsPage(ctx context.Context, params postgres.GetPackageVersionsPageParams) ([]postgres.PackageVersion, error) {
+	versions, _ := m.GetPackageVersions(ctx, params.PackageID)
+	start := slices.IndexFunc(versions, func(v postgres.PackageVersion) bool {
+		return v.CreatedAt.Before(params.LastCreated) || v.CreatedAt.Equal(params.LastCreated) && v.ID < params.LastID
+	})
+	if start < 0 {
+		return nil, nil
+	}
+	end := min(start+int(params.Limit), len(versions))
+	return versions[start:end], nil
+}
+
+func (m *mockQueries) GetPackageVersion(ctx context.Context, params postgres.GetPackageVersionParams) (postgres.PackageVersion, error) {
+	for _, v := range m.versions[params.PackageID] {
+		if v.Version == params.Version {
+			return *v, nil
+		}
+	}
+	return postgres.PackageVersion{}, sql.ErrNoRows
+}
+
+func (m *mockQueries) ListPackageVersionNumbers(ctx context.Context, packageID int32) ([]postgres.ListPackageVersionNumbersRow, error) {
+	var rows []postgres.ListPackageVersionNumbersRow
+	for _, v := range m.versions[packageID] {
+		rows = append(rows, postgres.ListPackageVersionNumbersRow{ID: v.ID, Version: v.Version, Retracted: v.Retracted})
+	}
+	return rows, nil
+}
+
+func (m *mockQueries) GetVersionByArchiveSha256(ctx context.Context, params postgres.GetVersionByArchiveSha256Params) (postgres.PackageVersion, error) {
+	// Versions are kept newest first, the query returns the oldest match
+	for _, v := range slices.Backward(m.versions[params.PackageID]) {
+		if v.ArchiveSha256 == params.ArchiveSha256 {
+			return *v, nil
+		}
+	}
+	return postgres.PackageVersion{}, sql.ErrNoRows
+}
+
 func (m *mockQueries) CreatePackageVersion(ctx context.Context, params postgres.CreatePackageVersionParams) (postgres.PackageVersion, error) {
+	// Mirrors the UNIQUE(package_id, version) constraint
+	for _, v := range m.versions[params.PackageID] {
+		if v.Version == params.Version {
+			return postgres.PackageVersion{}, &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+		}
+	}
+
+	id := int32(1)
+	for _, versions := range m.versions {
+		id += int32(len(versions))
+	}
 	version := postgres.PackageVersion{
-		ID:            int32(len(m.versions) + 1),
+		ID:            id,
 		PackageID:     params.PackageID,
 		Version:       params.Version,
 		Description:   params.Description,
@@ -82,6 +214,7 @@ func (m *mockQueries) CreatePackageVersion(ctx context.Context, params postgres.
 		ArchiveSha256: params.ArchiveSha256,
 		Uploader:      params.Uploader,
 		Retracted:     false,
+		SizeBytes:     params.SizeBytes,
 		CreatedAt:     time.Now(),
 	}
 
@@ -98,6 +231,279 @@ func (m *mockQueries) AddPackageUploader(ctx context.Context, params postgres.Ad
 	return nil
 }
 
+func (m *mockQueries) ClaimPackage(ctx context.Context, params postgres.ClaimPackageParams) (string, error) {
+	if claimant, ok := m.claims[params.PackageID]; ok {
+		return claimant, nil
+	}
+	if len(m.uploaders[params.PackageID]) > 0 {
+		return "", sql.ErrNoRows
+	}
+	m.claims[params.PackageID] = params.Uploader
+	return params.Uploader, nil
+}
+
+func (m *mockQueries) AddPackageLike(ctx context.Context, params postgres.AddPackageLikeParams) (int64, error) {
+	if m.likes[params.PackageID] == nil {
+		m.likes[params.PackageID] = make(map[string]bool)
+	}
+	if m.likes[params.PackageID][params.Liker] {
+		return 0, nil
+	}
+	m.likes[params.PackageID][params.Liker] = true
+	if pkg := m.packageByID(params.PackageID); pkg != nil {
+		pkg.LikeCount++
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) IncrementDownloadCount(ctx context.Context, id int32) error {
+	if pkg := m.packageByID(id); pkg != nil {
+		pkg.DownloadCount++
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackagePrivate(ctx context.Context, params postgres.SetPackagePrivateParams) error {
+	if pkg := m.packageByID(params.ID); pkg != nil {
+		pkg.Private = params.Private
+	}
+	return nil
+}
+
+func (m *mockQueries) TouchPackage(ctx context.Context, params postgres.TouchPackageParams) error {
+	if pkg := m.packageByID(params.ID); pkg != nil {
+		pkg.UpdatedAt = params.UpdatedAt
+	}
+	return nil
+}
+
+func (m *mockQueries) RestorePackageHistory(ctx context.Context, params postgres.RestorePackageHistoryParams) error {
+	if pkg := m.packageByID(params.ID); pkg != nil {
+		if params.CreatedAt.Before(pkg.CreatedAt) {
+			pkg.CreatedAt = params.CreatedAt
+		}
+		pkg.LikeCount = max(pkg.LikeCount, params.LikeCount)
+		pkg.DownloadCount = max(pkg.DownloadCount, params.DownloadCount)
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackageVersionRetracted(ctx context.Context, params postgres.SetPackageVersionRetractedParams) error {
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			if v.ID == params.ID {
+				v.Retracted = params.Retracted
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackageVersionSize(ctx context.Context, params postgres.SetPackageVersionSizeParams) error {
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			if v.ID == params.ID {
+				v.SizeBytes = params.SizeBytes
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackageVersionCreatedAt(ctx context.Context, params postgres.SetPackageVersionCreatedAtParams) error {
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			if v.ID == params.ID {
+				v.CreatedAt = params.CreatedAt
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackageVersionArchivePath(ctx context.Context, params postgres.SetPackageVersionArchivePathParams) error {
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			if v.ID == params.ID {
+				v.ArchivePath = params.ArchivePath
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQueries) SetPackageVersionDocsHTML(ctx context.Context, params postgres.SetPackageVersionDocsHTMLParams) error {
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			if v.ID == params.ID {
+				v.ReadmeHtml = params.ReadmeHtml
+				v.ChangelogHtml = params.ChangelogHtml
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQueries) DeletePackageVersion(ctx context.Context, id int32) error {
+	for packageID, versions := range m.versions {
+		m.versions[packageID] = slices.DeleteFunc(versions, func(v *postgres.PackageVersion) bool {
+			return v.ID == id
+		})
+	}
+	return nil
+}
+
+func (m *mockQueries) IncrementDownloadEvent(ctx context.Context, params postgres.IncrementDownloadEventParams) error {
+	for _, e := range m.downloads {
+		if e.PackageID == params.PackageID && e.Version == params.Version && e.Day.Equal(params.Day) {
+			e.Count++
+			return nil
+		}
+	}
+	m.downloads = append(m.downloads, &postgres.DownloadEvent{PackageID: params.PackageID, Version: params.Version, Day: params.Day, Count: 1})
+	return nil
+}
+
+func (m *mockQueries) AddDownloadCounts(ctx context.Context, counts json.RawMessage) error {
+	var rows []downloadCount
+	if err := json.Unmarshal(counts, &rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		pkg := m.packageByID(row.PackageID)
+		if pkg == nil {
+			continue
+		}
+		pkg.DownloadCount += row.Count
+		day, err := time.Parse(time.DateOnly, row.Day)
+		if err != nil {
+			return err
+		}
+		m.addDownloadEvent(row.PackageID, row.Version, day, row.Count)
+	}
+	return nil
+}
+
+func (m *mockQueries) addDownloadEvent(packageID int32, version string, day time.Time, count int64) {
+	for _, e := range m.downloads {
+		if e.PackageID == packageID && e.Version == version && e.Day.Equal(day) {
+			e.Count += count
+			return
+		}
+	}
+	m.downloads = append(m.downloads, &postgres.DownloadEvent{PackageID: packageID, Version: version, Day: day, Count: count})
+}
+
+func (m *mockQueries) GetDownloadEvents(ctx context.Context, params postgres.GetDownloadEventsParams) ([]postgres.GetDownloadEventsRow, error) {
+	var rows []postgres.GetDownloadEventsRow
+	for _, e := range m.downloads {
+		if e.PackageID == params.PackageID && !e.Day.Before(params.Day) {
+			rows = append(rows, postgres.GetDownloadEventsRow{Day: e.Day, Version: e.Version, Count: e.Count})
+		}
+	}
+	return rows, nil
+}
+
+func (m *mockQueries) GetInstanceStats(ctx context.Context) (postgres.GetInstanceStatsRow, error) {
+	var row postgres.GetInstanceStatsRow
+	publishers := make(map[string]bool)
+	for _, p := range m.packages {
+		row.PackageCount++
+		row.DownloadCount += p.DownloadCount
+	}
+	for _, versions := range m.versions {
+		for _, v := range versions {
+			row.VersionCount++
+			row.StorageBytes += v.SizeBytes.Int64
+			if v.Uploader.Valid {
+				publishers[v.Uploader.String] = true
+			}
+		}
+	}
+	row.PublisherCount = int64(len(publishers))
+	return row, nil
+}
+
+func (m *mockQueries) CreateToken(ctx context.Context, params postgres.CreateTokenParams) (postgres.Token, error) {
+	token := &postgres.Token{
+		ID:        int32(len(m.tokens) + 1),
+		Name:      params.Name,
+		TokenHash: params.TokenHash,
+		Scope:     params.Scope,
+		ExpiresAt: params.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	m.tokens = append(m.tokens, token)
+	return *token, nil
+}
+
+func (m *mockQueries) GetTokenByHash(ctx context.Context, tokenHash string) (postgres.Token, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			return *token, nil
+		}
+	}
+	return postgres.Token{}, sql.ErrNoRows
+}
+
+func (m *mockQueries) ListTokens(ctx context.Context) ([]postgres.Token, error) {
+	var result []postgres.Token
+	for _, token := range m.tokens {
+		result = append(result, *token)
+	}
+	return result, nil
+}
+
+func (m *mockQueries) RevokeToken(ctx context.Context, id int32) (int64, error) {
+	for _, token := range m.tokens {
+		if token.ID == id && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) CreateReport(ctx context.Context, params postgres.CreateReportParams) (postgres.Report, error) {
+	for _, report := range m.reports {
+		if report.PackageID == params.PackageID && report.Version == params.Version && report.Reporter == params.Reporter {
+			return postgres.Report{}, &pgconn.PgError{Code: uniqueViolation}
+		}
+	}
+	report := &postgres.Report{
+		ID:        int32(len(m.reports) + 1),
+		PackageID: params.PackageID,
+		Version:   params.Version,
+		Reporter:  params.Reporter,
+		Reason:    params.Reason,
+		CreatedAt: time.Now(),
+	}
+	m.reports = append(m.reports, report)
+	return *report, nil
+}
+
+func (m *mockQueries) ListReports(ctx context.Context, params postgres.ListReportsParams) ([]postgres.ListReportsRow, error) {
+	var result []postgres.ListReportsRow
+	for i := len(m.reports) - 1; i >= 0 && len(result) < int(params.MaxReports); i-- {
+		report := m.reports[i]
+		if report.ID >= params.Before {
+			continue
+		}
+		row := postgres.ListReportsRow{
+			ID:        report.ID,
+			Version:   report.Version,
+			Reporter:  report.Reporter,
+			Reason:    report.Reason,
+			CreatedAt: report.CreatedAt,
+		}
+		if pkg := m.packageByID(report.PackageID); pkg != nil {
+			row.PackageName = pkg.Name
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
 func TestPostgresPackageRepository_GetPackage(t *testing.T) {
 	queries := newMockQueries()
 	repo := NewPostgresPackageRepository(queries)
@@ -150,6 +556,28 @@ func TestPostgresPackageRepository_CreatePackage(t *testing.T) {
 	}
 }
 
+func TestPostgresPackageRepository_GetOrCreatePackage(t *testing.T) {
+	queries := newMockQueries()
+	repo := NewPostgresPackageRepository(queries)
+	ctx := context.Background()
+
+	created, err := repo.GetOrCreatePackage(ctx, "testpkg", false)
+	if err != nil {
+		t.Fatalf("GetOrCreatePackage failed: %v", err)
+	}
+
+	existing, err := repo.GetOrCreatePackage(ctx, "testpkg", false)
+	if err != nil {
+		t.Fatalf("GetOrCreatePackage failed for existing package: %v", err)
+	}
+	if existing.ID != created.ID {
+		t.Errorf("Expected the existing package %d, got %d", created.ID, existing.ID)
+	}
+	if len(queries.packages) != 1 {
+		t.Errorf("Expected 1 package, got %d", len(queries.packages))
+	}
+}
+
 func TestPostgresPackageRepository_ListPackages(t *testing.T) {
 	queries := newMockQueries()
 	repo := NewPostgresPackageRepository(queries)
@@ -258,6 +686,11 @@ func TestPostgresPackageRepository_CreateVersion(t *testing.T) {
 	if created.Description == nil || *created.Description != desc {
 		t.Errorf("Expected description '%s', got %v", desc, created.Description)
 	}
+
+	// A version recorded meanwhile by a concurrent publish is reported as existing
+	if _, err := repo.CreateVersion(context.Background(), version); !errors.Is(err, ErrVersionExists) {
+		t.Errorf("Expected ErrVersionExists for a duplicate version, got %v", err)
+	}
 }
 
 func TestPostgresPackageRepository_GetLatestVersion(t *testing.T) {
@@ -306,6 +739,150 @@ func TestPostgresPackageRepository_GetLatestVersion(t *testing.T) {
 	}
 }
 
+func TestPostgresPackageRepository_GetPackageVersionsPage(t *testing.T) {
+	queries := newMockQueries()
+	repo := NewPostgresPackageRepository(queries)
+
+	pkg, err := queries.CreatePackage(context.Background(), postgres.CreatePackageParams{
+		Name:    "testpkg",
+		Private: false,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	for _, v := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		_, err = queries.CreatePackageVersion(context.Background(), postgres.CreatePackageVersionParams{
+			PackageID:   pkg.ID,
+			Version:     v,
+			PubspecYaml: "name: testpkg\nversion: " + v,
+			ArchivePath: "/storage/testpkg/" + v + "/archive.tar.gz",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+	}
+
+	var pages [][]string
+	var after *domain.PackageVersion
+	for {
+		versions, err := repo.GetPackageVersionsPage(context.Background(), pkg.ID, after, 2)
+		if err != nil {
+			t.Fatalf("GetPackageVersionsPage failed: %v", err)
+		}
+		if len(versions) == 0 {
+			break
+		}
+		var page []string
+		for _, v := range versions {
+			page = append(page, v.Version)
+		}
+		pages = append(pages, page)
+		after = versions[len(versions)-1]
+	}
+
+	expected := [][]string{{"1.2.0", "1.1.0"}, {"1.0.0"}}
+	if !slices.EqualFunc(pages, expected, slices.Equal) {
+		t.Errorf("Expected pages %v, got %v", expected, pages)
+	}
+}
+
+func TestPostgresPackageRepository_GetVersionByArchiveSha256(t *testing.T) {
+	queries := newMockQueries()
+	repo := NewPostgresPackageRepository(queries)
+
+	pkg, err := queries.CreatePackage(context.Background(), postgres.CreatePackageParams{
+		Name:    "testpkg",
+		Private: false,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	for _, v := range []string{"1.0.0", "1.1.0"} {
+		_, err = queries.CreatePackageVersion(context.Background(), postgres.CreatePackageVersionParams{
+			PackageID:     pkg.ID,
+			Version:       v,
+			PubspecYaml:   "name: testpkg\nversion: " + v,
+			ArchivePath:   "/storage/testpkg/" + v + "/archive.tar.gz",
+			ArchiveSha256: sql.NullString{String: "sha-" + v, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+	}
+
+	version, err := repo.GetVersionByArchiveSha256(context.Background(), pkg.ID, "sha-1.0.0")
+	if err != nil {
+		t.Fatalf("GetVersionByArchiveSha256 failed: %v", err)
+	}
+	if version == nil || version.Version != "1.0.0" {
+		t.Errorf("Expected version 1.0.0, got %+v", version)
+	}
+
+	version, err = repo.GetVersionByArchiveSha256(context.Background(), pkg.ID, "unknown")
+	if err != nil || version != nil {
+		t.Errorf("Expected no version for an unknown checksum, got %+v, %v", version, err)
+	}
+}
+
+func TestPostgresPackageRepository_GetVersion(t *testing.T) {
+	queries := newMockQueries()
+	repo := NewPostgresPackageRepository(queries)
+
+	pkg, err := queries.CreatePackage(context.Background(), postgres.CreatePackageParams{
+		Name:    "testpkg",
+		Private: false,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	for _, v := range []string{"1.0.0", "1.1.0"} {
+		_, err = queries.CreatePackageVersion(context.Background(), postgres.CreatePackageVersionParams{
+			PackageID:   pkg.ID,
+			Version:     v,
+			PubspecYaml: "name: testpkg\nversion: " + v,
+			ArchivePath: "/storage/testpkg/" + v + "/archive.tar.gz",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+	}
+	if err := repo.SetVersionRetracted(context.Background(), 2, true); err != nil {
+		t.Fatalf("SetVersionRetracted failed: %v", err)
+	}
+
+	numbers, err := repo.GetVersionNumbers(context.Background(), pkg.ID)
+	if err != nil {
+		t.Fatalf("GetVersionNumbers failed: %v", err)
+	}
+	if len(numbers) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(numbers))
+	}
+	for _, v := range numbers {
+		if v.PubspecYaml != "" {
+			t.Errorf("Expected only the version number of %s, got its pubspec", v.Version)
+		}
+		if v.Retracted != (v.Version == "1.1.0") {
+			t.Errorf("Expected only 1.1.0 to be retracted, got %s retracted %v", v.Version, v.Retracted)
+		}
+	}
+
+	version, err := repo.GetVersion(context.Background(), pkg.ID, "1.0.0")
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if version == nil || version.Version != "1.0.0" || version.PubspecYaml != "name: testpkg\nversion: 1.0.0" {
+		t.Errorf("Expected version 1.0.0 in full, got %+v", version)
+	}
+
+	version, err = repo.GetVersion(context.Background(), pkg.ID, "2.0.0")
+	if err != nil || version != nil {
+		t.Errorf("Expected no version for an unknown number, got %+v, %v", version, err)
+	}
+}
+
 func TestPostgresPackageRepository_Uploaders(t *testing.T) {
 	queries := newMockQueries()
 	repo := NewPostgresPackageRepository(queries)
@@ -340,6 +917,54 @@ func TestPostgresPackageRepository_Uploaders(t *testing.T) {
 	}
 }
 
+func TestPostgresPackageRepository_Tokens(t *testing.T) {
+	repo := NewPostgresPackageRepository(newMockQueries())
+	ctx := context.Background()
+
+	expiresAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	created, err := repo.CreateToken(ctx, &domain.Token{
+		Name:      "ci",
+		TokenHash: "abc123",
+		Scope:     domain.TokenScopeWrite,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+	if created.Scope != domain.TokenScopeWrite || created.ExpiresAt == nil || !created.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected write token expiring at %v, got %+v", expiresAt, created)
+	}
+
+	// Test non-existent token
+	token, err := repo.GetTokenByHash(ctx, "missing")
+	if err != nil || token != nil {
+		t.Errorf("Expected nil for unknown hash, got %v, %v", token, err)
+	}
+
+	token, err = repo.GetTokenByHash(ctx, "abc123")
+	if err != nil || token == nil || token.Name != "ci" {
+		t.Fatalf("Expected ci token, got %v, %v", token, err)
+	}
+	if token.RevokedAt != nil {
+		t.Error("Expected new token not to be revoked")
+	}
+
+	if revoked, err := repo.RevokeToken(ctx, token.ID); err != nil || !revoked {
+		t.Fatalf("Expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, err := repo.RevokeToken(ctx, token.ID); err != nil || revoked {
+		t.Errorf("Expected second revoke to report false, got %v, %v", revoked, err)
+	}
+
+	tokens, err := repo.ListTokens(ctx)
+	if err != nil {
+		t.Fatalf("ListTokens failed: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].RevokedAt == nil {
+		t.Errorf("Expected one revoked token, got %+v", tokens)
+	}
+}
+
 func TestNullStringToPtr(t *testing.T) {
 	// Test valid string
 	validString := sql.NullString{String: "test", Valid: true}
@@ -357,3 +982,82 @@ func TestNullStringToPtr(t *testing.T) {
 		t.Error("Expected nil for null string")
 	}
 }
+
+func TestPostgresPackageRepository_LikePackage(t *testing.T) {
+	queries := newMockQueries()
+	repo := NewPostgresPackageRepository(queries)
+	ctx := context.Background()
+
+	pkg, err := repo.CreatePackage(ctx, "likedpkg", false)
+	if err != nil {
+		t.Fatalf("CreatePackage failed: %v", err)
+	}
+
+	liked, err := repo.LikePackage(ctx, pkg.ID, "alice")
+	if err != nil {
+		t.Fatalf("LikePackage failed: %v", err)
+	}
+	if !liked {
+		t.Error("Expected first like to be recorded")
+	}
+
+	// Liking again with the same liker is a no-op
+	liked, err = repo.LikePackage(ctx, pkg.ID, "alice")
+	if err != nil {
+		t.Fatalf("LikePackage failed: %v", err)
+	}
+	if liked {
+		t.Error("Expected repeated like to be ignored")
+	}
+
+	if err := repo.IncrementDownloadCount(ctx, pkg.ID); err != nil {
+		t.Fatalf("IncrementDownloadCount failed: %v", err)
+	}
+
+	pkg, err = repo.GetPackage(ctx, "likedpkg")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if pkg.LikeCount != 1 {
+		t.Errorf("Expected like count 1, got %d", pkg.LikeCount)
+	}
+	if pkg.DownloadCount != 1 {
+		t.Errorf("Expected download count 1, got %d", pkg.DownloadCount)
+	}
+}
+
+func TestPostgresPackageRepository_AddDownloadCounts(t *testing.T) {
+	repo := NewPostgresPackageRepository(newMockQueries())
+	ctx := context.Background()
+
+	pkg, err := repo.CreatePackage(ctx, "countedpkg", false)
+	if err != nil {
+		t.Fatalf("CreatePackage failed: %v", err)
+	}
+
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	err = repo.AddDownloadCounts(ctx, map[domain.DownloadKey]int64{
+		{PackageID: pkg.ID, Version: "1.0.0", Day: day}: 2,
+		{PackageID: pkg.ID, Version: "2.0.0", Day: day}: 3,
+		// A package deleted since the downloads were counted
+		{PackageID: pkg.ID + 1, Version: "1.0.0", Day: day}: 7,
+	})
+	if err != nil {
+		t.Fatalf("AddDownloadCounts failed: %v", err)
+	}
+
+	pkg, err = repo.GetPackage(ctx, "countedpkg")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if pkg.DownloadCount != 5 {
+		t.Errorf("Expected download count 5, got %d", pkg.DownloadCount)
+	}
+	history, err := repo.GetDownloadHistory(ctx, pkg.ID, day)
+	if err != nil {
+		t.Fatalf("GetDownloadHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected 2 daily buckets, got %d", len(history))
+	}
+}
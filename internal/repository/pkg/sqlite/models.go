@@ -9,6 +9,13 @@ import (
 	"time"
 )
 
+type DownloadEvent struct {
+	PackageID int64  `json:"package_id"`
+	Version   string `json:"version"`
+	Day       string `json:"day"`
+	Count     int64  `json:"count"`
+}
+
 type Package struct {
 	ID            int64          `json:"id"`
 	Name          string         `json:"name"`
@@ -19,6 +26,19 @@ type Package struct {
 	Documentation sql.NullString `json:"documentation"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
+	LikeCount     int64          `json:"like_count"`
+	DownloadCount int64          `json:"download_count"`
+}
+
+type PackageClaim struct {
+	PackageID int64  `json:"package_id"`
+	Uploader  string `json:"uploader"`
+}
+
+type PackageLike struct {
+	PackageID int64     `json:"package_id"`
+	Liker     string    `json:"liker"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type PackageUploader struct {
@@ -39,4 +59,30 @@ type PackageVersion struct {
 	Uploader      sql.NullString `json:"uploader"`
 	Retracted     bool           `json:"retracted"`
 	CreatedAt     time.Time      `json:"created_at"`
+	Platforms     string         `json:"platforms"`
+	SizeBytes     sql.NullInt64  `json:"size_bytes"`
+	Funding       string         `json:"funding"`
+	Screenshots   string         `json:"screenshots"`
+	Proxied       bool           `json:"proxied"`
+	ReadmeHtml    sql.NullString `json:"readme_html"`
+	ChangelogHtml sql.NullString `json:"changelog_html"`
+}
+
+type Report struct {
+	ID        int64     `json:"id"`
+	PackageID int64     `json:"package_id"`
+	Version   string    `json:"version"`
+	Reporter  string    `json:"reporter"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Token struct {
+	ID        int64        `json:"id"`
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Scope     string       `json:"scope"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	CreatedAt time.Time    `json:"created_at"`
 }
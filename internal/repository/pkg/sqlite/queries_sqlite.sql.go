@@ -8,8 +8,65 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+const addDownloadCount = `-- name: AddDownloadCount :exec
+UPDATE packages SET download_count = download_count + ? WHERE id = ?
+`
+
+type AddDownloadCountParams struct {
+	DownloadCount int64 `json:"download_count"`
+	ID            int64 `json:"id"`
+}
+
+func (q *Queries) AddDownloadCount(ctx context.Context, arg AddDownloadCountParams) error {
+	_, err := q.db.ExecContext(ctx, addDownloadCount, arg.DownloadCount, arg.ID)
+	return err
+}
+
+const addDownloadEvents = `-- name: AddDownloadEvents :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + excluded.count
+`
+
+type AddDownloadEventsParams struct {
+	PackageID int64  `json:"package_id"`
+	Version   string `json:"version"`
+	Day       string `json:"day"`
+	Count     int64  `json:"count"`
+}
+
+func (q *Queries) AddDownloadEvents(ctx context.Context, arg AddDownloadEventsParams) error {
+	_, err := q.db.ExecContext(ctx, addDownloadEvents,
+		arg.PackageID,
+		arg.Version,
+		arg.Day,
+		arg.Count,
+	)
+	return err
+}
+
+const addPackageLike = `-- name: AddPackageLike :execrows
+INSERT INTO package_likes (package_id, liker)
+VALUES (?, ?)
+ON CONFLICT (package_id, liker) DO NOTHING
+`
+
+type AddPackageLikeParams struct {
+	PackageID int64  `json:"package_id"`
+	Liker     string `json:"liker"`
+}
+
+func (q *Queries) AddPackageLike(ctx context.Context, arg AddPackageLikeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addPackageLike, arg.PackageID, arg.Liker)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const addPackageUploader = `-- name: AddPackageUploader :exec
 INSERT INTO package_uploaders (package_id, uploader)
 VALUES (?, ?)
@@ -26,10 +83,30 @@ func (q *Queries) AddPackageUploader(ctx context.Context, arg AddPackageUploader
 	return err
 }
 
+const claimPackage = `-- name: ClaimPackage :one
+INSERT INTO package_claims (package_id, uploader)
+SELECT ?1, ?2
+WHERE NOT EXISTS (SELECT 1 FROM package_uploaders WHERE package_id = ?1)
+ON CONFLICT (package_id) DO UPDATE SET uploader = package_claims.uploader
+RETURNING uploader
+`
+
+type ClaimPackageParams struct {
+	PackageID int64  `json:"package_id"`
+	Uploader  string `json:"uploader"`
+}
+
+func (q *Queries) ClaimPackage(ctx context.Context, arg ClaimPackageParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, claimPackage, arg.PackageID, arg.Uploader)
+	var uploader string
+	err := row.Scan(&uploader)
+	return uploader, err
+}
+
 const createPackage = `-- name: CreatePackage :one
 INSERT INTO packages (name, private, description, homepage, repository, documentation)
 VALUES (?, ?, ?, ?, ?, ?)
-RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count
 `
 
 type CreatePackageParams struct {
@@ -61,6 +138,39 @@ func (q *Queries) CreatePackage(ctx context.Context, arg CreatePackageParams) (P
 		&i.Documentation,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
+	)
+	return i, err
+}
+
+const createPackageIfNotExists = `-- name: CreatePackageIfNotExists :one
+INSERT INTO packages (name, private)
+VALUES (?, ?)
+ON CONFLICT (name) DO NOTHING
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count
+`
+
+type CreatePackageIfNotExistsParams struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+}
+
+func (q *Queries) CreatePackageIfNotExists(ctx context.Context, arg CreatePackageIfNotExistsParams) (Package, error) {
+	row := q.db.QueryRowContext(ctx, createPackageIfNotExists, arg.Name, arg.Private)
+	var i Package
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Private,
+		&i.Description,
+		&i.Homepage,
+		&i.Repository,
+		&i.Documentation,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
 	)
 	return i, err
 }
@@ -68,9 +178,9 @@ func (q *Queries) CreatePackage(ctx context.Context, arg CreatePackageParams) (P
 const createPackageVersion = `-- name: CreatePackageVersion :one
 INSERT INTO package_versions (
     package_id, version, description, pubspec_yaml, readme, changelog,
-    archive_path, archive_sha256, uploader
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at
+    archive_path, archive_sha256, uploader, platforms, size_bytes, funding, screenshots, proxied
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html
 `
 
 type CreatePackageVersionParams struct {
@@ -83,6 +193,11 @@ type CreatePackageVersionParams struct {
 	ArchivePath   string         `json:"archive_path"`
 	ArchiveSha256 sql.NullString `json:"archive_sha256"`
 	Uploader      sql.NullString `json:"uploader"`
+	Platforms     string         `json:"platforms"`
+	SizeBytes     sql.NullInt64  `json:"size_bytes"`
+	Funding       string         `json:"funding"`
+	Screenshots   string         `json:"screenshots"`
+	Proxied       bool           `json:"proxied"`
 }
 
 func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVersionParams) (PackageVersion, error) {
@@ -96,6 +211,11 @@ func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVer
 		arg.ArchivePath,
 		arg.ArchiveSha256,
 		arg.Uploader,
+		arg.Platforms,
+		arg.SizeBytes,
+		arg.Funding,
+		arg.Screenshots,
+		arg.Proxied,
 	)
 	var i PackageVersion
 	err := row.Scan(
@@ -111,12 +231,163 @@ func (q *Queries) CreatePackageVersion(ctx context.Context, arg CreatePackageVer
 		&i.Uploader,
 		&i.Retracted,
 		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (package_id, version, reporter, reason)
+VALUES (?, ?, ?, ?)
+RETURNING id, package_id, version, reporter, reason, created_at
+`
+
+type CreateReportParams struct {
+	PackageID int64  `json:"package_id"`
+	Version   string `json:"version"`
+	Reporter  string `json:"reporter"`
+	Reason    string `json:"reason"`
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.PackageID,
+		arg.Version,
+		arg.Reporter,
+		arg.Reason,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Reporter,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createToken = `-- name: CreateToken :one
+INSERT INTO tokens (name, token_hash, scope, expires_at)
+VALUES (?, ?, ?, ?)
+RETURNING id, name, token_hash, scope, expires_at, revoked_at, created_at
+`
+
+type CreateTokenParams struct {
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Scope     string       `json:"scope"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+}
+
+func (q *Queries) CreateToken(ctx context.Context, arg CreateTokenParams) (Token, error) {
+	row := q.db.QueryRowContext(ctx, createToken,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scope,
+		arg.ExpiresAt,
+	)
+	var i Token
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deletePackageVersion = `-- name: DeletePackageVersion :exec
+DELETE FROM package_versions WHERE id = ?
+`
+
+func (q *Queries) DeletePackageVersion(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deletePackageVersion, id)
+	return err
+}
+
+const getDownloadEvents = `-- name: GetDownloadEvents :many
+SELECT day, version, count FROM download_events
+WHERE package_id = ? AND day >= ?
+ORDER BY day, version
+`
+
+type GetDownloadEventsParams struct {
+	PackageID int64  `json:"package_id"`
+	Day       string `json:"day"`
+}
+
+type GetDownloadEventsRow struct {
+	Day     string `json:"day"`
+	Version string `json:"version"`
+	Count   int64  `json:"count"`
+}
+
+func (q *Queries) GetDownloadEvents(ctx context.Context, arg GetDownloadEventsParams) ([]GetDownloadEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDownloadEvents, arg.PackageID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDownloadEventsRow
+	for rows.Next() {
+		var i GetDownloadEventsRow
+		if err := rows.Scan(&i.Day, &i.Version, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInstanceStats = `-- name: GetInstanceStats :one
+SELECT
+    (SELECT COUNT(*) FROM packages) AS package_count,
+    (SELECT COUNT(*) FROM package_versions) AS version_count,
+    CAST((SELECT COALESCE(SUM(size_bytes), 0) FROM package_versions) AS INTEGER) AS storage_bytes,
+    CAST((SELECT COALESCE(SUM(download_count), 0) FROM packages) AS INTEGER) AS download_count,
+    (SELECT COUNT(DISTINCT uploader) FROM package_versions) AS publisher_count
+`
+
+type GetInstanceStatsRow struct {
+	PackageCount   int64 `json:"package_count"`
+	VersionCount   int64 `json:"version_count"`
+	StorageBytes   int64 `json:"storage_bytes"`
+	DownloadCount  int64 `json:"download_count"`
+	PublisherCount int64 `json:"publisher_count"`
+}
+
+func (q *Queries) GetInstanceStats(ctx context.Context) (GetInstanceStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getInstanceStats)
+	var i GetInstanceStatsRow
+	err := row.Scan(
+		&i.PackageCount,
+		&i.VersionCount,
+		&i.StorageBytes,
+		&i.DownloadCount,
+		&i.PublisherCount,
 	)
 	return i, err
 }
 
 const getLatestPackageVersion = `-- name: GetLatestPackageVersion :one
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = ? AND retracted = false
 ORDER BY created_at DESC 
 LIMIT 1
@@ -138,12 +409,19 @@ func (q *Queries) GetLatestPackageVersion(ctx context.Context, packageID int64)
 		&i.Uploader,
 		&i.Retracted,
 		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
 	)
 	return i, err
 }
 
 const getPackage = `-- name: GetPackage :one
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages WHERE name = ?
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages WHERE name = ?
 `
 
 func (q *Queries) GetPackage(ctx context.Context, name string) (Package, error) {
@@ -159,6 +437,8 @@ func (q *Queries) GetPackage(ctx context.Context, name string) (Package, error)
 		&i.Documentation,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.DownloadCount,
 	)
 	return i, err
 }
@@ -190,10 +470,47 @@ func (q *Queries) GetPackageUploaders(ctx context.Context, packageID sql.NullInt
 	return items, nil
 }
 
+const getPackageVersion = `-- name: GetPackageVersion :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND version = ?
+`
+
+type GetPackageVersionParams struct {
+	PackageID int64  `json:"package_id"`
+	Version   string `json:"version"`
+}
+
+func (q *Queries) GetPackageVersion(ctx context.Context, arg GetPackageVersionParams) (PackageVersion, error) {
+	row := q.db.QueryRowContext(ctx, getPackageVersion, arg.PackageID, arg.Version)
+	var i PackageVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Description,
+		&i.PubspecYaml,
+		&i.Readme,
+		&i.Changelog,
+		&i.ArchivePath,
+		&i.ArchiveSha256,
+		&i.Uploader,
+		&i.Retracted,
+		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
 const getPackageVersions = `-- name: GetPackageVersions :many
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = ? 
-ORDER BY created_at DESC
+ORDER BY created_at DESC, id DESC
 `
 
 func (q *Queries) GetPackageVersions(ctx context.Context, packageID int64) ([]PackageVersion, error) {
@@ -218,6 +535,75 @@ func (q *Queries) GetPackageVersions(ctx context.Context, packageID int64) ([]Pa
 			&i.Uploader,
 			&i.Retracted,
 			&i.CreatedAt,
+			&i.Platforms,
+			&i.SizeBytes,
+			&i.Funding,
+			&i.Screenshots,
+			&i.Proxied,
+			&i.ReadmeHtml,
+			&i.ChangelogHtml,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPackageVersionsPage = `-- name: GetPackageVersionsPage :many
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND (datetime(created_at), id) < (datetime(?), ?)
+ORDER BY created_at DESC, id DESC
+LIMIT ?
+`
+
+type GetPackageVersionsPageParams struct {
+	PackageID   int64       `json:"package_id"`
+	LastCreated interface{} `json:"last_created"`
+	LastID      int64       `json:"last_id"`
+	Limit       int64       `json:"limit"`
+}
+
+func (q *Queries) GetPackageVersionsPage(ctx context.Context, arg GetPackageVersionsPageParams) ([]PackageVersion, error) {
+	rows, err := q.db.QueryContext(ctx, getPackageVersionsPage,
+		arg.PackageID,
+		arg.LastCreated,
+		arg.LastID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackageVersion
+	for rows.Next() {
+		var i PackageVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackageID,
+			&i.Version,
+			&i.Description,
+			&i.PubspecYaml,
+			&i.Readme,
+			&i.Changelog,
+			&i.ArchivePath,
+			&i.ArchiveSha256,
+			&i.Uploader,
+			&i.Retracted,
+			&i.CreatedAt,
+			&i.Platforms,
+			&i.SizeBytes,
+			&i.Funding,
+			&i.Screenshots,
+			&i.Proxied,
+			&i.ReadmeHtml,
+			&i.ChangelogHtml,
 		); err != nil {
 			return nil, err
 		}
@@ -232,8 +618,135 @@ func (q *Queries) GetPackageVersions(ctx context.Context, packageID int64) ([]Pa
 	return items, nil
 }
 
+const getTokenByHash = `-- name: GetTokenByHash :one
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens WHERE token_hash = ?
+`
+
+func (q *Queries) GetTokenByHash(ctx context.Context, tokenHash string) (Token, error) {
+	row := q.db.QueryRowContext(ctx, getTokenByHash, tokenHash)
+	var i Token
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getVersionByArchiveSha256 = `-- name: GetVersionByArchiveSha256 :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND archive_sha256 = ?
+ORDER BY created_at
+LIMIT 1
+`
+
+type GetVersionByArchiveSha256Params struct {
+	PackageID     int64          `json:"package_id"`
+	ArchiveSha256 sql.NullString `json:"archive_sha256"`
+}
+
+func (q *Queries) GetVersionByArchiveSha256(ctx context.Context, arg GetVersionByArchiveSha256Params) (PackageVersion, error) {
+	row := q.db.QueryRowContext(ctx, getVersionByArchiveSha256, arg.PackageID, arg.ArchiveSha256)
+	var i PackageVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PackageID,
+		&i.Version,
+		&i.Description,
+		&i.PubspecYaml,
+		&i.Readme,
+		&i.Changelog,
+		&i.ArchivePath,
+		&i.ArchiveSha256,
+		&i.Uploader,
+		&i.Retracted,
+		&i.CreatedAt,
+		&i.Platforms,
+		&i.SizeBytes,
+		&i.Funding,
+		&i.Screenshots,
+		&i.Proxied,
+		&i.ReadmeHtml,
+		&i.ChangelogHtml,
+	)
+	return i, err
+}
+
+const incrementDownloadCount = `-- name: IncrementDownloadCount :exec
+UPDATE packages SET download_count = download_count + 1 WHERE id = ?
+`
+
+func (q *Queries) IncrementDownloadCount(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, incrementDownloadCount, id)
+	return err
+}
+
+const incrementDownloadEvent = `-- name: IncrementDownloadEvent :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES (?, ?, ?, 1)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + 1
+`
+
+type IncrementDownloadEventParams struct {
+	PackageID int64  `json:"package_id"`
+	Version   string `json:"version"`
+	Day       string `json:"day"`
+}
+
+func (q *Queries) IncrementDownloadEvent(ctx context.Context, arg IncrementDownloadEventParams) error {
+	_, err := q.db.ExecContext(ctx, incrementDownloadEvent, arg.PackageID, arg.Version, arg.Day)
+	return err
+}
+
+const incrementLikeCount = `-- name: IncrementLikeCount :exec
+UPDATE packages SET like_count = like_count + 1 WHERE id = ?
+`
+
+func (q *Queries) IncrementLikeCount(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, incrementLikeCount, id)
+	return err
+}
+
+const listPackageVersionNumbers = `-- name: ListPackageVersionNumbers :many
+SELECT id, version, retracted FROM package_versions
+WHERE package_id = ?
+`
+
+type ListPackageVersionNumbersRow struct {
+	ID        int64  `json:"id"`
+	Version   string `json:"version"`
+	Retracted bool   `json:"retracted"`
+}
+
+func (q *Queries) ListPackageVersionNumbers(ctx context.Context, packageID int64) ([]ListPackageVersionNumbersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPackageVersionNumbers, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPackageVersionNumbersRow
+	for rows.Next() {
+		var i ListPackageVersionNumbersRow
+		if err := rows.Scan(&i.ID, &i.Version, &i.Retracted); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPackages = `-- name: ListPackages :many
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages 
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages 
 ORDER BY name
 LIMIT ? OFFSET ?
 `
@@ -262,6 +775,8 @@ func (q *Queries) ListPackages(ctx context.Context, arg ListPackagesParams) ([]P
 			&i.Documentation,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
 		); err != nil {
 			return nil, err
 		}
@@ -276,6 +791,461 @@ func (q *Queries) ListPackages(ctx context.Context, arg ListPackagesParams) ([]P
 	return items, nil
 }
 
+const listPackagesByDownloads = `-- name: ListPackagesByDownloads :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY download_count DESC, name
+LIMIT ? OFFSET ?
+`
+
+type ListPackagesByDownloadsParams struct {
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+func (q *Queries) ListPackagesByDownloads(ctx context.Context, arg ListPackagesByDownloadsParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesByDownloads, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesByUpdated = `-- name: ListPackagesByUpdated :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY COALESCE((SELECT MAX(created_at) FROM package_versions WHERE package_id = packages.id), updated_at) DESC, name
+LIMIT ? OFFSET ?
+`
+
+type ListPackagesByUpdatedParams struct {
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+func (q *Queries) ListPackagesByUpdated(ctx context.Context, arg ListPackagesByUpdatedParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesByUpdated, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesPage = `-- name: ListPackagesPage :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE (datetime(updated_at), id) > (datetime(?), ?)
+ORDER BY datetime(updated_at), id
+LIMIT ?
+`
+
+type ListPackagesPageParams struct {
+	LastUpdated interface{} `json:"last_updated"`
+	LastID      int64       `json:"last_id"`
+	Limit       int64       `json:"limit"`
+}
+
+func (q *Queries) ListPackagesPage(ctx context.Context, arg ListPackagesPageParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesPage, arg.LastUpdated, arg.LastID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackagesUpdatedSince = `-- name: ListPackagesUpdatedSince :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE datetime(updated_at) > datetime(?)
+ORDER BY updated_at, name
+LIMIT ? OFFSET ?
+`
+
+type ListPackagesUpdatedSinceParams struct {
+	Since  interface{} `json:"since"`
+	Limit  int64       `json:"limit"`
+	Offset int64       `json:"offset"`
+}
+
+func (q *Queries) ListPackagesUpdatedSince(ctx context.Context, arg ListPackagesUpdatedSinceParams) ([]Package, error) {
+	rows, err := q.db.QueryContext(ctx, listPackagesUpdatedSince, arg.Since, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Package
+	for rows.Next() {
+		var i Package
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Private,
+			&i.Description,
+			&i.Homepage,
+			&i.Repository,
+			&i.Documentation,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.DownloadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPublicVersions = `-- name: ListPublicVersions :many
+SELECT p.name, p.updated_at, v.version, v.created_at AS version_created_at
+FROM packages p
+LEFT JOIN package_versions v ON v.package_id = p.id
+WHERE p.private = FALSE
+ORDER BY p.name, v.created_at DESC, v.id DESC
+`
+
+type ListPublicVersionsRow struct {
+	Name             string         `json:"name"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Version          sql.NullString `json:"version"`
+	VersionCreatedAt sql.NullTime   `json:"version_created_at"`
+}
+
+func (q *Queries) ListPublicVersions(ctx context.Context) ([]ListPublicVersionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPublicVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPublicVersionsRow
+	for rows.Next() {
+		var i ListPublicVersionsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.VersionCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReports = `-- name: ListReports :many
+SELECT r.id, p.name AS package_name, r.version, r.reporter, r.reason, r.created_at
+FROM reports r
+JOIN packages p ON p.id = r.package_id
+WHERE r.id < ?
+ORDER BY r.id DESC
+LIMIT ?
+`
+
+type ListReportsParams struct {
+	Before     int64 `json:"before"`
+	MaxReports int64 `json:"max_reports"`
+}
+
+type ListReportsRow struct {
+	ID          int64     `json:"id"`
+	PackageName string    `json:"package_name"`
+	Version     string    `json:"version"`
+	Reporter    string    `json:"reporter"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (q *Queries) ListReports(ctx context.Context, arg ListReportsParams) ([]ListReportsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReports, arg.Before, arg.MaxReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReportsRow
+	for rows.Next() {
+		var i ListReportsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackageName,
+			&i.Version,
+			&i.Reporter,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTokens = `-- name: ListTokens :many
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens ORDER BY id
+`
+
+func (q *Queries) ListTokens(ctx context.Context) ([]Token, error) {
+	rows, err := q.db.QueryContext(ctx, listTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Token
+	for rows.Next() {
+		var i Token
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scope,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restorePackageHistory = `-- name: RestorePackageHistory :exec
+UPDATE packages
+SET created_at = MIN(datetime(created_at), datetime(?)),
+    like_count = MAX(like_count, ?),
+    download_count = MAX(download_count, ?)
+WHERE id = ?
+`
+
+type RestorePackageHistoryParams struct {
+	CreatedAt     interface{} `json:"created_at"`
+	LikeCount     interface{} `json:"like_count"`
+	DownloadCount interface{} `json:"download_count"`
+	ID            int64       `json:"id"`
+}
+
+func (q *Queries) RestorePackageHistory(ctx context.Context, arg RestorePackageHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, restorePackageHistory,
+		arg.CreatedAt,
+		arg.LikeCount,
+		arg.DownloadCount,
+		arg.ID,
+	)
+	return err
+}
+
+const revokeToken = `-- name: RevokeToken :execrows
+UPDATE tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeToken(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setPackagePrivate = `-- name: SetPackagePrivate :exec
+UPDATE packages SET private = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+`
+
+type SetPackagePrivateParams struct {
+	Private bool  `json:"private"`
+	ID      int64 `json:"id"`
+}
+
+func (q *Queries) SetPackagePrivate(ctx context.Context, arg SetPackagePrivateParams) error {
+	_, err := q.db.ExecContext(ctx, setPackagePrivate, arg.Private, arg.ID)
+	return err
+}
+
+const setPackageVersionArchivePath = `-- name: SetPackageVersionArchivePath :exec
+UPDATE package_versions SET archive_path = ? WHERE id = ?
+`
+
+type SetPackageVersionArchivePathParams struct {
+	ArchivePath string `json:"archive_path"`
+	ID          int64  `json:"id"`
+}
+
+func (q *Queries) SetPackageVersionArchivePath(ctx context.Context, arg SetPackageVersionArchivePathParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionArchivePath, arg.ArchivePath, arg.ID)
+	return err
+}
+
+const setPackageVersionCreatedAt = `-- name: SetPackageVersionCreatedAt :exec
+UPDATE package_versions SET created_at = datetime(?) WHERE id = ?
+`
+
+type SetPackageVersionCreatedAtParams struct {
+	CreatedAt interface{} `json:"created_at"`
+	ID        int64       `json:"id"`
+}
+
+func (q *Queries) SetPackageVersionCreatedAt(ctx context.Context, arg SetPackageVersionCreatedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionCreatedAt, arg.CreatedAt, arg.ID)
+	return err
+}
+
+const setPackageVersionDocsHTML = `-- name: SetPackageVersionDocsHTML :exec
+UPDATE package_versions SET readme_html = ?, changelog_html = ? WHERE id = ?
+`
+
+type SetPackageVersionDocsHTMLParams struct {
+	ReadmeHtml    sql.NullString `json:"readme_html"`
+	ChangelogHtml sql.NullString `json:"changelog_html"`
+	ID            int64          `json:"id"`
+}
+
+func (q *Queries) SetPackageVersionDocsHTML(ctx context.Context, arg SetPackageVersionDocsHTMLParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionDocsHTML, arg.ReadmeHtml, arg.ChangelogHtml, arg.ID)
+	return err
+}
+
+const setPackageVersionRetracted = `-- name: SetPackageVersionRetracted :exec
+UPDATE package_versions SET retracted = ? WHERE id = ?
+`
+
+type SetPackageVersionRetractedParams struct {
+	Retracted bool  `json:"retracted"`
+	ID        int64 `json:"id"`
+}
+
+func (q *Queries) SetPackageVersionRetracted(ctx context.Context, arg SetPackageVersionRetractedParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionRetracted, arg.Retracted, arg.ID)
+	return err
+}
+
+const setPackageVersionSize = `-- name: SetPackageVersionSize :exec
+UPDATE package_versions SET size_bytes = ? WHERE id = ?
+`
+
+type SetPackageVersionSizeParams struct {
+	SizeBytes sql.NullInt64 `json:"size_bytes"`
+	ID        int64         `json:"id"`
+}
+
+func (q *Queries) SetPackageVersionSize(ctx context.Context, arg SetPackageVersionSizeParams) error {
+	_, err := q.db.ExecContext(ctx, setPackageVersionSize, arg.SizeBytes, arg.ID)
+	return err
+}
+
+const touchPackage = `-- name: TouchPackage :exec
+UPDATE packages SET updated_at = datetime(?) WHERE id = ?
+`
+
+type TouchPackageParams struct {
+	UpdatedAt interface{} `json:"updated_at"`
+	ID        int64       `json:"id"`
+}
+
+func (q *Queries) TouchPackage(ctx context.Context, arg TouchPackageParams) error {
+	_, err := q.db.ExecContext(ctx, touchPackage, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const updatePackageMetadata = `-- name: UpdatePackageMetadata :exec
 UPDATE packages 
 SET description = ?, homepage = ?, repository = ?, documentation = ?, updated_at = CURRENT_TIMESTAMP
@@ -0,0 +1,262 @@
+package pkg
+
+import (
+	"context"
+	"repub/internal/domain"
+	"repub/internal/telemetry"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("repub/internal/repository/pkg")
+
+// tracedRepository wraps a Repository and records a span for every query
+type tracedRepository struct {
+	next Repository
+}
+
+// NewTracedRepository wraps a Repository with OpenTelemetry spans
+func NewTracedRepository(next Repository) Repository {
+	return &tracedRepository{next: next}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "db."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (r *tracedRepository) GetPackage(ctx context.Context, name string) (_ *domain.Package, err error) {
+	ctx, span := startSpan(ctx, "GetPackage", attribute.String("package", name))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetPackage(ctx, name)
+}
+
+func (r *tracedRepository) CreatePackage(ctx context.Context, name string, private bool) (_ *domain.Package, err error) {
+	ctx, span := startSpan(ctx, "CreatePackage", attribute.String("package", name))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.CreatePackage(ctx, name, private)
+}
+
+func (r *tracedRepository) GetOrCreatePackage(ctx context.Context, name string, private bool) (_ *domain.Package, err error) {
+	ctx, span := startSpan(ctx, "GetOrCreatePackage", attribute.String("package", name))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetOrCreatePackage(ctx, name, private)
+}
+
+func (r *tracedRepository) SetPackagePrivate(ctx context.Context, packageID int32, private bool) (err error) {
+	ctx, span := startSpan(ctx, "SetPackagePrivate", attribute.Int("package_id", int(packageID)), attribute.Bool("private", private))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetPackagePrivate(ctx, packageID, private)
+}
+
+func (r *tracedRepository) ListPackages(ctx context.Context, limit, offset int32) (_ []*domain.Package, err error) {
+	ctx, span := startSpan(ctx, "ListPackages", attribute.Int("limit", int(limit)), attribute.Int("offset", int(offset)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListPackages(ctx, limit, offset)
+}
+
+func (r *tracedRepository) TouchPackage(ctx context.Context, packageID int32, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "TouchPackage", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.TouchPackage(ctx, packageID, at)
+}
+
+func (r *tracedRepository) RestorePackageHistory(ctx context.Context, packageID int32, createdAt time.Time, likeCount, downloadCount int64) (err error) {
+	ctx, span := startSpan(ctx, "RestorePackageHistory", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.RestorePackageHistory(ctx, packageID, createdAt, likeCount, downloadCount)
+}
+
+func (r *tracedRepository) ListPackagesUpdatedSince(ctx context.Context, since time.Time, limit, offset int32) (_ []*domain.Package, err error) {
+	ctx, span := startSpan(ctx, "ListPackagesUpdatedSince", attribute.Int("limit", int(limit)), attribute.Int("offset", int(offset)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListPackagesUpdatedSince(ctx, since, limit, offset)
+}
+
+func (r *tracedRepository) ListPackagesPage(ctx context.Context, since time.Time, after *domain.Package, limit int32) (_ []*domain.Package, err error) {
+	ctx, span := startSpan(ctx, "ListPackagesPage", attribute.Int("limit", int(limit)), attribute.Bool("first_page", after == nil))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListPackagesPage(ctx, since, after, limit)
+}
+
+func (r *tracedRepository) ListSitemapPackages(ctx context.Context) (_ []*domain.SitemapPackage, err error) {
+	ctx, span := startSpan(ctx, "ListSitemapPackages")
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListSitemapPackages(ctx)
+}
+
+func (r *tracedRepository) ListPackagesSorted(ctx context.Context, sort domain.PackageSort, limit, offset int32) (_ []*domain.Package, err error) {
+	ctx, span := startSpan(ctx, "ListPackagesSorted", attribute.String("sort", string(sort)), attribute.Int("limit", int(limit)), attribute.Int("offset", int(offset)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListPackagesSorted(ctx, sort, limit, offset)
+}
+
+func (r *tracedRepository) GetPackageVersions(ctx context.Context, packageID int32) (_ []*domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetPackageVersions", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetPackageVersions(ctx, packageID)
+}
+
+func (r *tracedRepository) GetPackageVersionsPage(ctx context.Context, packageID int32, after *domain.PackageVersion, limit int32) (_ []*domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetPackageVersionsPage", attribute.Int("package_id", int(packageID)), attribute.Int("limit", int(limit)), attribute.Bool("first_page", after == nil))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetPackageVersionsPage(ctx, packageID, after, limit)
+}
+
+func (r *tracedRepository) GetLatestVersion(ctx context.Context, packageID int32) (_ *domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetLatestVersion", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetLatestVersion(ctx, packageID)
+}
+
+func (r *tracedRepository) GetVersion(ctx context.Context, packageID int32, version string) (_ *domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetVersion", attribute.Int("package_id", int(packageID)), attribute.String("version", version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetVersion(ctx, packageID, version)
+}
+
+func (r *tracedRepository) GetVersionNumbers(ctx context.Context, packageID int32) (_ []*domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetVersionNumbers", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetVersionNumbers(ctx, packageID)
+}
+
+func (r *tracedRepository) GetVersionByArchiveSha256(ctx context.Context, packageID int32, sha256 string) (_ *domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "GetVersionByArchiveSha256", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetVersionByArchiveSha256(ctx, packageID, sha256)
+}
+
+func (r *tracedRepository) CreateVersion(ctx context.Context, version *domain.PackageVersion) (_ *domain.PackageVersion, err error) {
+	ctx, span := startSpan(ctx, "CreateVersion", attribute.Int("package_id", int(version.PackageID)), attribute.String("version", version.Version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.CreateVersion(ctx, version)
+}
+
+func (r *tracedRepository) SetVersionRetracted(ctx context.Context, versionID int32, retracted bool) (err error) {
+	ctx, span := startSpan(ctx, "SetVersionRetracted", attribute.Int("version_id", int(versionID)), attribute.Bool("retracted", retracted))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetVersionRetracted(ctx, versionID, retracted)
+}
+
+func (r *tracedRepository) SetVersionSize(ctx context.Context, versionID int32, sizeBytes int64) (err error) {
+	ctx, span := startSpan(ctx, "SetVersionSize", attribute.Int("version_id", int(versionID)), attribute.Int64("size_bytes", sizeBytes))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetVersionSize(ctx, versionID, sizeBytes)
+}
+
+func (r *tracedRepository) SetVersionArchivePath(ctx context.Context, versionID int32, archivePath string) (err error) {
+	ctx, span := startSpan(ctx, "SetVersionArchivePath", attribute.Int("version_id", int(versionID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetVersionArchivePath(ctx, versionID, archivePath)
+}
+
+func (r *tracedRepository) SetVersionCreatedAt(ctx context.Context, versionID int32, createdAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "SetVersionCreatedAt", attribute.Int("version_id", int(versionID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetVersionCreatedAt(ctx, versionID, createdAt)
+}
+
+func (r *tracedRepository) SetVersionDocsHTML(ctx context.Context, versionID int32, readmeHTML, changelogHTML string) (err error) {
+	ctx, span := startSpan(ctx, "SetVersionDocsHTML", attribute.Int("version_id", int(versionID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.SetVersionDocsHTML(ctx, versionID, readmeHTML, changelogHTML)
+}
+
+func (r *tracedRepository) DeleteVersion(ctx context.Context, versionID int32) (err error) {
+	ctx, span := startSpan(ctx, "DeleteVersion", attribute.Int("version_id", int(versionID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.DeleteVersion(ctx, versionID)
+}
+
+func (r *tracedRepository) GetUploaders(ctx context.Context, packageID int32) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "GetUploaders", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetUploaders(ctx, packageID)
+}
+
+func (r *tracedRepository) AddUploader(ctx context.Context, packageID int32, uploader string) (err error) {
+	ctx, span := startSpan(ctx, "AddUploader", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.AddUploader(ctx, packageID, uploader)
+}
+
+func (r *tracedRepository) ClaimPackage(ctx context.Context, packageID int32, uploader string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "ClaimPackage", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ClaimPackage(ctx, packageID, uploader)
+}
+
+func (r *tracedRepository) LikePackage(ctx context.Context, packageID int32, liker string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "LikePackage", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.LikePackage(ctx, packageID, liker)
+}
+
+func (r *tracedRepository) IncrementDownloadCount(ctx context.Context, packageID int32) (err error) {
+	ctx, span := startSpan(ctx, "IncrementDownloadCount", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.IncrementDownloadCount(ctx, packageID)
+}
+
+func (r *tracedRepository) RecordDownload(ctx context.Context, packageID int32, version string, day time.Time) (err error) {
+	ctx, span := startSpan(ctx, "RecordDownload", attribute.Int("package_id", int(packageID)), attribute.String("version", version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.RecordDownload(ctx, packageID, version, day)
+}
+
+func (r *tracedRepository) AddDownloadCounts(ctx context.Context, counts map[domain.DownloadKey]int64) (err error) {
+	ctx, span := startSpan(ctx, "AddDownloadCounts", attribute.Int("buckets", len(counts)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.AddDownloadCounts(ctx, counts)
+}
+
+func (r *tracedRepository) GetDownloadHistory(ctx context.Context, packageID int32, since time.Time) (_ []*domain.VersionDownloads, err error) {
+	ctx, span := startSpan(ctx, "GetDownloadHistory", attribute.Int("package_id", int(packageID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetDownloadHistory(ctx, packageID, since)
+}
+
+func (r *tracedRepository) GetInstanceStats(ctx context.Context) (_ *domain.InstanceStats, err error) {
+	ctx, span := startSpan(ctx, "GetInstanceStats")
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetInstanceStats(ctx)
+}
+
+func (r *tracedRepository) CreateToken(ctx context.Context, token *domain.Token) (_ *domain.Token, err error) {
+	ctx, span := startSpan(ctx, "CreateToken", attribute.String("scope", string(token.Scope)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.CreateToken(ctx, token)
+}
+
+func (r *tracedRepository) GetTokenByHash(ctx context.Context, tokenHash string) (_ *domain.Token, err error) {
+	ctx, span := startSpan(ctx, "GetTokenByHash")
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetTokenByHash(ctx, tokenHash)
+}
+
+func (r *tracedRepository) ListTokens(ctx context.Context) (_ []*domain.Token, err error) {
+	ctx, span := startSpan(ctx, "ListTokens")
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListTokens(ctx)
+}
+
+func (r *tracedRepository) RevokeToken(ctx context.Context, tokenID int32) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "RevokeToken", attribute.Int("token_id", int(tokenID)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.RevokeToken(ctx, tokenID)
+}
+
+func (r *tracedRepository) CreateReport(ctx context.Context, report *domain.Report) (_ *domain.Report, err error) {
+	ctx, span := startSpan(ctx, "CreateReport", attribute.Int("package_id", int(report.PackageID)), attribute.String("version", report.Version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.CreateReport(ctx, report)
+}
+
+func (r *tracedRepository) ListReports(ctx context.Context, before, limit int32) (_ []*domain.Report, err error) {
+	ctx, span := startSpan(ctx, "ListReports", attribute.Int("before", int(before)), attribute.Int("limit", int(limit)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ListReports(ctx, before, limit)
+}
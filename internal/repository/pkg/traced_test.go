@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedRepository(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	repo := NewTracedRepository(NewPostgresPackageRepository(newMockQueries()))
+	ctx := context.Background()
+
+	pkg, err := repo.CreatePackage(ctx, "testpkg", false)
+	if err != nil {
+		t.Fatalf("CreatePackage failed: %v", err)
+	}
+
+	if _, err := repo.GetPackage(ctx, "testpkg"); err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+
+	if _, err := repo.GetPackageVersions(ctx, pkg.ID); err != nil {
+		t.Fatalf("GetPackageVersions failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	expected := []string{"db.CreatePackage", "db.GetPackage", "db.GetPackageVersions"}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d", len(expected), len(spans))
+	}
+	for i, name := range expected {
+		if spans[i].Name() != name {
+			t.Errorf("Expected span %d to be %s, got %s", i, name, spans[i].Name())
+		}
+	}
+}
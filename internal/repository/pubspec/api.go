@@ -3,13 +3,27 @@ package pubspec
 import (
 	"context"
 	"repub/internal/domain"
+	"strings"
 )
 
+// ValidationError lists every problem found validating a pubspec
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
 // Repository defines the interface for pubspec operations
 type Repository interface {
 	// ParseYAML parses a pubspec.yaml string and returns a typed Pubspec
 	ParseYAML(ctx context.Context, yamlContent string) (*domain.Pubspec, error)
 	
+	// DecodeYAML parses a stored pubspec.yaml without validating it, since
+	// versions published before a check was added must still be served
+	DecodeYAML(ctx context.Context, yamlContent string) (*domain.Pubspec, error)
+	
 	// ValidatePubspec validates a pubspec for required fields and constraints
 	ValidatePubspec(ctx context.Context, pubspec *domain.Pubspec) error
 	
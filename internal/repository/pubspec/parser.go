@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"repub/internal/domain"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -17,6 +18,20 @@ func NewParserRepository() Repository {
 }
 
 func (p *parserRepository) ParseYAML(ctx context.Context, yamlContent string) (*domain.Pubspec, error) {
+	pubspec, err := p.DecodeYAML(ctx, yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate required fields
+	if err := p.ValidatePubspec(ctx, pubspec); err != nil {
+		return nil, fmt.Errorf("pubspec validation failed: %w", err)
+	}
+
+	return pubspec, nil
+}
+
+func (p *parserRepository) DecodeYAML(ctx context.Context, yamlContent string) (*domain.Pubspec, error) {
 	if strings.TrimSpace(yamlContent) == "" {
 		return nil, fmt.Errorf("pubspec content is empty")
 	}
@@ -27,33 +42,40 @@ func (p *parserRepository) ParseYAML(ctx context.Context, yamlContent string) (*
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
-	// Validate required fields
-	if err := p.ValidatePubspec(ctx, &pubspec); err != nil {
-		return nil, fmt.Errorf("pubspec validation failed: %w", err)
-	}
-
 	return &pubspec, nil
 }
 
 func (p *parserRepository) ValidatePubspec(ctx context.Context, pubspec *domain.Pubspec) error {
-	if pubspec.Name == "" {
-		return fmt.Errorf("package name is required")
-	}
+	// Every problem is reported so publishers can fix them in one go
+	var problems []string
 
-	if pubspec.Version == "" {
-		return fmt.Errorf("package version is required")
+	switch {
+	case pubspec.Name == "":
+		problems = append(problems, "package name is required")
+	case !isValidPackageName(pubspec.Name):
+		problems = append(problems, fmt.Sprintf("invalid package name format: %s", pubspec.Name))
 	}
 
-	// Validate package name format
-	if !isValidPackageName(pubspec.Name) {
-		return fmt.Errorf("invalid package name format: %s", pubspec.Name)
+	// Validate version format (basic semantic versioning)
+	switch {
+	case pubspec.Version == "":
+		problems = append(problems, "package version is required")
+	case !isValidVersion(pubspec.Version):
+		problems = append(problems, fmt.Sprintf("invalid version format: %s", pubspec.Version))
 	}
 
-	// Validate version format (basic semantic versioning)
-	if !isValidVersion(pubspec.Version) {
-		return fmt.Errorf("invalid version format: %s", pubspec.Version)
+	if env := pubspec.Environment; env != nil {
+		if env.SDK != "" && !isValidConstraint(env.SDK) {
+			problems = append(problems, fmt.Sprintf("invalid SDK constraint: %s", env.SDK))
+		}
+		if env.Flutter != "" && !isValidConstraint(env.Flutter) {
+			problems = append(problems, fmt.Sprintf("invalid Flutter SDK constraint: %s", env.Flutter))
+		}
 	}
 
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
 	return nil
 }
 
@@ -86,7 +108,7 @@ func (p *parserRepository) parseDependency(name string, dep interface{}) (*domai
 	switch v := dep.(type) {
 	case string:
 		// Simple version constraint
-		return &domain.Dependency{Version: v}, nil
+		return &domain.Dependency{Source: domain.DependencySourceHosted, Version: v}, nil
 	case map[string]interface{}:
 		// Complex dependency specification
 		dependency := &domain.Dependency{
@@ -104,6 +126,10 @@ func (p *parserRepository) parseDependency(name string, dep interface{}) (*domai
 					dependency.Hosted = str
 				}
 			case "git":
+				// "git: <url>" is shorthand for a repository's default branch
+				if url, ok := value.(string); ok {
+					dependency.Git = &domain.GitDependency{URL: url}
+				}
 				if gitMap, ok := value.(map[string]interface{}); ok {
 					git := &domain.GitDependency{}
 					if url, ok := gitMap["url"].(string); ok {
@@ -129,6 +155,7 @@ func (p *parserRepository) parseDependency(name string, dep interface{}) (*domai
 				dependency.Extra[key] = value
 			}
 		}
+		dependency.Source = dependencySource(dependency)
 
 		return dependency, nil
 	default:
@@ -136,6 +163,21 @@ func (p *parserRepository) parseDependency(name string, dep interface{}) (*domai
 	}
 }
 
+// dependencySource reports where a dependency is fetched from, packages
+// without a git, path or sdk key come from a hosted repository
+func dependencySource(dep *domain.Dependency) string {
+	switch {
+	case dep.Git != nil:
+		return domain.DependencySourceGit
+	case dep.Path != "":
+		return domain.DependencySourcePath
+	case dep.SDK != "":
+		return domain.DependencySourceSDK
+	default:
+		return domain.DependencySourceHosted
+	}
+}
+
 // isValidPackageName checks if package name follows pub.dev conventions
 func isValidPackageName(name string) bool {
 	if len(name) == 0 || len(name) > 64 {
@@ -185,5 +227,39 @@ func isValidVersion(version string) bool {
 		}
 	}
 
+	return true
+}
+
+// constraintOperators prefix the versions of a constraint, longest first
+var constraintOperators = []string{">=", "<=", ">", "<", "^"}
+
+// isValidConstraint checks a version constraint such as "^1.2.0", "any" or
+// ">=2.12.0 <4.0.0"
+func isValidConstraint(constraint string) bool {
+	fields := strings.Fields(constraint)
+	if len(fields) == 1 && fields[0] == "any" {
+		return true
+	}
+	if len(fields) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(fields); i++ {
+		term := fields[i]
+		// The version may be separated from its operator, e.g. ">= 2.12.0"
+		if slices.Contains(constraintOperators, term) && i+1 < len(fields) {
+			i++
+			term += fields[i]
+		}
+		for _, op := range constraintOperators {
+			if strings.HasPrefix(term, op) {
+				term = strings.TrimPrefix(term, op)
+				break
+			}
+		}
+		if !isValidVersion(term) {
+			return false
+		}
+	}
 	return true
 }
\ No newline at end of file
@@ -2,6 +2,9 @@ package pubspec
 
 import (
 	"context"
+	"errors"
+	"repub/internal/domain"
+	"slices"
 	"testing"
 )
 
@@ -156,6 +159,117 @@ dependencies:
 	}
 }
 
+func TestParserRepository_FundingAndScreenshots(t *testing.T) {
+	repo := NewParserRepository()
+
+	yaml := `name: test_package
+version: 1.0.0
+funding:
+  - https://github.com/sponsors/dev
+  - https://buymeacoffee.com/dev
+screenshots:
+  - description: The home screen
+    path: screenshots/home.png
+  - description: Settings
+    path: screenshots/settings.jpg`
+
+	parsed, err := repo.ParseYAML(context.Background(), yaml)
+	if err != nil {
+		t.Fatalf("ParseYAML failed: %v", err)
+	}
+
+	expectedFunding := []string{"https://github.com/sponsors/dev", "https://buymeacoffee.com/dev"}
+	if !slices.Equal(parsed.Funding, expectedFunding) {
+		t.Errorf("Expected funding %v, got %v", expectedFunding, parsed.Funding)
+	}
+
+	expectedScreenshots := []domain.Screenshot{
+		{Description: "The home screen", Path: "screenshots/home.png"},
+		{Description: "Settings", Path: "screenshots/settings.jpg"},
+	}
+	if !slices.Equal(parsed.Screenshots, expectedScreenshots) {
+		t.Errorf("Expected screenshots %v, got %v", expectedScreenshots, parsed.Screenshots)
+	}
+	if _, ok := parsed.Extra["funding"]; ok {
+		t.Error("funding should not be captured in Extra")
+	}
+}
+
+func TestParserRepository_Platforms(t *testing.T) {
+	repo := NewParserRepository()
+
+	tests := []struct {
+		name     string
+		yaml     string
+		expected []string
+	}{
+		{
+			name: "flutter plugin platforms",
+			yaml: `name: my_plugin
+version: 1.0.0
+flutter:
+  plugin:
+    platforms:
+      android:
+        package: com.example.my_plugin
+        pluginClass: MyPlugin
+      ios:
+        pluginClass: MyPlugin
+      web:
+        pluginClass: MyPluginWeb
+        fileName: my_plugin_web.dart`,
+			expected: []string{"android", "ios", "web"},
+		},
+		{
+			name: "top-level platforms",
+			yaml: `name: my_package
+version: 1.0.0
+platforms:
+  linux:
+  macos:
+  windows:`,
+			expected: []string{"linux", "macos", "windows"},
+		},
+		{
+			name: "both sections are merged",
+			yaml: `name: my_plugin
+version: 1.0.0
+platforms:
+  android:
+  linux:
+flutter:
+  plugin:
+    platforms:
+      android:
+        package: com.example.my_plugin
+        pluginClass: MyPlugin`,
+			expected: []string{"android", "linux"},
+		},
+		{
+			name: "no platforms",
+			yaml: `name: my_package
+version: 1.0.0
+flutter:
+  uses-material-design: true`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := repo.ParseYAML(context.Background(), tt.yaml)
+			if err != nil {
+				t.Fatalf("ParseYAML failed: %v", err)
+			}
+
+			platforms := parsed.SupportedPlatforms()
+			if !slices.Equal(platforms, tt.expected) {
+				t.Errorf("Expected platforms %v, got %v", tt.expected, platforms)
+			}
+		})
+	}
+}
+
 func TestParserRepository_ExtractDependencies(t *testing.T) {
 	repo := NewParserRepository()
 
@@ -429,6 +543,98 @@ version: 1.0.0+build.1`,
 	}
 }
 
+func TestParserRepository_ValidatePubspec_AllProblems(t *testing.T) {
+	repo := NewParserRepository()
+
+	tests := []struct {
+		name     string
+		pubspec  domain.Pubspec
+		problems []string
+	}{
+		{
+			name:     "missing name and version",
+			pubspec:  domain.Pubspec{},
+			problems: []string{"package name is required", "package version is required"},
+		},
+		{
+			name: "bad name, version and SDK constraints",
+			pubspec: domain.Pubspec{
+				Name:        "bad-name",
+				Version:     "1.0",
+				Environment: &domain.Environment{SDK: ">=2.12.0 <three", Flutter: "latest"},
+			},
+			problems: []string{
+				"invalid package name format: bad-name",
+				"invalid version format: 1.0",
+				"invalid SDK constraint: >=2.12.0 <three",
+				"invalid Flutter SDK constraint: latest",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.ValidatePubspec(context.Background(), &tt.pubspec)
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Expected a ValidationError, got %v", err)
+			}
+			if !slices.Equal(invalid.Problems, tt.problems) {
+				t.Errorf("Expected problems %q, got %q", tt.problems, invalid.Problems)
+			}
+		})
+	}
+}
+
+func TestParserRepository_DecodeYAML(t *testing.T) {
+	repo := NewParserRepository()
+	ctx := context.Background()
+	stored := "name: legacy\nversion: 1.0\nenvironment:\n  sdk: latest"
+
+	// Stored pubspecs are served as they were published
+	pubspec, err := repo.DecodeYAML(ctx, stored)
+	if err != nil {
+		t.Fatalf("DecodeYAML failed: %v", err)
+	}
+	if pubspec.Version != "1.0" || pubspec.Environment == nil || pubspec.Environment.SDK != "latest" {
+		t.Errorf("Unexpected pubspec %+v", pubspec)
+	}
+
+	if _, err := repo.ParseYAML(ctx, stored); err == nil {
+		t.Error("Expected ParseYAML to validate the pubspec")
+	}
+	if _, err := repo.DecodeYAML(ctx, "name: [unclosed"); err == nil {
+		t.Error("Expected invalid YAML to fail")
+	}
+}
+
+func TestIsValidConstraint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"any", true},
+		{"^3.0.0", true},
+		{">=2.12.0 <4.0.0", true},
+		{">= 2.12.0 < 4.0.0", true},
+		{">=3.0.0-0 <4.0.0", true},
+		{"1.2.3", true},
+		{"", false},
+		{"latest", false},
+		{">=2.12 <3.0.0", false},
+		{"^", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := isValidConstraint(tt.input); result != tt.expected {
+				t.Errorf("isValidConstraint(%q) = %v, expected %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestIsValidPackageName(t *testing.T) {
 	tests := []struct {
 		name     string
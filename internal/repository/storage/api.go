@@ -1,16 +1,48 @@
 package storage
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"strings"
+	"time"
 )
 
+// ErrNotFound is returned, wrapped, when a stored object doesn't exist, so
+// callers can tell a missing archive from an unavailable backend
+var ErrNotFound = errors.New("object not found")
+
+// Repository stores package archives. Every operation stops when its context
+// is cancelled, so requests that are given up don't keep transferring data.
 type Repository interface {
-	Store(packageName, version string, data []byte) (string, error)
-	Get(path string) ([]byte, error)
-	GetReader(path string) (io.ReadCloser, error)
-	Exists(path string) bool
-	Delete(path string) error
+	Store(ctx context.Context, packageName, version string, data []byte) (string, error)
+	// Stage stores data under a unique key next to the version's, so
+	// concurrent publishes of one version can't overwrite each other
+	Stage(ctx context.Context, packageName, version string, data []byte) (string, error)
+	// Commit moves a staged object to the key of its version and returns the
+	// new path
+	Commit(ctx context.Context, stagedPath string) (string, error)
+	Get(ctx context.Context, path string) ([]byte, error)
+	// GetReader opens an object for reading; reads fail once ctx is cancelled
+	GetReader(ctx context.Context, path string) (io.ReadCloser, error)
+	Exists(ctx context.Context, path string) bool
+	Size(ctx context.Context, path string) (int64, error)
+	ModTime(ctx context.Context, path string) (time.Time, error)
+	Delete(ctx context.Context, path string) error
+	// List returns the paths of all stored objects whose key, below the
+	// storage prefix, starts with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Seeker is implemented by repositories that can open an object for random
+// access, so downloads can serve byte ranges without reading whole archives
+type Seeker interface {
+	// Open opens an object for random access; reads fail once ctx is cancelled
+	Open(ctx context.Context, path string) (io.ReadSeekCloser, error)
 }
 
 type FileSystem interface {
@@ -19,4 +51,27 @@ type FileSystem interface {
 	MkdirAll(path string, perm fs.FileMode) error
 	Remove(name string) error
 	Stat(name string) (fs.FileInfo, error)
+	Rename(oldpath, newpath string) error
+}
+
+// stagedSuffix separates the key of a version from the random part of the
+// keys its archives are staged under
+const stagedSuffix = ".staged-"
+
+// stagedKey returns a unique key to stage an object for key under
+func stagedKey(key string) (string, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("failed to generate staged key: %w", err)
+	}
+	return key + stagedSuffix + hex.EncodeToString(suffix[:]), nil
+}
+
+// committedKey returns the key a staged object is committed to
+func committedKey(staged string) (string, error) {
+	i := strings.LastIndex(staged, stagedSuffix)
+	if i < 0 {
+		return "", fmt.Errorf("%s is not a staged object", staged)
+	}
+	return staged[:i], nil
 }
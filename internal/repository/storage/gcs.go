@@ -2,11 +2,19 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 const legacyPathPrefix = "/app/storage/"
@@ -14,30 +22,63 @@ const legacyPathPrefix = "/app/storage/"
 type gcsRepository struct {
 	client *gcs.Client
 	bucket string
+	// prefix namespaces the keys of new objects, e.g. "prod/", so
+	// environments can share a bucket
+	prefix string
+	keys   KeyTemplate
 }
 
-func NewGCSRepository(bucket string) (Repository, error) {
+// NewGCSRepository stores objects in bucket under prefix, which must be empty
+// or end with a slash (see ParsePrefix). Requests go through transport, nil
+// for the default one.
+func NewGCSRepository(bucket, prefix string, keys KeyTemplate, transport http.RoundTripper) (Repository, error) {
 	if bucket == "" {
 		return nil, fmt.Errorf("GCS bucket name is required")
 	}
-	client, err := gcs.NewClient(context.Background())
+	ctx := context.Background()
+	var opts []option.ClientOption
+	if transport != nil {
+		httpClient, err := gcsHTTPClient(ctx, transport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS transport: %w", err)
+		}
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+	client, err := gcs.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCS client: %w", err)
 	}
-	return newGCSRepositoryWithClient(client, bucket), nil
+	return newGCSRepositoryWithClient(client, bucket, prefix, keys), nil
+}
+
+// gcsHTTPClient authenticates requests over transport the way the GCS client
+// does by default, which it skips when given its own HTTP client
+func gcsHTTPClient(ctx context.Context, transport http.RoundTripper) (*http.Client, error) {
+	auth := []option.ClientOption{option.WithScopes(gcs.ScopeFullControl, "https://www.googleapis.com/auth/cloud-platform")}
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		auth = []option.ClientOption{option.WithoutAuthentication()}
+	}
+	authenticated, err := htransport.NewTransport(ctx, transport, auth...)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: authenticated}, nil
 }
 
-func newGCSRepositoryWithClient(client *gcs.Client, bucket string) Repository {
-	return &gcsRepository{client: client, bucket: bucket}
+func newGCSRepositoryWithClient(client *gcs.Client, bucket, prefix string, keys KeyTemplate) Repository {
+	return &gcsRepository{client: client, bucket: bucket, prefix: prefix, keys: keys}
 }
 
+// objectKey maps a stored path to its object key. Paths returned by Store
+// already carry the prefix, and legacy paths predate it, so neither gets the
+// prefix added.
 func (r *gcsRepository) objectKey(path string) string {
 	return strings.TrimPrefix(path, legacyPathPrefix)
 }
 
-func (r *gcsRepository) Store(packageName, version string, data []byte) (string, error) {
-	key := fmt.Sprintf("%s/%s/%s-%s.tar.gz", packageName, version, packageName, version)
-	w := r.client.Bucket(r.bucket).Object(key).NewWriter(context.Background())
+func (r *gcsRepository) Store(ctx context.Context, packageName, version string, data []byte) (string, error) {
+	key := r.prefix + r.keys.Key(packageName, version)
+	w := r.client.Bucket(r.bucket).Object(key).NewWriter(ctx)
 	_, writeErr := w.Write(data)
 	closeErr := w.Close()
 	if writeErr != nil {
@@ -49,33 +90,180 @@ func (r *gcsRepository) Store(packageName, version string, data []byte) (string,
 	return key, nil
 }
 
-func (r *gcsRepository) Get(path string) ([]byte, error) {
+func (r *gcsRepository) Stage(ctx context.Context, packageName, version string, data []byte) (string, error) {
+	key, err := stagedKey(r.prefix + r.keys.Key(packageName, version))
+	if err != nil {
+		return "", err
+	}
+	w := r.client.Bucket(r.bucket).Object(key).NewWriter(ctx)
+	_, writeErr := w.Write(data)
+	closeErr := w.Close()
+	if writeErr != nil {
+		return "", fmt.Errorf("failed to write to GCS: %w", writeErr)
+	}
+	if closeErr != nil {
+		return "", fmt.Errorf("failed to close GCS writer: %w", closeErr)
+	}
+	return key, nil
+}
+
+// Commit copies the staged object, GCS has no rename. A staged object that
+// can't be deleted afterwards is left for the orphaned archive cleanup.
+func (r *gcsRepository) Commit(ctx context.Context, stagedPath string) (string, error) {
+	key, err := committedKey(stagedPath)
+	if err != nil {
+		return "", err
+	}
+	bucket := r.client.Bucket(r.bucket)
+	staged := bucket.Object(stagedPath)
+	if _, err := bucket.Object(key).CopierFrom(staged).Run(ctx); err != nil {
+		return "", gcsError("failed to copy staged object in GCS", err)
+	}
+	if err := staged.Delete(ctx); err != nil {
+		slog.Warn("Failed to delete staged object", "path", stagedPath, "error", err)
+	}
+	return key, nil
+}
+
+func (r *gcsRepository) Get(ctx context.Context, path string) ([]byte, error) {
 	key := r.objectKey(path)
-	rc, err := r.client.Bucket(r.bucket).Object(key).NewReader(context.Background())
+	rc, err := r.client.Bucket(r.bucket).Object(key).NewReader(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from GCS: %w", err)
+		return nil, gcsError("failed to read from GCS", err)
 	}
 	defer rc.Close()
 	return io.ReadAll(rc)
 }
 
-func (r *gcsRepository) GetReader(path string) (io.ReadCloser, error) {
+func (r *gcsRepository) GetReader(ctx context.Context, path string) (io.ReadCloser, error) {
 	key := r.objectKey(path)
-	rc, err := r.client.Bucket(r.bucket).Object(key).NewReader(context.Background())
+	rc, err := r.client.Bucket(r.bucket).Object(key).NewReader(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get reader from GCS: %w", err)
+		return nil, gcsError("failed to get reader from GCS", err)
 	}
 	return rc, nil
 }
 
-func (r *gcsRepository) Exists(path string) bool {
+func (r *gcsRepository) Open(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	obj := r.client.Bucket(r.bucket).Object(r.objectKey(path))
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, gcsError("failed to get attributes from GCS", err)
+	}
+	return &gcsObjectReader{ctx: ctx, obj: obj, size: attrs.Size}, nil
+}
+
+// gcsObjectReader emulates seeking with ranged reads, opening a new reader
+// at the current offset after each seek
+type gcsObjectReader struct {
+	// ctx is the context Open was called with, range readers are opened
+	// lazily and must stop with the request that opened the object
+	ctx    context.Context
+	obj    *gcs.ObjectHandle
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+}
+
+func (o *gcsObjectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.rc == nil {
+		rc, err := o.obj.NewRangeReader(o.ctx, o.offset, -1)
+		if err != nil {
+			return 0, gcsError("failed to read from GCS", err)
+		}
+		o.rc = rc
+	}
+	n, err := o.rc.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *gcsObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+	if offset != o.offset && o.rc != nil {
+		_ = o.rc.Close()
+		o.rc = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *gcsObjectReader) Close() error {
+	if o.rc == nil {
+		return nil
+	}
+	return o.rc.Close()
+}
+
+func (r *gcsRepository) Exists(ctx context.Context, path string) bool {
 	key := r.objectKey(path)
-	_, err := r.client.Bucket(r.bucket).Object(key).Attrs(context.Background())
+	_, err := r.client.Bucket(r.bucket).Object(key).Attrs(ctx)
 	return err == nil
 }
 
-func (r *gcsRepository) Delete(path string) error {
+func (r *gcsRepository) Size(ctx context.Context, path string) (int64, error) {
+	key := r.objectKey(path)
+	attrs, err := r.client.Bucket(r.bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		return 0, gcsError("failed to get attributes from GCS", err)
+	}
+	return attrs.Size, nil
+}
+
+func (r *gcsRepository) ModTime(ctx context.Context, path string) (time.Time, error) {
 	key := r.objectKey(path)
-	return r.client.Bucket(r.bucket).Object(key).Delete(context.Background())
+	attrs, err := r.client.Bucket(r.bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		return time.Time{}, gcsError("failed to get attributes from GCS", err)
+	}
+	return attrs.Updated, nil
 }
 
+func (r *gcsRepository) Delete(ctx context.Context, path string) error {
+	key := r.objectKey(path)
+	if err := r.client.Bucket(r.bucket).Object(key).Delete(ctx); err != nil {
+		return gcsError("failed to delete from GCS", err)
+	}
+	return nil
+}
+
+// List only sees objects under the storage prefix, so a cleanup never deletes
+// the archives of another environment sharing the bucket
+func (r *gcsRepository) List(ctx context.Context, prefix string) ([]string, error) {
+	it := r.client.Bucket(r.bucket).Objects(ctx, &gcs.Query{Prefix: r.prefix + prefix})
+
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list GCS objects: %w", err)
+		}
+		keys = append(keys, attrs.Name)
+	}
+	return keys, nil
+}
+
+// gcsError wraps a GCS error with msg, marking missing objects with ErrNotFound
+func gcsError(msg string, err error) error {
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return fmt.Errorf("%s: %w: %w", msg, ErrNotFound, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
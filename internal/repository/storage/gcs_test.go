@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -103,14 +104,15 @@ func skipIfNoEmulator(t *testing.T) {
 func newTestGCSRepo(t *testing.T) Repository {
 	t.Helper()
 	skipIfNoEmulator(t)
-	return newGCSRepositoryWithClient(gcsTestClient, gcsTestBucket)
+	return newGCSRepositoryWithClient(gcsTestClient, gcsTestBucket, "", DefaultKeyTemplate)
 }
 
 func TestGCSRepository_Store(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
 	data := []byte("test package data")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
@@ -120,21 +122,61 @@ func TestGCSRepository_Store(t *testing.T) {
 		t.Errorf("expected path %s, got %s", expected, path)
 	}
 
-	if !repo.Exists(path) {
+	if !repo.Exists(ctx, path) {
 		t.Error("file should exist after storing")
 	}
 }
 
+func TestGCSRepository_StageCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestGCSRepo(t)
+
+	first, err := repo.Stage(ctx, "testpkg", "1.0.0", []byte("first"))
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	second, err := repo.Stage(ctx, "testpkg", "1.0.0", []byte("second"))
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if first == second {
+		t.Fatalf("Expected each staged archive to get its own path, got %s twice", first)
+	}
+
+	path, err := repo.Commit(ctx, first)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if expected := "testpkg/1.0.0/testpkg-1.0.0.tar.gz"; path != expected {
+		t.Errorf("Expected path %s, got %s", expected, path)
+	}
+	if data, err := repo.Get(ctx, path); err != nil || string(data) != "first" {
+		t.Errorf("Expected the committed archive, got %q, %v", data, err)
+	}
+	if repo.Exists(ctx, first) {
+		t.Error("Committing should remove the staged archive")
+	}
+	// Another staged archive of the version is untouched
+	if data, err := repo.Get(ctx, second); err != nil || string(data) != "second" {
+		t.Errorf("Expected the other staged archive, got %q, %v", data, err)
+	}
+
+	if _, err := repo.Commit(ctx, path); err == nil {
+		t.Error("Expected committing an archive that isn't staged to fail")
+	}
+}
+
 func TestGCSRepository_Get(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
 	data := []byte("test get data")
-	path, err := repo.Store("getpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "getpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 
-	retrieved, err := repo.Get(path)
+	retrieved, err := repo.Get(ctx, path)
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
@@ -145,15 +187,16 @@ func TestGCSRepository_Get(t *testing.T) {
 }
 
 func TestGCSRepository_GetReader(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
 	data := []byte("test reader data")
-	path, err := repo.Store("readerpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "readerpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 
-	reader, err := repo.GetReader(path)
+	reader, err := repo.GetReader(ctx, path)
 	if err != nil {
 		t.Fatalf("GetReader failed: %v", err)
 	}
@@ -169,48 +212,149 @@ func TestGCSRepository_GetReader(t *testing.T) {
 	}
 }
 
+func TestGCSRepository_Open(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestGCSRepo(t)
+
+	path, err := repo.Store(ctx, "openpkg", "1.0.0", []byte("0123456789"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	rs, err := repo.(Seeker).Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer rs.Close()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil || size != 10 {
+		t.Fatalf("Expected size 10 seeking to the end, got %d, %v", size, err)
+	}
+	if _, err := rs.Seek(4, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(rest) != "456789" {
+		t.Errorf("expected 456789 after seeking, got %s", rest)
+	}
+
+	if _, err := repo.(Seeker).Open(ctx, "nonexistent/key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestGCSRepository_Delete(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
 	data := []byte("delete me")
-	path, err := repo.Store("delpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "delpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 
-	if !repo.Exists(path) {
+	if !repo.Exists(ctx, path) {
 		t.Error("file should exist before deletion")
 	}
 
-	if err := repo.Delete(path); err != nil {
+	if err := repo.Delete(ctx, path); err != nil {
 		t.Fatalf("Delete failed: %v", err)
 	}
 
-	if repo.Exists(path) {
+	if repo.Exists(ctx, path) {
 		t.Error("file should not exist after deletion")
 	}
 }
 
+func TestGCSRepository_Size(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestGCSRepo(t)
+
+	data := []byte("sized data")
+	path, err := repo.Store(ctx, "sizepkg", "1.0.0", data)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	size, err := repo.Size(ctx, path)
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("expected size %d, got %d", len(data), size)
+	}
+}
+
+func TestGCSRepository_List(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestGCSRepo(t)
+
+	for _, pkg := range []string{"listpkga", "listpkgb"} {
+		if _, err := repo.Store(ctx, pkg, "1.0.0", []byte("list data")); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	keys, err := repo.List(ctx, "listpkga/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "listpkga/1.0.0/listpkga-1.0.0.tar.gz" {
+		t.Errorf("expected only the listpkga object, got %v", keys)
+	}
+
+	if _, err := repo.ModTime(ctx, keys[0]); err != nil {
+		t.Errorf("ModTime failed: %v", err)
+	}
+}
+
+func TestGCSRepository_Cancelled(t *testing.T) {
+	repo := newTestGCSRepo(t)
+	path, err := repo.Store(context.Background(), "cancelpkg", "1.0.0", []byte("cancel data"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.Store(ctx, "cancelpkg", "2.0.0", []byte("cancel data")); err == nil {
+		t.Error("Expected Store to fail with a cancelled context")
+	}
+	if repo.Exists(context.Background(), "cancelpkg/2.0.0/cancelpkg-2.0.0.tar.gz") {
+		t.Error("Cancelled Store should not write the object")
+	}
+	if _, err := repo.Get(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Get to be cancelled, got %v", err)
+	}
+}
+
 func TestGCSRepository_Exists_NonExistent(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
-	if repo.Exists("nonexistent/path") {
+	if repo.Exists(ctx, "nonexistent/path") {
 		t.Error("non-existent file should not exist")
 	}
 }
 
 func TestGCSRepository_LegacyPathStripping(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
 	data := []byte("legacy data")
-	path, err := repo.Store("legacypkg", "2.0.0", data)
+	path, err := repo.Store(ctx, "legacypkg", "2.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 
 	legacyPath := "/app/storage/" + path
 
-	retrieved, err := repo.Get(legacyPath)
+	retrieved, err := repo.Get(ctx, legacyPath)
 	if err != nil {
 		t.Fatalf("Get with legacy path failed: %v", err)
 	}
@@ -218,35 +362,122 @@ func TestGCSRepository_LegacyPathStripping(t *testing.T) {
 		t.Errorf("expected %s, got %s", data, retrieved)
 	}
 
-	if !repo.Exists(legacyPath) {
+	if !repo.Exists(ctx, legacyPath) {
 		t.Error("Exists should work with legacy path")
 	}
 
-	reader, err := repo.GetReader(legacyPath)
+	reader, err := repo.GetReader(ctx, legacyPath)
 	if err != nil {
 		t.Fatalf("GetReader with legacy path failed: %v", err)
 	}
 	reader.Close()
 
-	if err := repo.Delete(legacyPath); err != nil {
+	if err := repo.Delete(ctx, legacyPath); err != nil {
 		t.Fatalf("Delete with legacy path failed: %v", err)
 	}
 
-	if repo.Exists(path) {
+	if repo.Exists(ctx, path) {
 		t.Error("file should not exist after deletion via legacy path")
 	}
 }
 
+func TestGCSRepository_KeyTemplate(t *testing.T) {
+	ctx := context.Background()
+	skipIfNoEmulator(t)
+	repo := newGCSRepositoryWithClient(gcsTestClient, gcsTestBucket, "", "{initial}/{name}/{name}-{version}.tar.gz")
+
+	data := []byte("sharded data")
+	path, err := repo.Store(ctx, "shardpkg", "1.0.0", data)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	expected := "s/shardpkg/shardpkg-1.0.0.tar.gz"
+	if path != expected {
+		t.Errorf("expected path %s, got %s", expected, path)
+	}
+
+	retrieved, err := repo.Get(ctx, path)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(retrieved, data) {
+		t.Errorf("expected %s, got %s", data, retrieved)
+	}
+
+	// Legacy absolute paths are still stripped down to the templated key
+	if !repo.Exists(ctx, legacyPathPrefix+path) {
+		t.Error("Exists should work with legacy path")
+	}
+}
+
+func TestGCSRepository_Prefix(t *testing.T) {
+	ctx := context.Background()
+	skipIfNoEmulator(t)
+	repo := newGCSRepositoryWithClient(gcsTestClient, gcsTestBucket, "prod/", DefaultKeyTemplate)
+	unprefixed := newGCSRepositoryWithClient(gcsTestClient, gcsTestBucket, "", DefaultKeyTemplate)
+
+	data := []byte("prefixed data")
+	path, err := repo.Store(ctx, "prefixpkg", "1.0.0", data)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if expected := "prod/prefixpkg/1.0.0/prefixpkg-1.0.0.tar.gz"; path != expected {
+		t.Errorf("expected path %s, got %s", expected, path)
+	}
+	retrieved, err := repo.Get(ctx, path)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(retrieved, data) {
+		t.Errorf("expected %s, got %s", data, retrieved)
+	}
+	if !repo.Exists(ctx, legacyPathPrefix+path) {
+		t.Error("Exists should work with a legacy path to a prefixed key")
+	}
+
+	// Archives stored before the prefix was configured still resolve
+	legacyPath, err := unprefixed.Store(ctx, "prefixpkg", "0.9.0", []byte("legacy data"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	for _, p := range []string{legacyPath, legacyPathPrefix + legacyPath} {
+		if retrieved, err := repo.Get(ctx, p); err != nil || string(retrieved) != "legacy data" {
+			t.Errorf("Get(%s) = %q, %v, want the unprefixed archive", p, retrieved, err)
+		}
+	}
+
+	// Listing stays inside the prefix
+	keys, err := repo.List(ctx, "prefixpkg/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != path {
+		t.Errorf("expected only %s to be listed, got %v", path, keys)
+	}
+}
+
 func TestGCSRepository_ErrorCases(t *testing.T) {
+	ctx := context.Background()
 	repo := newTestGCSRepo(t)
 
-	_, err := repo.Get("nonexistent/object")
-	if err == nil {
-		t.Error("Get non-existent should return error")
+	_, err := repo.Get(ctx, "nonexistent/object")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get non-existent should return ErrNotFound, got %v", err)
+	}
+
+	_, err = repo.GetReader(ctx, "nonexistent/object")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetReader non-existent should return ErrNotFound, got %v", err)
+	}
+
+	_, err = repo.Size(ctx, "nonexistent/object")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Size non-existent should return ErrNotFound, got %v", err)
 	}
 
-	_, err = repo.GetReader("nonexistent/object")
-	if err == nil {
-		t.Error("GetReader non-existent should return error")
+	err = repo.Delete(ctx, "nonexistent/object")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete non-existent should return ErrNotFound, got %v", err)
 	}
 }
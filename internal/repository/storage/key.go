@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// KeyTemplate lays out archive keys from a package name and version. The
+// placeholders {name}, {version} and {initial} (the first character of the
+// name) are substituted, e.g. "{initial}/{name}/{name}-{version}.tar.gz"
+// shards packages by first letter.
+type KeyTemplate string
+
+// DefaultKeyTemplate is the layout used when no template is configured
+const DefaultKeyTemplate KeyTemplate = "{name}/{version}/{name}-{version}.tar.gz"
+
+var keyPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ParseKeyTemplate validates a template; an empty string selects DefaultKeyTemplate
+func ParseKeyTemplate(s string) (KeyTemplate, error) {
+	if s == "" {
+		return DefaultKeyTemplate, nil
+	}
+
+	for _, placeholder := range keyPlaceholderPattern.FindAllString(s, -1) {
+		switch placeholder {
+		case "{name}", "{version}", "{initial}":
+		default:
+			return "", fmt.Errorf("unknown placeholder %s in storage key template", placeholder)
+		}
+	}
+	// Every version needs its own key or publishes would overwrite each other
+	if !strings.Contains(s, "{name}") || !strings.Contains(s, "{version}") {
+		return "", fmt.Errorf("storage key template must contain {name} and {version}")
+	}
+
+	key := KeyTemplate(s).Key("pkg", "1.0.0")
+	if strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") || path.Clean(key) != key {
+		return "", fmt.Errorf("storage key template must be a clean relative path")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("storage key template must not contain '..'")
+		}
+	}
+
+	return KeyTemplate(s), nil
+}
+
+// ParsePrefix validates a storage prefix and normalizes it to end with a
+// slash, so it can be prepended to keys; an empty string means no prefix
+func ParsePrefix(s string) (string, error) {
+	s = strings.Trim(s, "/")
+	if s == "" {
+		return "", nil
+	}
+	if path.Clean(s) != s {
+		return "", fmt.Errorf("storage prefix must be a clean relative path")
+	}
+	for _, segment := range strings.Split(s, "/") {
+		if segment == ".." || segment == "." {
+			return "", fmt.Errorf("storage prefix must not contain '.' or '..'")
+		}
+	}
+	return s + "/", nil
+}
+
+// Key renders the slash separated key for a package version
+func (t KeyTemplate) Key(packageName, version string) string {
+	if t == "" {
+		t = DefaultKeyTemplate
+	}
+	initial := ""
+	if packageName != "" {
+		initial = packageName[:1]
+	}
+	return strings.NewReplacer(
+		"{name}", packageName,
+		"{version}", version,
+		"{initial}", initial,
+	).Replace(string(t))
+}
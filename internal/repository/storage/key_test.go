@@ -0,0 +1,88 @@
+package storage
+
+import "testing"
+
+func TestParseKeyTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     KeyTemplate
+		wantErr  bool
+	}{
+		{name: "empty uses default", template: "", want: DefaultKeyTemplate},
+		{name: "sharded by initial", template: "{initial}/{name}/{name}-{version}.tar.gz", want: "{initial}/{name}/{name}-{version}.tar.gz"},
+		{name: "flat", template: "{name}-{version}.tar.gz", want: "{name}-{version}.tar.gz"},
+		{name: "missing version", template: "{name}/archive.tar.gz", wantErr: true},
+		{name: "missing name", template: "{version}/archive.tar.gz", wantErr: true},
+		{name: "unknown placeholder", template: "{owner}/{name}-{version}.tar.gz", wantErr: true},
+		{name: "absolute", template: "/{name}/{name}-{version}.tar.gz", wantErr: true},
+		{name: "parent directory", template: "../{name}/{name}-{version}.tar.gz", wantErr: true},
+		{name: "empty segment", template: "{name}//{name}-{version}.tar.gz", wantErr: true},
+		{name: "trailing slash", template: "{name}/{version}/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyTemplate(tt.template)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseKeyTemplate(%q) expected error, got %q", tt.template, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyTemplate(%q) unexpected error: %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseKeyTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTemplate_Key(t *testing.T) {
+	tests := []struct {
+		template KeyTemplate
+		want     string
+	}{
+		{template: DefaultKeyTemplate, want: "http/1.2.0/http-1.2.0.tar.gz"},
+		{template: "", want: "http/1.2.0/http-1.2.0.tar.gz"},
+		{template: "{initial}/{name}/{name}-{version}.tar.gz", want: "h/http/http-1.2.0.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.template.Key("http", "1.2.0"); got != tt.want {
+			t.Errorf("KeyTemplate(%q).Key() = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		want    string
+		wantErr bool
+	}{
+		{prefix: "", want: ""},
+		{prefix: "/", want: ""},
+		{prefix: "prod", want: "prod/"},
+		{prefix: "prod/", want: "prod/"},
+		{prefix: "/envs/staging/", want: "envs/staging/"},
+		{prefix: "prod//archives", wantErr: true},
+		{prefix: "../prod", wantErr: true},
+		{prefix: "./prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePrefix(tt.prefix)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePrefix(%q) expected error, got %q", tt.prefix, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePrefix(%q) = %q, %v, want %q", tt.prefix, got, err, tt.want)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 type localRepository struct {
 	fs       FileSystem
 	basePath string
+	keys     KeyTemplate
 }
 
 type osFileSystem struct{}
@@ -35,38 +43,107 @@ func (osfs *osFileSystem) Stat(name string) (fs.FileInfo, error) {
 	return os.Stat(name)
 }
 
-func NewLocalRepository(basePath string) Repository {
-	return NewLocalRepositoryWithFS(&osFileSystem{}, basePath)
+func (osfs *osFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
 }
 
-func NewLocalRepositoryWithFS(filesystem FileSystem, basePath string) Repository {
+func NewLocalRepository(basePath string, keys KeyTemplate) Repository {
+	return NewLocalRepositoryWithFS(&osFileSystem{}, basePath, keys)
+}
+
+func NewLocalRepositoryWithFS(filesystem FileSystem, basePath string, keys KeyTemplate) Repository {
 	return &localRepository{
 		fs:       filesystem,
 		basePath: basePath,
+		keys:     keys,
 	}
 }
 
-func (r *localRepository) Store(packageName, version string, data []byte) (string, error) {
-	dir := filepath.Join(r.basePath, packageName, version)
-	if err := r.fs.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
-	}
+// Local file operations are quick and can't be interrupted, so the context
+// is only checked before starting work that is no longer wanted.
 
-	filename := fmt.Sprintf("%s-%s.tar.gz", packageName, version)
-	path := filepath.Join(dir, filename)
+func (r *localRepository) Store(ctx context.Context, packageName, version string, data []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.basePath, filepath.FromSlash(r.keys.Key(packageName, version)))
+	if err := r.write(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
 
-	if err := r.fs.WriteFile(path, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+func (r *localRepository) Stage(ctx context.Context, packageName, version string, data []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	path, err := stagedKey(filepath.Join(r.basePath, filepath.FromSlash(r.keys.Key(packageName, version))))
+	if err != nil {
+		return "", err
 	}
+	if err := r.write(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
 
+func (r *localRepository) Commit(ctx context.Context, stagedPath string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	path, err := committedKey(stagedPath)
+	if err != nil {
+		return "", err
+	}
+	if err := r.fs.Rename(stagedPath, path); err != nil {
+		return "", notFoundError(fmt.Errorf("failed to move staged file into place: %w", err))
+	}
 	return path, nil
 }
 
-func (r *localRepository) Get(path string) ([]byte, error) {
-	file, err := r.fs.Open(path)
+// write stores data at path, creating its directory
+func (r *localRepository) write(path string, data []byte) error {
+	if err := r.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temp file in the same directory and rename it into place so a
+	// crash mid-write never leaves a partial archive at the final path
+	tmpPath, err := tempPath(path)
 	if err != nil {
+		return err
+	}
+
+	if err := r.fs.WriteFile(tmpPath, data, 0644); err != nil {
+		_ = r.fs.Remove(tmpPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := r.fs.Rename(tmpPath, path); err != nil {
+		_ = r.fs.Remove(tmpPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	return nil
+}
+
+// tempPath returns a unique sibling path for staging writes to path
+func tempPath(path string) (string, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("failed to generate temp file name: %w", err)
+	}
+	return fmt.Sprintf("%s.tmp-%s", path, hex.EncodeToString(suffix[:])), nil
+}
+
+func (r *localRepository) Get(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
 	defer func() {
 		if closeErr := file.Close(); closeErr != nil {
 			// Log error but don't fail the operation - we could log this with slog
@@ -77,15 +154,100 @@ func (r *localRepository) Get(path string) ([]byte, error) {
 	return io.ReadAll(file)
 }
 
-func (r *localRepository) GetReader(path string) (io.ReadCloser, error) {
-	return r.fs.Open(path)
+func (r *localRepository) GetReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	return file, nil
+}
+
+func (r *localRepository) Open(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	if seekable, ok := file.(io.ReadSeekCloser); ok {
+		return seekable, nil
+	}
+
+	// File systems without seekable files are read into memory
+	defer func() { _ = file.Close() }()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, nil
+}
+
+// nopSeekCloser adds a no-op Close to an in-memory object
+type nopSeekCloser struct {
+	io.ReadSeeker
 }
 
-func (r *localRepository) Exists(path string) bool {
+func (nopSeekCloser) Close() error { return nil }
+
+func (r *localRepository) Exists(ctx context.Context, path string) bool {
 	_, err := r.fs.Stat(path)
 	return err == nil
 }
 
-func (r *localRepository) Delete(path string) error {
-	return r.fs.Remove(path)
+func (r *localRepository) Size(ctx context.Context, path string) (int64, error) {
+	info, err := r.fs.Stat(path)
+	if err != nil {
+		return 0, notFoundError(err)
+	}
+	return info.Size(), nil
+}
+
+func (r *localRepository) ModTime(ctx context.Context, path string) (time.Time, error) {
+	info, err := r.fs.Stat(path)
+	if err != nil {
+		return time.Time{}, notFoundError(err)
+	}
+	return info.ModTime(), nil
+}
+
+func (r *localRepository) Delete(ctx context.Context, path string) error {
+	return notFoundError(r.fs.Remove(path))
+}
+
+// notFoundError wraps file system errors for missing files with ErrNotFound
+func notFoundError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
+func (r *localRepository) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	err := fs.WalkDir(r.fs, r.basePath, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// An empty storage directory simply has nothing to list
+			if path == r.basePath && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.basePath, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(filepath.ToSlash(rel), prefix) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return paths, nil
 }
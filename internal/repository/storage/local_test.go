@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"io"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"testing"
 	"testing/fstest"
@@ -25,6 +29,10 @@ func (t *testFS) Open(name string) (fs.File, error) {
 	return t.MapFS.Open(t.normalizePath(name))
 }
 
+func (t *testFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return t.MapFS.ReadDir(t.normalizePath(name))
+}
+
 func (t *testFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
 	t.MapFS[t.normalizePath(name)] = &fstest.MapFile{Data: data, Mode: perm}
 	return nil
@@ -52,7 +60,19 @@ func (t *testFS) Stat(name string) (fs.FileInfo, error) {
 			mode: file.Mode,
 		}, nil
 	}
-	return nil, fs.ErrNotExist
+	// Directories are synthesized by MapFS from the file paths
+	return fs.Stat(t.MapFS, normalizedName)
+}
+
+func (t *testFS) Rename(oldpath, newpath string) error {
+	oldName := t.normalizePath(oldpath)
+	file, exists := t.MapFS[oldName]
+	if !exists {
+		return fs.ErrNotExist
+	}
+	t.MapFS[t.normalizePath(newpath)] = file
+	delete(t.MapFS, oldName)
+	return nil
 }
 
 type testFileInfo struct {
@@ -69,11 +89,12 @@ func (fi *testFileInfo) IsDir() bool        { return false }
 func (fi *testFileInfo) Sys() interface{}   { return nil }
 
 func TestLocalRepository_Store(t *testing.T) {
+	ctx := context.Background()
 	fs := &testFS{fstest.MapFS{}}
-	repo := NewLocalRepositoryWithFS(fs, "/storage")
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
 	
 	data := []byte("test package data")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
@@ -84,22 +105,122 @@ func TestLocalRepository_Store(t *testing.T) {
 	}
 	
 	// Verify file was stored
-	if !repo.Exists(path) {
+	if !repo.Exists(ctx, path) {
 		t.Error("File should exist after storing")
 	}
 }
 
+func TestLocalRepository_Store_KeyTemplate(t *testing.T) {
+	ctx := context.Background()
+	fs := &testFS{fstest.MapFS{}}
+	repo := NewLocalRepositoryWithFS(fs, "/storage", "{initial}/{name}/{name}-{version}.tar.gz")
+
+	data := []byte("sharded package data")
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	expected := "/storage/t/testpkg/testpkg-1.0.0.tar.gz"
+	if path != expected {
+		t.Errorf("Expected path %s, got %s", expected, path)
+	}
+
+	retrieved, err := repo.Get(ctx, path)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(retrieved) != string(data) {
+		t.Errorf("Expected %s, got %s", data, retrieved)
+	}
+
+	paths, err := repo.List(ctx, "t/testpkg/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != expected {
+		t.Errorf("Expected only the sharded archive, got %v", paths)
+	}
+}
+
+func TestLocalRepository_StageCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage", DefaultKeyTemplate)
+
+	first, err := repo.Stage(ctx, "testpkg", "1.0.0", []byte("first"))
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	second, err := repo.Stage(ctx, "testpkg", "1.0.0", []byte("second"))
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if first == second {
+		t.Fatalf("Expected each staged archive to get its own path, got %s twice", first)
+	}
+
+	path, err := repo.Commit(ctx, first)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if expected := "/storage/testpkg/1.0.0/testpkg-1.0.0.tar.gz"; path != expected {
+		t.Errorf("Expected path %s, got %s", expected, path)
+	}
+	if data, err := repo.Get(ctx, path); err != nil || string(data) != "first" {
+		t.Errorf("Expected the committed archive, got %q, %v", data, err)
+	}
+	if repo.Exists(ctx, first) {
+		t.Error("Committing should remove the staged archive")
+	}
+	// Another staged archive of the version is untouched
+	if data, err := repo.Get(ctx, second); err != nil || string(data) != "second" {
+		t.Errorf("Expected the other staged archive, got %q, %v", data, err)
+	}
+
+	if _, err := repo.Commit(ctx, path); err == nil {
+		t.Error("Expected committing an archive that isn't staged to fail")
+	}
+}
+
+// failingWriteFS simulates a crash mid-write by storing only part of the data
+type failingWriteFS struct {
+	*testFS
+}
+
+func (f *failingWriteFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	_ = f.testFS.WriteFile(name, data[:len(data)/2], perm)
+	return errors.New("disk full")
+}
+
+func TestLocalRepository_Store_FailedWrite(t *testing.T) {
+	ctx := context.Background()
+	mapFS := fstest.MapFS{}
+	repo := NewLocalRepositoryWithFS(&failingWriteFS{&testFS{mapFS}}, "/storage", DefaultKeyTemplate)
+
+	if _, err := repo.Store(ctx, "testpkg", "1.0.0", []byte("test package data")); err == nil {
+		t.Fatal("Expected Store to fail")
+	}
+
+	if repo.Exists(ctx, "/storage/testpkg/1.0.0/testpkg-1.0.0.tar.gz") {
+		t.Error("Failed write should not leave a file at the final path")
+	}
+	if len(mapFS) != 0 {
+		t.Errorf("Failed write should clean up temp files, found %d files", len(mapFS))
+	}
+}
+
 func TestLocalRepository_Get(t *testing.T) {
+	ctx := context.Background()
 	fs := &testFS{fstest.MapFS{}}
-	repo := NewLocalRepositoryWithFS(fs, "/storage")
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
 	
 	data := []byte("test package data")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 	
-	retrieved, err := repo.Get(path)
+	retrieved, err := repo.Get(ctx, path)
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
@@ -110,16 +231,17 @@ func TestLocalRepository_Get(t *testing.T) {
 }
 
 func TestLocalRepository_GetReader(t *testing.T) {
+	ctx := context.Background()
 	fs := &testFS{fstest.MapFS{}}
-	repo := NewLocalRepositoryWithFS(fs, "/storage")
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
 	
 	data := []byte("test package data")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 	
-	reader, err := repo.GetReader(path)
+	reader, err := repo.GetReader(ctx, path)
 	if err != nil {
 		t.Fatalf("GetReader failed: %v", err)
 	}
@@ -141,51 +263,198 @@ func TestLocalRepository_GetReader(t *testing.T) {
 }
 
 func TestLocalRepository_Delete(t *testing.T) {
+	ctx := context.Background()
 	fs := &testFS{fstest.MapFS{}}
-	repo := NewLocalRepositoryWithFS(fs, "/storage")
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
 	
 	data := []byte("test package data")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 	
-	if !repo.Exists(path) {
+	if !repo.Exists(ctx, path) {
 		t.Error("File should exist before deletion")
 	}
 	
-	err = repo.Delete(path)
+	err = repo.Delete(ctx, path)
 	if err != nil {
 		t.Fatalf("Delete failed: %v", err)
 	}
 	
-	if repo.Exists(path) {
+	if repo.Exists(ctx, path) {
 		t.Error("File should not exist after deletion")
 	}
 }
 
 func TestLocalRepository_Exists(t *testing.T) {
+	ctx := context.Background()
 	fs := &testFS{fstest.MapFS{}}
-	repo := NewLocalRepositoryWithFS(fs, "/storage")
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
 	
 	// Test non-existent file
-	if repo.Exists("/nonexistent") {
+	if repo.Exists(ctx, "/nonexistent") {
 		t.Error("Non-existent file should not exist")
 	}
 	
 	// Test existing file
 	data := []byte("test")
-	path, err := repo.Store("testpkg", "1.0.0", data)
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
 	if err != nil {
 		t.Fatalf("Store failed: %v", err)
 	}
 	
-	if !repo.Exists(path) {
+	if !repo.Exists(ctx, path) {
 		t.Error("Stored file should exist")
 	}
 }
 
+func TestLocalRepository_Size(t *testing.T) {
+	ctx := context.Background()
+	fs := &testFS{fstest.MapFS{}}
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
+
+	data := []byte("test package data")
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", data)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	size, err := repo.Size(ctx, path)
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("Expected size %d, got %d", len(data), size)
+	}
+
+	if _, err := repo.Size(ctx, "/nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for non-existent file, got %v", err)
+	}
+}
+
+func TestLocalRepository_Open(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalRepository(t.TempDir(), DefaultKeyTemplate)
+
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", []byte("0123456789"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	rs, err := repo.(Seeker).Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer rs.Close()
+
+	if _, err := rs.Seek(4, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(rest) != "456789" {
+		t.Errorf("Expected 456789 after seeking, got %s", rest)
+	}
+
+	if _, err := repo.(Seeker).Open(ctx, "/nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for non-existent file, got %v", err)
+	}
+}
+
+func TestLocalRepository_Cancelled(t *testing.T) {
+	fs := &testFS{fstest.MapFS{}}
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
+	path, err := repo.Store(context.Background(), "testpkg", "1.0.0", []byte("data"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.Store(ctx, "testpkg", "2.0.0", []byte("data")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Store to be cancelled, got %v", err)
+	}
+	if repo.Exists(context.Background(), "/storage/testpkg/2.0.0/testpkg-2.0.0.tar.gz") {
+		t.Error("Cancelled Store should not write the archive")
+	}
+	if _, err := repo.Get(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Get to be cancelled, got %v", err)
+	}
+	if _, err := repo.List(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected List to be cancelled, got %v", err)
+	}
+}
+
+func TestLocalRepository_List(t *testing.T) {
+	ctx := context.Background()
+	fs := &testFS{fstest.MapFS{}}
+	repo := NewLocalRepositoryWithFS(fs, "/storage", DefaultKeyTemplate)
+
+	// Listing an empty storage directory is not an error
+	paths, err := repo.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List on empty storage failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("Expected no paths, got %v", paths)
+	}
+
+	for _, pkg := range []string{"pkga", "pkgb"} {
+		if _, err := repo.Store(ctx, pkg, "1.0.0", []byte("data")); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	paths, err = repo.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Expected 2 paths, got %v", paths)
+	}
+
+	paths, err = repo.List(ctx, "pkga/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/storage/pkga/1.0.0/pkga-1.0.0.tar.gz" {
+		t.Errorf("Expected only the pkga archive, got %v", paths)
+	}
+}
+
+func TestLocalRepository_ModTime(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalRepository(t.TempDir(), DefaultKeyTemplate)
+
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", []byte("data"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	modTime, err := repo.ModTime(ctx, path)
+	if err != nil {
+		t.Fatalf("ModTime failed: %v", err)
+	}
+	if !modTime.Equal(old) {
+		t.Errorf("Expected mod time %v, got %v", old, modTime)
+	}
+
+	if _, err := repo.ModTime(ctx, path+".missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for non-existent file, got %v", err)
+	}
+}
+
 func TestLocalRepository_ErrorCases(t *testing.T) {
+	ctx := context.Background()
 	tests := []struct {
 		name     string
 		setup    func() Repository
@@ -194,30 +463,40 @@ func TestLocalRepository_ErrorCases(t *testing.T) {
 		{
 			name: "Get non-existent file",
 			setup: func() Repository {
-				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage")
+				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage", DefaultKeyTemplate)
 			},
 			testFunc: func(repo Repository) error {
-				_, err := repo.Get("/nonexistent")
+				_, err := repo.Get(ctx, "/nonexistent")
 				return err
 			},
 		},
 		{
 			name: "GetReader non-existent file",
 			setup: func() Repository {
-				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage")
+				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage", DefaultKeyTemplate)
 			},
 			testFunc: func(repo Repository) error {
-				_, err := repo.GetReader("/nonexistent")
+				_, err := repo.GetReader(ctx, "/nonexistent")
 				return err
 			},
 		},
 		{
 			name: "Delete non-existent file",
 			setup: func() Repository {
-				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage")
+				return NewLocalRepositoryWithFS(&testFS{fstest.MapFS{}}, "/storage", DefaultKeyTemplate)
+			},
+			testFunc: func(repo Repository) error {
+				return repo.Delete(ctx, "/nonexistent")
+			},
+		},
+		{
+			name: "Get non-existent file on disk",
+			setup: func() Repository {
+				return NewLocalRepository(t.TempDir(), DefaultKeyTemplate)
 			},
 			testFunc: func(repo Repository) error {
-				return repo.Delete("/nonexistent")
+				_, err := repo.Get(ctx, filepath.Join(t.TempDir(), "nonexistent"))
+				return err
 			},
 		},
 	}
@@ -226,8 +505,8 @@ func TestLocalRepository_ErrorCases(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			repo := tt.setup()
 			err := tt.testFunc(repo)
-			if err == nil {
-				t.Error("Expected error for operation on non-existent file")
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for operation on non-existent file, got %v", err)
 			}
 		})
 	}
@@ -235,7 +514,7 @@ func TestLocalRepository_ErrorCases(t *testing.T) {
 
 func TestNewLocalRepository_Coverage(t *testing.T) {
 	// Test the constructor that uses osFileSystem
-	repo := NewLocalRepository("/tmp")
+	repo := NewLocalRepository("/tmp", DefaultKeyTemplate)
 	if repo == nil {
 		t.Error("Expected repository, got nil")
 	}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"repub/internal/telemetry"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("repub/internal/repository/storage")
+
+// tracedRepository wraps a Repository and records a span for every operation
+type tracedRepository struct {
+	next Repository
+}
+
+// tracedSeeker is a tracedRepository over a Seeker, so wrapping keeps
+// range downloads streaming from the backend
+type tracedSeeker struct {
+	*tracedRepository
+	seeker Seeker
+}
+
+// NewTracedRepository wraps a Repository with OpenTelemetry spans. The
+// result is a Seeker if next is one.
+func NewTracedRepository(next Repository) Repository {
+	traced := &tracedRepository{next: next}
+	if seeker, ok := next.(Seeker); ok {
+		return &tracedSeeker{tracedRepository: traced, seeker: seeker}
+	}
+	return traced
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (r *tracedRepository) Store(ctx context.Context, packageName, version string, data []byte) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Store", attribute.String("package", packageName), attribute.String("version", version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Store(ctx, packageName, version, data)
+}
+
+func (r *tracedRepository) Stage(ctx context.Context, packageName, version string, data []byte) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Stage", attribute.String("package", packageName), attribute.String("version", version))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Stage(ctx, packageName, version, data)
+}
+
+func (r *tracedRepository) Commit(ctx context.Context, stagedPath string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Commit", attribute.String("storage.path", stagedPath))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Commit(ctx, stagedPath)
+}
+
+func (r *tracedRepository) Get(ctx context.Context, path string) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "Get", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Get(ctx, path)
+}
+
+// GetReader's span ends once the object is open, not when it has been read
+func (r *tracedRepository) GetReader(ctx context.Context, path string) (_ io.ReadCloser, err error) {
+	ctx, span := startSpan(ctx, "GetReader", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.GetReader(ctx, path)
+}
+
+func (r *tracedRepository) Exists(ctx context.Context, path string) bool {
+	ctx, span := startSpan(ctx, "Exists", attribute.String("storage.path", path))
+	defer span.End()
+	return r.next.Exists(ctx, path)
+}
+
+func (r *tracedRepository) Size(ctx context.Context, path string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "Size", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Size(ctx, path)
+}
+
+func (r *tracedRepository) ModTime(ctx context.Context, path string) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "ModTime", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.ModTime(ctx, path)
+}
+
+func (r *tracedRepository) Delete(ctx context.Context, path string) (err error) {
+	ctx, span := startSpan(ctx, "Delete", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.Delete(ctx, path)
+}
+
+func (r *tracedRepository) List(ctx context.Context, prefix string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "List", attribute.String("storage.prefix", prefix))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.next.List(ctx, prefix)
+}
+
+// Open's span ends once the object is open, not when it has been read
+func (r *tracedSeeker) Open(ctx context.Context, path string) (_ io.ReadSeekCloser, err error) {
+	ctx, span := startSpan(ctx, "Open", attribute.String("storage.path", path))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return r.seeker.Open(ctx, path)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedRepository(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	repo := NewTracedRepository(NewLocalRepository(t.TempDir(), DefaultKeyTemplate))
+	ctx := context.Background()
+
+	path, err := repo.Store(ctx, "testpkg", "1.0.0", []byte("archive"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if _, err := repo.ModTime(ctx, path); err != nil {
+		t.Fatalf("ModTime failed: %v", err)
+	}
+
+	// Wrapping keeps a seekable backend seekable
+	seeker, ok := repo.(Seeker)
+	if !ok {
+		t.Fatal("Expected the traced local repository to be a Seeker")
+	}
+	archive, err := seeker.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	_ = archive.Close()
+
+	if _, err := repo.Get(ctx, path+".missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	expected := []string{"storage.Store", "storage.ModTime", "storage.Open", "storage.Get"}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d", len(expected), len(spans))
+	}
+	for i, name := range expected {
+		if spans[i].Name() != name {
+			t.Errorf("Expected span %d to be %s, got %s", i, name, spans[i].Name())
+		}
+	}
+	if spans[3].Status().Code != codes.Error {
+		t.Errorf("Expected error status for the failed Get, got %v", spans[3].Status().Code)
+	}
+}
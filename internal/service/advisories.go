@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	// maxAdvisoryBytes caps a page of advisories read from OSV
+	maxAdvisoryBytes = 16 << 20
+	// maxAdvisoryPages caps the pages of advisories read for one package
+	maxAdvisoryPages = 10
+	// maxAdvisoryCacheEntries caps the packages whose advisories are kept in
+	// memory
+	maxAdvisoryCacheEntries = 10000
+	// defaultAdvisoryTTL is how long the advisories of a package are reused;
+	// clients ask for them on every dependency resolution
+	defaultAdvisoryTTL = 10 * time.Minute
+)
+
+// ErrAdvisoriesUnavailable is returned when the advisory database can't be
+// reached or answers with an error
+var ErrAdvisoriesUnavailable = errors.New("advisories unavailable")
+
+// AdvisoryConfig configures an AdvisoryClient; zero values select the defaults
+type AdvisoryConfig struct {
+	// URL of the OSV API, e.g. https://api.osv.dev
+	URL string
+	// TTL is how long the advisories of a package are reused before asking
+	// OSV again; negative disables it
+	TTL time.Duration
+	// Transport is used by the default Client, nil for http.DefaultTransport
+	Transport http.RoundTripper
+	Client    *http.Client
+	Clock     clock.Clock
+}
+
+// AdvisoryClient looks up the security advisories of pub packages in the OSV
+// database
+type AdvisoryClient struct {
+	cfg AdvisoryConfig
+
+	mu    sync.Mutex
+	cache map[string]cachedAdvisories
+}
+
+type cachedAdvisories struct {
+	advisories []domain.Advisory
+	expires    time.Time
+}
+
+// NewAdvisoryClient creates a client for the OSV API at cfg.URL
+func NewAdvisoryClient(cfg AdvisoryConfig) *AdvisoryClient {
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second, Transport: cfg.Transport}
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultAdvisoryTTL
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	return &AdvisoryClient{cfg: cfg, cache: make(map[string]cachedAdvisories)}
+}
+
+// osvQuery is the body of an OSV query for a pub package
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+type osvResponse struct {
+	Vulns         []domain.Advisory `json:"vulns"`
+	NextPageToken string            `json:"next_page_token"`
+}
+
+// advisories returns the advisories OSV has for a pub package. Answers are
+// reused for TTL, failures are not.
+func (c *AdvisoryClient) advisories(ctx context.Context, name string) ([]domain.Advisory, error) {
+	if cached, ok := c.cached(name); ok {
+		return cached, nil
+	}
+
+	query := osvQuery{}
+	query.Package.Name = name
+	query.Package.Ecosystem = "Pub"
+	advisories := []domain.Advisory{}
+	for range maxAdvisoryPages {
+		page, err := c.query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		advisories = append(advisories, page.Vulns...)
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+	}
+
+	c.store(name, advisories)
+	return advisories, nil
+}
+
+func (c *AdvisoryClient) query(ctx context.Context, query osvQuery) (*osvResponse, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode advisory query: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL+"/v1/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create advisory request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAdvisoriesUnavailable, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: OSV answered status %d", ErrAdvisoriesUnavailable, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAdvisoryBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read OSV response: %w", ErrAdvisoriesUnavailable, err)
+	}
+	if len(data) > maxAdvisoryBytes {
+		return nil, fmt.Errorf("%w: OSV response exceeds %d bytes", ErrAdvisoriesUnavailable, maxAdvisoryBytes)
+	}
+	var page osvResponse
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, fmt.Errorf("%w: invalid OSV response: %w", ErrAdvisoriesUnavailable, err)
+	}
+	return &page, nil
+}
+
+// advisoriesUpdated returns when the newest of advisories was last modified,
+// the Unix epoch if there are none. It only moves when an advisory changes,
+// so clients don't fetch the advisories again on every resolution.
+func advisoriesUpdated(advisories []domain.Advisory) time.Time {
+	updated := time.Unix(0, 0).UTC()
+	for _, advisory := range advisories {
+		if modified, err := time.Parse(time.RFC3339, advisory.Modified); err == nil && modified.After(updated) {
+			updated = modified.UTC()
+		}
+	}
+	return updated
+}
+
+// cached returns the unexpired advisories cached for name
+func (c *AdvisoryClient) cached(name string) ([]domain.Advisory, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.cache[name]
+	if !ok || !c.cfg.Clock.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.advisories, true
+}
+
+// store keeps the advisories of name for TTL
+func (c *AdvisoryClient) store(name string, advisories []domain.Advisory) {
+	if c.cfg.TTL < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.cfg.Clock.Now()
+	if len(c.cache) >= maxAdvisoryCacheEntries {
+		for key, entry := range c.cache {
+			if !now.Before(entry.expires) {
+				delete(c.cache, key)
+			}
+		}
+		if len(c.cache) >= maxAdvisoryCacheEntries {
+			clear(c.cache)
+		}
+	}
+	c.cache[name] = cachedAdvisories{advisories: advisories, expires: now.Add(c.cfg.TTL)}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdvisoryClient(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []osvQuery
+		status  = http.StatusOK
+	)
+	osv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var query osvQuery
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/query" || json.NewDecoder(r.Body).Decode(&query) != nil {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		queries = append(queries, query)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		// Advisories come in two pages
+		if query.PageToken == "" {
+			_, _ = w.Write([]byte(`{"vulns":[{"id":"GHSA-1","summary":"First","modified":"2024-01-01T00:00:00Z"}],"next_page_token":"more"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"vulns":[{"id":"GHSA-2","summary":"Second","modified":"2024-02-01T00:00:00Z"}]}`))
+	}))
+	defer osv.Close()
+
+	clk := clock.NewFake(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	client := NewAdvisoryClient(AdvisoryConfig{URL: osv.URL + "/", TTL: time.Minute, Clock: clk})
+	ctx := context.Background()
+
+	advisories, err := client.advisories(ctx, "http")
+	if err != nil {
+		t.Fatalf("advisories failed: %v", err)
+	}
+	if len(advisories) != 2 || advisories[0].ID != "GHSA-1" || advisories[1].ID != "GHSA-2" {
+		t.Fatalf("Expected both pages of advisories, got %+v", advisories)
+	}
+	if len(queries) != 2 || queries[0].Package.Name != "http" || queries[0].Package.Ecosystem != "Pub" || queries[1].PageToken != "more" {
+		t.Errorf("Unexpected OSV queries %+v", queries)
+	}
+
+	// Answers are reused until they expire
+	if _, err := client.advisories(ctx, "http"); err != nil {
+		t.Fatalf("advisories failed: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("Expected cached advisories, got %d queries", len(queries))
+	}
+	if updated := advisoriesUpdated(advisories); !updated.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the newest modification time, got %s", updated)
+	}
+
+	clk.Advance(2 * time.Minute)
+	status = http.StatusServiceUnavailable
+	if _, err := client.advisories(ctx, "http"); !errors.Is(err, ErrAdvisoriesUnavailable) {
+		t.Errorf("Expected ErrAdvisoriesUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"repub/internal/domain"
+	"repub/internal/repository/pubspec"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ArchiveContents is what a package archive provides to a published version
+type ArchiveContents struct {
+	Pubspec     *domain.Pubspec
+	PubspecYAML string
+	Readme      *string
+	Changelog   *string
+}
+
+// ArchiveLimits are optional checks of the files in an archive; zero values
+// disable them
+type ArchiveLimits struct {
+	// MaxFiles caps the number of files in the archive
+	MaxFiles int
+	// MaxFileBytes caps the uncompressed size of any single file
+	MaxFileBytes int64
+	// RequireDartCode rejects archives without a lib/ directory or .dart file
+	RequireDartCode bool
+	// MaxDocBytes caps the README and CHANGELOG stored with a version. Longer
+	// ones are truncated with a notice, or rejected with RejectLargeDocs.
+	MaxDocBytes     int64
+	RejectLargeDocs bool
+}
+
+func (l ArchiveLimits) enabled() bool {
+	return l.MaxFiles > 0 || l.MaxFileBytes > 0 || l.RequireDartCode
+}
+
+// ArchiveInventory summarizes the files of an archive
+type ArchiveInventory struct {
+	Files            int
+	LargestFile      string
+	LargestFileBytes int64
+	HasDartCode      bool
+}
+
+// add records a tar entry in the inventory
+func (inv *ArchiveInventory) add(header *tar.Header) {
+	name := strings.TrimPrefix(header.Name, "./")
+	if header.Typeflag == tar.TypeDir {
+		if strings.TrimSuffix(name, "/") == "lib" {
+			inv.HasDartCode = true
+		}
+		return
+	}
+
+	inv.Files++
+	if header.Size > inv.LargestFileBytes {
+		inv.LargestFile, inv.LargestFileBytes = name, header.Size
+	}
+	if strings.HasPrefix(name, "lib/") || strings.HasSuffix(name, ".dart") {
+		inv.HasDartCode = true
+	}
+}
+
+// check reports the first limit the inventory exceeds, wrapping ErrArchiveInvalid
+func (l ArchiveLimits) check(inv *ArchiveInventory) error {
+	if l.MaxFiles > 0 && inv.Files > l.MaxFiles {
+		return fmt.Errorf("%w: archive contains %d files, the limit is %d", ErrArchiveInvalid, inv.Files, l.MaxFiles)
+	}
+	if l.MaxFileBytes > 0 && inv.LargestFileBytes > l.MaxFileBytes {
+		return fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrArchiveInvalid, inv.LargestFile, inv.LargestFileBytes, l.MaxFileBytes)
+	}
+	if l.RequireDartCode && !inv.HasDartCode {
+		return fmt.Errorf("%w: archive contains no lib/ directory or .dart files", ErrArchiveInvalid)
+	}
+	return nil
+}
+
+// limitDoc applies MaxDocBytes to a README or CHANGELOG: an oversized doc is
+// cut at a character boundary and ends with a notice, or wraps
+// ErrArchiveInvalid with RejectLargeDocs
+func (l ArchiveLimits) limitDoc(name string, doc *string) (*string, error) {
+	if doc == nil || l.MaxDocBytes <= 0 || int64(len(*doc)) <= l.MaxDocBytes {
+		return doc, nil
+	}
+	if l.RejectLargeDocs {
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrArchiveInvalid, name, len(*doc), l.MaxDocBytes)
+	}
+
+	cut := int(l.MaxDocBytes)
+	for cut > 0 && !utf8.RuneStart((*doc)[cut]) {
+		cut--
+	}
+	truncated := (*doc)[:cut] + fmt.Sprintf("\n\n*The %s was truncated, it exceeds the %d byte limit of this registry.*\n", name, l.MaxDocBytes)
+	return &truncated, nil
+}
+
+// ValidateArchive extracts an archive and parses and validates its pubspec.yaml,
+// the same checks PublishPackage applies before touching the database or storage.
+// Errors wrap ErrPubspecInvalid.
+func ValidateArchive(ctx context.Context, parser pubspec.Repository, archive []byte) (*ArchiveContents, error) {
+	return ValidateArchiveWithLimits(ctx, parser, archive, ArchiveLimits{})
+}
+
+// ValidateArchiveWithLimits is ValidateArchive that also checks the archive's
+// files against limits; limit violations wrap ErrArchiveInvalid
+func ValidateArchiveWithLimits(ctx context.Context, parser pubspec.Repository, archive []byte, limits ArchiveLimits) (*ArchiveContents, error) {
+	// Limits need every entry, otherwise extraction stops after the docs
+	var inventory *ArchiveInventory
+	if limits.enabled() {
+		inventory = &ArchiveInventory{}
+	}
+
+	extracted, err := extractArchive(archive, inventory, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to extract files from archive: %w", ErrPubspecInvalid, err)
+	}
+	if inventory != nil {
+		if err := limits.check(inventory); err != nil {
+			return nil, err
+		}
+	}
+	readme, err := limits.limitDoc("README", extracted.readme)
+	if err != nil {
+		return nil, err
+	}
+	changelog, err := limits.limitDoc("CHANGELOG", extracted.changelog)
+	if err != nil {
+		return nil, err
+	}
+
+	// ParseYAML also runs ValidatePubspec
+	parsed, err := parser.ParseYAML(ctx, extracted.pubspec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse pubspec.yaml: %w", ErrPubspecInvalid, err)
+	}
+
+	// A tarball built from another version than its pubspec declares is stale
+	if dirVersion, ok := strings.CutPrefix(extracted.packageDir, parsed.Name+"-"); ok && dirVersion != parsed.Version {
+		return nil, fmt.Errorf("%w: archive directory %s doesn't match version %s in pubspec.yaml", ErrArchiveInvalid, extracted.packageDir, parsed.Version)
+	}
+
+	return &ArchiveContents{
+		Pubspec:     parsed,
+		PubspecYAML: extracted.pubspec,
+		Readme:      readme,
+		Changelog:   changelog,
+	}, nil
+}
+
+// ArchivePackageName returns the package name declared by an archive's
+// pubspec.yaml without validating the rest of the pubspec
+func ArchivePackageName(archive []byte) (string, error) {
+	pubspecContent, _, _, err := extractFilesFromArchive(archive)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to extract files from archive: %w", ErrPubspecInvalid, err)
+	}
+
+	var declared struct {
+		Name string `yaml:"name"`
+	}
+	if err := yaml.Unmarshal([]byte(pubspecContent), &declared); err != nil {
+		return "", fmt.Errorf("%w: failed to parse pubspec.yaml: %w", ErrPubspecInvalid, err)
+	}
+	return declared.Name, nil
+}
+
+// maxExtractedFileSize caps how much of a single pubspec, README or CHANGELOG
+// is read from an archive
+const maxExtractedFileSize = 16 << 20
+
+func extractFilesFromArchive(archiveData []byte) (pubspecContent string, readme *string, changelog *string, err error) {
+	extracted, err := extractArchive(archiveData, nil, nil)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return extracted.pubspec, extracted.readme, extracted.changelog, nil
+}
+
+// extractedArchive is what extractArchive reads from an archive
+type extractedArchive struct {
+	pubspec   string
+	readme    *string
+	changelog *string
+	// packageDir is the top-level directory holding the pubspec, such as
+	// name-1.2.3, empty when the pubspec is at the root
+	packageDir string
+	// files holds the archiveFiles found, by cleaned path
+	files map[string][]byte
+}
+
+// archiveFiles asks extractArchive for other files, by path relative to the
+// package root. Files larger than maxBytes are left out, as are paths the
+// archive doesn't contain.
+type archiveFiles struct {
+	paths    []string
+	maxBytes int64
+}
+
+// extractArchive reads the pubspec, README and CHANGELOG of an archive, and
+// the files asked for. With an inventory it records every entry, otherwise it
+// stops as soon as it can.
+func extractArchive(archiveData []byte, inventory *ArchiveInventory, files *archiveFiles) (*extractedArchive, error) {
+	// Create a gzip reader
+	gzReader, err := gzip.NewReader(bytes.NewReader(archiveData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer func() { _ = gzReader.Close() }()
+
+	// Create a tar reader
+	tarReader := tar.NewReader(gzReader)
+
+	var extracted extractedArchive
+	wanted := make(map[string]bool)
+	if files != nil {
+		extracted.files = make(map[string][]byte)
+		for _, p := range files.paths {
+			wanted[path.Clean(strings.TrimPrefix(p, "./"))] = true
+		}
+	}
+	var foundPubspec bool
+	var readmeRank, changelogRank int
+	// Control files seen, by lowercased path relative to the package root;
+	// clients extracting the archive would keep whichever copy comes last
+	seenControlFiles := make(map[string]bool)
+	for {
+		// Stop once nothing later in the archive could replace what we have: the
+		// pubspec and the preferred README and CHANGELOG variants
+		if inventory == nil && foundPubspec && extracted.readme != nil && readmeRank == 0 && extracted.changelog != nil && changelogRank == 0 && len(extracted.files) == len(wanted) {
+			break
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		if inventory != nil {
+			inventory.add(header)
+		}
+
+		// Skip directories
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		// Get the file name relative to the package root
+		entryName := strings.TrimPrefix(header.Name, "./")
+		fileName := packageRootPath(entryName)
+
+		lowerName := strings.ToLower(fileName)
+		if isControlFile(lowerName) {
+			if seenControlFiles[lowerName] {
+				return nil, fmt.Errorf("archive contains %s more than once", strings.TrimPrefix(header.Name, "./"))
+			}
+			seenControlFiles[lowerName] = true
+		}
+
+		if cleaned := path.Clean(fileName); wanted[cleaned] && header.Typeflag == tar.TypeReg && header.Size <= files.maxBytes {
+			content, err := io.ReadAll(io.LimitReader(tarReader, files.maxBytes+1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+			}
+			if int64(len(content)) <= files.maxBytes {
+				extracted.files[cleaned] = content
+			}
+			continue
+		}
+
+		switch {
+		case lowerName == "pubspec.yaml":
+			content, err := readArchiveEntry(tarReader, fileName)
+			if err != nil {
+				return nil, err
+			}
+			extracted.pubspec = content
+			extracted.packageDir = strings.TrimSuffix(strings.TrimSuffix(entryName, fileName), "/")
+			foundPubspec = true
+
+		case docFileRank(lowerName, "readme") >= 0:
+			// Prefer README.md over other variants when several are present
+			if rank := docFileRank(lowerName, "readme"); extracted.readme == nil || rank < readmeRank {
+				content, err := readArchiveEntry(tarReader, fileName)
+				if err != nil {
+					return nil, err
+				}
+				extracted.readme = &content
+				readmeRank = rank
+			}
+
+		case docFileRank(lowerName, "changelog") >= 0:
+			if rank := docFileRank(lowerName, "changelog"); extracted.changelog == nil || rank < changelogRank {
+				content, err := readArchiveEntry(tarReader, fileName)
+				if err != nil {
+					return nil, err
+				}
+				extracted.changelog = &content
+				changelogRank = rank
+			}
+		}
+	}
+
+	if !foundPubspec {
+		return nil, fmt.Errorf("pubspec.yaml not found in archive")
+	}
+
+	return &extracted, nil
+}
+
+// packageRootPath returns an archive file name relative to the package root,
+// removing a leading package directory such as "package-1.0.0/". Other
+// directories, like example/, are kept.
+func packageRootPath(fileName string) string {
+	dir, rest, ok := strings.Cut(fileName, "/")
+	if !ok {
+		return fileName
+	}
+	name, version, ok := strings.Cut(dir, "-")
+	if !ok || name == "" || version == "" || version[0] < '0' || version[0] > '9' {
+		return fileName
+	}
+	for _, char := range name {
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') && (char < '0' || char > '9') && char != '_' {
+			return fileName
+		}
+	}
+	return rest
+}
+
+// readArchiveEntry reads the current tar entry, failing if it is larger than
+// maxExtractedFileSize rather than buffering it whole
+func readArchiveEntry(r io.Reader, fileName string) (string, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxExtractedFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	if len(content) > maxExtractedFileSize {
+		return "", fmt.Errorf("%s exceeds %d bytes", fileName, maxExtractedFileSize)
+	}
+	return string(content), nil
+}
+
+// isControlFile reports whether a file name relative to the package root is
+// the pubspec or a README or CHANGELOG variant
+func isControlFile(lowerName string) bool {
+	return lowerName == "pubspec.yaml" || docFileRank(lowerName, "readme") >= 0 || docFileRank(lowerName, "changelog") >= 0
+}
+
+// docFileExtensions lists accepted README/CHANGELOG extensions in order of preference
+var docFileExtensions = []string{".md", ".markdown", "", ".txt"}
+
+// docFileRank returns the preference rank of a root-level documentation file
+// such as README.md or CHANGELOG.txt, or -1 if the name doesn't match baseName
+func docFileRank(lowerName, baseName string) int {
+	for i, ext := range docFileExtensions {
+		if lowerName == baseName+ext {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,91 +3,120 @@ package service
 import (
 	"context"
 	"fmt"
+	"repub/internal/clock"
 	"repub/internal/config"
 	"strings"
+	"time"
 )
 
 type AuthService interface {
 	ValidateReadToken(ctx context.Context, token string) error
 	ValidateWriteToken(ctx context.Context, token string) error
+	ValidateAdminToken(ctx context.Context, token string) error
 	AuthenticateReadRequest(ctx context.Context, authHeader string) error
 	AuthenticateWriteRequest(ctx context.Context, authHeader string) error
+	AuthenticateAdminRequest(ctx context.Context, authHeader string) error
 }
 
 type authService struct {
-	readTokens  map[string]struct{}
-	writeTokens map[string]struct{}
+	readTokens  tokenSet
+	writeTokens tokenSet
+	adminTokens tokenSet
+	clock       clock.Clock
 }
 
-func NewAuthService(readTokens, writeTokens []config.Token) AuthService {
-	readMap := make(map[string]struct{})
-	for _, token := range readTokens {
-		readMap[token.Value] = struct{}{}
-	}
+// tokenSet maps token values to their expiry, nil for tokens that never expire
+type tokenSet map[string]*time.Time
 
-	writeMap := make(map[string]struct{})
-	for _, token := range writeTokens {
-		writeMap[token.Value] = struct{}{}
+func newTokenSet(tokens []config.Token) tokenSet {
+	set := make(tokenSet, len(tokens))
+	for _, token := range tokens {
+		set[token.Value] = token.ExpiresAt
 	}
+	return set
+}
 
+// NewAuthService creates an AuthService accepting the env tokens until they
+// expire on clk, the system clock when nil
+func NewAuthService(readTokens, writeTokens, adminTokens []config.Token, clk clock.Clock) AuthService {
+	if clk == nil {
+		clk = clock.Real()
+	}
 	return &authService{
-		readTokens:  readMap,
-		writeTokens: writeMap,
+		readTokens:  newTokenSet(readTokens),
+		writeTokens: newTokenSet(writeTokens),
+		adminTokens: newTokenSet(adminTokens),
+		clock:       clk,
 	}
 }
 
-func (s *authService) ValidateReadToken(ctx context.Context, token string) error {
+// validate accepts token if any of the sets contains it and it hasn't expired
+func (s *authService) validate(token string, sets ...tokenSet) error {
 	if token == "" {
 		return fmt.Errorf("token is required")
 	}
 
-	// Check both read and write tokens (write tokens can read too)
-	if _, exists := s.readTokens[token]; exists {
-		return nil
-	}
-	if _, exists := s.writeTokens[token]; exists {
+	for _, set := range sets {
+		expiresAt, exists := set[token]
+		if !exists {
+			continue
+		}
+		if expiresAt != nil && !s.clock.Now().Before(*expiresAt) {
+			return fmt.Errorf("token has expired")
+		}
 		return nil
 	}
 
 	return fmt.Errorf("invalid token")
 }
 
-func (s *authService) ValidateWriteToken(ctx context.Context, token string) error {
-	if token == "" {
-		return fmt.Errorf("token is required")
-	}
+func (s *authService) ValidateReadToken(ctx context.Context, token string) error {
+	// Check read, write and admin tokens (higher classes can read too)
+	return s.validate(token, s.readTokens, s.writeTokens, s.adminTokens)
+}
 
-	// Only write tokens can write
-	if _, exists := s.writeTokens[token]; exists {
-		return nil
-	}
+func (s *authService) ValidateWriteToken(ctx context.Context, token string) error {
+	// Only write and admin tokens can write
+	return s.validate(token, s.writeTokens, s.adminTokens)
+}
 
-	return fmt.Errorf("invalid token")
+func (s *authService) ValidateAdminToken(ctx context.Context, token string) error {
+	return s.validate(token, s.adminTokens)
 }
 
 func (s *authService) AuthenticateReadRequest(ctx context.Context, authHeader string) error {
-	if authHeader == "" {
-		return fmt.Errorf("authorization header is required")
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
 	}
+	return s.ValidateReadToken(ctx, token)
+}
 
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return fmt.Errorf("authorization header must start with 'Bearer '")
+func (s *authService) AuthenticateWriteRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
 	}
+	return s.ValidateWriteToken(ctx, token)
+}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	return s.ValidateReadToken(ctx, token)
+func (s *authService) AuthenticateAdminRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
+	}
+	return s.ValidateAdminToken(ctx, token)
 }
 
-func (s *authService) AuthenticateWriteRequest(ctx context.Context, authHeader string) error {
+// bearerToken extracts the token from a "Bearer <token>" Authorization header
+func bearerToken(authHeader string) (string, error) {
 	if authHeader == "" {
-		return fmt.Errorf("authorization header is required")
+		return "", fmt.Errorf("authorization header is required")
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return fmt.Errorf("authorization header must start with 'Bearer '")
+		return "", fmt.Errorf("authorization header must start with 'Bearer '")
 	}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	return s.ValidateWriteToken(ctx, token)
+	return strings.TrimPrefix(authHeader, "Bearer "), nil
 }
-
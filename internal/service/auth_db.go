@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/repository/pkg"
+	"strings"
+)
+
+// ErrInvalidTokenRequest is returned when a token can't be created as requested
+var ErrInvalidTokenRequest = errors.New("invalid token request")
+
+// TokenService manages API tokens stored in the database
+type TokenService interface {
+	// CreateToken stores a new token and returns its secret, which is not kept
+	CreateToken(ctx context.Context, req *domain.CreateTokenRequest) (*domain.CreateTokenResponse, error)
+	ListTokens(ctx context.Context) ([]*domain.Token, error)
+	// RevokeToken reports whether an active token with the given id was revoked
+	RevokeToken(ctx context.Context, tokenID int32) (bool, error)
+}
+
+// DBAuthService authenticates requests against hashed tokens in the database
+type DBAuthService interface {
+	AuthService
+	TokenService
+}
+
+type dbAuthService struct {
+	tokens   pkg.Repository
+	fallback AuthService
+	clock    clock.Clock
+}
+
+// NewDBAuthService creates an AuthService backed by the tokens table. Tokens
+// accepted by fallback (typically the env configured ones) stay valid, so an
+// ADMIN_TOKEN_* can be used to create the first database tokens.
+func NewDBAuthService(tokens pkg.Repository, fallback AuthService, clk clock.Clock) DBAuthService {
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &dbAuthService{tokens: tokens, fallback: fallback, clock: clk}
+}
+
+// hashToken returns the hex SHA-256 of a token secret, as stored in the tokens table
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *dbAuthService) validate(ctx context.Context, token string, required domain.TokenScope, fallback func(context.Context, string) error) error {
+	if token == "" {
+		return fmt.Errorf("token is required")
+	}
+
+	if fallback != nil && fallback(ctx, token) == nil {
+		return nil
+	}
+
+	stored, err := s.tokens.GetTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to look up token: %w", err)
+	}
+	if stored == nil {
+		return fmt.Errorf("invalid token")
+	}
+	if stored.RevokedAt != nil {
+		return fmt.Errorf("token has been revoked")
+	}
+	if stored.ExpiresAt != nil && !s.clock.Now().Before(*stored.ExpiresAt) {
+		return fmt.Errorf("token has expired")
+	}
+	if !stored.Scope.Allows(required) {
+		return fmt.Errorf("token does not have %s scope", required)
+	}
+	return nil
+}
+
+func (s *dbAuthService) ValidateReadToken(ctx context.Context, token string) error {
+	return s.validate(ctx, token, domain.TokenScopeRead, s.fallbackFunc(domain.TokenScopeRead))
+}
+
+func (s *dbAuthService) ValidateWriteToken(ctx context.Context, token string) error {
+	return s.validate(ctx, token, domain.TokenScopeWrite, s.fallbackFunc(domain.TokenScopeWrite))
+}
+
+func (s *dbAuthService) ValidateAdminToken(ctx context.Context, token string) error {
+	return s.validate(ctx, token, domain.TokenScopeAdmin, s.fallbackFunc(domain.TokenScopeAdmin))
+}
+
+// fallbackFunc returns the fallback validator for scope
+func (s *dbAuthService) fallbackFunc(scope domain.TokenScope) func(context.Context, string) error {
+	if s.fallback == nil {
+		return nil
+	}
+	switch scope {
+	case domain.TokenScopeAdmin:
+		return s.fallback.ValidateAdminToken
+	case domain.TokenScopeWrite:
+		return s.fallback.ValidateWriteToken
+	default:
+		return s.fallback.ValidateReadToken
+	}
+}
+
+func (s *dbAuthService) AuthenticateReadRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
+	}
+	return s.ValidateReadToken(ctx, token)
+}
+
+func (s *dbAuthService) AuthenticateWriteRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
+	}
+	return s.ValidateWriteToken(ctx, token)
+}
+
+func (s *dbAuthService) AuthenticateAdminRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
+	}
+	return s.ValidateAdminToken(ctx, token)
+}
+
+func (s *dbAuthService) CreateToken(ctx context.Context, req *domain.CreateTokenRequest) (*domain.CreateTokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTokenRequest)
+	}
+	if !req.Scope.Valid() {
+		return nil, fmt.Errorf("%w: scope must be read, write or admin", ErrInvalidTokenRequest)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(s.clock.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidTokenRequest)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	record, err := s.tokens.CreateToken(ctx, &domain.Token{
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scope:     req.Scope,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return &domain.CreateTokenResponse{Token: token, Record: record}, nil
+}
+
+func (s *dbAuthService) ListTokens(ctx context.Context) ([]*domain.Token, error) {
+	tokens, err := s.tokens.ListTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *dbAuthService) RevokeToken(ctx context.Context, tokenID int32) (bool, error) {
+	revoked, err := s.tokens.RevokeToken(ctx, tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"repub/internal/clock"
+	"repub/internal/config"
+	"repub/internal/domain"
+	"repub/internal/service"
+	"repub/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestDBAuthService(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	ctx := context.Background()
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	envAuth := service.NewAuthService(nil, nil, []config.Token{{Name: "BOOTSTRAP", Value: "env-admin-token"}}, nil)
+	authSvc := service.NewDBAuthService(repos.DB.Repo, envAuth, clk)
+
+	create := func(name string, scope domain.TokenScope, expiresAt *time.Time) *domain.CreateTokenResponse {
+		t.Helper()
+		created, err := authSvc.CreateToken(ctx, &domain.CreateTokenRequest{Name: name, Scope: scope, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatalf("Failed to create %s token: %v", name, err)
+		}
+		return created
+	}
+
+	expiry := now.Add(time.Hour)
+	reader := create("reader", domain.TokenScopeRead, nil)
+	writer := create("writer", domain.TokenScopeWrite, nil)
+	admin := create("admin", domain.TokenScopeAdmin, nil)
+	expiring := create("expiring", domain.TokenScopeWrite, &expiry)
+	revoked := create("revoked", domain.TokenScopeWrite, nil)
+
+	if ok, err := authSvc.RevokeToken(ctx, revoked.Record.ID); err != nil || !ok {
+		t.Fatalf("Expected token to be revoked, got %v, %v", ok, err)
+	}
+	if ok, err := authSvc.RevokeToken(ctx, revoked.Record.ID); err != nil || ok {
+		t.Errorf("Expected revoking twice to report false, got %v, %v", ok, err)
+	}
+
+	tests := []struct {
+		name        string
+		validate    func(context.Context, string) error
+		token       string
+		expectError bool
+	}{
+		{"read token can read", authSvc.ValidateReadToken, reader.Token, false},
+		{"read token can't write", authSvc.ValidateWriteToken, reader.Token, true},
+		{"write token can read", authSvc.ValidateReadToken, writer.Token, false},
+		{"write token can write", authSvc.ValidateWriteToken, writer.Token, false},
+		{"write token isn't admin", authSvc.ValidateAdminToken, writer.Token, true},
+		{"admin token can write", authSvc.ValidateWriteToken, admin.Token, false},
+		{"admin token is admin", authSvc.ValidateAdminToken, admin.Token, false},
+		{"unexpired token", authSvc.ValidateWriteToken, expiring.Token, false},
+		{"revoked token", authSvc.ValidateReadToken, revoked.Token, true},
+		{"env token still accepted", authSvc.ValidateAdminToken, "env-admin-token", false},
+		{"unknown token", authSvc.ValidateReadToken, "unknown-token", true},
+		{"empty token", authSvc.ValidateReadToken, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate(ctx, tt.token)
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("expired token", func(t *testing.T) {
+		clk.Advance(time.Hour)
+		defer clk.Set(now)
+
+		if err := authSvc.AuthenticateWriteRequest(ctx, "Bearer "+expiring.Token); err == nil {
+			t.Error("Expected expired token to be rejected")
+		}
+		if err := authSvc.AuthenticateWriteRequest(ctx, "Bearer "+writer.Token); err != nil {
+			t.Errorf("Expected unexpiring token to stay valid, got %v", err)
+		}
+	})
+
+	t.Run("secrets are not stored", func(t *testing.T) {
+		tokens, err := authSvc.ListTokens(ctx)
+		if err != nil {
+			t.Fatalf("ListTokens failed: %v", err)
+		}
+		if len(tokens) != 5 {
+			t.Fatalf("Expected 5 tokens, got %d", len(tokens))
+		}
+		for _, token := range tokens {
+			if token.TokenHash == "" || token.TokenHash == reader.Token || token.TokenHash == writer.Token {
+				t.Errorf("Expected only a hash of %s to be stored, got %q", token.Name, token.TokenHash)
+			}
+		}
+		if tokens[4].RevokedAt == nil {
+			t.Error("Expected revoked token to have revoked_at set")
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		past := now.Add(-time.Hour)
+		requests := []*domain.CreateTokenRequest{
+			{Name: "", Scope: domain.TokenScopeRead},
+			{Name: "bad-scope", Scope: "owner"},
+			{Name: "expired", Scope: domain.TokenScopeRead, ExpiresAt: &past},
+		}
+		for _, req := range requests {
+			if _, err := authSvc.CreateToken(ctx, req); !errors.Is(err, service.ErrInvalidTokenRequest) {
+				t.Errorf("Expected ErrInvalidTokenRequest for %+v, got %v", req, err)
+			}
+		}
+	})
+}
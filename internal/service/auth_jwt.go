@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/goccy/go-json"
+	"golang.org/x/sync/singleflight"
+)
+
+// IdentityProvider is implemented by AuthServices whose credentials name the
+// caller, so publishes can be attributed to a person instead of a token
+type IdentityProvider interface {
+	// AuthenticateIdentity authenticates a request like
+	// AuthenticateWriteRequest, or AuthenticateReadRequest when write is
+	// false, and returns its caller, "" if the credentials don't carry one
+	AuthenticateIdentity(ctx context.Context, authHeader string, write bool) (string, error)
+}
+
+// JWTAuthService authenticates requests with JWTs issued by an OIDC identity
+// provider and reports the identity claim of the token
+type JWTAuthService interface {
+	AuthService
+	IdentityProvider
+}
+
+// DefaultIdentityClaim is the JWT claim used as the uploader identity
+const DefaultIdentityClaim = "email"
+
+const (
+	// jwksRefreshInterval is how long fetched signing keys are used before refetching
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits refetches triggered by tokens signed with unknown keys
+	jwksMinRefreshInterval = time.Minute
+	// jwtLeeway tolerates clock skew between the identity provider and this server
+	jwtLeeway = time.Minute
+)
+
+// jwtSignatureAlgorithms are the signing algorithms accepted from the identity provider
+var jwtSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.RS384, jose.RS512, jose.ES256, jose.ES384, jose.ES512, jose.PS256}
+
+// JWTConfig configures a JWTAuthService
+type JWTConfig struct {
+	// JWKSURL serves the identity provider's signing keys as a JSON Web Key Set
+	JWKSURL string
+	// Issuer must match the iss claim of tokens
+	Issuer string
+	// Audience must be one of the aud claim values
+	Audience string
+	// IdentityClaim names the claim recorded as the uploader (default DefaultIdentityClaim)
+	IdentityClaim string
+	// Transport is used by the default Client, nil for http.DefaultTransport
+	Transport http.RoundTripper
+	Client    *http.Client
+	Clock     clock.Clock
+}
+
+type jwtAuthService struct {
+	cfg      JWTConfig
+	fallback AuthService
+
+	// fetches shares one JWKS request between concurrent callers, made
+	// without holding mu
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	keys        *jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetchErr    error
+}
+
+// NewJWTAuthService creates an AuthService accepting JWTs for reads and
+// publishes. Tokens accepted by fallback (typically the static ones) stay
+// valid, and admin access is only granted through fallback.
+func NewJWTAuthService(cfg JWTConfig, fallback AuthService) JWTAuthService {
+	if cfg.IdentityClaim == "" {
+		cfg.IdentityClaim = DefaultIdentityClaim
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second, Transport: cfg.Transport}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	return &jwtAuthService{cfg: cfg, fallback: fallback}
+}
+
+// validate accepts token if fallback does, or if it is a valid JWT whose
+// identity claim it returns
+func (s *jwtAuthService) validate(ctx context.Context, token string, fallback func(context.Context, string) error) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("token is required")
+	}
+
+	if fallback != nil && fallback(ctx, token) == nil {
+		return "", nil
+	}
+
+	return s.verify(ctx, token)
+}
+
+func (s *jwtAuthService) ValidateReadToken(ctx context.Context, token string) error {
+	_, err := s.validate(ctx, token, s.fallbackFunc(domain.TokenScopeRead))
+	return err
+}
+
+func (s *jwtAuthService) ValidateWriteToken(ctx context.Context, token string) error {
+	_, err := s.validate(ctx, token, s.fallbackFunc(domain.TokenScopeWrite))
+	return err
+}
+
+func (s *jwtAuthService) ValidateAdminToken(ctx context.Context, token string) error {
+	if s.fallback == nil {
+		return fmt.Errorf("admin access requires an admin token")
+	}
+	return s.fallback.ValidateAdminToken(ctx, token)
+}
+
+// fallbackFunc returns the fallback validator for scope
+func (s *jwtAuthService) fallbackFunc(scope domain.TokenScope) func(context.Context, string) error {
+	if s.fallback == nil {
+		return nil
+	}
+	if scope == domain.TokenScopeWrite {
+		return s.fallback.ValidateWriteToken
+	}
+	return s.fallback.ValidateReadToken
+}
+
+func (s *jwtAuthService) AuthenticateReadRequest(ctx context.Context, authHeader string) error {
+	_, err := s.AuthenticateIdentity(ctx, authHeader, false)
+	return err
+}
+
+func (s *jwtAuthService) AuthenticateWriteRequest(ctx context.Context, authHeader string) error {
+	_, err := s.AuthenticateIdentity(ctx, authHeader, true)
+	return err
+}
+
+func (s *jwtAuthService) AuthenticateAdminRequest(ctx context.Context, authHeader string) error {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return err
+	}
+	return s.ValidateAdminToken(ctx, token)
+}
+
+// AuthenticateIdentity returns the identity claim of a valid JWT, "" for
+// tokens accepted by the fallback
+func (s *jwtAuthService) AuthenticateIdentity(ctx context.Context, authHeader string, write bool) (string, error) {
+	token, err := bearerToken(authHeader)
+	if err != nil {
+		return "", err
+	}
+	scope := domain.TokenScopeRead
+	if write {
+		scope = domain.TokenScopeWrite
+	}
+	return s.validate(ctx, token, s.fallbackFunc(scope))
+}
+
+// verify checks the signature and claims of a JWT and returns its identity claim
+func (s *jwtAuthService) verify(ctx context.Context, token string) (string, error) {
+	parsed, err := jwt.ParseSigned(token, jwtSignatureAlgorithms)
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+
+	keys, err := s.keySet(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	var claims jwt.Claims
+	custom := map[string]any{}
+	err = parsed.Claims(keys, &claims, &custom)
+	if errors.Is(err, jose.ErrJWKSKidNotFound) {
+		// The identity provider may have rotated its keys
+		if keys, err = s.keySet(ctx, true); err != nil {
+			return "", err
+		}
+		err = parsed.Claims(keys, &claims, &custom)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	if claims.Expiry == nil {
+		return "", fmt.Errorf("token has no expiry")
+	}
+	expected := jwt.Expected{Issuer: s.cfg.Issuer, AnyAudience: jwt.Audience{s.cfg.Audience}, Time: s.cfg.Clock.Now()}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		if errors.Is(err, jwt.ErrExpired) {
+			return "", fmt.Errorf("token has expired")
+		}
+		return "", fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	identity, _ := custom[s.cfg.IdentityClaim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("token has no %s claim", s.cfg.IdentityClaim)
+	}
+	return identity, nil
+}
+
+// keySet returns the cached signing keys, fetching them when they are stale.
+// refresh asks for a refetch. Fetches, failed ones included, are rate limited
+// by jwksMinRefreshInterval.
+func (s *jwtAuthService) keySet(ctx context.Context, refresh bool) (*jose.JSONWebKeySet, error) {
+	s.mu.Lock()
+	now := s.cfg.Clock.Now()
+	keys, fetchErr := s.keys, s.fetchErr
+	stale := keys == nil || refresh || now.Sub(s.fetchedAt) >= jwksRefreshInterval
+	throttled := now.Sub(s.attemptedAt) < jwksMinRefreshInterval
+	s.mu.Unlock()
+
+	if !stale || throttled {
+		if keys == nil {
+			return nil, fetchErr
+		}
+		return keys, nil
+	}
+
+	// The fetch outlives a caller that gives up so the others sharing it
+	// still get the keys; the client timeout bounds it
+	fetched, err, _ := s.fetches.Do("jwks", func() (any, error) {
+		return s.refreshKeys(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		if keys != nil {
+			// Keep accepting tokens while the identity provider is unreachable
+			slog.Warn("Failed to refresh JWKS, using cached keys", "url", s.cfg.JWKSURL, "error", err)
+			return keys, nil
+		}
+		return nil, err
+	}
+	return fetched.(*jose.JSONWebKeySet), nil
+}
+
+// refreshKeys fetches the signing keys and records the attempt
+func (s *jwtAuthService) refreshKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	keys, err := s.fetchKeys(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attemptedAt, s.fetchErr = s.cfg.Clock.Now(), err
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.fetchedAt = keys, s.attemptedAt
+	return keys, nil
+}
+
+func (s *jwtAuthService) fetchKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	return &keys, nil
+}
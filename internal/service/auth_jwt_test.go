@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/clock"
+	"repub/internal/config"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const testIssuer = "https://idp.example.com"
+
+// testKeySet serves the public halves of its signing keys as a JWKS
+type testKeySet struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func (ks *testKeySet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.fetches++
+
+	var set jose.JSONWebKeySet
+	for kid, key := range ks.keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"})
+	}
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+func (ks *testKeySet) add(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = key
+	return key
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.Claims, extra map[string]any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Claims(extra).Serialize()
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTAuthService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	keySet := &testKeySet{keys: map[string]*rsa.PrivateKey{}}
+	key := keySet.add(t, "key-1")
+	jwks := httptest.NewServer(keySet)
+	defer jwks.Close()
+
+	staticAuth := NewAuthService(nil, []config.Token{{Name: "CI", Value: "static-write-token"}}, []config.Token{{Name: "ADMIN", Value: "static-admin-token"}}, nil)
+	authSvc := NewJWTAuthService(JWTConfig{
+		JWKSURL:  jwks.URL,
+		Issuer:   testIssuer,
+		Audience: "repub",
+		Clock:    clk,
+	}, staticAuth)
+
+	validClaims := jwt.Claims{
+		Issuer:   testIssuer,
+		Subject:  "user-1",
+		Audience: jwt.Audience{"repub"},
+		IssuedAt: jwt.NewNumericDate(now.Add(-time.Minute)),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	email := map[string]any{"email": "dev@example.com"}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	withClaims := func(modify func(*jwt.Claims)) jwt.Claims {
+		claims := validClaims
+		modify(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr string
+	}{
+		{"valid token", signJWT(t, key, "key-1", validClaims, email), ""},
+		{"static token", "static-write-token", ""},
+		{"expired token", signJWT(t, key, "key-1", withClaims(func(c *jwt.Claims) {
+			c.Expiry = jwt.NewNumericDate(now.Add(-time.Hour))
+		}), email), "token has expired"},
+		{"wrong issuer", signJWT(t, key, "key-1", withClaims(func(c *jwt.Claims) {
+			c.Issuer = "https://evil.example.com"
+		}), email), "invalid token claims"},
+		{"wrong audience", signJWT(t, key, "key-1", withClaims(func(c *jwt.Claims) {
+			c.Audience = jwt.Audience{"other"}
+		}), email), "invalid token claims"},
+		{"no audience", signJWT(t, key, "key-1", withClaims(func(c *jwt.Claims) {
+			c.Audience = nil
+		}), email), "invalid token claims"},
+		{"no expiry", signJWT(t, key, "key-1", withClaims(func(c *jwt.Claims) {
+			c.Expiry = nil
+		}), email), "token has no expiry"},
+		{"untrusted key", signJWT(t, otherKey, "key-1", validClaims, email), "invalid token signature"},
+		{"unknown key id", signJWT(t, otherKey, "unknown", validClaims, email), "invalid token signature"},
+		{"missing identity claim", signJWT(t, key, "key-1", validClaims, map[string]any{"name": "Dev"}), "token has no email claim"},
+		{"not a token", "garbage", "invalid token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, authenticate := range []func(context.Context, string) error{
+				authSvc.AuthenticateReadRequest,
+				authSvc.AuthenticateWriteRequest,
+			} {
+				err := authenticate(ctx, "Bearer "+tt.token)
+				if tt.expectedErr == "" {
+					if err != nil {
+						t.Errorf("Expected token to be accepted, got %v", err)
+					}
+				} else if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+			}
+		})
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		if identity, err := authSvc.AuthenticateIdentity(ctx, "Bearer "+signJWT(t, key, "key-1", validClaims, email), true); err != nil || identity != "dev@example.com" {
+			t.Errorf("Expected identity dev@example.com, got %q, %v", identity, err)
+		}
+		if identity, err := authSvc.AuthenticateIdentity(ctx, "Bearer static-write-token", true); err != nil || identity != "" {
+			t.Errorf("Expected no identity for a static token, got %q, %v", identity, err)
+		}
+		if _, err := authSvc.AuthenticateIdentity(ctx, "Bearer garbage", false); err == nil {
+			t.Error("Expected an invalid token to be rejected")
+		}
+	})
+
+	t.Run("admin requires a static admin token", func(t *testing.T) {
+		if err := authSvc.AuthenticateAdminRequest(ctx, "Bearer "+signJWT(t, key, "key-1", validClaims, email)); err == nil {
+			t.Error("Expected JWTs to be rejected for admin access")
+		}
+		if err := authSvc.AuthenticateAdminRequest(ctx, "Bearer static-admin-token"); err != nil {
+			t.Errorf("Expected static admin token to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		rotated := keySet.add(t, "key-2")
+		token := signJWT(t, rotated, "key-2", validClaims, email)
+
+		// Unknown key ids refetch the key set at most once a minute
+		fetches := keySet.fetches
+		clk.Advance(2 * time.Minute)
+		if err := authSvc.AuthenticateWriteRequest(ctx, "Bearer "+token); err != nil {
+			t.Fatalf("Expected token signed with a rotated key to be accepted, got %v", err)
+		}
+		if err := authSvc.AuthenticateWriteRequest(ctx, "Bearer "+signJWT(t, otherKey, "unknown", validClaims, email)); err == nil {
+			t.Error("Expected unknown key id to be rejected")
+		}
+		if keySet.fetches != fetches+1 {
+			t.Errorf("Expected 1 key set refetch, got %d", keySet.fetches-fetches)
+		}
+	})
+}
+
+func TestJWTAuthService_JWKSFetch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	keySet := &testKeySet{keys: map[string]*rsa.PrivateKey{}}
+	key := keySet.add(t, "key-1")
+
+	var (
+		mu      sync.Mutex
+		failing = true
+		release = make(chan struct{})
+	)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		fail := failing
+		mu.Unlock()
+		if fail {
+			keySet.mu.Lock()
+			keySet.fetches++
+			keySet.mu.Unlock()
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		keySet.ServeHTTP(w, r)
+	}))
+	defer jwks.Close()
+
+	authSvc := NewJWTAuthService(JWTConfig{
+		JWKSURL:  jwks.URL,
+		Issuer:   testIssuer,
+		Audience: "repub",
+		Clock:    clk,
+	}, nil)
+	token := "Bearer " + signJWT(t, key, "key-1", jwt.Claims{
+		Issuer:   testIssuer,
+		Audience: jwt.Audience{"repub"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}, map[string]any{"email": "dev@example.com"})
+
+	fetches := func() int {
+		keySet.mu.Lock()
+		defer keySet.mu.Unlock()
+		return keySet.fetches
+	}
+
+	// Concurrent requests wait on a single fetch
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- authSvc.AuthenticateReadRequest(ctx, token)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err == nil {
+			t.Error("Expected tokens to be rejected while the JWKS is unavailable")
+		}
+	}
+	if got := fetches(); got != 1 {
+		t.Fatalf("Expected 1 JWKS fetch for concurrent requests, got %d", got)
+	}
+
+	// A failed fetch isn't retried before jwksMinRefreshInterval
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if err := authSvc.AuthenticateReadRequest(ctx, token); err == nil {
+		t.Error("Expected the failed fetch to be remembered")
+	}
+	if got := fetches(); got != 1 {
+		t.Errorf("Expected no JWKS refetch right after a failure, got %d fetches", got)
+	}
+
+	clk.Advance(2 * time.Minute)
+	if identity, err := authSvc.AuthenticateIdentity(ctx, token, false); err != nil || identity != "dev@example.com" {
+		t.Errorf("Expected the token to be accepted after the retry, got %q, %v", identity, err)
+	}
+	if got := fetches(); got != 2 {
+		t.Errorf("Expected 1 JWKS refetch, got %d fetches", got)
+	}
+}
@@ -2,9 +2,11 @@ package service_test
 
 import (
 	"context"
+	"repub/internal/clock"
 	"repub/internal/config"
 	"repub/internal/service"
 	"testing"
+	"time"
 )
 
 func TestAuthService_ValidateReadToken(t *testing.T) {
@@ -14,7 +16,7 @@ func TestAuthService_ValidateReadToken(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token-456"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	tests := []struct {
 		name        string
@@ -63,7 +65,7 @@ func TestAuthService_ValidateWriteToken(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token-456"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	tests := []struct {
 		name        string
@@ -112,7 +114,7 @@ func TestAuthService_AuthenticateReadRequest(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token-456"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	tests := []struct {
 		name        string
@@ -171,7 +173,7 @@ func TestAuthService_AuthenticateWriteRequest(t *testing.T) {
 	writeTokens := []config.Token{
 		{Name: "WRITER", Value: "write-token-456"},
 	}
-	authSvc := service.NewAuthService(readTokens, writeTokens)
+	authSvc := service.NewAuthService(readTokens, writeTokens, nil, nil)
 
 	tests := []struct {
 		name        string
@@ -213,3 +215,98 @@ func TestAuthService_AuthenticateWriteRequest(t *testing.T) {
 	}
 }
 
+func TestAuthService_AuthenticateAdminRequest(t *testing.T) {
+	readTokens := []config.Token{
+		{Name: "READER", Value: "read-token-123"},
+	}
+	writeTokens := []config.Token{
+		{Name: "WRITER", Value: "write-token-456"},
+	}
+	adminTokens := []config.Token{
+		{Name: "ADMIN", Value: "admin-token-789"},
+	}
+	authSvc := service.NewAuthService(readTokens, writeTokens, adminTokens, nil)
+
+	tests := []struct {
+		name        string
+		authHeader  string
+		expectError bool
+	}{
+		{
+			name:        "valid admin token",
+			authHeader:  "Bearer admin-token-789",
+			expectError: false,
+		},
+		{
+			name:        "write token is not admin",
+			authHeader:  "Bearer write-token-456",
+			expectError: true,
+		},
+		{
+			name:        "read token is not admin",
+			authHeader:  "Bearer read-token-123",
+			expectError: true,
+		},
+		{
+			name:        "empty auth header",
+			authHeader:  "",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authSvc.AuthenticateAdminRequest(context.Background(), tt.authHeader)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+
+	// Admin tokens can also read and write
+	if err := authSvc.AuthenticateWriteRequest(context.Background(), "Bearer admin-token-789"); err != nil {
+		t.Errorf("Expected admin token to write, got %v", err)
+	}
+	if err := authSvc.AuthenticateReadRequest(context.Background(), "Bearer admin-token-789"); err != nil {
+		t.Errorf("Expected admin token to read, got %v", err)
+	}
+}
+
+func TestAuthService_ExpiredTokens(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	writeTokens := []config.Token{
+		{Name: "EXPIRED", Value: "expired-token", ExpiresAt: &past},
+		{Name: "CURRENT", Value: "current-token", ExpiresAt: &future},
+		{Name: "FOREVER", Value: "forever-token"},
+	}
+	// Expiry is checked against the injected clock
+	authSvc := service.NewAuthService(nil, writeTokens, nil, clock.NewFake(now))
+
+	tests := []struct {
+		name        string
+		authHeader  string
+		expectError bool
+	}{
+		{"expired token", "Bearer expired-token", true},
+		{"unexpired token", "Bearer current-token", false},
+		{"token without expiry", "Bearer forever-token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, authenticate := range []func(context.Context, string) error{authSvc.AuthenticateReadRequest, authSvc.AuthenticateWriteRequest} {
+				err := authenticate(context.Background(), tt.authHeader)
+				if tt.expectError && err == nil {
+					t.Error("Expected error, got nil")
+				} else if !tt.expectError && err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+			}
+		})
+	}
+}
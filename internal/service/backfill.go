@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"repub/internal/domain"
+	"repub/internal/markdown"
+)
+
+// Data derived from archives is computed at publish time. Versions published
+// before that are filled in by `repub backfill` rather than on read paths, so
+// serving a page never writes to the database.
+
+func (s *packageService) BackfillDocsHTML(ctx context.Context) (int, error) {
+	rendered := 0
+	err := s.forEachVersion(ctx, func(p *domain.Package, v *domain.PackageVersion) error {
+		if !hasDocs(v) || (v.ReadmeHTML != nil && v.ChangelogHTML != nil) {
+			return nil
+		}
+		readmeHTML, changelogHTML := markdown.Render(stringValue(v.Readme)), markdown.Render(stringValue(v.Changelog))
+		if err := s.Package.SetVersionDocsHTML(ctx, v.ID, readmeHTML, changelogHTML); err != nil {
+			return fmt.Errorf("failed to store the docs of %s %s: %w", p.Name, v.Version, err)
+		}
+		rendered++
+		return nil
+	})
+	return rendered, err
+}
+
+func (s *packageService) BackfillSizes(ctx context.Context) (int, error) {
+	recorded := 0
+	err := s.forEachVersion(ctx, func(p *domain.Package, v *domain.PackageVersion) error {
+		if v.SizeBytes != nil {
+			return nil
+		}
+
+		size, err := s.Storage.Size(ctx, v.ArchivePath)
+		if err != nil {
+			slog.Warn("Failed to get archive size", "package", p.Name, "version", v.Version, "path", v.ArchivePath, "error", err)
+			return nil
+		}
+
+		if err := s.Package.SetVersionSize(ctx, v.ID, size); err != nil {
+			return fmt.Errorf("failed to record the archive size of %s %s: %w", p.Name, v.Version, err)
+		}
+		recorded++
+		return nil
+	})
+	return recorded, err
+}
+
+// forEachVersion calls fn with every version of every package, fetching a
+// page of packages at a time, and stops at the first error
+func (s *packageService) forEachVersion(ctx context.Context, fn func(*domain.Package, *domain.PackageVersion) error) error {
+	for offset := int32(0); ; offset += cleanupPageSize {
+		packages, err := s.Package.ListPackages(ctx, cleanupPageSize, offset)
+		if err != nil {
+			return fmt.Errorf("failed to list packages: %w", err)
+		}
+
+		for _, p := range packages {
+			versions, err := s.Package.GetPackageVersions(ctx, p.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get package versions: %w", err)
+			}
+			for _, v := range versions {
+				if err := fn(p, v); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(packages) < cleanupPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// cleanupPageSize is the number of packages fetched per page while collecting archive paths
+const cleanupPageSize = 100
+
+// CleanupOrphanedArchives deletes stored objects that no version references
+// and that are older than the grace period, returning the deleted paths.
+// The grace period protects archives of publishes that are still in flight.
+func (s *packageService) CleanupOrphanedArchives(ctx context.Context, gracePeriod time.Duration) ([]string, error) {
+	referenced, err := s.referencedArchives(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := s.Storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+
+	cutoff := s.Clock.Now().Add(-gracePeriod)
+	var deleted []string
+	for _, path := range objects {
+		if _, ok := referenced[archiveKey(path)]; ok {
+			continue
+		}
+
+		modTime, err := s.Storage.ModTime(ctx, path)
+		if err != nil {
+			slog.Warn("Failed to get storage object age", "path", path, "error", err)
+			continue
+		}
+		if modTime.After(cutoff) {
+			continue
+		}
+
+		if err := s.Storage.Delete(ctx, path); err != nil {
+			slog.Warn("Failed to delete orphaned storage object", "path", path, "error", err)
+			continue
+		}
+		deleted = append(deleted, path)
+	}
+
+	return deleted, nil
+}
+
+// referencedArchives collects the keys of all archives that have a version row
+func (s *packageService) referencedArchives(ctx context.Context) (map[string]struct{}, error) {
+	referenced := make(map[string]struct{})
+	for offset := int32(0); ; offset += cleanupPageSize {
+		packages, err := s.Package.ListPackages(ctx, cleanupPageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages: %w", err)
+		}
+
+		for _, p := range packages {
+			versions, err := s.Package.GetPackageVersions(ctx, p.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get package versions: %w", err)
+			}
+			for _, v := range versions {
+				referenced[archiveKey(v.ArchivePath)] = struct{}{}
+			}
+		}
+
+		if len(packages) < cleanupPageSize {
+			return referenced, nil
+		}
+	}
+}
+
+// archiveKey reduces a storage path to its package/version/file suffix so that
+// paths recorded with a different root (e.g. legacy GCS paths) still match
+func archiveKey(path string) string {
+	parts := strings.Split(strings.ReplaceAll(path, "\\", "/"), "/")
+	if len(parts) > 3 {
+		parts = parts[len(parts)-3:]
+	}
+	return strings.Join(parts, "/")
+}
+
+// RunStorageCleanup periodically deletes orphaned storage objects until ctx is cancelled
+func RunStorageCleanup(ctx context.Context, pubSvc PubService, interval, gracePeriod time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := pubSvc.CleanupOrphanedArchives(ctx, gracePeriod)
+			if err != nil {
+				slog.Error("Storage cleanup failed", "error", err)
+				continue
+			}
+			for _, path := range deleted {
+				slog.Info("Reclaimed orphaned storage object", "path", path)
+			}
+			if len(deleted) > 0 {
+				slog.Info("Storage cleanup finished", "reclaimed", len(deleted))
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"os"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestPubService_CleanupOrphanedArchives(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+	published, err := repos.StorageSvc.List(ctx, "test_package/")
+	if err != nil || len(published) != 1 {
+		t.Fatalf("Expected one published archive, got %v, %v", published, err)
+	}
+
+	orphan, err := repos.StorageSvc.Store(ctx, "orphan", "1.0.0", []byte("orphaned archive"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	recent, err := repos.StorageSvc.Store(ctx, "recent", "1.0.0", []byte("in-flight archive"))
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// Age everything except the in-flight archive past the grace period
+	for _, path := range []string{published[0], orphan} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to age %s: %v", path, err)
+		}
+	}
+
+	deleted, err := svc.CleanupOrphanedArchives(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupOrphanedArchives failed: %v", err)
+	}
+
+	if len(deleted) != 1 || deleted[0] != orphan {
+		t.Errorf("Expected only %s to be deleted, got %v", orphan, deleted)
+	}
+	if repos.StorageSvc.Exists(ctx, orphan) {
+		t.Error("Orphaned archive should be deleted")
+	}
+	if !repos.StorageSvc.Exists(ctx, published[0]) {
+		t.Error("Published archive should be kept")
+	}
+	if !repos.StorageSvc.Exists(ctx, recent) {
+		t.Error("Archive within the grace period should be kept")
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/tmp/storage/foo/1.0.0/foo-1.0.0.tar.gz", "foo/1.0.0/foo-1.0.0.tar.gz"},
+		{"/app/storage/foo/1.0.0/foo-1.0.0.tar.gz", "foo/1.0.0/foo-1.0.0.tar.gz"},
+		{"foo/1.0.0/foo-1.0.0.tar.gz", "foo/1.0.0/foo-1.0.0.tar.gz"},
+	}
+
+	for _, test := range tests {
+		if result := archiveKey(test.path); result != test.expected {
+			t.Errorf("archiveKey(%q) = %q, expected %q", test.path, result, test.expected)
+		}
+	}
+}
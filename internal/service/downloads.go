@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"repub/internal/domain"
+	"repub/internal/repository/pkg"
+	"sync"
+	"time"
+)
+
+// DownloadCounter aggregates downloads in memory and writes them to the
+// repository in batches, so downloads of hot packages don't contend on the
+// same rows for every request
+type DownloadCounter struct {
+	repo pkg.Repository
+
+	mu      sync.Mutex
+	pending map[domain.DownloadKey]int64
+}
+
+// NewDownloadCounter returns a counter flushing to repo; see Run and Flush
+func NewDownloadCounter(repo pkg.Repository) *DownloadCounter {
+	return &DownloadCounter{
+		repo:    repo,
+		pending: make(map[domain.DownloadKey]int64),
+	}
+}
+
+// Add counts one download of a version on a day
+func (c *DownloadCounter) Add(key domain.DownloadKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[key]++
+}
+
+// Flush writes the pending counts. The repository applies them all or none,
+// so after a failure they are kept for the next flush rather than lost.
+func (c *DownloadCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	counts := c.pending
+	c.pending = make(map[domain.DownloadKey]int64)
+	c.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	if err := c.repo.AddDownloadCounts(ctx, counts); err != nil {
+		c.mu.Lock()
+		for key, count := range counts {
+			c.pending[key] += count
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("failed to flush download counts: %w", err)
+	}
+	return nil
+}
+
+// Run flushes the pending counts every interval until ctx is cancelled. The
+// caller flushes once more after the last download has been counted.
+func (c *DownloadCounter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				slog.Error("Download count flush failed", "error", err)
+			}
+		}
+	}
+}
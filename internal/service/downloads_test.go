@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/repository/pkg"
+	"repub/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadCounter_ConcurrentDownloads(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	counter := NewDownloadCounter(repos.DB.Repo)
+	svc := NewPubService(PackageDependencies{
+		Package:   repos.DB.Repo,
+		Storage:   repos.StorageSvc,
+		Pubspec:   repos.PubspecSvc,
+		BaseURL:   "http://localhost:8080",
+		Clock:     clock.NewFake(time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)),
+		Downloads: counter,
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: hot_package\nversion: 1.0.0",
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	const downloads = 50
+	var wg sync.WaitGroup
+	for range downloads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.DownloadPackage(ctx, "hot_package", "1.0.0"); err != nil {
+				t.Errorf("DownloadPackage failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Nothing is written until the counter is flushed
+	if score := mustScore(t, svc, "hot_package"); score.DownloadCount != 0 {
+		t.Errorf("Expected no downloads before flushing, got %d", score.DownloadCount)
+	}
+
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if score := mustScore(t, svc, "hot_package"); score.DownloadCount != downloads {
+		t.Errorf("Expected download count %d, got %d", downloads, score.DownloadCount)
+	}
+	metrics, err := svc.GetDownloadMetrics(ctx, "hot_package", 1)
+	if err != nil {
+		t.Fatalf("GetDownloadMetrics failed: %v", err)
+	}
+	if metrics.Series[0].Versions["1.0.0"] != downloads {
+		t.Errorf("Expected %d downloads of 1.0.0, got %d", downloads, metrics.Series[0].Versions["1.0.0"])
+	}
+
+	// A second flush has nothing left to add
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if score := mustScore(t, svc, "hot_package"); score.DownloadCount != downloads {
+		t.Errorf("Expected download count to stay %d, got %d", downloads, score.DownloadCount)
+	}
+}
+
+// flakyDownloadRepo fails the first AddDownloadCounts call
+type flakyDownloadRepo struct {
+	pkg.Repository
+	failed bool
+}
+
+func (r *flakyDownloadRepo) AddDownloadCounts(ctx context.Context, counts map[domain.DownloadKey]int64) error {
+	if !r.failed {
+		r.failed = true
+		return errors.New("database unavailable")
+	}
+	return r.Repository.AddDownloadCounts(ctx, counts)
+}
+
+func TestDownloadCounter_KeepsCountsOnFailedFlush(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	ctx := context.Background()
+	created, err := repos.DB.Repo.CreatePackage(ctx, "flaky_package", false)
+	if err != nil {
+		t.Fatalf("CreatePackage failed: %v", err)
+	}
+
+	counter := NewDownloadCounter(&flakyDownloadRepo{Repository: repos.DB.Repo})
+	key := domain.DownloadKey{PackageID: created.ID, Version: "1.0.0", Day: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)}
+	counter.Add(key)
+	counter.Add(key)
+
+	if err := counter.Flush(ctx); err == nil {
+		t.Fatal("Expected the first flush to fail")
+	}
+	counter.Add(key)
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	got, err := repos.DB.Repo.GetPackage(ctx, "flaky_package")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if got.DownloadCount != 3 {
+		t.Errorf("Expected download count 3, got %d", got.DownloadCount)
+	}
+}
+
+func TestDownloadCounter_FailedFlushKeepsEveryBucket(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	ctx := context.Background()
+	created, err := repos.DB.Repo.CreatePackage(ctx, "partial_package", false)
+	if err != nil {
+		t.Fatalf("CreatePackage failed: %v", err)
+	}
+
+	counter := NewDownloadCounter(&flakyDownloadRepo{Repository: repos.DB.Repo})
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	expected := map[string]int64{"1.0.0": 2, "2.0.0": 3}
+	for version, count := range expected {
+		for range count {
+			counter.Add(domain.DownloadKey{PackageID: created.ID, Version: version, Day: day})
+		}
+	}
+
+	if err := counter.Flush(ctx); err == nil {
+		t.Fatal("Expected the first flush to fail")
+	}
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	got, err := repos.DB.Repo.GetPackage(ctx, "partial_package")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if got.DownloadCount != 5 {
+		t.Errorf("Expected download count 5, got %d", got.DownloadCount)
+	}
+	history, err := repos.DB.Repo.GetDownloadHistory(ctx, created.ID, day)
+	if err != nil {
+		t.Fatalf("GetDownloadHistory failed: %v", err)
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d daily buckets, got %d", len(expected), len(history))
+	}
+	for _, bucket := range history {
+		if bucket.Count != expected[bucket.Version] {
+			t.Errorf("Expected %d downloads of %s, got %d", expected[bucket.Version], bucket.Version, bucket.Count)
+		}
+	}
+}
+
+func mustScore(t *testing.T, svc PubService, name string) *domain.ScoreResponse {
+	t.Helper()
+	score, err := svc.GetScore(context.Background(), name)
+	if err != nil {
+		t.Fatalf("GetScore failed: %v", err)
+	}
+	return score
+}
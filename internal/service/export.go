@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"repub/internal/domain"
+	"slices"
+	"time"
+)
+
+// exportPageSize is the number of packages held in memory at once while exporting
+const exportPageSize = 100
+
+// ExportPackages calls fn with the metadata of every package changed after
+// since (every package when since is zero), including private ones, oldest
+// change first. Packages are fetched a page at a time, each page starting
+// after the last package of the previous one, so packages changed while
+// exporting are exported again rather than skipped.
+func (s *packageService) ExportPackages(ctx context.Context, since time.Time, fn func(*domain.ExportedPackage) error) error {
+	var after *domain.Package
+	for {
+		packages, err := s.Package.ListPackagesPage(ctx, since, after, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to list packages: %w", err)
+		}
+
+		for _, p := range packages {
+			exported, err := s.exportPackage(ctx, p)
+			if err != nil {
+				return err
+			}
+			if err := fn(exported); err != nil {
+				return err
+			}
+		}
+
+		if len(packages) < exportPageSize {
+			return nil
+		}
+		after = packages[len(packages)-1]
+	}
+}
+
+func (s *packageService) exportPackage(ctx context.Context, p *domain.Package) (*domain.ExportedPackage, error) {
+	uploaders, err := s.Package.GetUploaders(ctx, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploaders of %s: %w", p.Name, err)
+	}
+	versions, err := s.Package.GetPackageVersions(ctx, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get versions of %s: %w", p.Name, err)
+	}
+
+	exported := &domain.ExportedPackage{
+		Name:          p.Name,
+		Private:       p.Private,
+		CreatedAt:     p.CreatedAt.UTC(),
+		LikeCount:     p.LikeCount,
+		DownloadCount: p.DownloadCount,
+		Uploaders:     uploaders,
+		Versions:      make([]domain.ExportedVersion, 0, len(versions)),
+	}
+	if exported.Uploaders == nil {
+		exported.Uploaders = []string{}
+	}
+
+	// Export in version order so exports of the same data are identical
+	slices.SortFunc(versions, func(a, b *domain.PackageVersion) int {
+		return domain.CompareVersions(a.Version, b.Version)
+	})
+	for _, v := range versions {
+		exported.Versions = append(exported.Versions, domain.ExportedVersion{
+			Version:       v.Version,
+			Description:   v.Description,
+			PubspecYaml:   v.PubspecYaml,
+			Readme:        v.Readme,
+			Changelog:     v.Changelog,
+			ArchivePath:   v.ArchivePath,
+			ArchiveSha256: v.ArchiveSha256,
+			Uploader:      v.Uploader,
+			Retracted:     v.Retracted,
+			CreatedAt:     v.CreatedAt.UTC(),
+			Platforms:     v.Platforms,
+			SizeBytes:     v.SizeBytes,
+			Funding:       v.Funding,
+			Screenshots:   v.Screenshots,
+			Proxied:       v.Proxied,
+		})
+	}
+	return exported, nil
+}
+
+// ImportPackage restores exported package metadata, creating the package if
+// needed and skipping versions that already exist. Archives are not copied,
+// the referenced paths must already be present in storage. Returns the number
+// of versions created.
+func (s *packageService) ImportPackage(ctx context.Context, exported *domain.ExportedPackage) (int, error) {
+	if exported.Name == "" {
+		return 0, fmt.Errorf("package name is required")
+	}
+
+	pkg, err := s.Package.GetOrCreatePackage(ctx, exported.Name, exported.Private)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get or create package: %w", err)
+	}
+	if pkg.Private != exported.Private {
+		if err := s.Package.SetPackagePrivate(ctx, pkg.ID, exported.Private); err != nil {
+			return 0, fmt.Errorf("failed to set package privacy: %w", err)
+		}
+	}
+
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	for _, uploader := range exported.Uploaders {
+		if slices.Contains(uploaders, uploader) {
+			continue
+		}
+		if err := s.Package.AddUploader(ctx, pkg.ID, uploader); err != nil {
+			return 0, fmt.Errorf("failed to add uploader: %w", err)
+		}
+	}
+
+	// Importing into a registry that already has the package keeps its
+	// earlier creation time and its higher counts
+	createdAt := exported.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = pkg.CreatedAt
+	}
+	if err := s.Package.RestorePackageHistory(ctx, pkg.ID, createdAt, exported.LikeCount, exported.DownloadCount); err != nil {
+		return 0, fmt.Errorf("failed to restore package history: %w", err)
+	}
+
+	existing, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	created := 0
+	for _, v := range exported.Versions {
+		if v.Version == "" {
+			return created, fmt.Errorf("version is required for package %s", exported.Name)
+		}
+		if slices.ContainsFunc(existing, func(e *domain.PackageVersion) bool { return e.Version == v.Version }) {
+			continue
+		}
+
+		version, err := s.Package.CreateVersion(ctx, &domain.PackageVersion{
+			PackageID:     pkg.ID,
+			Version:       v.Version,
+			Description:   v.Description,
+			PubspecYaml:   v.PubspecYaml,
+			Readme:        v.Readme,
+			Changelog:     v.Changelog,
+			ArchivePath:   v.ArchivePath,
+			ArchiveSha256: v.ArchiveSha256,
+			Uploader:      v.Uploader,
+			Platforms:     v.Platforms,
+			SizeBytes:     v.SizeBytes,
+			Funding:       v.Funding,
+			Screenshots:   v.Screenshots,
+			Proxied:       v.Proxied,
+		})
+		if err != nil {
+			return created, fmt.Errorf("failed to create version %s: %w", v.Version, err)
+		}
+		if v.Retracted {
+			if err := s.Package.SetVersionRetracted(ctx, version.ID, true); err != nil {
+				return created, fmt.Errorf("failed to retract version %s: %w", v.Version, err)
+			}
+		}
+		// Older exports don't record publish times
+		if !v.CreatedAt.IsZero() {
+			if err := s.Package.SetVersionCreatedAt(ctx, version.ID, v.CreatedAt); err != nil {
+				return created, fmt.Errorf("failed to restore the publish time of version %s: %w", v.Version, err)
+			}
+		}
+		s.prerenderDocs(ctx, version)
+		created++
+	}
+	if created > 0 {
+		s.touchPackage(ctx, pkg)
+	}
+	return created, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestPubService_ExportPackages(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	// More packages than fit in a page, several changed in the same second
+	ctx := context.Background()
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	count := exportPageSize + exportPageSize/2
+	for i := range count {
+		pkg, err := repos.DB.Repo.CreatePackage(ctx, fmt.Sprintf("pkg_%03d", i), false)
+		if err != nil {
+			t.Fatalf("CreatePackage failed: %v", err)
+		}
+		if err := repos.DB.Repo.TouchPackage(ctx, pkg.ID, start.Add(time.Duration(i/10)*time.Minute)); err != nil {
+			t.Fatalf("TouchPackage failed: %v", err)
+		}
+	}
+
+	export := func(since time.Time) []string {
+		t.Helper()
+		var names []string
+		err := svc.ExportPackages(ctx, since, func(pkg *domain.ExportedPackage) error {
+			names = append(names, pkg.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ExportPackages failed: %v", err)
+		}
+		return names
+	}
+
+	names := export(time.Time{})
+	if len(names) != count {
+		t.Fatalf("Expected %d packages, got %d", count, len(names))
+	}
+	for i, name := range names {
+		if want := fmt.Sprintf("pkg_%03d", i); name != want {
+			t.Fatalf("Expected %s at position %d, oldest change first, got %s", want, i, name)
+		}
+	}
+
+	// Only packages changed after since, the ones changed at since excluded
+	names = export(start.Add(10 * time.Minute))
+	if len(names) != count-110 || names[0] != "pkg_110" {
+		t.Errorf("Expected the %d packages from pkg_110 on, got %d starting with %v", count-110, len(names), names[:min(1, len(names))])
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/repository/storage"
+	"time"
+)
+
+// CollectRetractedVersions deletes the rows and archives of retracted versions
+// published before the cutoff. A package whose versions are all retracted keeps
+// them, so garbage collection never leaves a package without versions.
+func (s *packageService) CollectRetractedVersions(ctx context.Context, olderThan time.Duration, dryRun bool) ([]*domain.CollectedVersion, error) {
+	cutoff := s.Clock.Now().Add(-olderThan)
+
+	var collected []*domain.CollectedVersion
+	for offset := int32(0); ; offset += cleanupPageSize {
+		packages, err := s.Package.ListPackages(ctx, cleanupPageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages: %w", err)
+		}
+
+		for _, p := range packages {
+			versions, err := s.Package.GetPackageVersions(ctx, p.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get package versions: %w", err)
+			}
+
+			hasActiveVersion := false
+			for _, v := range versions {
+				if !v.Retracted {
+					hasActiveVersion = true
+					break
+				}
+			}
+
+			for _, v := range versions {
+				if !v.Retracted || !v.CreatedAt.Before(cutoff) {
+					continue
+				}
+
+				candidate := &domain.CollectedVersion{
+					Package:     p.Name,
+					Version:     v.Version,
+					PublishedAt: v.CreatedAt,
+				}
+				if v.SizeBytes != nil {
+					candidate.SizeBytes = *v.SizeBytes
+				}
+				collected = append(collected, candidate)
+
+				switch {
+				case !hasActiveVersion:
+					candidate.Skipped = "package has no unretracted version"
+				case !dryRun:
+					if err := s.deleteVersion(ctx, v); err != nil {
+						return collected[:len(collected)-1], err
+					}
+				}
+			}
+		}
+
+		if len(packages) < cleanupPageSize {
+			return collected, nil
+		}
+	}
+}
+
+// deleteVersion removes a version row and then its archive. An archive left
+// behind by a failed delete is reclaimed by the orphaned archive cleanup.
+func (s *packageService) deleteVersion(ctx context.Context, v *domain.PackageVersion) error {
+	if err := s.Package.DeleteVersion(ctx, v.ID); err != nil {
+		return fmt.Errorf("failed to delete version %s: %w", v.Version, err)
+	}
+
+	if err := s.Storage.Delete(ctx, v.ArchivePath); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to delete archive of version %s: %w", v.Version, err)
+	}
+	return nil
+}
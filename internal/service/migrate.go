@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/repository/storage"
+	"strings"
+)
+
+// MigrateStorage copies the archive of every version from the configured
+// storage backend to target. A version is switched to its copy with a single
+// row update once the copy is stored, so an interrupted migration leaves each
+// version pointing at a complete archive and can simply be run again. The
+// archives in the old backend are left for the operator to remove.
+func (s *packageService) MigrateStorage(ctx context.Context, target storage.Repository, dryRun bool) ([]*domain.MigratedVersion, error) {
+	var migrated []*domain.MigratedVersion
+	for offset := int32(0); ; offset += cleanupPageSize {
+		packages, err := s.Package.ListPackages(ctx, cleanupPageSize, offset)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to list packages: %w", err)
+		}
+
+		for _, p := range packages {
+			versions, err := s.Package.GetPackageVersions(ctx, p.ID)
+			if err != nil {
+				return migrated, fmt.Errorf("failed to get package versions: %w", err)
+			}
+
+			for _, v := range versions {
+				m := &domain.MigratedVersion{Package: p.Name, Version: v.Version, From: v.ArchivePath}
+				migrated = append(migrated, m)
+				if err := s.migrateVersion(ctx, target, p, v, m, dryRun); err != nil {
+					return migrated, err
+				}
+			}
+		}
+
+		if len(packages) < cleanupPageSize {
+			return migrated, nil
+		}
+	}
+}
+
+// migrateVersion copies the archive of v to target and records the outcome in m
+func (s *packageService) migrateVersion(ctx context.Context, target storage.Repository, p *domain.Package, v *domain.PackageVersion, m *domain.MigratedVersion, dryRun bool) error {
+	data, err := s.Storage.Get(ctx, v.ArchivePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Versions migrated by an earlier run already point into target
+		if target.Exists(ctx, v.ArchivePath) {
+			m.AlreadyMigrated = true
+		} else {
+			m.Skipped = "archive not found"
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive of %s %s: %w", p.Name, v.Version, err)
+	}
+	m.SizeBytes = int64(len(data))
+
+	if v.ArchiveSha256 != nil && !strings.EqualFold(s.calculateSHA256(data), *v.ArchiveSha256) {
+		m.Skipped = "archive doesn't match its checksum"
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+
+	path, err := target.Store(ctx, p.Name, v.Version, data)
+	if err != nil {
+		return fmt.Errorf("failed to store archive of %s %s: %w", p.Name, v.Version, err)
+	}
+	if path != v.ArchivePath {
+		if err := s.Package.SetVersionArchivePath(ctx, v.ID, path); err != nil {
+			_ = target.Delete(context.WithoutCancel(ctx), path)
+			return fmt.Errorf("failed to update archive path of %s %s: %w", p.Name, v.Version, err)
+		}
+	}
+	m.To = path
+	return nil
+}
@@ -1,32 +1,169 @@
 package service
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"path"
+	"repub/internal/auth"
+	"repub/internal/clock"
 	"repub/internal/domain"
+	"repub/internal/markdown"
 	"repub/internal/repository/pkg"
 	"repub/internal/repository/pubspec"
 	"repub/internal/repository/storage"
+	"repub/internal/telemetry"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrQuotaExceeded is returned when a publish would exceed a per-package quota
+var ErrQuotaExceeded = errors.New("package quota exceeded")
+
+// ErrVersionExists is returned when publishing a version that is already
+// published. It is the repository's error, so a version recorded by a
+// concurrent publish is reported the same way.
+var ErrVersionExists = pkg.ErrVersionExists
+
+// ErrReportExists is returned when a reporter reports a version again
+var ErrReportExists = pkg.ErrReportExists
+
+// ErrUnauthorized is returned when the uploader may not publish to a package
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrPackageReserved is returned when publishing a package with a reserved name
+var ErrPackageReserved = errors.New("package name is reserved")
+
+// ErrPackageBlocked is returned when creating a package whose name matches
+// one of the BlockedPackageNames patterns
+var ErrPackageBlocked = errors.New("package name is blocked")
+
+// ErrPubspecInvalid is returned when an archive's pubspec.yaml is missing or malformed
+var ErrPubspecInvalid = errors.New("invalid pubspec")
+
+// ErrPackageMismatch is returned when an archive is not for the package its upload declared
+var ErrPackageMismatch = errors.New("package name mismatch")
+
+// ErrArchiveInvalid is returned when an archive's files exceed the configured
+// ArchiveLimits or its layout contradicts its pubspec
+var ErrArchiveInvalid = errors.New("invalid archive")
+
+// ErrSDKNotAllowed is returned when publishing a package built on an SDK the registry doesn't accept
+var ErrSDKNotAllowed = errors.New("package SDK not allowed")
+
+// ErrPublishToMismatch is returned when EnforcePublishTo is set and a pubspec's
+// publish_to doesn't name this registry
+var ErrPublishToMismatch = errors.New("publish_to doesn't match this registry")
+
+// ErrNotFound is returned by operations that require an existing package or version
+var ErrNotFound = errors.New("not found")
+
+// ErrStorageUnavailable is returned when the storage backend fails, as opposed
+// to an archive that doesn't exist; the failure is likely transient
+var ErrStorageUnavailable = errors.New("storage unavailable")
+
+// ErrUpstreamUnavailable is returned when the upstream pub server of a proxied
+// package can't be reached or answers with an error
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
 type PubService interface {
 	GetPackage(ctx context.Context, name string) (*domain.PackageResponse, error)
+	// StreamPackage is GetPackage for encoding large packages incrementally:
+	// it returns the package without its versions, nil if it doesn't exist,
+	// and a VersionStream yielding them
+	StreamPackage(ctx context.Context, name string) (*domain.PackageResponse, VersionStream, error)
 	GetPackageDetail(ctx context.Context, name string) (*domain.PackageDetail, error)
 	GetPackageVersion(ctx context.Context, name, version string) (*domain.VersionResponse, error)
+	// GetLatestVersion returns the version clients should resolve to by default,
+	// nil if the package doesn't exist and ErrNotFound if no version qualifies
+	GetLatestVersion(ctx context.Context, name string) (*domain.VersionResponse, error)
+	GetPubspecYAML(ctx context.Context, name, version string) (*string, error)
+	// GetVersionDependencies returns the dependencies declared in a version's
+	// pubspec, nil if the package or version doesn't exist
+	GetVersionDependencies(ctx context.Context, name, version string) (*domain.VersionDependencies, error)
+	// GetReadme returns the README of a version, or nil if it has none
+	GetReadme(ctx context.Context, name, version string) (*string, error)
+	// GetReadmeHTML returns the README of a version as sanitized HTML, or nil
+	// if it has none
+	GetReadmeHTML(ctx context.Context, name, version string) (*string, error)
 	PublishPackage(ctx context.Context, req *domain.PublishRequest) (*domain.PublishResponse, error)
-	ListPackages(ctx context.Context, page, size int) ([]*domain.Package, error)
+	PreflightPublish(ctx context.Context, req *domain.PublishPreflight) error
+	// ValidatePublish runs every publish check on req without storing anything
+	ValidatePublish(ctx context.Context, req *domain.PublishRequest) (*domain.PublishValidation, error)
+	// ListPackages returns a page of packages in the given order, or in
+	// domain.DefaultPackageSort order when sort is empty
+	ListPackages(ctx context.Context, sort domain.PackageSort, page, size int) ([]*domain.Package, error)
+	// ListPackagesUpdatedSince returns a page of packages changed after
+	// since, oldest change first, for incremental mirroring
+	ListPackagesUpdatedSince(ctx context.Context, since time.Time, page, size int) ([]*domain.Package, error)
 	DownloadPackage(ctx context.Context, name, version string) ([]byte, error)
+	// OpenPackageArchive opens a version's archive for ranged reads. Resumed
+	// downloads pass countDownload false so they aren't counted twice.
+	OpenPackageArchive(ctx context.Context, name, version string, countDownload bool) (io.ReadSeekCloser, error)
 	GetAdvisories(ctx context.Context, name string) (*domain.AdvisoriesResponse, error)
+	GetScore(ctx context.Context, name string) (*domain.ScoreResponse, error)
+	GetDownloadMetrics(ctx context.Context, name string, days int) (*domain.DownloadMetrics, error)
+	GetPackageOptions(ctx context.Context, name string) (*domain.PackageOptions, error)
+	// GetUploaders returns the uploaders of a package, nil if it doesn't exist
+	GetUploaders(ctx context.Context, name string) (*domain.UploadersResponse, error)
+	LikePackage(ctx context.Context, name, liker string) (*domain.LikeResponse, error)
+	// SetPackagePrivate marks a package private or public; only its uploaders
+	// and admins may, others get ErrUnauthorized
+	SetPackagePrivate(ctx context.Context, name string, private bool, actor domain.Actor) (*domain.PrivacyResponse, error)
+	// SetVersionRetracted retracts or restores a version, authorized like
+	// SetPackagePrivate
+	SetVersionRetracted(ctx context.Context, name, version string, retracted bool, actor domain.Actor) (*domain.VersionResponse, error)
+	CleanupOrphanedArchives(ctx context.Context, gracePeriod time.Duration) ([]string, error)
+	// GetScreenshot returns a screenshot declared by a version, read from its archive
+	GetScreenshot(ctx context.Context, name, version, path string) (*domain.ScreenshotImage, error)
+	// VerifyVersion compares a version's row with the pubspec in its stored
+	// archive, nil if the package or version doesn't exist
+	VerifyVersion(ctx context.Context, name, version string) (*domain.VersionVerification, error)
+	// GetVersionRecord returns a version's stored row, including the fields
+	// VersionResponse leaves out, nil if the package or version doesn't exist
+	GetVersionRecord(ctx context.Context, name, version string) (*domain.PackageVersion, error)
+	// CollectRetractedVersions deletes retracted versions published more than
+	// olderThan ago; with dryRun nothing is deleted
+	CollectRetractedVersions(ctx context.Context, olderThan time.Duration, dryRun bool) ([]*domain.CollectedVersion, error)
+	// MigrateStorage copies every archive to target and points the versions at
+	// the copies; with dryRun nothing is copied
+	MigrateStorage(ctx context.Context, target storage.Repository, dryRun bool) ([]*domain.MigratedVersion, error)
+	// ExportPackages exports the packages changed after since, or every
+	// package when since is zero
+	ExportPackages(ctx context.Context, since time.Time, fn func(*domain.ExportedPackage) error) error
+	ImportPackage(ctx context.Context, exported *domain.ExportedPackage) (int, error)
+	// GetInstanceStats returns totals over every package, cached briefly
+	GetInstanceStats(ctx context.Context) (*domain.InstanceStats, error)
+	// GetSitemapPackages returns every public package with its versions,
+	// cached for a few minutes
+	GetSitemapPackages(ctx context.Context) ([]*domain.SitemapPackage, error)
+	// BackfillDocsHTML renders and stores the README and CHANGELOG HTML of
+	// versions published before it was rendered at publish time, returning how
+	// many versions it stored
+	BackfillDocsHTML(ctx context.Context) (int, error)
+	// BackfillSizes records the archive sizes of versions published before
+	// sizes were stored, returning how many versions it updated. Archives that
+	// can't be read are logged and skipped.
+	BackfillSizes(ctx context.Context) (int, error)
+	// RefreshPackage re-renders the stored README and CHANGELOG HTML of every version and
+	// drops cached stats and sitemap, for operators who edited the database by hand. It
+	// returns the package's metadata, nil if it doesn't exist.
+	RefreshPackage(ctx context.Context, name string) (*domain.PackageResponse, error)
+	// ReportVersion files an abuse report against a version
+	ReportVersion(ctx context.Context, name, version, reporter, reason string) (*domain.Report, error)
+	// ListReports returns up to limit abuse reports with an id below before,
+	// newest first
+	ListReports(ctx context.Context, before, limit int32) ([]*domain.Report, error)
 }
 
 type (
@@ -35,29 +172,114 @@ type (
 		Package pkg.Repository
 		Storage storage.Repository
 		Pubspec pubspec.Repository
+
+		// RequireIncreasingVersions rejects versions that are not greater than the highest published one
+		RequireIncreasingVersions bool
+
+		// Per-package quotas enforced on publish, zero means unlimited
+		MaxVersionsPerPackage   int
+		MaxTotalBytesPerPackage int64
+
+		// ReservedPackageNames can't be published, compared case-insensitively
+		ReservedPackageNames []string
+
+		// BlockedPackageNames are glob patterns, e.g. evil_*, of names no new
+		// package may take, compared case-insensitively. Packages that
+		// already exist keep accepting versions.
+		BlockedPackageNames []string
+
+		// ArchiveLimits bounds the files in published archives
+		ArchiveLimits ArchiveLimits
+
+		// AllowedSDKs restricts publishes to SDKDart and/or SDKFlutter packages, empty allows both
+		AllowedSDKs []string
+
+		// UploaderFromAuthor records publishes as the email in the pubspec
+		// author/authors field, falling back to the token identity. Publishes
+		// are always authorized as the token identity.
+		UploaderFromAuthor bool
+
+		// TarGzArchiveURLs advertises archive URLs ending in archive.tar.gz
+		// instead of download, for proxies that sniff the extension
+		TarGzArchiveURLs bool
+
+		// Clock is the source of timestamps, defaults to the system clock
+		Clock clock.Clock
+
+		// Notifier is told about successful publishes, nil disables notifications
+		Notifier PublishNotifier
+
+		// Downloads batches download counting; nil writes each download to the
+		// repository as it happens
+		Downloads *DownloadCounter
+
+		// Upstream serves packages that aren't hosted locally, nil disables proxying
+		Upstream *UpstreamProxy
+
+		// Advisories looks up security advisories in OSV, nil reports none
+		Advisories *AdvisoryClient
+
+		// Readmes renders READMEs of new versions in the background; nil
+		// renders them during the publish
+		Readmes *ReadmeRenderer
+
+		// EnforcePublishTo only accepts pubspecs whose publish_to is BaseURL,
+		// catching publishes meant for another registry
+		EnforcePublishTo bool
+
+		// DownloadSigner signs the archive URLs in package metadata so they
+		// can be downloaded without a token; nil advertises plain URLs
+		DownloadSigner *DownloadSigner
+
+		// ReportNotifier is told about new abuse reports, nil disables
+		// notifications
+		ReportNotifier ReportNotifier
 	}
 	packageService struct {
 		PackageDependencies
+
+		stats   statsCache
+		sitemap sitemapCache
 	}
 )
 
 func NewPubService(deps PackageDependencies) PubService {
+	if deps.Clock == nil {
+		deps.Clock = clock.Real()
+	}
 	return &packageService{
 		PackageDependencies: deps,
 	}
 }
 
+// baseURL is the externally reachable URL every generated URL starts with
 func (s *packageService) baseURL() string {
-	return s.BaseURL
+	return strings.TrimSuffix(s.BaseURL, "/")
 }
 
-func (s *packageService) GetPackage(ctx context.Context, name string) (*domain.PackageResponse, error) {
+// getVisiblePackage looks up a package, hiding private packages from
+// unauthenticated callers so their existence isn't leaked
+func (s *packageService) getVisiblePackage(ctx context.Context, name string) (*domain.Package, error) {
 	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil || pkg == nil {
+		return pkg, err
+	}
+	if pkg.Private && !auth.IsAuthenticated(ctx) {
+		return nil, nil
+	}
+	return pkg, nil
+}
+
+func (s *packageService) GetPackage(ctx context.Context, name string) (_ *domain.PackageResponse, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.GetPackage", trace.WithAttributes(attribute.String("package", name)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	pkg, err := s.getVisiblePackage(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get package: %w", err)
 	}
 	if pkg == nil {
-		return nil, nil
+		return s.upstreamPackage(ctx, name)
 	}
 
 	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
@@ -65,10 +287,17 @@ func (s *packageService) GetPackage(ctx context.Context, name string) (*domain.P
 		return nil, fmt.Errorf("failed to get package versions: %w", err)
 	}
 
-	if len(versions) == 0 {
-		return nil, fmt.Errorf("package has no versions")
+	if s.Upstream != nil && mirrored(versions) {
+		upstream, err := s.fetchUpstreamPackage(ctx, name)
+		if upstream != nil || (err != nil && !errors.Is(err, ErrUpstreamUnavailable)) {
+			return upstream, err
+		}
+		// Upstream is down or dropped the package, serve the cached versions
 	}
 
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: package %s has no versions", ErrNotFound, name)
+	}
 	// Convert to response format
 	versionResponses := make([]domain.VersionResponse, len(versions))
 	for i, v := range versions {
@@ -92,7 +321,7 @@ func (s *packageService) GetPackage(ctx context.Context, name string) (*domain.P
 }
 
 func (s *packageService) GetPackageDetail(ctx context.Context, name string) (*domain.PackageDetail, error) {
-	pkg, err := s.Package.GetPackage(ctx, name)
+	pkg, err := s.getVisiblePackage(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get package: %w", err)
 	}
@@ -106,85 +335,102 @@ func (s *packageService) GetPackageDetail(ctx context.Context, name string) (*do
 	}
 
 	if len(versions) == 0 {
-		return nil, fmt.Errorf("package has no versions")
+		return nil, fmt.Errorf("%w: package %s has no versions", ErrNotFound, name)
 	}
+	renderMissingDocs(versions[0])
 
 	return &domain.PackageDetail{
-		Package:  pkg,
-		Latest:   versions[0], // First is latest due to ORDER BY created_at DESC
-		Versions: versions,
+		Package:          pkg,
+		Latest:           versions[0], // First is latest due to ORDER BY created_at DESC
+		Versions:         versions,
+		VersionDownloads: s.versionDownloads(ctx, pkg),
 	}, nil
 }
 
-func (s *packageService) PublishPackage(ctx context.Context, req *domain.PublishRequest) (*domain.PublishResponse, error) {
-	// 1. Extract and parse pubspec.yaml from archive
-	pubspecContent, readme, changelog, err := s.extractFilesFromArchive(req.Archive)
+// versionDownloads totals the recorded downloads of each version of a package.
+// The counts are informational, so a failed lookup is logged and yields none.
+func (s *packageService) versionDownloads(ctx context.Context, pkg *domain.Package) map[string]int64 {
+	history, err := s.Package.GetDownloadHistory(ctx, pkg.ID, time.Time{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract files from archive: %w", err)
+		slog.Warn("Failed to get download history", "package", pkg.Name, "error", err)
+		return nil
 	}
 
-	// 2. Parse and validate pubspec
-	pubspec, err := s.Pubspec.ParseYAML(ctx, pubspecContent)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse pubspec.yaml: %w", err)
+	totals := make(map[string]int64)
+	for _, entry := range history {
+		totals[entry.Version] += entry.Count
 	}
+	return totals
+}
 
-	// 3. Get or create package
-	pkg, err := s.Package.GetPackage(ctx, pubspec.Name)
+func (s *packageService) PublishPackage(ctx context.Context, req *domain.PublishRequest) (_ *domain.PublishResponse, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.PublishPackage", trace.WithAttributes(attribute.Int("archive.size", len(req.Archive))))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	// 1-2. Extract, parse and validate pubspec.yaml from archive
+	contents, err := s.checkArchive(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check existing package: %w", err)
+		return nil, err
 	}
+	pubspec, pubspecContent, readme, changelog := contents.Pubspec, contents.PubspecYAML, contents.Readme, contents.Changelog
+	span.SetAttributes(attribute.String("package", pubspec.Name), attribute.String("version", pubspec.Version))
 
-	if pkg == nil {
-		// Create new package
-		pkg, err = s.Package.CreatePackage(ctx, pubspec.Name, false)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create package: %w", err)
-		}
+	if err := s.checkBlocked(ctx, pubspec.Name); err != nil {
+		return nil, err
 	}
 
-	// 4. Check if uploader is authorized (add them if first time)
-	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	// 3. Get or create package, concurrent first publishes share the same row
+	pkg, err := s.Package.GetOrCreatePackage(ctx, pubspec.Name, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get uploaders: %w", err)
+		return nil, fmt.Errorf("failed to get or create package: %w", err)
 	}
 
-	// If no uploaders exist, add the current uploader
-	if len(uploaders) == 0 {
-		err = s.Package.AddUploader(ctx, pkg.ID, req.Uploader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add uploader: %w", err)
-		}
-	} else {
-		// Check if uploader is authorized
-		authorized := slices.Contains(uploaders, req.Uploader)
-		if !authorized {
-			return nil, fmt.Errorf("unauthorized to upload to package %s", pubspec.Name)
-		}
+	// 4-5. Check the token's uploader is authorized and the version is new
+	claim, err := s.checkPublishTarget(ctx, pkg, pubspec, req.Uploader, int64(len(req.Archive)))
+	if errors.Is(err, ErrVersionExists) && req.AllowRetry && s.isRepublish(ctx, pkg, pubspec, req.Archive) {
+		slog.Info("Archive is already published", "package", pubspec.Name, "version", pubspec.Version)
+		return s.publishResponse(pubspec.Name, pubspec.Version), nil
 	}
-
-	// 5. Check if version already exists
-	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get package versions: %w", err)
+		return nil, err
 	}
 
-	for _, v := range versions {
-		if v.Version == pubspec.Version {
-			return nil, fmt.Errorf("version %s already exists for package %s", pubspec.Version, pubspec.Name)
+	// The first uploader of a package owns it
+	if claim {
+		if err := s.claimPackage(ctx, pkg, req.Uploader); err != nil {
+			return nil, err
 		}
 	}
+	if err := s.dropProxiedVersions(ctx, pkg); err != nil {
+		return nil, err
+	}
+	uploader := s.publishUploader(pubspec, req.Uploader)
+
+	// From here on, a failed step undoes the ones before it so no archive or
+	// row is left behind without the others
+	var undo rollback
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+	// Undoing must not be cut short by the client going away
+	cleanupCtx := context.WithoutCancel(ctx)
 
-	// 6. Store archive file
-	archivePath, err := s.Storage.Store(pubspec.Name, pubspec.Version, req.Archive)
+	// 6. Stage the archive under a key of its own, a concurrent publish of the
+	// same version must not overwrite it before the version row decides which
+	// publish wins
+	archivePath, err := s.Storage.Stage(ctx, pubspec.Name, pubspec.Version, req.Archive)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store archive: %w", err)
 	}
+	undo.add(func() { s.deleteFailedArchive(cleanupCtx, archivePath) })
 
 	// 7. Calculate SHA256 hash
 	sha256Hash := s.calculateSHA256(req.Archive)
 
 	// 8. Create package version record
+	sizeBytes := int64(len(req.Archive))
 	version := &domain.PackageVersion{
 		PackageID:     pkg.ID,
 		Version:       pubspec.Version,
@@ -194,30 +440,379 @@ func (s *packageService) PublishPackage(ctx context.Context, req *domain.Publish
 		Changelog:     changelog,
 		ArchivePath:   archivePath,
 		ArchiveSha256: &sha256Hash,
-		Uploader:      &req.Uploader,
+		Uploader:      &uploader,
+		Platforms:     pubspec.SupportedPlatforms(),
+		SizeBytes:     &sizeBytes,
+		Funding:       pubspec.Funding,
+		Screenshots:   archiveScreenshots(req.Archive, pubspec.Screenshots),
 	}
 
 	createdVersion, err := s.Package.CreateVersion(ctx, version)
+	if errors.Is(err, ErrVersionExists) {
+		// A concurrent publish of the same version got past the check in step 5
+		// and recorded it first; undoing only deletes this publish's archive
+		return nil, fmt.Errorf("%w: package %s version %s", ErrVersionExists, pubspec.Name, pubspec.Version)
+	}
 	if err != nil {
-		// Clean up stored archive on failure
-		_ = s.Storage.Delete(archivePath)
 		return nil, fmt.Errorf("failed to create version record: %w", err)
 	}
+	undo.add(func() {
+		if err := s.Package.DeleteVersion(cleanupCtx, createdVersion.ID); err != nil {
+			slog.Error("Failed to delete version of failed publish", "package", pubspec.Name, "version", createdVersion.Version, "error", err)
+		}
+	})
+
+	// This publish owns the version now, so its archive moves to the
+	// version's key. The row points at the staged archive until then.
+	committedPath, err := s.Storage.Commit(ctx, archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store archive: %w", err)
+	}
+	undo.add(func() { s.deleteFailedArchive(cleanupCtx, committedPath) })
+	if err := s.Package.SetVersionArchivePath(ctx, createdVersion.ID, committedPath); err != nil {
+		return nil, fmt.Errorf("failed to record archive path: %w", err)
+	}
+	createdVersion.ArchivePath = committedPath
+
+	// 9. Mirrors find new versions through the package's update time
+	if err := s.Package.TouchPackage(ctx, pkg.ID, s.Clock.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to update package timestamp: %w", err)
+	}
+	s.prerenderDocs(ctx, createdVersion)
+
+	if s.Notifier != nil {
+		s.Notifier.NotifyPublished(domain.PublishEvent{
+			Event:       "publish",
+			Package:     pubspec.Name,
+			Version:     createdVersion.Version,
+			Uploader:    uploader,
+			PublishedAt: s.Clock.Now(),
+		})
+	}
+
+	return s.publishResponse(pubspec.Name, createdVersion.Version), nil
+}
+
+// deleteFailedArchive deletes an archive stored by a publish that failed
+func (s *packageService) deleteFailedArchive(ctx context.Context, path string) {
+	if err := s.Storage.Delete(ctx, path); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		// The orphaned archive cleanup reclaims it later
+		slog.Warn("Failed to delete archive of failed publish", "path", path, "error", err)
+	}
+}
 
+// publishResponse is the result of publishing version of a package
+func (s *packageService) publishResponse(name, version string) *domain.PublishResponse {
 	return &domain.PublishResponse{
-		URL: fmt.Sprintf("%s/packages/%s/versions/%s", s.baseURL(), pubspec.Name, createdVersion.Version),
+		URL: fmt.Sprintf("%s/packages/%s/versions/%s", s.baseURL(), name, version),
 		Fields: map[string]string{
-			"package": pubspec.Name,
-			"version": createdVersion.Version,
+			"package": name,
+			"version": version,
 		},
+	}
+}
+
+// isRepublish reports whether the version of pubspec was already published
+// from the same archive bytes. Archives of different versions can't match,
+// since the version is part of the pubspec inside them.
+func (s *packageService) isRepublish(ctx context.Context, pkg *domain.Package, pubspec *domain.Pubspec, archive []byte) bool {
+	existing, err := s.Package.GetVersionByArchiveSha256(ctx, pkg.ID, s.calculateSHA256(archive))
+	if err != nil {
+		slog.Warn("Failed to look up archive checksum", "package", pkg.Name, "error", err)
+		return false
+	}
+	return existing != nil && existing.Version == pubspec.Version
+}
+
+// ValidatePublish runs every check of PublishPackage without storing the
+// archive or creating the package, so CI pipelines can gate on it
+func (s *packageService) ValidatePublish(ctx context.Context, req *domain.PublishRequest) (_ *domain.PublishValidation, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.ValidatePublish", trace.WithAttributes(attribute.Int("archive.size", len(req.Archive))))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	contents, err := s.checkArchive(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	pubspec := contents.Pubspec
+	span.SetAttributes(attribute.String("package", pubspec.Name), attribute.String("version", pubspec.Version))
+
+	if err := s.checkBlocked(ctx, pubspec.Name); err != nil {
+		return nil, err
+	}
+
+	pkg, err := s.Package.GetPackage(ctx, pubspec.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if _, err := s.checkPublishTarget(ctx, pkg, pubspec, req.Uploader, int64(len(req.Archive))); err != nil {
+		return nil, err
+	}
+
+	return &domain.PublishValidation{
+		Package:       pubspec.Name,
+		Version:       pubspec.Version,
+		Uploader:      s.publishUploader(pubspec, req.Uploader),
+		NewPackage:    pkg == nil,
+		SizeBytes:     int64(len(req.Archive)),
+		ArchiveSha256: s.calculateSHA256(req.Archive),
 	}, nil
 }
 
-func (s *packageService) ListPackages(ctx context.Context, page, size int) ([]*domain.Package, error) {
+// checkArchive validates the archive of a publish and the package it declares,
+// returning its contents
+func (s *packageService) checkArchive(ctx context.Context, req *domain.PublishRequest) (*ArchiveContents, error) {
+	contents, err := ValidateArchiveWithLimits(ctx, s.Pubspec, req.Archive, s.ArchiveLimits)
+	if err != nil {
+		// A reserved name is reported along with the pubspec's other problems
+		var invalid *pubspec.ValidationError
+		if errors.As(err, &invalid) {
+			if name, _ := ArchivePackageName(req.Archive); s.isReserved(name) {
+				invalid.Problems = append(invalid.Problems, fmt.Sprintf("%s: %s", ErrPackageReserved, name))
+			}
+		}
+		return nil, err
+	}
+	pubspec := contents.Pubspec
+
+	if req.ExpectedPackage != "" && pubspec.Name != req.ExpectedPackage {
+		return nil, fmt.Errorf("%w: archive is for package %s but the upload was for %s", ErrPackageMismatch, pubspec.Name, req.ExpectedPackage)
+	}
+
+	if s.isReserved(pubspec.Name) {
+		return nil, fmt.Errorf("%w: %s", ErrPackageReserved, pubspec.Name)
+	}
+
+	if err := s.checkSDK(ctx, pubspec); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPublishTo(pubspec); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// checkPublishTarget checks that uploader may publish the version of pubspec
+// to pkg, nil for a package that doesn't exist yet: the uploader is authorized,
+// the version is new and the quotas allow the archive. It reports whether pkg
+// has no uploaders, in which case the uploader claims it.
+func (s *packageService) checkPublishTarget(ctx context.Context, pkg *domain.Package, pubspec *domain.Pubspec, uploader string, archiveSize int64) (bool, error) {
+	if pkg == nil {
+		return true, s.checkQuota(ctx, pubspec.Name, nil, archiveSize)
+	}
+
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	if len(uploaders) > 0 && !slices.Contains(uploaders, uploader) {
+		return false, fmt.Errorf("%w to upload to package %s", ErrUnauthorized, pubspec.Name)
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	// Versions cached from upstream don't count, the publish drops them
+	versions = slices.DeleteFunc(versions, func(v *domain.PackageVersion) bool { return v.Proxied })
+
+	for _, v := range versions {
+		if v.Version == pubspec.Version {
+			return false, fmt.Errorf("%w: package %s version %s", ErrVersionExists, pubspec.Name, pubspec.Version)
+		}
+	}
+
+	if s.RequireIncreasingVersions {
+		if highest := highestVersion(versions, nil); highest != nil && domain.CompareVersions(pubspec.Version, highest.Version) <= 0 {
+			return false, fmt.Errorf("version %s must be greater than the highest published version %s of package %s", pubspec.Version, highest.Version, pubspec.Name)
+		}
+	}
+
+	if err := s.checkQuota(ctx, pkg.Name, versions, archiveSize); err != nil {
+		return false, err
+	}
+
+	return len(uploaders) == 0, nil
+}
+
+// dropProxiedVersions deletes the versions of pkg cached from upstream, so a
+// first-party publish takes over a name that was only proxied rather than
+// mixing local and upstream versions
+func (s *packageService) dropProxiedVersions(ctx context.Context, pkg *domain.Package) error {
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get package versions: %w", err)
+	}
+	for _, v := range versions {
+		if !v.Proxied {
+			continue
+		}
+		if err := s.Package.DeleteVersion(ctx, v.ID); err != nil {
+			return fmt.Errorf("failed to delete proxied version %s: %w", v.Version, err)
+		}
+		s.deleteFailedArchive(ctx, v.ArchivePath)
+		slog.Info("Dropped proxied version for a first-party publish", "package", pkg.Name, "version", v.Version)
+	}
+	return nil
+}
+
+// claimPackage makes uploader the owner of a package without uploaders. Of
+// concurrent first publishes only the claimant becomes an uploader, the
+// others are then rejected like any publish by a non-uploader.
+func (s *packageService) claimPackage(ctx context.Context, pkg *domain.Package, uploader string) error {
+	claimed, err := s.Package.ClaimPackage(ctx, pkg.ID, uploader)
+	if err != nil {
+		return fmt.Errorf("failed to claim package: %w", err)
+	}
+	if claimed {
+		if err := s.Package.AddUploader(ctx, pkg.ID, uploader); err != nil {
+			return fmt.Errorf("failed to add uploader: %w", err)
+		}
+	}
+
+	// Re-check, the package may have been claimed or given uploaders since
+	// checkPublishTarget
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	if !slices.Contains(uploaders, uploader) {
+		return fmt.Errorf("%w to upload to package %s", ErrUnauthorized, pkg.Name)
+	}
+	return nil
+}
+
+// PreflightPublish checks publish hints without an archive: reserved names,
+// uploader authorization and, when a size is given, the package quotas.
+// Hints that are not provided are not checked.
+func (s *packageService) PreflightPublish(ctx context.Context, req *domain.PublishPreflight) error {
+	if req.Package == "" {
+		return nil
+	}
+
+	if s.isReserved(req.Package) {
+		return fmt.Errorf("%w: %s", ErrPackageReserved, req.Package)
+	}
+	if err := s.checkBlocked(ctx, req.Package); err != nil {
+		return err
+	}
+
+	pkg, err := s.Package.GetPackage(ctx, req.Package)
+	if err != nil {
+		return fmt.Errorf("failed to check existing package: %w", err)
+	}
+	if pkg == nil {
+		// A new package has no uploaders or versions to check against
+		return nil
+	}
+
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	if len(uploaders) > 0 && !slices.Contains(uploaders, req.Uploader) {
+		return fmt.Errorf("%w to upload to package %s", ErrUnauthorized, pkg.Name)
+	}
+
+	if req.Size > 0 {
+		versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get package versions: %w", err)
+		}
+		if err := s.checkQuota(ctx, pkg.Name, versions, req.Size); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isReserved reports whether name is one of the reserved package names
+func (s *packageService) isReserved(name string) bool {
+	return slices.ContainsFunc(s.ReservedPackageNames, func(reserved string) bool {
+		return strings.EqualFold(reserved, name)
+	})
+}
+
+// checkBlocked rejects creating a package whose name matches one of the
+// BlockedPackageNames patterns; existing packages aren't affected
+func (s *packageService) checkBlocked(ctx context.Context, name string) error {
+	index := slices.IndexFunc(s.BlockedPackageNames, func(pattern string) bool {
+		matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+		return matched
+	})
+	if index < 0 {
+		return nil
+	}
+
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check existing package: %w", err)
+	}
+	if pkg != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %s matches %s", ErrPackageBlocked, name, s.BlockedPackageNames[index])
+}
+
+// checkSDK rejects packages whose SDK is not in AllowedSDKs
+func (s *packageService) checkSDK(ctx context.Context, pubspec *domain.Pubspec) error {
+	if len(s.AllowedSDKs) == 0 {
+		return nil
+	}
+
+	sdk, err := packageSDK(ctx, s.Pubspec, pubspec)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPubspecInvalid, err)
+	}
+	if !slices.ContainsFunc(s.AllowedSDKs, func(allowed string) bool { return strings.EqualFold(allowed, sdk) }) {
+		return fmt.Errorf("%w: %s is a %s package, this registry only accepts %s packages",
+			ErrSDKNotAllowed, pubspec.Name, sdk, strings.Join(s.AllowedSDKs, ", "))
+	}
+	return nil
+}
+
+// checkPublishTo checks that a pubspec's publish_to names this registry when
+// EnforcePublishTo is set. URLs are compared ignoring case and a trailing slash.
+func (s *packageService) checkPublishTo(pubspec *domain.Pubspec) error {
+	if !s.EnforcePublishTo {
+		return nil
+	}
+
+	switch target := strings.TrimSuffix(pubspec.PublishTo, "/"); {
+	case target == "":
+		return fmt.Errorf("%w: %s must set publish_to to %s", ErrPublishToMismatch, pubspec.Name, s.BaseURL)
+	case target == "none":
+		return fmt.Errorf("%w: %s sets publish_to: none and must not be published", ErrPublishToMismatch, pubspec.Name)
+	case !strings.EqualFold(target, strings.TrimSuffix(s.BaseURL, "/")):
+		return fmt.Errorf("%w: %s is meant for %s, not %s", ErrPublishToMismatch, pubspec.Name, pubspec.PublishTo, s.BaseURL)
+	}
+	return nil
+}
+
+func (s *packageService) ListPackages(ctx context.Context, sort domain.PackageSort, page, size int) ([]*domain.Package, error) {
+	offset := int32((page - 1) * size)
+	limit := int32(size)
+	if sort == "" {
+		sort = domain.DefaultPackageSort
+	}
+
+	packages, err := s.Package.ListPackagesSorted(ctx, sort, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	return packages, nil
+}
+
+func (s *packageService) ListPackagesUpdatedSince(ctx context.Context, since time.Time, page, size int) ([]*domain.Package, error) {
 	offset := int32((page - 1) * size)
 	limit := int32(size)
 
-	packages, err := s.Package.ListPackages(ctx, limit, offset)
+	packages, err := s.Package.ListPackagesUpdatedSince(ctx, since, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list packages: %w", err)
 	}
@@ -225,11 +820,33 @@ func (s *packageService) ListPackages(ctx context.Context, page, size int) ([]*d
 	return packages, nil
 }
 
+// touchPackage bumps the update time of pkg so incremental mirrors pick up
+// the change. The change itself has already been stored, so a failure is
+// only logged.
+func (s *packageService) touchPackage(ctx context.Context, pkg *domain.Package) {
+	if err := s.Package.TouchPackage(ctx, pkg.ID, s.Clock.Now().UTC()); err != nil {
+		slog.Warn("Failed to update package timestamp", "package", pkg.Name, "error", err)
+	}
+}
+
+// archiveURL is where clients download the archive of a version
+func (s *packageService) archiveURL(packageName, version string) string {
+	archiveFile := "download"
+	if s.TarGzArchiveURLs {
+		archiveFile = "archive.tar.gz"
+	}
+	archiveURL := fmt.Sprintf("%s/packages/%s/versions/%s/%s", s.baseURL(), packageName, version, archiveFile)
+	if s.DownloadSigner != nil {
+		return s.DownloadSigner.SignURL(archiveURL, packageName, version)
+	}
+	return archiveURL
+}
+
 func (s *packageService) versionToResponseWithPackage(v *domain.PackageVersion, packageName string) (domain.VersionResponse, error) {
-	archiveURL := fmt.Sprintf("%s/packages/%s/versions/%s/download", s.baseURL(), packageName, v.Version)
+	archiveURL := s.archiveURL(packageName, v.Version)
 
-	// Parse pubspec YAML to JSON
-	parsed, err := s.Pubspec.ParseYAML(context.Background(), v.PubspecYaml)
+	// Parse pubspec YAML to JSON; it was validated when it was published
+	parsed, err := s.Pubspec.DecodeYAML(context.Background(), v.PubspecYaml)
 	if err != nil {
 		return domain.VersionResponse{}, err
 	}
@@ -249,17 +866,22 @@ func (s *packageService) versionToResponseWithPackage(v *domain.PackageVersion,
 		Retracted:     v.Retracted,
 		ArchiveURL:    archiveURL,
 		ArchiveSha256: stringValue(v.ArchiveSha256),
+		Published:     v.CreatedAt,
+		Platforms:     v.Platforms,
+		SizeBytes:     int64Value(v.SizeBytes),
+		Proxied:       v.Proxied,
 		Pubspec:       pubspecJSON,
 	}, nil
 }
 
 func (s *packageService) GetPackageVersion(ctx context.Context, name, version string) (*domain.VersionResponse, error) {
-	pkg, err := s.Package.GetPackage(ctx, name)
+	pkg, err := s.getVisiblePackage(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get package: %w", err)
 	}
 	if pkg == nil {
-		return nil, nil
+		upstream, err := s.upstreamPackage(ctx, name)
+		return upstreamVersion(upstream, version), err
 	}
 
 	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
@@ -277,16 +899,70 @@ func (s *packageService) GetPackageVersion(ctx context.Context, name, version st
 		}
 	}
 
+	// Versions of a mirrored package that haven't been cached yet
+	if s.Upstream != nil && mirrored(versions) {
+		upstream, err := s.fetchUpstreamPackage(ctx, name)
+		return upstreamVersion(upstream, version), err
+	}
+
 	return nil, nil // Version not found
 }
 
-func (s *packageService) DownloadPackage(ctx context.Context, name, version string) ([]byte, error) {
-	pkg, err := s.Package.GetPackage(ctx, name)
+// upstreamVersion finds version in upstream metadata, nil if it isn't there
+func upstreamVersion(pkg *domain.PackageResponse, version string) *domain.VersionResponse {
+	if pkg == nil {
+		return nil
+	}
+	for _, v := range pkg.Versions {
+		if v.Version == version {
+			return &v
+		}
+	}
+	return nil
+}
+
+func (s *packageService) GetLatestVersion(ctx context.Context, name string) (*domain.VersionResponse, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get package: %w", err)
 	}
 	if pkg == nil {
-		return nil, fmt.Errorf("package not found")
+		return nil, nil
+	}
+
+	// Only the version numbers are needed to pick the latest, then only it
+	// is loaded in full
+	numbers, err := s.Package.GetVersionNumbers(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	number := latestVersion(numbers)
+	if number == nil {
+		return nil, fmt.Errorf("%w: package %s has no unretracted versions", ErrNotFound, name)
+	}
+	latest, err := s.Package.GetVersion(ctx, pkg.ID, number.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package version: %w", err)
+	}
+	if latest == nil {
+		// Deleted since the numbers were read
+		return nil, fmt.Errorf("%w: version %s of package %s", ErrNotFound, number.Version, name)
+	}
+
+	response, err := s.versionToResponseWithPackage(latest, pkg.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert version response: %w", err)
+	}
+	return &response, nil
+}
+
+func (s *packageService) GetPubspecYAML(ctx context.Context, name, version string) (*string, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
 	}
 
 	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
@@ -296,114 +972,506 @@ func (s *packageService) DownloadPackage(ctx context.Context, name, version stri
 
 	for _, v := range versions {
 		if v.Version == version {
-			// Get the archive from storage
-			data, err := s.Storage.Get(v.ArchivePath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get archive: %w", err)
-			}
-			return data, nil
+			return &v.PubspecYaml, nil
 		}
 	}
 
-	return nil, fmt.Errorf("version not found")
+	return nil, nil // Version not found
 }
 
-func (s *packageService) GetAdvisories(ctx context.Context, name string) (*domain.AdvisoriesResponse, error) {
-	// For now, return empty advisories
-	// In a real implementation, this would query a security advisory database
-	return &domain.AdvisoriesResponse{
-		Advisories:        []domain.Advisory{},
-		AdvisoriesUpdated: "2024-01-01T00:00:00Z",
+func (s *packageService) GetVersionDependencies(ctx context.Context, name, version string) (*domain.VersionDependencies, error) {
+	pubspecYAML, err := s.GetPubspecYAML(ctx, name, version)
+	if err != nil || pubspecYAML == nil {
+		return nil, err
+	}
+
+	spec, err := s.Pubspec.DecodeYAML(ctx, *pubspecYAML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pubspec: %w", err)
+	}
+
+	// ExtractDependencies merges both sections, so run it on each separately
+	deps, err := s.Pubspec.ExtractDependencies(ctx, &domain.Pubspec{Dependencies: spec.Dependencies})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract dependencies: %w", err)
+	}
+	devDeps, err := s.Pubspec.ExtractDependencies(ctx, &domain.Pubspec{DevDependencies: spec.DevDependencies})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract dev dependencies: %w", err)
+	}
+
+	return &domain.VersionDependencies{
+		Package:         name,
+		Version:         version,
+		Dependencies:    deps,
+		DevDependencies: devDeps,
 	}, nil
 }
 
-func stringValue(s *string) string {
-	if s == nil {
-		return ""
+func (s *packageService) GetReadme(ctx context.Context, name, version string) (*string, error) {
+	v, err := s.getVersion(ctx, name, version)
+	if err != nil {
+		return nil, err
 	}
-	return *s
+	return v.Readme, nil
 }
 
-func (s *packageService) extractFilesFromArchive(archiveData []byte) (pubspecContent string, readme *string, changelog *string, err error) {
-	// Create a gzip reader
-	gzReader, err := gzip.NewReader(bytes.NewReader(archiveData))
+func (s *packageService) GetReadmeHTML(ctx context.Context, name, version string) (*string, error) {
+	v, err := s.getVersion(ctx, name, version)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, err
+	}
+	if v.Readme == nil {
+		return nil, nil
 	}
-	defer func() { _ = gzReader.Close() }()
+	if v.ReadmeHTML == nil {
+		html := markdown.Render(*v.Readme)
+		return &html, nil
+	}
+	return v.ReadmeHTML, nil
+}
 
-	// Create a tar reader
-	tarReader := tar.NewReader(gzReader)
+// getVersion returns a version of a visible package, ErrNotFound if either
+// doesn't exist
+func (s *packageService) getVersion(ctx context.Context, name, version string) (*domain.PackageVersion, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("%w: package %s", ErrNotFound, name)
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: version %s of package %s", ErrNotFound, version, name)
+}
+
+func (s *packageService) DownloadPackage(ctx context.Context, name, version string) (_ []byte, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.DownloadPackage", trace.WithAttributes(attribute.String("package", name), attribute.String("version", version)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	pkg, v, err := s.findDownloadableVersion(ctx, name, version)
+	if errors.Is(err, ErrNotFound) {
+		if data, proxied, err := s.upstreamArchive(ctx, name, version); proxied || err != nil {
+			return data, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the archive from storage
+	data, err := s.Storage.Get(ctx, v.ArchivePath)
+	if err != nil {
+		return nil, archiveError(name, version, err)
+	}
+
+	s.recordDownload(ctx, pkg, v.Version)
+	return data, nil
+}
+
+func (s *packageService) OpenPackageArchive(ctx context.Context, name, version string, countDownload bool) (_ io.ReadSeekCloser, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.OpenPackageArchive", trace.WithAttributes(attribute.String("package", name), attribute.String("version", version)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	pkg, v, err := s.findDownloadableVersion(ctx, name, version)
+	if errors.Is(err, ErrNotFound) {
+		if archive, proxied, err := s.upstreamArchiveReader(ctx, name, version); proxied || err != nil {
+			return archive, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	var foundPubspec bool
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
+	var archive io.ReadSeekCloser
+	if seeker, ok := s.Storage.(storage.Seeker); ok {
+		archive, err = seeker.Open(ctx, v.ArchivePath)
+		if err != nil {
+			return nil, archiveError(name, version, err)
 		}
+	} else {
+		// Backends that can't seek serve ranges from the whole archive in memory
+		data, err := s.Storage.Get(ctx, v.ArchivePath)
 		if err != nil {
-			return "", nil, nil, fmt.Errorf("failed to read tar entry: %w", err)
+			return nil, archiveError(name, version, err)
 		}
+		archive = nopSeekCloser{bytes.NewReader(data)}
+	}
 
-		// Skip directories
-		if header.Typeflag == tar.TypeDir {
-			continue
+	if countDownload {
+		s.recordDownload(ctx, pkg, v.Version)
+	}
+	return archive, nil
+}
+
+// rollback undoes the completed steps of an operation, most recent first,
+// when a later step fails
+type rollback []func()
+
+func (r *rollback) add(undo func()) {
+	*r = append(*r, undo)
+}
+
+func (r rollback) run() {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]()
+	}
+}
+
+// nopSeekCloser adds a no-op Close to an in-memory archive
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// findDownloadableVersion returns a version visible to the caller, or ErrNotFound
+func (s *packageService) findDownloadableVersion(ctx context.Context, name, version string) (*domain.Package, *domain.PackageVersion, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil, fmt.Errorf("%w: package %s", ErrNotFound, name)
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			return pkg, v, nil
 		}
+	}
 
-		// Get the file name relative to the package root
-		fileName := strings.TrimPrefix(header.Name, "./")
+	return nil, nil, fmt.Errorf("%w: version %s of package %s", ErrNotFound, version, name)
+}
 
-		// Remove package name prefix if present (e.g., "package-1.0.0/pubspec.yaml" -> "pubspec.yaml")
-		parts := strings.Split(fileName, "/")
-		if len(parts) > 1 {
-			fileName = strings.Join(parts[1:], "/")
+// archiveError tells a missing archive from an unavailable storage backend
+func archiveError(name, version string, err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: archive of version %s of package %s: %w", ErrNotFound, version, name, err)
+	}
+	return fmt.Errorf("%w: failed to get archive: %w", ErrStorageUnavailable, err)
+}
+
+// recordDownload counts a download of version
+func (s *packageService) recordDownload(ctx context.Context, pkg *domain.Package, version string) {
+	if s.Downloads != nil {
+		s.Downloads.Add(domain.DownloadKey{PackageID: pkg.ID, Version: version, Day: s.today()})
+		return
+	}
+
+	// A failed counter update shouldn't fail the download
+	if err := s.Package.IncrementDownloadCount(ctx, pkg.ID); err != nil {
+		slog.Warn("Failed to increment download count", "package", pkg.Name, "error", err)
+	}
+	if err := s.Package.RecordDownload(ctx, pkg.ID, version, s.today()); err != nil {
+		slog.Warn("Failed to record download", "package", pkg.Name, "version", version, "error", err)
+	}
+}
+
+func (s *packageService) GetAdvisories(ctx context.Context, name string) (*domain.AdvisoriesResponse, error) {
+	advisories := []domain.Advisory{}
+	if s.Advisories != nil {
+		var err error
+		if advisories, err = s.Advisories.advisories(ctx, name); err != nil {
+			return nil, err
 		}
+	}
+	return &domain.AdvisoriesResponse{
+		Advisories:        advisories,
+		AdvisoriesUpdated: advisoriesUpdated(advisories).Format(time.RFC3339),
+	}, nil
+}
 
-		switch strings.ToLower(fileName) {
-		case "pubspec.yaml":
-			// Always read content first
-			content, err := io.ReadAll(tarReader)
-			if err != nil {
-				return "", nil, nil, fmt.Errorf("failed to read pubspec.yaml: %w", err)
-			}
-			// Only keep if it's the root pubspec (no path separators) or we haven't found any yet
-			pathDepth := strings.Count(header.Name, "/")
-			if pathDepth == 0 {
-				// This is the root pubspec - always use it
-				pubspecContent = string(content)
-				foundPubspec = true
-			} else if !foundPubspec {
-				// No root pubspec found yet, temporarily use this nested one
-				pubspecContent = string(content)
-				foundPubspec = true
-			}
+// checkQuota verifies that adding an archive of the given size stays within the package quotas
+func (s *packageService) checkQuota(ctx context.Context, name string, versions []*domain.PackageVersion, archiveSize int64) error {
+	if s.MaxVersionsPerPackage > 0 && len(versions) >= s.MaxVersionsPerPackage {
+		return fmt.Errorf("%w: package %s already has %d versions (limit %d)", ErrQuotaExceeded, name, len(versions), s.MaxVersionsPerPackage)
+	}
 
-		case "readme.md":
-			content, err := io.ReadAll(tarReader)
-			if err != nil {
-				return "", nil, nil, fmt.Errorf("failed to read README.md: %w", err)
+	if s.MaxTotalBytesPerPackage > 0 {
+		total := archiveSize
+		for _, v := range versions {
+			if v.SizeBytes != nil {
+				total += *v.SizeBytes
+				continue
 			}
-			readmeContent := string(content)
-			readme = &readmeContent
 
-		case "changelog.md":
-			content, err := io.ReadAll(tarReader)
+			size, err := s.Storage.Size(ctx, v.ArchivePath)
 			if err != nil {
-				return "", nil, nil, fmt.Errorf("failed to read CHANGELOG.md: %w", err)
+				return fmt.Errorf("failed to get archive size for version %s: %w", v.Version, err)
 			}
-			changelogContent := string(content)
-			changelog = &changelogContent
+			total += size
+		}
+		if total > s.MaxTotalBytesPerPackage {
+			return fmt.Errorf("%w: package %s would use %d bytes (limit %d)", ErrQuotaExceeded, name, total, s.MaxTotalBytesPerPackage)
 		}
 	}
 
-	if !foundPubspec {
-		return "", nil, nil, fmt.Errorf("pubspec.yaml not found in archive")
+	return nil
+}
+
+// highestVersion returns the greatest of the versions include accepts by
+// semver ordering, nil if there are none; a nil include accepts every version
+func highestVersion(versions []*domain.PackageVersion, include func(*domain.PackageVersion) bool) *domain.PackageVersion {
+	var highest *domain.PackageVersion
+	for _, v := range versions {
+		if include != nil && !include(v) {
+			continue
+		}
+		if highest == nil || domain.CompareVersions(v.Version, highest.Version) > 0 {
+			highest = v
+		}
 	}
+	return highest
+}
 
-	return pubspecContent, readme, changelog, nil
+// latestVersion picks the highest unretracted stable version, falling back to
+// the highest unretracted pre-release when there is no stable one
+func latestVersion(versions []*domain.PackageVersion) *domain.PackageVersion {
+	if stable := highestVersion(versions, func(v *domain.PackageVersion) bool {
+		return !v.Retracted && !domain.IsPrerelease(v.Version)
+	}); stable != nil {
+		return stable
+	}
+	return highestVersion(versions, func(v *domain.PackageVersion) bool { return !v.Retracted })
+}
+
+func (s *packageService) GetScore(ctx context.Context, name string) (*domain.ScoreResponse, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	// Package analysis isn't implemented, so no pub points are granted
+	return &domain.ScoreResponse{
+		GrantedPoints: 0,
+		MaxPoints:     0,
+		LikeCount:     pkg.LikeCount,
+		DownloadCount: pkg.DownloadCount,
+	}, nil
+}
+
+// GetDownloadMetrics returns daily download counts for the last days days, including today
+func (s *packageService) GetDownloadMetrics(ctx context.Context, name string, days int) (*domain.DownloadMetrics, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	since := s.today().AddDate(0, 0, -(days - 1))
+	history, err := s.Package.GetDownloadHistory(ctx, pkg.ID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download history: %w", err)
+	}
+
+	// Emit every day in the window so that clients get a continuous series
+	metrics := &domain.DownloadMetrics{
+		Package: pkg.Name,
+		Days:    days,
+		Series:  make([]domain.DailyDownloads, days),
+	}
+	index := make(map[string]int, days)
+	for i := range metrics.Series {
+		date := since.AddDate(0, 0, i).Format(time.DateOnly)
+		metrics.Series[i].Date = date
+		index[date] = i
+	}
+
+	for _, h := range history {
+		i, ok := index[h.Day.Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		day := &metrics.Series[i]
+		if day.Versions == nil {
+			day.Versions = make(map[string]int64)
+		}
+		day.Versions[h.Version] += h.Count
+		day.Downloads += h.Count
+		metrics.Total += h.Count
+	}
+
+	return metrics, nil
+}
+
+// today returns the start of the current UTC day, the granularity of download buckets
+func (s *packageService) today() time.Time {
+	return s.Clock.Now().UTC().Truncate(24 * time.Hour)
+}
+
+func (s *packageService) GetPackageOptions(ctx context.Context, name string) (*domain.PackageOptions, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	// Discontinuing and unlisting packages isn't supported, so the flags are always off
+	return &domain.PackageOptions{
+		IsDiscontinued: false,
+		ReplacedBy:     nil,
+		IsUnlisted:     false,
+	}, nil
+}
+
+func (s *packageService) GetUploaders(ctx context.Context, name string) (*domain.UploadersResponse, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	if uploaders == nil {
+		uploaders = []string{}
+	}
+	return &domain.UploadersResponse{Package: pkg.Name, Uploaders: uploaders}, nil
+}
+
+func (s *packageService) LikePackage(ctx context.Context, name, liker string) (*domain.LikeResponse, error) {
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	liked, err := s.Package.LikePackage(ctx, pkg.ID, liker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to like package: %w", err)
+	}
+
+	likeCount := pkg.LikeCount
+	if liked {
+		likeCount++
+	}
+
+	return &domain.LikeResponse{
+		Package:   pkg.Name,
+		LikeCount: likeCount,
+	}, nil
+}
+
+func int64Value(n *int64) int64 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 func (s *packageService) calculateSHA256(data []byte) string {
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:])
 }
+
+func (s *packageService) SetPackagePrivate(ctx context.Context, name string, private bool, actor domain.Actor) (*domain.PrivacyResponse, error) {
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+	if err := s.checkActor(ctx, pkg, actor); err != nil {
+		return nil, err
+	}
+
+	if err := s.Package.SetPackagePrivate(ctx, pkg.ID, private); err != nil {
+		return nil, fmt.Errorf("failed to update package privacy: %w", err)
+	}
+
+	return &domain.PrivacyResponse{
+		Package: pkg.Name,
+		Private: private,
+	}, nil
+}
+
+// checkActor returns ErrUnauthorized unless actor is an admin or one of the
+// uploaders of pkg
+func (s *packageService) checkActor(ctx context.Context, pkg *domain.Package, actor domain.Actor) error {
+	if actor.Admin {
+		return nil
+	}
+	uploaders, err := s.Package.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get uploaders: %w", err)
+	}
+	if !slices.Contains(uploaders, actor.Uploader) {
+		return fmt.Errorf("%w to change package %s", ErrUnauthorized, pkg.Name)
+	}
+	return nil
+}
+
+// SetVersionRetracted retracts or unretracts a published version
+func (s *packageService) SetVersionRetracted(ctx context.Context, name, version string, retracted bool, actor domain.Actor) (*domain.VersionResponse, error) {
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+	if err := s.checkActor(ctx, pkg, actor); err != nil {
+		return nil, err
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			if err := s.Package.SetVersionRetracted(ctx, v.ID, retracted); err != nil {
+				return nil, fmt.Errorf("failed to update version: %w", err)
+			}
+			v.Retracted = retracted
+			s.touchPackage(ctx, pkg)
+
+			response, err := s.versionToResponseWithPackage(v, pkg.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert version response: %w", err)
+			}
+			return &response, nil
+		}
+	}
+
+	return nil, nil // Version not found
+}
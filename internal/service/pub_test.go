@@ -5,13 +5,24 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"repub/internal/auth"
+	"repub/internal/clock"
 	"repub/internal/domain"
+	"repub/internal/repository/pkg"
+	"repub/internal/repository/pubspec"
 	"repub/internal/testutil"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestPubService_GetPackage(t *testing.T) {
@@ -68,6 +79,61 @@ func TestPubService_GetPackage(t *testing.T) {
 	}
 }
 
+func TestPubService_GetPackage_LegacyPubspec(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	ctx := context.Background()
+	pkg, err := repos.DB.CreateTestPackage(ctx, "legacy", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	// Published before SDK constraints were validated, publishing it now
+	// would be rejected
+	legacy := "name: legacy\nversion: 1.0.0\nenvironment:\n  sdk: '>=banana'"
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: legacy,
+		ArchivePath: "/storage/legacy/1.0.0/legacy-1.0.0.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	result, err := svc.GetPackage(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if result.Latest.Pubspec["environment"] == nil {
+		t.Errorf("Expected the stored environment to be served, got %v", result.Latest.Pubspec)
+	}
+
+	head, versions, err := svc.StreamPackage(ctx, "legacy")
+	if err != nil || head == nil {
+		t.Fatalf("StreamPackage failed: %v", err)
+	}
+	streamed := 0
+	err = versions(func(v *domain.VersionResponse) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 1 {
+		t.Errorf("Expected 1 streamed version, got %d, %v", streamed, err)
+	}
+
+	if _, err := svc.GetVersionDependencies(ctx, "legacy", "1.0.0"); err != nil {
+		t.Errorf("GetVersionDependencies failed: %v", err)
+	}
+}
+
 func TestPubService_GetPackage_NotFound(t *testing.T) {
 	repos := testutil.SetupTestRepositories(t)
 	defer repos.Close()
@@ -114,7 +180,7 @@ func TestPubService_ListPackages(t *testing.T) {
 	}
 
 	// Test ListPackages
-	result, err := svc.ListPackages(ctx, 1, 10)
+	result, err := svc.ListPackages(ctx, "", 1, 10)
 	if err != nil {
 		t.Fatalf("ListPackages failed: %v", err)
 	}
@@ -334,6 +400,9 @@ description: Malicious update`,
 		if !strings.Contains(err.Error(), "unauthorized") {
 			t.Errorf("Expected unauthorized error, got: %v", err)
 		}
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Expected ErrUnauthorized, got: %v", err)
+		}
 	})
 
 	t.Run("reject duplicate version", func(t *testing.T) {
@@ -375,6 +444,171 @@ description: A test package`,
 		if !strings.Contains(err.Error(), "already exists") {
 			t.Errorf("Expected version exists error, got: %v", err)
 		}
+		if !errors.Is(err, ErrVersionExists) {
+			t.Errorf("Expected ErrVersionExists, got: %v", err)
+		}
+	})
+
+	t.Run("retry with identical bytes", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		svc := NewPubService(PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			BaseURL: "http://localhost:8080",
+		})
+		ctx := context.Background()
+
+		files := map[string]string{"pubspec.yaml": "name: retried_package\nversion: 1.0.0"}
+		req := &domain.PublishRequest{
+			Archive:    testutil.CreateTestTarGzArchive(t, files),
+			Uploader:   "test@example.com",
+			AllowRetry: true,
+		}
+		if _, err := svc.PublishPackage(ctx, req); err != nil {
+			t.Fatalf("First publish failed: %v", err)
+		}
+
+		resp, err := svc.PublishPackage(ctx, req)
+		if err != nil {
+			t.Fatalf("Expected the retry to succeed, got %v", err)
+		}
+		if resp.URL != "http://localhost:8080/packages/retried_package/versions/1.0.0" {
+			t.Errorf("Expected the published version's URL, got %s", resp.URL)
+		}
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "retried_package")
+		if err != nil || pkg == nil {
+			t.Fatalf("Failed to get package: %v", err)
+		}
+		if versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID); err != nil || len(versions) != 1 {
+			t.Errorf("Expected the single published version, got %d, %v", len(versions), err)
+		}
+
+		// Different bytes for the version are still a conflict
+		files["README.md"] = "# Changed"
+		req.Archive = testutil.CreateTestTarGzArchive(t, files)
+		if _, err := svc.PublishPackage(ctx, req); !errors.Is(err, ErrVersionExists) {
+			t.Errorf("Expected ErrVersionExists for a changed archive, got %v", err)
+		}
+	})
+
+	t.Run("increasing versions required", func(t *testing.T) {
+		tests := []struct {
+			name                      string
+			requireIncreasingVersions bool
+			expectErr                 bool
+		}{
+			{"reject older version when enabled", true, true},
+			{"allow older version when disabled", false, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				repos := testutil.SetupTestRepositories(t)
+				defer repos.Close()
+
+				svc := NewPubService(PackageDependencies{
+					Package:                   repos.DB.Repo,
+					Storage:                   repos.StorageSvc,
+					Pubspec:                   repos.PubspecSvc,
+					BaseURL:                   "http://localhost:8080",
+					RequireIncreasingVersions: tt.requireIncreasingVersions,
+				})
+
+				ctx := context.Background()
+
+				// Publish version 2.0.0 first
+				newer := testutil.CreateTestTarGzArchive(t, map[string]string{
+					"test_package-2.0.0/pubspec.yaml": "name: test_package\nversion: 2.0.0",
+				})
+				if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: newer, Uploader: "test@example.com"}); err != nil {
+					t.Fatalf("First publish failed: %v", err)
+				}
+
+				// Then try to publish an older 1.0.0
+				older := testutil.CreateTestTarGzArchive(t, map[string]string{
+					"test_package-1.0.0/pubspec.yaml": "name: test_package\nversion: 1.0.0",
+				})
+				_, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: older, Uploader: "test@example.com"})
+
+				if tt.expectErr {
+					if err == nil {
+						t.Fatal("Expected older version to be rejected")
+					}
+					if !strings.Contains(err.Error(), "must be greater than") {
+						t.Errorf("Expected increasing version error, got: %v", err)
+					}
+				} else if err != nil {
+					t.Errorf("Expected older version to be accepted, got: %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("package quotas", func(t *testing.T) {
+		tests := []struct {
+			name                    string
+			maxVersionsPerPackage   int
+			maxTotalBytesPerPackage int64
+			expectErr               bool
+		}{
+			{"reject when version quota exceeded", 1, 0, true},
+			{"reject when byte quota exceeded", 0, 1, true},
+			{"allow within quotas", 2, 1 << 20, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				repos := testutil.SetupTestRepositories(t)
+				defer repos.Close()
+
+				svc := NewPubService(PackageDependencies{
+					Package:                 repos.DB.Repo,
+					Storage:                 repos.StorageSvc,
+					Pubspec:                 repos.PubspecSvc,
+					BaseURL:                 "http://localhost:8080",
+					MaxVersionsPerPackage:   tt.maxVersionsPerPackage,
+					MaxTotalBytesPerPackage: tt.maxTotalBytesPerPackage,
+				})
+
+				ctx := context.Background()
+
+				// Seed an existing version directly so the byte quota only applies to the second upload
+				first := testutil.CreateTestTarGzArchive(t, map[string]string{
+					"test_package-1.0.0/pubspec.yaml": "name: test_package\nversion: 1.0.0",
+				})
+				pkg, err := repos.DB.CreateTestPackage(ctx, "test_package", false)
+				if err != nil {
+					t.Fatalf("Failed to create package: %v", err)
+				}
+				if err := repos.DB.Repo.AddUploader(ctx, pkg.ID, "test@example.com"); err != nil {
+					t.Fatalf("Failed to add uploader: %v", err)
+				}
+				_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+					Version:     "1.0.0",
+					PubspecYaml: "name: test_package\nversion: 1.0.0",
+					ArchivePath: repos.CreateTestArchive(t, "test_package", "1.0.0", first),
+				})
+				if err != nil {
+					t.Fatalf("Failed to create version: %v", err)
+				}
+
+				second := testutil.CreateTestTarGzArchive(t, map[string]string{
+					"test_package-1.1.0/pubspec.yaml": "name: test_package\nversion: 1.1.0",
+				})
+				_, err = svc.PublishPackage(ctx, &domain.PublishRequest{Archive: second, Uploader: "test@example.com"})
+
+				if tt.expectErr {
+					if !errors.Is(err, ErrQuotaExceeded) {
+						t.Errorf("Expected quota exceeded error, got: %v", err)
+					}
+				} else if err != nil {
+					t.Errorf("Expected publish within quotas to succeed, got: %v", err)
+				}
+			})
+		}
 	})
 
 	t.Run("reject invalid pubspec", func(t *testing.T) {
@@ -407,6 +641,9 @@ description: A test package`,
 		if !strings.Contains(err.Error(), "parse pubspec.yaml") {
 			t.Errorf("Expected pubspec parse error, got: %v", err)
 		}
+		if !errors.Is(err, ErrPubspecInvalid) {
+			t.Errorf("Expected ErrPubspecInvalid, got: %v", err)
+		}
 	})
 
 	t.Run("reject archive without pubspec", func(t *testing.T) {
@@ -438,134 +675,1857 @@ description: A test package`,
 		if !strings.Contains(err.Error(), "pubspec.yaml not found") {
 			t.Errorf("Expected missing pubspec error, got: %v", err)
 		}
+		if !errors.Is(err, ErrPubspecInvalid) {
+			t.Errorf("Expected ErrPubspecInvalid, got: %v", err)
+		}
 	})
 }
 
-func TestStringValue(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    *string
-		expected string
-	}{
-		{
-			name:     "nil pointer",
-			input:    nil,
-			expected: "",
-		},
-		{
-			name:     "non-nil pointer",
-			input:    stringPtr("test"),
-			expected: "test",
-		},
-	}
+func TestPubService_PublishPackage_UpdatedAt(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := stringValue(tt.input)
-			if result != tt.expected {
-				t.Errorf("Expected %s, got %s", tt.expected, result)
-			}
+	clk := clock.NewFake(time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC))
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+		Clock:   clk,
+	})
+
+	ctx := context.Background()
+	publish := func(version string) time.Time {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: touched\nversion: " + version,
 		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", version, err)
+		}
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "touched")
+		if err != nil || pkg == nil {
+			t.Fatalf("Failed to get package: %v", err)
+		}
+		return pkg.UpdatedAt
+	}
+
+	first := publish("1.0.0")
+	clk.Advance(time.Hour)
+	second := publish("1.1.0")
+
+	if !second.After(first) {
+		t.Errorf("Expected UpdatedAt to increase after the second publish, got %v then %v", first, second)
+	}
+	if !second.Equal(clk.Now()) {
+		t.Errorf("Expected UpdatedAt %v, got %v", clk.Now(), second)
 	}
 }
 
-func TestPubService_ErrorCases(t *testing.T) {
-	t.Run("GetPackage with no versions", func(t *testing.T) {
-		repos := testutil.SetupTestRepositories(t)
-		defer repos.Close()
+func TestPubService_GetPubspecYAML(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
 
-		svc := NewPubService(PackageDependencies{
-			Package: repos.DB.Repo,
-			Storage: repos.StorageSvc,
-			Pubspec: repos.PubspecSvc,
-			BaseURL: "http://localhost:8080",
-		})
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
 
-		ctx := context.Background()
+	ctx := context.Background()
 
-		// Create package without versions
-		_, err := repos.DB.CreateTestPackage(ctx, "testpkg", false)
+	pkg, err := repos.DB.CreateTestPackage(ctx, "testpkg", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	pubspecYAML := "name: testpkg\nversion: 1.0.0\n# keep this comment\n"
+	_, err = repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+		Version:     "1.0.0",
+		PubspecYaml: pubspecYAML,
+		ArchivePath: "/storage/testpkg/1.0.0/testpkg-1.0.0.tar.gz",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	t.Run("existing version", func(t *testing.T) {
+		result, err := svc.GetPubspecYAML(ctx, "testpkg", "1.0.0")
 		if err != nil {
-			t.Fatalf("Failed to create package: %v", err)
+			t.Fatalf("GetPubspecYAML failed: %v", err)
 		}
+		if result == nil || *result != pubspecYAML {
+			t.Errorf("Expected raw pubspec %q, got %v", pubspecYAML, result)
+		}
+	})
 
-		// Should return error when no versions exist
-		_, err = svc.GetPackage(ctx, "testpkg")
-		if err == nil {
-			t.Error("Expected error for package with no versions, got nil")
+	t.Run("missing version", func(t *testing.T) {
+		result, err := svc.GetPubspecYAML(ctx, "testpkg", "2.0.0")
+		if err != nil {
+			t.Fatalf("GetPubspecYAML failed: %v", err)
+		}
+		if result != nil {
+			t.Error("Expected nil for non-existent version")
 		}
 	})
-}
 
-// Helper function
-func stringPtr(s string) *string {
-	return &s
+	t.Run("missing package", func(t *testing.T) {
+		result, err := svc.GetPubspecYAML(ctx, "nonexistent", "1.0.0")
+		if err != nil {
+			t.Fatalf("GetPubspecYAML failed: %v", err)
+		}
+		if result != nil {
+			t.Error("Expected nil for non-existent package")
+		}
+	})
 }
 
-func TestExtractPubspecFromArchive(t *testing.T) {
-	// Create tar archive from quill testdata
-	archiveData := createArchiveFromQuillTestData(t)
+func TestPubService_PublishPackage_Platforms(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
 
-	// Use the internal service method to extract pubspec content
-	svc := &packageService{}
-	pubspecContent, _, _, err := svc.extractFilesFromArchive(archiveData)
-	if err != nil {
-		t.Fatalf("Failed to extract pubspec: %v", err)
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	ctx := context.Background()
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": `name: my_plugin
+version: 1.0.0
+flutter:
+  plugin:
+    platforms:
+      ios:
+        pluginClass: MyPlugin
+      android:
+        package: com.example.my_plugin
+        pluginClass: MyPlugin`,
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
 	}
 
-	// Verify the extracted pubspec content is from the root quill package
-	if !strings.Contains(pubspecContent, "name: quill") {
-		t.Errorf("Expected pubspec content to contain 'name: quill', got: %s", pubspecContent)
+	version, err := svc.GetPackageVersion(ctx, "my_plugin", "1.0.0")
+	if err != nil {
+		t.Fatalf("GetPackageVersion failed: %v", err)
+	}
+	if !slices.Equal(version.Platforms, []string{"android", "ios"}) {
+		t.Errorf("Expected platforms [android ios], got %v", version.Platforms)
 	}
 
-	if !strings.Contains(pubspecContent, "version: 1.0.0") {
-		t.Errorf("Expected pubspec content to contain 'version: 1.0.0', got: %s", pubspecContent)
+	detail, err := svc.GetPackageDetail(ctx, "my_plugin")
+	if err != nil {
+		t.Fatalf("GetPackageDetail failed: %v", err)
 	}
+	if !slices.Equal(detail.Latest.Platforms, []string{"android", "ios"}) {
+		t.Errorf("Expected latest platforms [android ios], got %v", detail.Latest.Platforms)
+	}
+}
 
-	if !strings.Contains(pubspecContent, "A rich text editor for Flutter") {
-		t.Errorf("Expected pubspec content to contain root description, got: %s", pubspecContent)
+func TestPubService_Screenshots(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	ctx := context.Background()
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": `name: pictured
+version: 1.0.0
+funding:
+  - https://github.com/sponsors/dev
+screenshots:
+  - description: Home
+    path: screenshots/home.png
+  - description: Missing from the archive
+    path: screenshots/missing.png
+  - description: Not an accepted image format
+    path: screenshots/logo.svg
+  - description: Outside the package
+    path: ../secret.png`,
+		"screenshots/home.png": "fake png bytes",
+		"screenshots/logo.svg": "<svg></svg>",
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
 	}
 
-	// Verify it's NOT from the nested packages
-	if strings.Contains(pubspecContent, "name: quill_extension") {
-		t.Error("Pubspec content should NOT be from quill_extension package")
+	detail, err := svc.GetPackageDetail(ctx, "pictured")
+	if err != nil {
+		t.Fatalf("GetPackageDetail failed: %v", err)
+	}
+	if !slices.Equal(detail.Latest.Funding, []string{"https://github.com/sponsors/dev"}) {
+		t.Errorf("Expected funding to be stored, got %v", detail.Latest.Funding)
+	}
+	expected := []domain.Screenshot{{Description: "Home", Path: "screenshots/home.png"}}
+	if !slices.Equal(detail.Latest.Screenshots, expected) {
+		t.Errorf("Expected only the screenshot in the archive to be kept, got %v", detail.Latest.Screenshots)
 	}
 
-	if strings.Contains(pubspecContent, "name: quill_test_fixtures") {
-		t.Error("Pubspec content should NOT be from quill_test_fixtures package")
+	screenshot, err := svc.GetScreenshot(ctx, "pictured", "1.0.0", "screenshots/home.png")
+	if err != nil {
+		t.Fatalf("GetScreenshot failed: %v", err)
+	}
+	if screenshot.ContentType != "image/png" || string(screenshot.Data) != "fake png bytes" {
+		t.Errorf("Unexpected screenshot %s %q", screenshot.ContentType, screenshot.Data)
 	}
 
-	if strings.Contains(pubspecContent, "version: 2.0.0") {
-		t.Error("Pubspec content should NOT contain version 2.0.0 from extension package")
+	// Only declared screenshots are served, not other files of the archive
+	for _, path := range []string{"screenshots/logo.svg", "pubspec.yaml", "screenshots/missing.png"} {
+		if _, err := svc.GetScreenshot(ctx, "pictured", "1.0.0", path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for %s, got %v", path, err)
+		}
 	}
+}
 
-	t.Logf("Extracted pubspec content:\n%s", pubspecContent)
+func TestExtractArchive_Files(t *testing.T) {
+	for name, prefix := range map[string]string{"root": "", "package directory": "files-1.0.0/"} {
+		t.Run(name, func(t *testing.T) {
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+				prefix + "pubspec.yaml":          "name: files\nversion: 1.0.0",
+				prefix + "screenshots/small.png": "small",
+				prefix + "screenshots/large.png": strings.Repeat("x", 100),
+			})
+
+			extracted, err := extractArchive(archive, nil, &archiveFiles{paths: []string{"./screenshots/small.png", "screenshots/large.png", "absent.png"}, maxBytes: 10})
+			if err != nil {
+				t.Fatalf("extractArchive failed: %v", err)
+			}
+			if len(extracted.files) != 1 || string(extracted.files["screenshots/small.png"]) != "small" {
+				t.Errorf("Expected only the small file, got %v", extracted.files)
+			}
+			if extracted.pubspec == "" {
+				t.Error("Expected the pubspec to be read in the same pass")
+			}
+		})
+	}
 }
 
-// createArchiveFromQuillTestData creates a tar.gz from the quill testdata directory
-func createArchiveFromQuillTestData(t *testing.T) []byte {
-	testdataPath := "testdata/quill"
+func TestPubService_VersionSize(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
 
-	var buf bytes.Buffer
-	gzWriter := gzip.NewWriter(&buf)
-	tarWriter := tar.NewWriter(gzWriter)
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
 
-	// Walk the quill directory
-	err := filepath.Walk(testdataPath, func(filePath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	ctx := context.Background()
 
-		if info.IsDir() {
-			return nil
+	t.Run("published version reports its archive size", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: sized_package\nversion: 1.0.0",
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
 		}
 
-		// Get relative path from quill root
-		relPath, err := filepath.Rel("testdata", filePath)
+		version, err := svc.GetPackageVersion(ctx, "sized_package", "1.0.0")
 		if err != nil {
-			return err
+			t.Fatalf("GetPackageVersion failed: %v", err)
 		}
-
+		if version.SizeBytes != int64(len(archive)) {
+			t.Errorf("Expected size %d, got %d", len(archive), version.SizeBytes)
+		}
+	})
+
+	t.Run("versions without a recorded size are backfilled out of band", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: legacy_package\nversion: 1.0.0",
+		})
+		archivePath, err := repos.StorageSvc.Store(ctx, "legacy_package", "1.0.0", archive)
+		if err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+
+		pkg, err := repos.DB.CreateTestPackage(ctx, "legacy_package", false)
+		if err != nil {
+			t.Fatalf("CreateTestPackage failed: %v", err)
+		}
+		if _, err := repos.DB.CreateTestPackageVersion(ctx, pkg.ID, testutil.CreateVersionRequest{
+			Version:     "1.0.0",
+			PubspecYaml: "name: legacy_package\nversion: 1.0.0",
+			ArchivePath: archivePath,
+		}); err != nil {
+			t.Fatalf("CreateTestPackageVersion failed: %v", err)
+		}
+
+		// Reads don't write the missing size
+		detail, err := svc.GetPackageDetail(ctx, "legacy_package")
+		if err != nil {
+			t.Fatalf("GetPackageDetail failed: %v", err)
+		}
+		if detail.Latest.SizeBytes != nil {
+			t.Errorf("Expected no size before the backfill, got %d", *detail.Latest.SizeBytes)
+		}
+
+		recorded, err := svc.BackfillSizes(ctx)
+		if err != nil {
+			t.Fatalf("BackfillSizes failed: %v", err)
+		}
+		if recorded != 1 {
+			t.Errorf("Expected 1 size to be backfilled, got %d", recorded)
+		}
+		versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID)
+		if err != nil {
+			t.Fatalf("GetPackageVersions failed: %v", err)
+		}
+		if versions[0].SizeBytes == nil || *versions[0].SizeBytes != int64(len(archive)) {
+			t.Errorf("Expected stored size %d, got %v", len(archive), versions[0].SizeBytes)
+		}
+	})
+}
+
+func TestPubService_PrivatePackages(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	anonymous := context.Background()
+	authenticated := auth.SetAuthenticated(context.Background(), true)
+
+	for _, name := range []string{"public_package", "private_package"} {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+		if _, err := svc.PublishPackage(authenticated, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+	}
+
+	if _, err := svc.SetPackagePrivate(authenticated, "private_package", true, domain.Actor{Uploader: "mallory@example.com"}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected ErrUnauthorized for a non-uploader, got %v", err)
+	}
+
+	privacy, err := svc.SetPackagePrivate(authenticated, "private_package", true, domain.Actor{Uploader: "test@example.com"})
+	if err != nil {
+		t.Fatalf("SetPackagePrivate failed: %v", err)
+	}
+	if privacy == nil || !privacy.Private {
+		t.Fatalf("Expected package to be private, got %+v", privacy)
+	}
+
+	t.Run("anonymous access is hidden", func(t *testing.T) {
+		pkg, err := svc.GetPackage(anonymous, "private_package")
+		if err != nil || pkg != nil {
+			t.Errorf("Expected nil package without error, got %v, %v", pkg, err)
+		}
+
+		if _, err := svc.DownloadPackage(anonymous, "private_package", "1.0.0"); err == nil {
+			t.Error("Expected download of private package to fail anonymously")
+		}
+
+		if _, err := svc.GetPackage(anonymous, "public_package"); err != nil {
+			t.Errorf("Expected public package to stay readable, got %v", err)
+		}
+	})
+
+	t.Run("authenticated access is allowed", func(t *testing.T) {
+		pkg, err := svc.GetPackage(authenticated, "private_package")
+		if err != nil || pkg == nil {
+			t.Fatalf("Expected private package, got %v, %v", pkg, err)
+		}
+
+		if _, err := svc.DownloadPackage(authenticated, "private_package", "1.0.0"); err != nil {
+			t.Errorf("DownloadPackage failed: %v", err)
+		}
+	})
+
+	t.Run("missing package", func(t *testing.T) {
+		privacy, err := svc.SetPackagePrivate(authenticated, "nonexistent", true, domain.Actor{Admin: true})
+		if err != nil || privacy != nil {
+			t.Errorf("Expected nil response without error, got %v, %v", privacy, err)
+		}
+	})
+}
+
+func TestPubService_DownloadMetrics(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+		Clock:   clock.NewFake(time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)),
+	})
+
+	ctx := context.Background()
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	for range 2 {
+		if _, err := svc.DownloadPackage(ctx, "test_package", "1.0.0"); err != nil {
+			t.Fatalf("DownloadPackage failed: %v", err)
+		}
+	}
+
+	metrics, err := svc.GetDownloadMetrics(ctx, "test_package", 7)
+	if err != nil {
+		t.Fatalf("GetDownloadMetrics failed: %v", err)
+	}
+	if metrics == nil {
+		t.Fatal("Expected metrics, got nil")
+	}
+	if len(metrics.Series) != 7 {
+		t.Fatalf("Expected 7 days in series, got %d", len(metrics.Series))
+	}
+
+	// Both downloads happened today, which is the last entry of the series
+	todayEntry := metrics.Series[len(metrics.Series)-1]
+	if todayEntry.Date != "2024-03-15" {
+		t.Errorf("Expected last entry to be today, got %s", todayEntry.Date)
+	}
+	if todayEntry.Downloads != 2 {
+		t.Errorf("Expected 2 downloads today, got %d", todayEntry.Downloads)
+	}
+	if todayEntry.Versions["1.0.0"] != 2 {
+		t.Errorf("Expected 2 downloads of 1.0.0, got %d", todayEntry.Versions["1.0.0"])
+	}
+	if metrics.Total != 2 {
+		t.Errorf("Expected total 2, got %d", metrics.Total)
+	}
+	for _, day := range metrics.Series[:len(metrics.Series)-1] {
+		if day.Downloads != 0 {
+			t.Errorf("Expected no downloads on %s, got %d", day.Date, day.Downloads)
+		}
+	}
+
+	detail, err := svc.GetPackageDetail(ctx, "test_package")
+	if err != nil {
+		t.Fatalf("GetPackageDetail failed: %v", err)
+	}
+	if detail.VersionDownloads["1.0.0"] != 2 {
+		t.Errorf("Expected 2 downloads of 1.0.0 in the package detail, got %d", detail.VersionDownloads["1.0.0"])
+	}
+
+	t.Run("missing package", func(t *testing.T) {
+		metrics, err := svc.GetDownloadMetrics(ctx, "nonexistent", 7)
+		if err != nil {
+			t.Fatalf("GetDownloadMetrics failed: %v", err)
+		}
+		if metrics != nil {
+			t.Errorf("Expected nil metrics for missing package, got %+v", metrics)
+		}
+	})
+}
+
+func TestPubService_GetAdvisories(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(now)
+	svc := NewPubService(PackageDependencies{Clock: fakeClock})
+
+	advisories, err := svc.GetAdvisories(context.Background(), "test_package")
+	if err != nil {
+		t.Fatalf("GetAdvisories failed: %v", err)
+	}
+	if len(advisories.Advisories) != 0 {
+		t.Errorf("Expected no advisories, got %d", len(advisories.Advisories))
+	}
+	if advisories.AdvisoriesUpdated != "1970-01-01T00:00:00Z" {
+		t.Errorf("Expected advisoriesUpdated 1970-01-01T00:00:00Z without advisories, got %s", advisories.AdvisoriesUpdated)
+	}
+
+	// The timestamp doesn't follow the clock, or clients would fetch the
+	// advisories again on every resolution
+	fakeClock.Advance(time.Hour)
+	advisories, err = svc.GetAdvisories(context.Background(), "test_package")
+	if err != nil {
+		t.Fatalf("GetAdvisories failed: %v", err)
+	}
+	if advisories.AdvisoriesUpdated != "1970-01-01T00:00:00Z" {
+		t.Errorf("Expected a stable advisoriesUpdated, got %s", advisories.AdvisoriesUpdated)
+	}
+}
+
+func TestPubService_PublishPackage_ExpectedPackage(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: actual\nversion: 1.0.0"})
+
+	_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice", ExpectedPackage: "declared"})
+	if !errors.Is(err, ErrPackageMismatch) {
+		t.Fatalf("Expected ErrPackageMismatch, got %v", err)
+	}
+
+	if _, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice", ExpectedPackage: "actual"}); err != nil {
+		t.Fatalf("Expected matching package to publish, got %v", err)
+	}
+
+	name, err := ArchivePackageName(archive)
+	if err != nil || name != "actual" {
+		t.Errorf("ArchivePackageName() = %q, %v; want actual", name, err)
+	}
+}
+
+func TestPubService_AllowedSDKs(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		pubspec     string
+		expectError bool
+	}{
+		{
+			name:    "dart package on a dart-only registry",
+			allowed: []string{"dart"},
+			pubspec: "name: pure\nversion: 1.0.0\nenvironment:\n  sdk: ^3.0.0\ndependencies:\n  path: ^1.8.0",
+		},
+		{
+			name:        "flutter environment on a dart-only registry",
+			allowed:     []string{"dart"},
+			pubspec:     "name: widgets\nversion: 1.0.0\nenvironment:\n  sdk: ^3.0.0\n  flutter: \">=3.10.0\"",
+			expectError: true,
+		},
+		{
+			name:        "flutter sdk dependency on a dart-only registry",
+			allowed:     []string{"dart"},
+			pubspec:     "name: plugin\nversion: 1.0.0\ndependencies:\n  flutter:\n    sdk: flutter",
+			expectError: true,
+		},
+		{
+			name:    "flutter_test dev dependency stays a dart package",
+			allowed: []string{"dart"},
+			pubspec: "name: tested\nversion: 1.0.0\ndev_dependencies:\n  flutter_test:\n    sdk: flutter",
+		},
+		{
+			name:        "dart package on a flutter-only registry",
+			allowed:     []string{"Flutter"},
+			pubspec:     "name: pure\nversion: 1.0.0",
+			expectError: true,
+		},
+		{
+			name:    "no restriction",
+			pubspec: "name: plugin\nversion: 1.0.0\ndependencies:\n  flutter:\n    sdk: flutter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package:     repos.DB.Repo,
+				Storage:     repos.StorageSvc,
+				Pubspec:     repos.PubspecSvc,
+				BaseURL:     "http://localhost:8080",
+				AllowedSDKs: tt.allowed,
+			})
+
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": tt.pubspec})
+			_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice"})
+			if tt.expectError {
+				if !errors.Is(err, ErrSDKNotAllowed) {
+					t.Fatalf("Expected ErrSDKNotAllowed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected publish to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPubService_EnforcePublishTo(t *testing.T) {
+	tests := []struct {
+		name        string
+		enforce     bool
+		publishTo   string
+		expectError bool
+	}{
+		{name: "matching", enforce: true, publishTo: "http://localhost:8080"},
+		{name: "matching with a trailing slash", enforce: true, publishTo: "HTTP://localhost:8080/"},
+		{name: "another registry", enforce: true, publishTo: "https://pub.dev", expectError: true},
+		{name: "none", enforce: true, publishTo: "none", expectError: true},
+		{name: "absent", enforce: true, expectError: true},
+		{name: "absent without enforcement"},
+		{name: "another registry without enforcement", publishTo: "https://pub.dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package:          repos.DB.Repo,
+				Storage:          repos.StorageSvc,
+				Pubspec:          repos.PubspecSvc,
+				BaseURL:          "http://localhost:8080",
+				EnforcePublishTo: tt.enforce,
+			})
+
+			pubspec := "name: targeted\nversion: 1.0.0"
+			if tt.publishTo != "" {
+				pubspec += "\npublish_to: " + tt.publishTo
+			}
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": pubspec})
+			_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice"})
+			if tt.expectError {
+				if !errors.Is(err, ErrPublishToMismatch) {
+					t.Fatalf("Expected ErrPublishToMismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected publish to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPubService_PublishPackage_ArchiveDirectoryVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		expectError error
+	}{
+		{
+			name:        "directory for another version",
+			files:       map[string]string{"stale-1.0.0/pubspec.yaml": "name: stale\nversion: 1.1.0"},
+			expectError: ErrArchiveInvalid,
+		},
+		{
+			name:  "directory for the pubspec version",
+			files: map[string]string{"stale-1.1.0/pubspec.yaml": "name: stale\nversion: 1.1.0"},
+		},
+		{
+			name:  "pre-release directory",
+			files: map[string]string{"stale-2.0.0-dev.1/pubspec.yaml": "name: stale\nversion: 2.0.0-dev.1"},
+		},
+		{
+			// Only a name-version directory is the package root
+			name:        "directory without a version",
+			files:       map[string]string{"package/pubspec.yaml": "name: stale\nversion: 1.1.0"},
+			expectError: ErrPubspecInvalid,
+		},
+		{
+			name: "root and package directory pubspecs",
+			files: map[string]string{
+				"pubspec.yaml":             "name: stale\nversion: 1.1.0",
+				"stale-1.0.0/pubspec.yaml": "name: stale\nversion: 1.0.0",
+			},
+			expectError: ErrPubspecInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package: repos.DB.Repo,
+				Storage: repos.StorageSvc,
+				Pubspec: repos.PubspecSvc,
+				BaseURL: "http://localhost:8080",
+			})
+
+			archive := testutil.CreateTestTarGzArchive(t, tt.files)
+			_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice"})
+			if tt.expectError == nil {
+				if err != nil {
+					t.Fatalf("Expected publish to succeed, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("Expected %v, got %v", tt.expectError, err)
+			}
+			if tt.expectError == ErrArchiveInvalid && !strings.Contains(err.Error(), "stale-1.0.0 doesn't match version 1.1.0") {
+				t.Errorf("Expected a version mismatch error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPubService_ArchiveLimits(t *testing.T) {
+	pubspec := "name: limited\nversion: 1.0.0"
+	tests := []struct {
+		name        string
+		limits      ArchiveLimits
+		files       map[string]string
+		expectError string
+	}{
+		{
+			name:        "no dart files when dart code is required",
+			limits:      ArchiveLimits{RequireDartCode: true},
+			files:       map[string]string{"pubspec.yaml": pubspec, "README.md": "docs only"},
+			expectError: "no lib/ directory or .dart files",
+		},
+		{
+			name:   "dart files when dart code is required",
+			limits: ArchiveLimits{RequireDartCode: true},
+			files:  map[string]string{"pubspec.yaml": pubspec, "lib/limited.dart": "void main() {}"},
+		},
+		{
+			name:   "no dart files without the check",
+			limits: ArchiveLimits{},
+			files:  map[string]string{"pubspec.yaml": pubspec, "README.md": "docs only"},
+		},
+		{
+			name:        "too many files",
+			limits:      ArchiveLimits{MaxFiles: 2},
+			files:       map[string]string{"pubspec.yaml": pubspec, "lib/a.dart": "", "lib/b.dart": ""},
+			expectError: "contains 3 files, the limit is 2",
+		},
+		{
+			name:        "file too large",
+			limits:      ArchiveLimits{MaxFileBytes: 64},
+			files:       map[string]string{"pubspec.yaml": pubspec, "lib/big.dart": strings.Repeat("x", 65)},
+			expectError: "lib/big.dart is 65 bytes, the limit is 64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package:       repos.DB.Repo,
+				Storage:       repos.StorageSvc,
+				Pubspec:       repos.PubspecSvc,
+				BaseURL:       "http://localhost:8080",
+				ArchiveLimits: tt.limits,
+			})
+
+			archive := testutil.CreateTestTarGzArchive(t, tt.files)
+			_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "alice"})
+			if tt.expectError == "" {
+				if err != nil {
+					t.Fatalf("Expected publish to succeed, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrArchiveInvalid) {
+				t.Fatalf("Expected ErrArchiveInvalid, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("Expected error to mention %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestPubService_DocLimits(t *testing.T) {
+	ctx := context.Background()
+	// The multi-byte character straddles the limit, it must not be split
+	readme := strings.Repeat("x", 63) + "é" + strings.Repeat("y", 100)
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: wordy\nversion: 1.0.0",
+		"README.md":    readme,
+		"CHANGELOG.md": "## 1.0.0",
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		svc := NewPubService(PackageDependencies{
+			Package:       repos.DB.Repo,
+			Storage:       repos.StorageSvc,
+			Pubspec:       repos.PubspecSvc,
+			ArchiveLimits: ArchiveLimits{MaxDocBytes: 64},
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "alice"}); err != nil {
+			t.Fatalf("Expected publish to succeed, got %v", err)
+		}
+
+		pkg, _ := repos.DB.Repo.GetPackage(ctx, "wordy")
+		version, err := repos.DB.Repo.GetLatestVersion(ctx, pkg.ID)
+		if err != nil {
+			t.Fatalf("Failed to get version: %v", err)
+		}
+		expected := strings.Repeat("x", 63) + "\n\n*The README was truncated, it exceeds the 64 byte limit of this registry.*\n"
+		if version.Readme == nil || *version.Readme != expected {
+			t.Errorf("Expected the truncated README %q, got %v", expected, version.Readme)
+		}
+		if version.Changelog == nil || *version.Changelog != "## 1.0.0" {
+			t.Errorf("Expected the CHANGELOG within the limit to be kept, got %v", version.Changelog)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		svc := NewPubService(PackageDependencies{
+			Package:       repos.DB.Repo,
+			Storage:       repos.StorageSvc,
+			Pubspec:       repos.PubspecSvc,
+			ArchiveLimits: ArchiveLimits{MaxDocBytes: 64, RejectLargeDocs: true},
+		})
+		_, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "alice"})
+		if !errors.Is(err, ErrArchiveInvalid) || !strings.Contains(err.Error(), "README is 165 bytes, the limit is 64") {
+			t.Errorf("Expected the README to be rejected, got %v", err)
+		}
+		if pkg, _ := repos.DB.Repo.GetPackage(ctx, "wordy"); pkg != nil {
+			t.Error("Expected nothing to be stored for a rejected archive")
+		}
+	})
+}
+
+func TestPubService_UploaderFromAuthor(t *testing.T) {
+	tests := []struct {
+		name             string
+		fromAuthor       bool
+		pubspec          string
+		expectedUploader string
+	}{
+		{
+			name:             "author with display name",
+			fromAuthor:       true,
+			pubspec:          "name: authored\nversion: 1.0.0\nauthor: Jane Doe <Jane@Example.com>",
+			expectedUploader: "jane@example.com",
+		},
+		{
+			name:             "first valid entry of authors",
+			fromAuthor:       true,
+			pubspec:          "name: authored\nversion: 1.0.0\nauthors:\n  - The Team\n  - dev@example.com",
+			expectedUploader: "dev@example.com",
+		},
+		{
+			name:             "author without an email falls back to the token",
+			fromAuthor:       true,
+			pubspec:          "name: authored\nversion: 1.0.0\nauthor: Jane Doe",
+			expectedUploader: "alice",
+		},
+		{
+			name:             "missing author falls back to the token",
+			fromAuthor:       true,
+			pubspec:          "name: authored\nversion: 1.0.0",
+			expectedUploader: "alice",
+		},
+		{
+			name:             "author ignored when disabled",
+			pubspec:          "name: authored\nversion: 1.0.0\nauthor: jane@example.com",
+			expectedUploader: "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package:            repos.DB.Repo,
+				Storage:            repos.StorageSvc,
+				Pubspec:            repos.PubspecSvc,
+				BaseURL:            "http://localhost:8080",
+				UploaderFromAuthor: tt.fromAuthor,
+			})
+
+			ctx := context.Background()
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": tt.pubspec})
+			if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "alice"}); err != nil {
+				t.Fatalf("PublishPackage failed: %v", err)
+			}
+
+			detail, err := svc.GetPackageDetail(ctx, "authored")
+			if err != nil {
+				t.Fatalf("GetPackageDetail failed: %v", err)
+			}
+			if got := stringValue(detail.Latest.Uploader); got != tt.expectedUploader {
+				t.Errorf("Expected uploader %s, got %s", tt.expectedUploader, got)
+			}
+
+			uploaders, err := repos.DB.Repo.GetUploaders(ctx, detail.Package.ID)
+			if err != nil {
+				t.Fatalf("GetUploaders failed: %v", err)
+			}
+			// The package is owned by the token, not the pubspec author
+			if len(uploaders) != 1 || uploaders[0] != "alice" {
+				t.Errorf("Expected uploaders [alice], got %v", uploaders)
+			}
+		})
+	}
+
+	t.Run("authors don't authorize other tokens", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		svc := NewPubService(PackageDependencies{
+			Package:            repos.DB.Repo,
+			Storage:            repos.StorageSvc,
+			Pubspec:            repos.PubspecSvc,
+			BaseURL:            "http://localhost:8080",
+			UploaderFromAuthor: true,
+		})
+
+		ctx := context.Background()
+		first := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: authored\nversion: 1.0.0\nauthor: jane@example.com"})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: first, Uploader: "alice"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+
+		// Naming the owner's email in the pubspec doesn't make mallory an uploader
+		second := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: authored\nversion: 1.1.0\nauthor: jane@example.com"})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: second, Uploader: "mallory"}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Expected ErrUnauthorized, got %v", err)
+		}
+		if err := svc.PreflightPublish(ctx, &domain.PublishPreflight{Package: "authored", Uploader: "mallory"}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Expected preflight to reject mallory, got %v", err)
+		}
+
+		// The owner's token may publish under another author
+		third := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: authored\nversion: 1.2.0\nauthor: bob@example.com"})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: third, Uploader: "alice"}); err != nil {
+			t.Errorf("Expected the owner's publish to succeed, got %v", err)
+		}
+	})
+}
+
+func TestPubService_ReservedPackageNames(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package:              repos.DB.Repo,
+		Storage:              repos.StorageSvc,
+		Pubspec:              repos.PubspecSvc,
+		BaseURL:              "http://localhost:8080",
+		ReservedPackageNames: []string{"flutter"},
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: flutter\nversion: 1.0.0",
+	})
+	_, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"})
+	if !errors.Is(err, ErrPackageReserved) {
+		t.Errorf("Expected ErrPackageReserved, got %v", err)
+	}
+
+	// A reserved name is reported together with the pubspec's problems
+	archive = testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: flutter\nversion: 1.0",
+	})
+	_, err = svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"})
+	var invalid *pubspec.ValidationError
+	if !errors.Is(err, ErrPubspecInvalid) || !errors.As(err, &invalid) {
+		t.Fatalf("Expected a pubspec validation error, got %v", err)
+	}
+	expected := []string{"invalid version format: 1.0", "package name is reserved: flutter"}
+	if !slices.Equal(invalid.Problems, expected) {
+		t.Errorf("Expected problems %q, got %q", expected, invalid.Problems)
+	}
+}
+
+func TestPubService_BlockedPackageNames(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	// Published before the name was blocked
+	if _, err := repos.DB.CreateTestPackage(ctx, "evil_legacy", false); err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+
+	svc := NewPubService(PackageDependencies{
+		Package:             repos.DB.Repo,
+		Storage:             repos.StorageSvc,
+		Pubspec:             repos.PubspecSvc,
+		BaseURL:             "http://localhost:8080",
+		BlockedPackageNames: []string{"acme", "evil_*"},
+	})
+
+	tests := []struct {
+		name    string
+		pkg     string
+		wantErr error
+	}{
+		{"exact name", "acme", ErrPackageBlocked},
+		{"glob match", "evil_http", ErrPackageBlocked},
+		{"existing package", "evil_legacy", nil},
+		{"no match", "acme_http", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+				"pubspec.yaml": "name: " + tt.pkg + "\nversion: 1.0.0",
+			})
+			_, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.pkg) {
+					t.Errorf("Expected the error to name the package, got %v", err)
+				}
+				// Nothing is created for a blocked name
+				if pkg, _ := repos.DB.Repo.GetPackage(ctx, tt.pkg); pkg != nil {
+					t.Errorf("Expected no package row for %s", tt.pkg)
+				}
+			}
+		})
+	}
+}
+
+// barrierRepository holds GetOrCreatePackage calls until all expected callers
+// have arrived, so that concurrent publishes really race on package creation
+type barrierRepository struct {
+	pkg.Repository
+	arrived *sync.WaitGroup
+}
+
+func (r *barrierRepository) GetOrCreatePackage(ctx context.Context, name string, private bool) (*domain.Package, error) {
+	r.arrived.Done()
+	r.arrived.Wait()
+	return r.Repository.GetOrCreatePackage(ctx, name, private)
+}
+
+func TestPubService_ConcurrentFirstPublish(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	const publishers = 2
+	arrived := &sync.WaitGroup{}
+	arrived.Add(publishers)
+
+	svc := NewPubService(PackageDependencies{
+		Package: &barrierRepository{Repository: repos.DB.Repo, arrived: arrived},
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	errs := make([]error, publishers)
+	var done sync.WaitGroup
+	for i := range publishers {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+				"pubspec.yaml": fmt.Sprintf("name: racy_package\nversion: 1.0.%d", i),
+			})
+			_, errs[i] = svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"})
+		}()
+	}
+	done.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Publish %d failed: %v", i, err)
+		}
+	}
+
+	packages, err := repos.DB.Repo.ListPackages(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("ListPackages failed: %v", err)
+	}
+	if len(packages) != 1 {
+		t.Fatalf("Expected exactly one package row, got %d", len(packages))
+	}
+
+	versions, err := repos.DB.Repo.GetPackageVersions(context.Background(), packages[0].ID)
+	if err != nil {
+		t.Fatalf("GetPackageVersions failed: %v", err)
+	}
+	if len(versions) != publishers {
+		t.Errorf("Expected %d versions, got %d", publishers, len(versions))
+	}
+}
+
+// orderedCreateRepository holds CreateVersion calls until all expected
+// callers have arrived, so that concurrent publishes have all stored their
+// archives before any version row exists, then lets them through in arrival
+// order: the first publish to store its archive records the version
+type orderedCreateRepository struct {
+	pkg.Repository
+
+	mu       sync.Mutex
+	arrivals int
+	turns    []chan struct{}
+}
+
+func newOrderedCreateRepository(repo pkg.Repository, callers int) *orderedCreateRepository {
+	r := &orderedCreateRepository{Repository: repo, turns: make([]chan struct{}, callers)}
+	for i := range r.turns {
+		r.turns[i] = make(chan struct{})
+	}
+	return r
+}
+
+func (r *orderedCreateRepository) CreateVersion(ctx context.Context, version *domain.PackageVersion) (*domain.PackageVersion, error) {
+	r.mu.Lock()
+	turn := r.arrivals
+	r.arrivals++
+	r.mu.Unlock()
+	if turn == len(r.turns)-1 {
+		close(r.turns[0])
+	}
+
+	<-r.turns[turn]
+	created, err := r.Repository.CreateVersion(ctx, version)
+	if turn+1 < len(r.turns) {
+		close(r.turns[turn+1])
+	}
+	return created, err
+}
+
+// uploadersBarrierRepository holds the first GetUploaders calls until all
+// expected callers have made one, so concurrent first publishes all see the
+// package without uploaders before any of them claims it
+type uploadersBarrierRepository struct {
+	pkg.Repository
+
+	mu      sync.Mutex
+	waiting int
+	release chan struct{}
+}
+
+func newUploadersBarrierRepository(repo pkg.Repository, callers int) *uploadersBarrierRepository {
+	return &uploadersBarrierRepository{Repository: repo, waiting: callers, release: make(chan struct{})}
+}
+
+func (r *uploadersBarrierRepository) GetUploaders(ctx context.Context, packageID int32) ([]string, error) {
+	uploaders, err := r.Repository.GetUploaders(ctx, packageID)
+
+	r.mu.Lock()
+	if r.waiting == 0 {
+		r.mu.Unlock()
+		return uploaders, err
+	}
+	r.waiting--
+	if r.waiting == 0 {
+		close(r.release)
+	}
+	r.mu.Unlock()
+
+	<-r.release
+	return uploaders, err
+}
+
+func TestPubService_ConcurrentClaim(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	uploaders := []string{"alice@example.com", "mallory@example.com"}
+	svc := NewPubService(PackageDependencies{
+		Package: newUploadersBarrierRepository(repos.DB.Repo, len(uploaders)),
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	errs := make([]error, len(uploaders))
+	var done sync.WaitGroup
+	for i, uploader := range uploaders {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": fmt.Sprintf("name: claimed_package\nversion: 1.0.%d", i),
+		})
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			_, errs[i] = svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: uploader})
+		}()
+	}
+	done.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrUnauthorized):
+			t.Errorf("Expected publish by %s to fail with ErrUnauthorized, got %v", uploaders[i], err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("Expected exactly one first publish to succeed, got %d: %v", succeeded, errs)
+	}
+
+	pkg, err := repos.DB.Repo.GetPackage(ctx, "claimed_package")
+	if err != nil || pkg == nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	owners, err := repos.DB.Repo.GetUploaders(ctx, pkg.ID)
+	if err != nil {
+		t.Fatalf("GetUploaders failed: %v", err)
+	}
+	if len(owners) != 1 {
+		t.Errorf("Expected the package to have one owner, got %v", owners)
+	}
+}
+
+func TestPubService_ConcurrentSameVersionPublish(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	const publishers = 2
+	svc := NewPubService(PackageDependencies{
+		Package: newOrderedCreateRepository(repos.DB.Repo, publishers),
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+	// Different archives of the same version, so an overwritten archive
+	// doesn't match the checksum recorded by the winner
+	archives := make([][]byte, publishers)
+	for i := range archives {
+		archives[i] = testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": fmt.Sprintf("name: twice_raced\nversion: 1.0.0\ndescription: Publish %d", i),
+		})
+	}
+
+	errs := make([]error, publishers)
+	var done sync.WaitGroup
+	for i := range publishers {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			_, errs[i] = svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archives[i], Uploader: "test@example.com"})
+		}()
+	}
+	done.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrVersionExists):
+			t.Errorf("Expected publish %d to fail with ErrVersionExists, got %v", i, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("Expected exactly one publish to succeed, got %d: %v", succeeded, errs)
+	}
+
+	// The losing publish leaves the winner's archive in place
+	pkg, err := repos.DB.Repo.GetPackage(ctx, "twice_raced")
+	if err != nil || pkg == nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	version, err := repos.DB.Repo.GetLatestVersion(ctx, pkg.ID)
+	if err != nil {
+		t.Fatalf("GetLatestVersion failed: %v", err)
+	}
+	data, err := svc.DownloadPackage(ctx, "twice_raced", "1.0.0")
+	if err != nil {
+		t.Fatalf("DownloadPackage failed: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != *version.ArchiveSha256 {
+		t.Error("Expected the stored archive to match the recorded sha256")
+	}
+
+	// Only the committed archive is left
+	paths, err := repos.StorageSvc.List(ctx, "twice_raced/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != version.ArchivePath {
+		t.Errorf("Expected only %s in storage, got %v", version.ArchivePath, paths)
+	}
+}
+
+// conflictingRepository hides existing versions from the publish checks, as
+// if a concurrent publish created the version right after they ran
+type conflictingRepository struct {
+	pkg.Repository
+}
+
+func (r *conflictingRepository) GetPackageVersions(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error) {
+	return nil, nil
+}
+
+func TestPubService_PublishPackage_VersionConflict(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: conflicted\nversion: 1.0.0",
+	})
+	first := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+	if _, err := first.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	// The unique constraint catches what the checks missed
+	late := NewPubService(PackageDependencies{
+		Package: &conflictingRepository{Repository: repos.DB.Repo},
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+	if _, err := late.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); !errors.Is(err, ErrVersionExists) {
+		t.Fatalf("Expected ErrVersionExists, got %v", err)
+	}
+
+	if _, err := first.DownloadPackage(ctx, "conflicted", "1.0.0"); err != nil {
+		t.Errorf("Expected the first publish's archive to be kept, got %v", err)
+	}
+}
+
+// failingRepository fails the named write, simulating a database error
+// partway through a publish
+type failingRepository struct {
+	pkg.Repository
+	fail string
+}
+
+var errInjected = errors.New("injected failure")
+
+func (r *failingRepository) CreateVersion(ctx context.Context, version *domain.PackageVersion) (*domain.PackageVersion, error) {
+	if r.fail == "CreateVersion" {
+		return nil, errInjected
+	}
+	return r.Repository.CreateVersion(ctx, version)
+}
+
+func (r *failingRepository) TouchPackage(ctx context.Context, packageID int32, at time.Time) error {
+	if r.fail == "TouchPackage" {
+		return errInjected
+	}
+	return r.Repository.TouchPackage(ctx, packageID, at)
+}
+
+func TestPubService_PublishPackage_Rollback(t *testing.T) {
+	for _, step := range []string{"CreateVersion", "TouchPackage"} {
+		t.Run(step, func(t *testing.T) {
+			repos := testutil.SetupTestRepositories(t)
+			defer repos.Close()
+
+			svc := NewPubService(PackageDependencies{
+				Package: &failingRepository{Repository: repos.DB.Repo, fail: step},
+				Storage: repos.StorageSvc,
+				Pubspec: repos.PubspecSvc,
+			})
+			ctx := context.Background()
+
+			archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+				"pubspec.yaml": "name: rolled_back\nversion: 1.0.0",
+			})
+			_, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"})
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("Expected the injected failure, got %v", err)
+			}
+
+			// Neither the archive nor the version is left behind
+			paths, err := repos.StorageSvc.List(ctx, "rolled_back")
+			if err != nil || len(paths) != 0 {
+				t.Errorf("Expected the stored archive to be deleted, got %v, %v", paths, err)
+			}
+			pkg, err := repos.DB.Repo.GetPackage(ctx, "rolled_back")
+			if err != nil {
+				t.Fatalf("GetPackage failed: %v", err)
+			}
+			versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID)
+			if err != nil || len(versions) != 0 {
+				t.Errorf("Expected no versions, got %+v, %v", versions, err)
+			}
+
+			// Publishing again succeeds once the database recovers
+			retry := NewPubService(PackageDependencies{
+				Package: repos.DB.Repo,
+				Storage: repos.StorageSvc,
+				Pubspec: repos.PubspecSvc,
+			})
+			if _, err := retry.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+				t.Errorf("Expected the retried publish to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPubService_ScoreAndLikes(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	ctx := context.Background()
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"test_package-1.0.0/pubspec.yaml": "name: test_package\nversion: 1.0.0",
+	})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "test@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+
+	// Likes are idempotent per liker
+	for _, liker := range []string{"alice", "alice", "bob"} {
+		like, err := svc.LikePackage(ctx, "test_package", liker)
+		if err != nil {
+			t.Fatalf("LikePackage failed: %v", err)
+		}
+		if like == nil {
+			t.Fatal("Expected like response, got nil")
+		}
+	}
+
+	if _, err := svc.DownloadPackage(ctx, "test_package", "1.0.0"); err != nil {
+		t.Fatalf("DownloadPackage failed: %v", err)
+	}
+
+	score, err := svc.GetScore(ctx, "test_package")
+	if err != nil {
+		t.Fatalf("GetScore failed: %v", err)
+	}
+	if score == nil {
+		t.Fatal("Expected score, got nil")
+	}
+	if score.LikeCount != 2 {
+		t.Errorf("Expected like count 2, got %d", score.LikeCount)
+	}
+	if score.DownloadCount != 1 {
+		t.Errorf("Expected download count 1, got %d", score.DownloadCount)
+	}
+
+	t.Run("missing package", func(t *testing.T) {
+		score, err := svc.GetScore(ctx, "nonexistent")
+		if err != nil || score != nil {
+			t.Errorf("Expected nil score without error, got %v, %v", score, err)
+		}
+
+		like, err := svc.LikePackage(ctx, "nonexistent", "alice")
+		if err != nil || like != nil {
+			t.Errorf("Expected nil like without error, got %v, %v", like, err)
+		}
+	})
+}
+
+func TestStringValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *string
+		expected string
+	}{
+		{
+			name:     "nil pointer",
+			input:    nil,
+			expected: "",
+		},
+		{
+			name:     "non-nil pointer",
+			input:    stringPtr("test"),
+			expected: "test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := stringValue(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	version := func(v string, retracted bool) *domain.PackageVersion {
+		return &domain.PackageVersion{Version: v, Retracted: retracted}
+	}
+
+	tests := []struct {
+		name     string
+		versions []*domain.PackageVersion
+		expected string
+	}{
+		{
+			name:     "highest by semver, not publish order",
+			versions: []*domain.PackageVersion{version("1.2.0", false), version("1.10.0", false), version("1.9.0", false)},
+			expected: "1.10.0",
+		},
+		{
+			name:     "skips retracted",
+			versions: []*domain.PackageVersion{version("2.0.0", true), version("1.0.0", false)},
+			expected: "1.0.0",
+		},
+		{
+			name:     "prefers stable over newer pre-release",
+			versions: []*domain.PackageVersion{version("2.0.0-dev.1", false), version("1.0.0", false)},
+			expected: "1.0.0",
+		},
+		{
+			name:     "falls back to pre-release",
+			versions: []*domain.PackageVersion{version("1.0.0-beta", false), version("1.0.0-alpha", false), version("0.9.0", true)},
+			expected: "1.0.0-beta",
+		},
+		{
+			name:     "all retracted",
+			versions: []*domain.PackageVersion{version("1.0.0", true)},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := latestVersion(tt.versions)
+			var got string
+			if result != nil {
+				got = result.Version
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPubService_ErrorCases(t *testing.T) {
+	t.Run("GetPackage with no versions", func(t *testing.T) {
+		repos := testutil.SetupTestRepositories(t)
+		defer repos.Close()
+
+		svc := NewPubService(PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			BaseURL: "http://localhost:8080",
+		})
+
+		ctx := context.Background()
+
+		// Create package without versions
+		_, err := repos.DB.CreateTestPackage(ctx, "testpkg", false)
+		if err != nil {
+			t.Fatalf("Failed to create package: %v", err)
+		}
+
+		// Should return error when no versions exist
+		_, err = svc.GetPackage(ctx, "testpkg")
+		if err == nil {
+			t.Error("Expected error for package with no versions, got nil")
+		}
+	})
+}
+
+// Helper function
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestExtractPubspecFromArchive(t *testing.T) {
+	// Create tar archive from quill testdata
+	archiveData := createArchiveFromQuillTestData(t)
+
+	// Use the internal archive helper to extract pubspec content
+	pubspecContent, _, _, err := extractFilesFromArchive(archiveData)
+	if err != nil {
+		t.Fatalf("Failed to extract pubspec: %v", err)
+	}
+
+	// Verify the extracted pubspec content is from the root quill package
+	if !strings.Contains(pubspecContent, "name: quill") {
+		t.Errorf("Expected pubspec content to contain 'name: quill', got: %s", pubspecContent)
+	}
+
+	if !strings.Contains(pubspecContent, "version: 1.0.0") {
+		t.Errorf("Expected pubspec content to contain 'version: 1.0.0', got: %s", pubspecContent)
+	}
+
+	if !strings.Contains(pubspecContent, "A rich text editor for Flutter") {
+		t.Errorf("Expected pubspec content to contain root description, got: %s", pubspecContent)
+	}
+
+	// Verify it's NOT from the nested packages
+	if strings.Contains(pubspecContent, "name: quill_extension") {
+		t.Error("Pubspec content should NOT be from quill_extension package")
+	}
+
+	if strings.Contains(pubspecContent, "name: quill_test_fixtures") {
+		t.Error("Pubspec content should NOT be from quill_test_fixtures package")
+	}
+
+	if strings.Contains(pubspecContent, "version: 2.0.0") {
+		t.Error("Pubspec content should NOT contain version 2.0.0 from extension package")
+	}
+
+	t.Logf("Extracted pubspec content:\n%s", pubspecContent)
+}
+
+func TestExtractFilesFromArchive_DocVariants(t *testing.T) {
+	tests := []struct {
+		name              string
+		files             map[string]string
+		expectedReadme    *string
+		expectedChangelog *string
+	}{
+		{
+			name: "README without extension and CHANGELOG.txt",
+			files: map[string]string{
+				"pkg-1.0.0/pubspec.yaml":  "name: pkg\nversion: 1.0.0",
+				"pkg-1.0.0/README":        "plain readme",
+				"pkg-1.0.0/CHANGELOG.txt": "plain changelog",
+			},
+			expectedReadme:    stringPtr("plain readme"),
+			expectedChangelog: stringPtr("plain changelog"),
+		},
+		{
+			name: "markdown extension",
+			files: map[string]string{
+				"pkg-1.0.0/pubspec.yaml":       "name: pkg\nversion: 1.0.0",
+				"pkg-1.0.0/Readme.markdown":    "markdown readme",
+				"pkg-1.0.0/changelog.markdown": "markdown changelog",
+			},
+			expectedReadme:    stringPtr("markdown readme"),
+			expectedChangelog: stringPtr("markdown changelog"),
+		},
+		{
+			name: "README.md preferred over other variants",
+			files: map[string]string{
+				"pkg-1.0.0/pubspec.yaml": "name: pkg\nversion: 1.0.0",
+				"pkg-1.0.0/README.txt":   "text readme",
+				"pkg-1.0.0/README.md":    "md readme",
+			},
+			expectedReadme: stringPtr("md readme"),
+		},
+		{
+			name: "nested files are ignored",
+			files: map[string]string{
+				"pkg-1.0.0/pubspec.yaml":           "name: pkg\nversion: 1.0.0",
+				"pkg-1.0.0/example/README.md":      "example readme",
+				"pkg-1.0.0/doc/CHANGELOG.md":       "doc changelog",
+				"pkg-1.0.0/README.md.orig":         "backup readme",
+				"pkg-1.0.0/CHANGELOG_OLD.md":       "old changelog",
+				"pkg-1.0.0/lib/src/readme.dart":    "// not a readme",
+				"pkg-1.0.0/test/changelog_test.md": "not a changelog",
+			},
+		},
+		{
+			name: "root layout keeps subdirectories",
+			files: map[string]string{
+				"pubspec.yaml":      "name: pkg\nversion: 1.0.0",
+				"README":            "root readme",
+				"example/README.md": "example readme",
+			},
+			expectedReadme: stringPtr("root readme"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := testutil.CreateTestTarGzArchive(t, tt.files)
+
+			_, readme, changelog, err := extractFilesFromArchive(archive)
+			if err != nil {
+				t.Fatalf("Failed to extract files: %v", err)
+			}
+
+			if stringValue(readme) != stringValue(tt.expectedReadme) || (readme == nil) != (tt.expectedReadme == nil) {
+				t.Errorf("Expected README %v, got %v", stringValue(tt.expectedReadme), stringValue(readme))
+			}
+			if stringValue(changelog) != stringValue(tt.expectedChangelog) || (changelog == nil) != (tt.expectedChangelog == nil) {
+				t.Errorf("Expected CHANGELOG %v, got %v", stringValue(tt.expectedChangelog), stringValue(changelog))
+			}
+		})
+	}
+}
+
+func TestExtractFilesFromArchive_StopsEarly(t *testing.T) {
+	for _, dir := range []string{"", "pkg-1.0.0/"} {
+		t.Run("dir "+dir, func(t *testing.T) {
+			testExtractStopsEarly(t, dir)
+		})
+	}
+}
+
+func testExtractStopsEarly(t *testing.T, dir string) {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	for _, file := range []struct{ name, content string }{
+		{dir + "pubspec.yaml", "name: pkg\nversion: 1.0.0"},
+		{dir + "README.md", "readme"},
+		{dir + "CHANGELOG.md", "changelog"},
+	} {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(file.content)); err != nil {
+			t.Fatalf("Failed to write tar content: %v", err)
+		}
+	}
+
+	// A large trailing file that is truncated, so reading past the
+	// documentation files would fail with an unexpected EOF
+	if err := tarWriter.WriteHeader(&tar.Header{Name: dir + "lib/huge.bin", Mode: 0644, Size: 1 << 30}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tarWriter.Write(make([]byte, 1<<20)); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+
+	pubspecContent, readme, changelog, err := extractFilesFromArchive(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Expected extraction to stop before the trailing file, got: %v", err)
+	}
+	if !strings.Contains(pubspecContent, "name: pkg") {
+		t.Errorf("Unexpected pubspec content: %s", pubspecContent)
+	}
+	if stringValue(readme) != "readme" || stringValue(changelog) != "changelog" {
+		t.Errorf("Expected README and CHANGELOG, got %v and %v", stringValue(readme), stringValue(changelog))
+	}
+}
+
+func TestExtractFilesFromArchive_OversizedFile(t *testing.T) {
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pkg-1.0.0/pubspec.yaml": "name: pkg\nversion: 1.0.0",
+		"pkg-1.0.0/README.md":    strings.Repeat("a", maxExtractedFileSize+1),
+	})
+
+	_, _, _, err := extractFilesFromArchive(archive)
+	if err == nil || !strings.Contains(err.Error(), "README.md exceeds") {
+		t.Errorf("Expected oversized README error, got %v", err)
+	}
+}
+
+func TestExtractFilesFromArchive_DuplicateControlFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       [][2]string
+		expectedErr string
+	}{
+		{
+			name: "conflicting root pubspecs",
+			files: [][2]string{
+				{"pubspec.yaml", "name: first\nversion: 1.0.0"},
+				{"lib/first.dart", "void main() {}"},
+				{"pubspec.yaml", "name: second\nversion: 1.0.0"},
+			},
+			expectedErr: "pubspec.yaml more than once",
+		},
+		{
+			name: "duplicate prefixed pubspec",
+			files: [][2]string{
+				{"pkg-1.0.0/pubspec.yaml", "name: pkg\nversion: 1.0.0"},
+				{"./pkg-1.0.0/pubspec.yaml", "name: pkg\nversion: 1.0.0"},
+			},
+			expectedErr: "pubspec.yaml more than once",
+		},
+		{
+			name: "README differing only in case",
+			files: [][2]string{
+				{"pubspec.yaml", "name: pkg\nversion: 1.0.0"},
+				{"README.md", "first"},
+				{"readme.md", "second"},
+			},
+			expectedErr: "readme.md more than once",
+		},
+		{
+			name: "nested example package",
+			files: [][2]string{
+				{"pubspec.yaml", "name: pkg\nversion: 1.0.0"},
+				{"README.md", "readme"},
+				{"example/pubspec.yaml", "name: pkg_example\nversion: 1.0.0"},
+				{"example/README.md", "example readme"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			gzWriter := gzip.NewWriter(&buf)
+			tarWriter := tar.NewWriter(gzWriter)
+			for _, file := range tt.files {
+				if err := tarWriter.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}); err != nil {
+					t.Fatalf("Failed to write tar header: %v", err)
+				}
+				if _, err := tarWriter.Write([]byte(file[1])); err != nil {
+					t.Fatalf("Failed to write tar content: %v", err)
+				}
+			}
+			if err := tarWriter.Close(); err != nil {
+				t.Fatalf("Failed to close tar writer: %v", err)
+			}
+			if err := gzWriter.Close(); err != nil {
+				t.Fatalf("Failed to close gzip writer: %v", err)
+			}
+
+			_, err := ValidateArchive(context.Background(), pubspec.NewParserRepository(), buf.Bytes())
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Expected archive to be accepted, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPubspecInvalid) || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected ErrPubspecInvalid containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+// createArchiveFromQuillTestData creates a tar.gz from the quill testdata directory
+func createArchiveFromQuillTestData(t *testing.T) []byte {
+	testdataPath := "testdata/quill"
+
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	// Walk the quill directory
+	err := filepath.Walk(testdataPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// Get relative path from quill root, under a package directory
+		relPath, err := filepath.Rel(testdataPath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.Join("quill-1.0.0", relPath)
+
 		header := &tar.Header{
 			Name:    relPath,
 			Mode:    0644,
@@ -596,3 +2556,113 @@ func createArchiveFromQuillTestData(t *testing.T) []byte {
 
 	return buf.Bytes()
 }
+
+func TestPubService_GetInstanceStats(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	clk := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		Clock:   clk,
+	})
+	ctx := context.Background()
+
+	publish := func(name string) {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", name, err)
+		}
+	}
+	packages := func() int64 {
+		t.Helper()
+		stats, err := svc.GetInstanceStats(ctx)
+		if err != nil {
+			t.Fatalf("GetInstanceStats failed: %v", err)
+		}
+		return stats.Packages
+	}
+
+	publish("one")
+	if n := packages(); n != 1 {
+		t.Fatalf("Expected 1 package, got %d", n)
+	}
+
+	// Cached stats are reused until they expire
+	publish("two")
+	if n := packages(); n != 1 {
+		t.Errorf("Expected the cached count of 1, got %d", n)
+	}
+	clk.Advance(statsCacheTTL)
+	if n := packages(); n != 2 {
+		t.Errorf("Expected 2 packages once the cache expired, got %d", n)
+	}
+}
+
+func TestPubService_GetSitemapPackages(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	clk := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		Clock:   clk,
+	})
+	ctx := context.Background()
+
+	publish := func(name, version string) {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: " + version,
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s %s: %v", name, version, err)
+		}
+	}
+	listed := func() []string {
+		t.Helper()
+		packages, err := svc.GetSitemapPackages(ctx)
+		if err != nil {
+			t.Fatalf("GetSitemapPackages failed: %v", err)
+		}
+		var names []string
+		for _, pkg := range packages {
+			names = append(names, pkg.Name)
+			for _, v := range pkg.Versions {
+				names = append(names, pkg.Name+"@"+v.Version)
+			}
+		}
+		return names
+	}
+
+	publish("beta", "1.0.0")
+	publish("alpha", "1.0.0")
+	publish("alpha", "1.1.0")
+	publish("secret", "1.0.0")
+	if _, err := svc.SetPackagePrivate(ctx, "secret", true, domain.Actor{Admin: true}); err != nil {
+		t.Fatalf("Failed to mark package private: %v", err)
+	}
+
+	want := []string{"alpha", "alpha@1.1.0", "alpha@1.0.0", "beta", "beta@1.0.0"}
+	if got := listed(); !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	// The listing is reused until it expires
+	publish("gamma", "1.0.0")
+	if got := listed(); !slices.Equal(got, want) {
+		t.Errorf("Expected the cached %v, got %v", want, got)
+	}
+	clk.Advance(sitemapCacheTTL)
+	want = append(want, "gamma", "gamma@1.0.0")
+	if got := listed(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v once the cache expired, got %v", want, got)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"repub/internal/domain"
+	"repub/internal/markdown"
+	"repub/internal/repository/pkg"
+	"sync"
+	"time"
+)
+
+// readmeQueueSize bounds the versions waiting for a render worker; versions
+// dropped from a full queue are rendered in memory when their page is viewed
+// until `repub backfill` stores their HTML
+const readmeQueueSize = 100
+
+// renderStoreTimeout bounds storing the HTML of one version from a worker
+const renderStoreTimeout = 30 * time.Second
+
+type readmeJob struct {
+	versionID         int32
+	readme, changelog *string
+}
+
+// ReadmeRenderer renders READMEs and CHANGELOGs to sanitized HTML on a pool of
+// background workers and stores the HTML with their versions, so package
+// pages don't render markdown on every request
+type ReadmeRenderer struct {
+	repo  pkg.Repository
+	queue chan readmeJob
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewReadmeRenderer starts workers render workers; Close stops them after
+// draining the queue
+func NewReadmeRenderer(repo pkg.Repository, workers int) *ReadmeRenderer {
+	r := &ReadmeRenderer{
+		repo:  repo,
+		queue: make(chan readmeJob, readmeQueueSize),
+	}
+	for range max(workers, 1) {
+		r.wg.Add(1)
+		go r.run()
+	}
+	return r
+}
+
+// Enqueue queues the README and CHANGELOG of a version for rendering,
+// dropping them if the queue is full
+func (r *ReadmeRenderer) Enqueue(versionID int32, readme, changelog *string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.queue <- readmeJob{versionID: versionID, readme: readme, changelog: changelog}:
+	default:
+		slog.Warn("README render queue is full, rendering on view", "version_id", versionID)
+	}
+}
+
+// Close stops accepting versions and waits for the queued ones to be stored
+func (r *ReadmeRenderer) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *ReadmeRenderer) run() {
+	defer r.wg.Done()
+	for job := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), renderStoreTimeout)
+		storeDocsHTML(ctx, r.repo, job.versionID, job.readme, job.changelog)
+		cancel()
+	}
+}
+
+// storeDocsHTML renders and saves the README and CHANGELOG HTML of a version,
+// empty for a missing doc; a failure only costs a render on later views, so
+// it is logged
+func storeDocsHTML(ctx context.Context, repo pkg.Repository, versionID int32, readme, changelog *string) {
+	readmeHTML, changelogHTML := markdown.Render(stringValue(readme)), markdown.Render(stringValue(changelog))
+	if err := repo.SetVersionDocsHTML(ctx, versionID, readmeHTML, changelogHTML); err != nil {
+		slog.Warn("Failed to store rendered README", "version_id", versionID, "error", err)
+	}
+}
+
+// hasDocs reports whether a version has a README or CHANGELOG to render
+func hasDocs(v *domain.PackageVersion) bool {
+	return stringValue(v.Readme) != "" || stringValue(v.Changelog) != ""
+}
+
+// prerenderDocs renders the README and CHANGELOG of a newly created version,
+// on the render workers when configured and otherwise right away
+func (s *packageService) prerenderDocs(ctx context.Context, v *domain.PackageVersion) {
+	if !hasDocs(v) {
+		return
+	}
+	if s.Readmes != nil {
+		s.Readmes.Enqueue(v.ID, v.Readme, v.Changelog)
+		return
+	}
+	storeDocsHTML(ctx, s.Package, v.ID, v.Readme, v.Changelog)
+}
+
+// renderMissingDocs renders in memory the docs of a version stored before
+// they were rendered at publish time, or whose render was dropped. Read paths
+// don't store the HTML, `repub backfill` does.
+func renderMissingDocs(v *domain.PackageVersion) {
+	if v.ReadmeHTML == nil && stringValue(v.Readme) != "" {
+		html := markdown.Render(*v.Readme)
+		v.ReadmeHTML = &html
+	}
+	if v.ChangelogHTML == nil && stringValue(v.Changelog) != "" {
+		html := markdown.Render(*v.Changelog)
+		v.ChangelogHTML = &html
+	}
+}
+
+func (s *packageService) RefreshPackage(ctx context.Context, name string) (*domain.PackageResponse, error) {
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	for _, v := range versions {
+		if !hasDocs(v) && v.ReadmeHTML == nil && v.ChangelogHTML == nil {
+			continue
+		}
+		// Docs removed by hand are stored as empty HTML
+		readmeHTML, changelogHTML := markdown.Render(stringValue(v.Readme)), markdown.Render(stringValue(v.Changelog))
+		if v.ReadmeHTML != nil && *v.ReadmeHTML == readmeHTML && v.ChangelogHTML != nil && *v.ChangelogHTML == changelogHTML {
+			continue
+		}
+		if err := s.Package.SetVersionDocsHTML(ctx, v.ID, readmeHTML, changelogHTML); err != nil {
+			return nil, fmt.Errorf("failed to store the docs of version %s: %w", v.Version, err)
+		}
+	}
+	s.stats.invalidate()
+	s.sitemap.invalidate()
+
+	return s.GetPackage(ctx, name)
+}
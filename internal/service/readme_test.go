@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestPubService_ReadmeHTML(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	publish := func(svc PubService, name string) {
+		t.Helper()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+			"README.md":    "# " + name + "\n\n<script>alert(1)</script>",
+			"CHANGELOG.md": "## 1.0.0\n\n- First release",
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("Failed to publish %s: %v", name, err)
+		}
+	}
+	stored := func(name string) *domain.PackageVersion {
+		t.Helper()
+		pkg, err := repos.DB.Repo.GetPackage(ctx, name)
+		if err != nil || pkg == nil {
+			t.Fatalf("Failed to get %s: %v", name, err)
+		}
+		version, err := repos.DB.Repo.GetLatestVersion(ctx, pkg.ID)
+		if err != nil {
+			t.Fatalf("Failed to get the latest %s version: %v", name, err)
+		}
+		return version
+	}
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+	})
+
+	t.Run("rendered at publish time", func(t *testing.T) {
+		publish(svc, "sync_readme")
+		html := stored("sync_readme").ReadmeHTML
+		if html == nil {
+			t.Fatal("Expected the README to be rendered when publishing")
+		}
+		if !strings.Contains(*html, "<h1") || strings.Contains(*html, "<script>") {
+			t.Errorf("Expected sanitized README HTML, got %q", *html)
+		}
+		if changelog := stored("sync_readme").ChangelogHTML; changelog == nil || !strings.Contains(*changelog, "First release") {
+			t.Errorf("Expected the CHANGELOG to be rendered when publishing, got %v", changelog)
+		}
+
+		// Package pages serve the stored HTML instead of rendering again
+		version := stored("sync_readme")
+		if err := repos.DB.Repo.SetVersionDocsHTML(ctx, version.ID, "<p>stored</p>", "<p>changes</p>"); err != nil {
+			t.Fatalf("SetVersionDocsHTML failed: %v", err)
+		}
+		detail, err := svc.GetPackageDetail(ctx, "sync_readme")
+		if err != nil {
+			t.Fatalf("GetPackageDetail failed: %v", err)
+		}
+		if detail.Latest.ReadmeHTML == nil || *detail.Latest.ReadmeHTML != "<p>stored</p>" {
+			t.Errorf("Expected the stored README HTML, got %v", detail.Latest.ReadmeHTML)
+		}
+		if detail.Latest.ChangelogHTML == nil || *detail.Latest.ChangelogHTML != "<p>changes</p>" {
+			t.Errorf("Expected the stored CHANGELOG HTML, got %v", detail.Latest.ChangelogHTML)
+		}
+
+		// The README endpoint serves the stored HTML too
+		readme, err := svc.GetReadmeHTML(ctx, "sync_readme", "1.0.0")
+		if err != nil {
+			t.Fatalf("GetReadmeHTML failed: %v", err)
+		}
+		if readme == nil || *readme != "<p>stored</p>" {
+			t.Errorf("Expected the stored README HTML, got %v", readme)
+		}
+	})
+
+	t.Run("rendered by workers", func(t *testing.T) {
+		renderer := NewReadmeRenderer(repos.DB.Repo, 2)
+		pooled := NewPubService(PackageDependencies{
+			Package: repos.DB.Repo,
+			Storage: repos.StorageSvc,
+			Pubspec: repos.PubspecSvc,
+			Readmes: renderer,
+		})
+		publish(pooled, "pooled_readme")
+		renderer.Close()
+
+		if html := stored("pooled_readme").ReadmeHTML; html == nil || !strings.Contains(*html, "<h1") {
+			t.Errorf("Expected the workers to store the README HTML, got %v", html)
+		}
+	})
+
+	t.Run("legacy versions are rendered on view and stored by the backfill", func(t *testing.T) {
+		publish(svc, "legacy_readme")
+		version := stored("legacy_readme")
+		// Versions published before pre-rendering have no HTML
+		if _, err := repos.DB.DB.ExecContext(ctx, "UPDATE package_versions SET readme_html = NULL, changelog_html = NULL WHERE id = ?", version.ID); err != nil {
+			t.Fatalf("Failed to clear the docs HTML: %v", err)
+		}
+
+		detail, err := svc.GetPackageDetail(ctx, "legacy_readme")
+		if err != nil {
+			t.Fatalf("GetPackageDetail failed: %v", err)
+		}
+		if detail.Latest.ReadmeHTML == nil || !strings.Contains(*detail.Latest.ReadmeHTML, "<h1") {
+			t.Errorf("Expected the README to be rendered, got %v", detail.Latest.ReadmeHTML)
+		}
+		if detail.Latest.ChangelogHTML == nil || !strings.Contains(*detail.Latest.ChangelogHTML, "First release") {
+			t.Errorf("Expected the CHANGELOG to be rendered, got %v", detail.Latest.ChangelogHTML)
+		}
+		// Viewing a page doesn't write to the database
+		if html := stored("legacy_readme").ReadmeHTML; html != nil {
+			t.Errorf("Expected viewing not to store the README HTML, got %q", *html)
+		}
+
+		rendered, err := svc.BackfillDocsHTML(ctx)
+		if err != nil {
+			t.Fatalf("BackfillDocsHTML failed: %v", err)
+		}
+		if rendered != 1 {
+			t.Errorf("Expected 1 version to be backfilled, got %d", rendered)
+		}
+		backfilled := stored("legacy_readme")
+		if backfilled.ReadmeHTML == nil || !strings.Contains(*backfilled.ReadmeHTML, "<h1") {
+			t.Errorf("Expected the backfill to store the README HTML, got %v", backfilled.ReadmeHTML)
+		}
+		if backfilled.ChangelogHTML == nil || !strings.Contains(*backfilled.ChangelogHTML, "First release") {
+			t.Errorf("Expected the backfill to store the CHANGELOG HTML, got %v", backfilled.ChangelogHTML)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"repub/internal/domain"
+	"slices"
+	"strings"
+)
+
+// maxReportReasonBytes caps the reason given for an abuse report
+const maxReportReasonBytes = 4096
+
+// ErrInvalidReport is returned for an abuse report without a usable reason
+var ErrInvalidReport = errors.New("invalid report")
+
+func (s *packageService) ReportVersion(ctx context.Context, name, version, reporter, reason string) (*domain.Report, error) {
+	reason = strings.TrimSpace(reason)
+	switch {
+	case reason == "":
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidReport)
+	case len(reason) > maxReportReasonBytes:
+		return nil, fmt.Errorf("%w: the reason exceeds %d bytes", ErrInvalidReport, maxReportReasonBytes)
+	}
+
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("%w: package %s", ErrNotFound, name)
+	}
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	if !slices.ContainsFunc(versions, func(v *domain.PackageVersion) bool { return v.Version == version }) {
+		return nil, fmt.Errorf("%w: version %s of package %s", ErrNotFound, version, name)
+	}
+
+	report, err := s.Package.CreateReport(ctx, &domain.Report{
+		PackageID: pkg.ID,
+		Package:   pkg.Name,
+		Version:   version,
+		Reporter:  reporter,
+		Reason:    reason,
+	})
+	if errors.Is(err, ErrReportExists) {
+		return nil, fmt.Errorf("%w: %s already reported version %s of package %s", ErrReportExists, reporter, version, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %w", err)
+	}
+
+	if s.ReportNotifier != nil {
+		s.ReportNotifier.NotifyReported(domain.ReportEvent{
+			Event:      "report",
+			Package:    report.Package,
+			Version:    report.Version,
+			Reporter:   report.Reporter,
+			Reason:     report.Reason,
+			ReportedAt: s.Clock.Now(),
+		})
+	}
+	return report, nil
+}
+
+func (s *packageService) ListReports(ctx context.Context, before, limit int32) ([]*domain.Report, error) {
+	reports, err := s.Package.ListReports(ctx, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"repub/internal/domain"
+	"repub/internal/telemetry"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxScreenshotBytes caps the size of a screenshot kept from an archive
+const maxScreenshotBytes = 4 << 20
+
+// screenshotContentTypes are the image formats accepted as screenshots by
+// extension. SVG is left out as it can carry scripts.
+var screenshotContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// screenshotContentType returns the content type of a screenshot path, or ""
+// if it isn't an accepted image inside the package
+func screenshotContentType(p string) string {
+	if p == "" || path.IsAbs(p) || strings.HasPrefix(path.Clean(p), "..") {
+		return ""
+	}
+	return screenshotContentTypes[strings.ToLower(path.Ext(p))]
+}
+
+// archiveScreenshots returns the declared screenshots that are images present
+// in the archive and no larger than maxScreenshotBytes. Screenshots are only
+// shown on the web UI, so the rest are dropped rather than failing the publish.
+func archiveScreenshots(archive []byte, declared []domain.Screenshot) []domain.Screenshot {
+	var paths []string
+	for _, screenshot := range declared {
+		if screenshotContentType(screenshot.Path) != "" {
+			paths = append(paths, screenshot.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	extracted, err := extractArchive(archive, nil, &archiveFiles{paths: paths, maxBytes: maxScreenshotBytes})
+	if err != nil {
+		slog.Warn("Failed to read screenshots from archive", "error", err)
+		return nil
+	}
+
+	var kept []domain.Screenshot
+	for _, screenshot := range declared {
+		if screenshotContentType(screenshot.Path) == "" {
+			continue
+		}
+		if _, ok := extracted.files[path.Clean(screenshot.Path)]; ok {
+			kept = append(kept, domain.Screenshot{Description: screenshot.Description, Path: path.Clean(screenshot.Path)})
+		}
+	}
+	return kept
+}
+
+func (s *packageService) GetScreenshot(ctx context.Context, name, version, screenshotPath string) (_ *domain.ScreenshotImage, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.GetScreenshot", trace.WithAttributes(
+		attribute.String("package", name), attribute.String("version", version), attribute.String("path", screenshotPath)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	_, v, err := s.findDownloadableVersion(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only declared screenshots are served, not arbitrary archive files
+	contentType := screenshotContentType(screenshotPath)
+	declared := false
+	for _, screenshot := range v.Screenshots {
+		declared = declared || screenshot.Path == path.Clean(screenshotPath)
+	}
+	if contentType == "" || !declared {
+		return nil, fmt.Errorf("%w: screenshot %s of version %s of package %s", ErrNotFound, screenshotPath, version, name)
+	}
+
+	archive, err := s.Storage.Get(ctx, v.ArchivePath)
+	if err != nil {
+		return nil, archiveError(name, version, err)
+	}
+
+	extracted, err := extractArchive(archive, nil, &archiveFiles{paths: []string{screenshotPath}, maxBytes: maxScreenshotBytes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshot: %w", err)
+	}
+	data, ok := extracted.files[path.Clean(screenshotPath)]
+	if !ok {
+		return nil, fmt.Errorf("%w: screenshot %s is missing from the archive", ErrNotFound, screenshotPath)
+	}
+	return &domain.ScreenshotImage{ContentType: contentType, Data: data}, nil
+}
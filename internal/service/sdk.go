@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"repub/internal/domain"
+	"repub/internal/repository/pubspec"
+)
+
+// SDKs a package can be built on, see AllowedSDKs
+const (
+	SDKDart    = "dart"
+	SDKFlutter = "flutter"
+)
+
+// packageSDK reports SDKFlutter for packages that constrain the Flutter SDK in
+// their environment or depend on a package from the Flutter SDK, and SDKDart otherwise.
+// Dev dependencies such as flutter_test don't make a package a Flutter package.
+func packageSDK(ctx context.Context, parser pubspec.Repository, spec *domain.Pubspec) (string, error) {
+	if spec.Environment != nil && spec.Environment.Flutter != "" {
+		return SDKFlutter, nil
+	}
+
+	deps, err := parser.ExtractDependencies(ctx, &domain.Pubspec{Dependencies: spec.Dependencies})
+	if err != nil {
+		return "", err
+	}
+	for _, dep := range deps {
+		if dep.SDK == SDKFlutter {
+			return SDKFlutter, nil
+		}
+	}
+	return SDKDart, nil
+}
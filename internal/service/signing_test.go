@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"repub/internal/clock"
+	"testing"
+	"time"
+)
+
+func TestDownloadSigner(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	signer := NewDownloadSigner("signing-key", time.Hour, clk)
+
+	signed, err := url.Parse(signer.SignURL("http://localhost:8080/packages/foo/versions/1.0.0/download", "foo", "1.0.0"))
+	if err != nil {
+		t.Fatalf("Failed to parse signed URL: %v", err)
+	}
+	if signed.Path != "/packages/foo/versions/1.0.0/download" {
+		t.Errorf("Expected the archive path to be kept, got %s", signed.Path)
+	}
+	exp, sig := signed.Query().Get("exp"), signed.Query().Get("sig")
+
+	tests := []struct {
+		name    string
+		signer  *DownloadSigner
+		pkg     string
+		version string
+		exp     string
+		sig     string
+		advance time.Duration
+		wantErr error
+	}{
+		{"valid", signer, "foo", "1.0.0", exp, sig, 0, nil},
+		{"valid until expiry", signer, "foo", "1.0.0", exp, sig, time.Hour - time.Second, nil},
+		{"expired", signer, "foo", "1.0.0", exp, sig, time.Hour, ErrSignatureExpired},
+		{"tampered signature", signer, "foo", "1.0.0", exp, sig[1:], 0, ErrInvalidSignature},
+		{"tampered expiry", signer, "foo", "1.0.0", "99999999999", sig, 0, ErrInvalidSignature},
+		{"other version", signer, "foo", "2.0.0", exp, sig, 0, ErrInvalidSignature},
+		{"other package", signer, "bar", "1.0.0", exp, sig, 0, ErrInvalidSignature},
+		{"other key", NewDownloadSigner("other-key", time.Hour, clk), "foo", "1.0.0", exp, sig, 0, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk.Advance(tt.advance)
+			defer clk.Advance(-tt.advance)
+
+			if err := tt.signer.Verify(tt.pkg, tt.version, tt.exp, tt.sig); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
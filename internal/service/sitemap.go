@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/telemetry"
+	"sync"
+	"time"
+)
+
+// sitemapCacheTTL is how long the sitemap listing is reused; crawlers fetch
+// it often and it covers every public version
+const sitemapCacheTTL = 5 * time.Minute
+
+// sitemapCache holds the last listed sitemap packages
+type sitemapCache struct {
+	mu         sync.Mutex
+	packages   []*domain.SitemapPackage
+	computedAt time.Time
+}
+
+func (s *packageService) GetSitemapPackages(ctx context.Context) (_ []*domain.SitemapPackage, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.GetSitemapPackages")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	s.sitemap.mu.Lock()
+	defer s.sitemap.mu.Unlock()
+
+	now := s.Clock.Now()
+	if s.sitemap.packages != nil && now.Sub(s.sitemap.computedAt) < sitemapCacheTTL {
+		return s.sitemap.packages, nil
+	}
+
+	packages, err := s.Package.ListSitemapPackages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sitemap packages: %w", err)
+	}
+	if packages == nil {
+		packages = []*domain.SitemapPackage{}
+	}
+	s.sitemap.packages, s.sitemap.computedAt = packages, now
+	return packages, nil
+}
+
+// invalidate makes the next GetSitemapPackages query the database
+func (c *sitemapCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packages = nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/telemetry"
+	"sync"
+	"time"
+)
+
+// statsCacheTTL is how long instance stats are reused before the aggregate
+// queries run again
+const statsCacheTTL = 30 * time.Second
+
+// statsCache holds the last computed instance stats
+type statsCache struct {
+	mu         sync.Mutex
+	stats      *domain.InstanceStats
+	computedAt time.Time
+}
+
+func (s *packageService) GetInstanceStats(ctx context.Context) (_ *domain.InstanceStats, err error) {
+	ctx, span := tracer.Start(ctx, "PubService.GetInstanceStats")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	now := s.Clock.Now()
+	if s.stats.stats != nil && now.Sub(s.stats.computedAt) < statsCacheTTL {
+		return s.stats.stats, nil
+	}
+
+	stats, err := s.Package.GetInstanceStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance stats: %w", err)
+	}
+	s.stats.stats, s.stats.computedAt = stats, now
+	return stats, nil
+}
+
+// invalidate makes the next GetInstanceStats query the database
+func (c *statsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats = nil
+}
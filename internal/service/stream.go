@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// versionPageSize is the number of versions held in memory at once while
+// streaming a package
+const versionPageSize = 500
+
+// VersionStream calls fn with each version of a package, newest first, and
+// stops at the first error fn returns
+type VersionStream func(fn func(*domain.VersionResponse) error) error
+
+// StreamPackage looks up a package like GetPackage, but its versions are
+// loaded one page at a time as the returned stream is consumed, so memory
+// stays flat however many versions the package has
+func (s *packageService) StreamPackage(ctx context.Context, name string) (_ *domain.PackageResponse, _ VersionStream, err error) {
+	// The stream loads its pages after this span has ended
+	streamCtx := ctx
+	ctx, span := tracer.Start(ctx, "PubService.StreamPackage", trace.WithAttributes(attribute.String("package", name)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	// Whether a package is served from upstream depends on every one of its
+	// versions, so proxying registries build the whole response
+	if s.Upstream != nil {
+		pkg, err := s.GetPackage(ctx, name)
+		if err != nil || pkg == nil {
+			return nil, nil, err
+		}
+		versions := pkg.Versions
+		pkg.Versions = nil
+		return pkg, func(fn func(*domain.VersionResponse) error) error {
+			for i := range versions {
+				if err := fn(&versions[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	}
+
+	pkg, err := s.getVisiblePackage(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil, nil
+	}
+
+	first, err := s.versionPage(ctx, pkg.ID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(first) == 0 {
+		return nil, nil, fmt.Errorf("%w: package %s has no versions", ErrNotFound, name)
+	}
+	latest, err := s.versionToResponseWithPackage(first[0], pkg.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert latest version response: %w", err)
+	}
+
+	stream := func(fn func(*domain.VersionResponse) error) error {
+		page := first
+		for {
+			for _, v := range page {
+				resp, err := s.versionToResponseWithPackage(v, pkg.Name)
+				if err != nil {
+					return fmt.Errorf("failed to convert version response: %w", err)
+				}
+				if err := fn(&resp); err != nil {
+					return err
+				}
+			}
+			if len(page) < versionPageSize {
+				return nil
+			}
+
+			var err error
+			if page, err = s.versionPage(streamCtx, pkg.ID, page[len(page)-1]); err != nil {
+				return err
+			}
+		}
+	}
+	return &domain.PackageResponse{Name: pkg.Name, Latest: latest}, stream, nil
+}
+
+// versionPage loads the page of a package's versions following after, the
+// first page when after is nil
+func (s *packageService) versionPage(ctx context.Context, packageID int32, after *domain.PackageVersion) ([]*domain.PackageVersion, error) {
+	versions, err := s.Package.GetPackageVersionsPage(ctx, packageID, after, versionPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	return versions, nil
+}
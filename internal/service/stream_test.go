@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"strconv"
+	"testing"
+)
+
+func TestPubService_StreamPackage(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+	ctx := context.Background()
+
+	svc := NewPubService(PackageDependencies{
+		Package: repos.DB.Repo,
+		Storage: repos.StorageSvc,
+		Pubspec: repos.PubspecSvc,
+		BaseURL: "http://localhost:8080",
+	})
+
+	// One more version than fits in a page
+	pkg, err := repos.DB.CreateTestPackage(ctx, "many_versions", false)
+	if err != nil {
+		t.Fatalf("Failed to create package: %v", err)
+	}
+	for i := range versionPageSize + 1 {
+		version := "1.0." + strconv.Itoa(i)
+		size := int64(1024)
+		_, err := repos.DB.Repo.CreateVersion(ctx, &domain.PackageVersion{
+			PackageID:   pkg.ID,
+			Version:     version,
+			PubspecYaml: "name: many_versions\nversion: " + version,
+			ArchivePath: "many_versions/" + version + ".tar.gz",
+			SizeBytes:   &size,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create version %s: %v", version, err)
+		}
+	}
+
+	head, versions, err := svc.StreamPackage(ctx, "many_versions")
+	if err != nil {
+		t.Fatalf("StreamPackage failed: %v", err)
+	}
+	if head == nil || head.Versions != nil {
+		t.Fatalf("Expected the package without its versions, got %+v", head)
+	}
+	streamed := *head
+	err = versions(func(v *domain.VersionResponse) error {
+		streamed.Versions = append(streamed.Versions, *v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Streaming versions failed: %v", err)
+	}
+
+	// Streaming yields exactly what GetPackage builds in memory
+	expected, err := svc.GetPackage(ctx, "many_versions")
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if len(streamed.Versions) != versionPageSize+1 {
+		t.Fatalf("Expected %d versions, got %d", versionPageSize+1, len(streamed.Versions))
+	}
+	got, _ := json.Marshal(streamed)
+	want, _ := json.Marshal(expected)
+	if string(got) != string(want) {
+		t.Error("Expected the streamed package to match GetPackage")
+	}
+
+	// A version published mid-stream neither shifts nor repeats later pages
+	_, versions, err = svc.StreamPackage(ctx, "many_versions")
+	if err != nil {
+		t.Fatalf("StreamPackage failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	err = versions(func(v *domain.VersionResponse) error {
+		if seen[v.Version] {
+			t.Errorf("Version %s streamed twice", v.Version)
+		}
+		seen[v.Version] = true
+		if len(seen) == 1 {
+			size := int64(1024)
+			_, err := repos.DB.Repo.CreateVersion(ctx, &domain.PackageVersion{
+				PackageID:   pkg.ID,
+				Version:     "2.0.0",
+				PubspecYaml: "name: many_versions\nversion: 2.0.0",
+				ArchivePath: "many_versions/2.0.0.tar.gz",
+				SizeBytes:   &size,
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Streaming versions failed: %v", err)
+	}
+	if len(seen) != versionPageSize+1 {
+		t.Errorf("Expected %d versions, got %d", versionPageSize+1, len(seen))
+	}
+
+	head, _, err = svc.StreamPackage(ctx, "missing")
+	if err != nil || head != nil {
+		t.Errorf("Expected no package, got %+v, %v", head, err)
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/mail"
+	"repub/internal/domain"
+	"strings"
+)
+
+// publishUploader returns the identity a publish is recorded as. With
+// UploaderFromAuthor it is the first valid email in the pubspec's author or
+// authors, otherwise, or when there is none, the token identity. The pubspec
+// is the publisher's to write, so it is never used to authorize a publish.
+func (s *packageService) publishUploader(spec *domain.Pubspec, tokenUploader string) string {
+	if !s.UploaderFromAuthor {
+		return tokenUploader
+	}
+	if email := authorEmail(spec); email != "" {
+		return email
+	}
+	return tokenUploader
+}
+
+// authorEmail extracts the email address from the pubspec's author field, or
+// the first authors entry that has one, e.g. "Jane Doe <jane@example.com>"
+// gives "jane@example.com". It returns "" when no author has a valid address.
+func authorEmail(spec *domain.Pubspec) string {
+	candidates := append([]string{spec.Author}, spec.Authors...)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(candidate)
+		if err != nil {
+			continue
+		}
+		// ParseAddress accepts local addresses such as "root@localhost"
+		if at := strings.LastIndex(addr.Address, "@"); at < 1 || !strings.Contains(addr.Address[at+1:], ".") {
+			continue
+		}
+		return strings.ToLower(addr.Address)
+	}
+	return ""
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	// maxUpstreamMetadataBytes caps the package metadata read from upstream
+	maxUpstreamMetadataBytes = 64 << 20
+	// maxUpstreamArchiveBytes caps the archives downloaded from upstream
+	maxUpstreamArchiveBytes = 1 << 30
+	// maxUpstreamCacheEntries caps the package metadata and misses kept in
+	// memory, every name a client asks for may add one
+	maxUpstreamCacheEntries = 10000
+	// defaultUpstreamMetadataTTL is how long upstream metadata is reused
+	defaultUpstreamMetadataTTL = time.Minute
+	// upstreamCacheQueueSize bounds the downloaded archives waiting to be
+	// cached; archives dropped from a full queue are downloaded again on
+	// their next request
+	upstreamCacheQueueSize = 16
+	// upstreamCacheTimeout bounds storing and recording one cached archive
+	upstreamCacheTimeout = 5 * time.Minute
+)
+
+// UpstreamConfig configures an UpstreamProxy; zero values select the defaults
+type UpstreamConfig struct {
+	// URL of the upstream pub server, e.g. https://pub.dev
+	URL string
+	// CacheArchives makes the proxy a pull-through cache: archives downloaded
+	// from upstream are kept in storage and recorded as proxied versions, so
+	// later downloads are served locally, even while upstream is down
+	CacheArchives bool
+	// MetadataTTL is how long package metadata, and packages upstream doesn't
+	// have, are reused before asking upstream again; negative disables it
+	MetadataTTL time.Duration
+	// Transport is used by the default Client, nil for http.DefaultTransport
+	Transport http.RoundTripper
+	Client    *http.Client
+	Clock     clock.Clock
+}
+
+// UpstreamProxy serves packages this registry doesn't host from an upstream
+// pub server, so clients can use a single registry for everything
+type UpstreamProxy struct {
+	cfg UpstreamConfig
+
+	mu       sync.Mutex
+	metadata map[string]upstreamMetadata
+
+	// Archives are cached on a background worker so downloads don't wait on
+	// storage and the database
+	cacheQueue chan upstreamCacheJob
+	worker     sync.WaitGroup
+	pending    sync.WaitGroup
+	closeMu    sync.RWMutex
+	closed     bool
+}
+
+// upstreamCacheJob caches one downloaded archive
+type upstreamCacheJob struct {
+	ctx   context.Context
+	cache func(ctx context.Context)
+}
+
+// upstreamMetadata is the cached answer of upstream for a package, nil when
+// upstream doesn't have it
+type upstreamMetadata struct {
+	pkg     *domain.PackageResponse
+	expires time.Time
+}
+
+// NewUpstreamProxy creates a proxy for the pub server at cfg.URL
+func NewUpstreamProxy(cfg UpstreamConfig) *UpstreamProxy {
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Minute, Transport: cfg.Transport}
+	}
+	if cfg.MetadataTTL == 0 {
+		cfg.MetadataTTL = defaultUpstreamMetadataTTL
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	u := &UpstreamProxy{cfg: cfg, metadata: make(map[string]upstreamMetadata)}
+	if cfg.CacheArchives {
+		u.cacheQueue = make(chan upstreamCacheJob, upstreamCacheQueueSize)
+		u.worker.Add(1)
+		go u.runCache()
+	}
+	return u
+}
+
+// enqueueCache queues cache to run on the cache worker, dropping it if the
+// queue is full. The job keeps the values of ctx but not its cancellation.
+func (u *UpstreamProxy) enqueueCache(ctx context.Context, name, version string, cache func(ctx context.Context)) {
+	u.closeMu.RLock()
+	defer u.closeMu.RUnlock()
+	if u.closed || u.cacheQueue == nil {
+		return
+	}
+
+	u.pending.Add(1)
+	select {
+	case u.cacheQueue <- upstreamCacheJob{ctx: context.WithoutCancel(ctx), cache: cache}:
+	default:
+		u.pending.Done()
+		slog.Warn("Upstream cache queue is full, not caching archive", "package", name, "version", version)
+	}
+}
+
+// Close stops accepting archives and waits for the queued ones to be cached
+func (u *UpstreamProxy) Close() {
+	u.closeMu.Lock()
+	if !u.closed {
+		u.closed = true
+		if u.cacheQueue != nil {
+			close(u.cacheQueue)
+		}
+	}
+	u.closeMu.Unlock()
+	u.worker.Wait()
+}
+
+// waitCached waits for the queued archives to be cached
+func (u *UpstreamProxy) waitCached() {
+	u.pending.Wait()
+}
+
+func (u *UpstreamProxy) runCache() {
+	defer u.worker.Done()
+	for job := range u.cacheQueue {
+		ctx, cancel := context.WithTimeout(job.ctx, upstreamCacheTimeout)
+		job.cache(ctx)
+		cancel()
+		u.pending.Done()
+	}
+}
+
+// fetchPackage returns the upstream metadata of a package, nil if upstream
+// doesn't have it. Answers are reused for MetadataTTL, failures are not.
+func (u *UpstreamProxy) fetchPackage(ctx context.Context, name string) (*domain.PackageResponse, error) {
+	if cached, ok := u.cachedPackage(name); ok {
+		return copyPackageResponse(cached), nil
+	}
+
+	body, err := u.get(ctx, u.cfg.URL+"/api/packages/"+url.PathEscape(name), "application/vnd.pub.v2+json", maxUpstreamMetadataBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg *domain.PackageResponse
+	if body != nil {
+		pkg = &domain.PackageResponse{}
+		if err := json.Unmarshal(body, pkg); err != nil {
+			return nil, fmt.Errorf("%w: invalid package metadata: %w", ErrUpstreamUnavailable, err)
+		}
+	}
+	u.cachePackage(name, pkg)
+	return copyPackageResponse(pkg), nil
+}
+
+// cachedPackage returns the unexpired metadata cached for name
+func (u *UpstreamProxy) cachedPackage(name string) (*domain.PackageResponse, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	entry, ok := u.metadata[name]
+	if !ok || !u.cfg.Clock.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.pkg, true
+}
+
+// cachePackage keeps the metadata of name, nil for a miss, for MetadataTTL
+func (u *UpstreamProxy) cachePackage(name string, pkg *domain.PackageResponse) {
+	if u.cfg.MetadataTTL < 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.cfg.Clock.Now()
+	if len(u.metadata) >= maxUpstreamCacheEntries {
+		for key, entry := range u.metadata {
+			if !now.Before(entry.expires) {
+				delete(u.metadata, key)
+			}
+		}
+		if len(u.metadata) >= maxUpstreamCacheEntries {
+			clear(u.metadata)
+		}
+	}
+	u.metadata[name] = upstreamMetadata{pkg: pkg, expires: now.Add(u.cfg.MetadataTTL)}
+}
+
+// copyPackageResponse copies the fields of pkg that callers rewrite, so the
+// cached metadata stays as upstream sent it
+func copyPackageResponse(pkg *domain.PackageResponse) *domain.PackageResponse {
+	if pkg == nil {
+		return nil
+	}
+	copied := *pkg
+	copied.Versions = slices.Clone(pkg.Versions)
+	return &copied
+}
+
+// fetchArchive downloads an archive, checking it against sha256 when upstream
+// reported one
+func (u *UpstreamProxy) fetchArchive(ctx context.Context, archiveURL, sha256Hex string) ([]byte, error) {
+	data, err := u.get(ctx, archiveURL, "", maxUpstreamArchiveBytes)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%w: archive %s", ErrNotFound, archiveURL)
+	}
+	if sha256Hex != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), sha256Hex) {
+			return nil, fmt.Errorf("%w: archive %s doesn't match its checksum", ErrUpstreamUnavailable, archiveURL)
+		}
+	}
+	return data, nil
+}
+
+// get reads the body of a GET request, nil when upstream answers 404
+func (u *UpstreamProxy) get(ctx context.Context, target, accept string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream request: %w", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := u.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s answered status %d", ErrUpstreamUnavailable, target, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %s: %w", ErrUpstreamUnavailable, target, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrUpstreamUnavailable, target, limit)
+	}
+	return body, nil
+}
+
+// mirrored reports whether a package only has versions cached from upstream,
+// in which case it is still served from upstream
+func mirrored(versions []*domain.PackageVersion) bool {
+	return !slices.ContainsFunc(versions, func(v *domain.PackageVersion) bool { return !v.Proxied })
+}
+
+// proxiesUpstream reports whether requests for name go to upstream: a proxy
+// is configured and no local package has the name, or it only has versions
+// cached from upstream. Private packages hidden from the caller still take
+// precedence, so they can't be shadowed.
+func (s *packageService) proxiesUpstream(ctx context.Context, name string) (bool, error) {
+	if s.Upstream == nil {
+		return false, nil
+	}
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return true, nil
+	}
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	return mirrored(versions), nil
+}
+
+// upstreamPackage returns the upstream metadata of a package that isn't
+// hosted locally. It returns nil when no upstream is configured or upstream
+// doesn't have the package.
+func (s *packageService) upstreamPackage(ctx context.Context, name string) (*domain.PackageResponse, error) {
+	proxied, err := s.proxiesUpstream(ctx, name)
+	if err != nil || !proxied {
+		return nil, err
+	}
+	return s.fetchUpstreamPackage(ctx, name)
+}
+
+// fetchUpstreamPackage returns the upstream metadata of a package with
+// archive URLs pointing at this server, nil if upstream doesn't have it
+func (s *packageService) fetchUpstreamPackage(ctx context.Context, name string) (*domain.PackageResponse, error) {
+	pkg, err := s.Upstream.fetchPackage(ctx, name)
+	if err != nil || pkg == nil {
+		return nil, err
+	}
+
+	// Downloads come back here so archives can be cached and clients only
+	// need access to this registry
+	pkg.Latest.ArchiveURL = s.archiveURL(name, pkg.Latest.Version)
+	pkg.Latest.Proxied = true
+	for i := range pkg.Versions {
+		pkg.Versions[i].ArchiveURL = s.archiveURL(name, pkg.Versions[i].Version)
+		pkg.Versions[i].Proxied = true
+	}
+	return pkg, nil
+}
+
+// upstreamArchive downloads the archive of a version proxied from upstream
+// and caches it when configured to. ok is false when the package is hosted
+// locally or no upstream is configured.
+func (s *packageService) upstreamArchive(ctx context.Context, name, version string) (_ []byte, ok bool, err error) {
+	proxied, err := s.proxiesUpstream(ctx, name)
+	if err != nil || !proxied {
+		return nil, false, err
+	}
+
+	pkg, err := s.Upstream.fetchPackage(ctx, name)
+	if err != nil {
+		return nil, true, err
+	}
+	if pkg == nil {
+		return nil, true, fmt.Errorf("%w: package %s", ErrNotFound, name)
+	}
+
+	for _, v := range pkg.Versions {
+		if v.Version != version {
+			continue
+		}
+		data, err := s.Upstream.fetchArchive(ctx, v.ArchiveURL, v.ArchiveSha256)
+		if err != nil {
+			return nil, true, err
+		}
+		if s.Upstream.cfg.CacheArchives {
+			s.Upstream.enqueueCache(ctx, name, version, func(ctx context.Context) {
+				s.cacheUpstreamVersion(ctx, name, version, data)
+			})
+		}
+		return data, true, nil
+	}
+	return nil, true, fmt.Errorf("%w: version %s of package %s", ErrNotFound, version, name)
+}
+
+// cacheUpstreamVersion stores an archive downloaded from upstream and records
+// it as a proxied version; it runs on the cache worker. Caching is best
+// effort, failures are logged and the archive is downloaded again on the next
+// request.
+func (s *packageService) cacheUpstreamVersion(ctx context.Context, name, version string, data []byte) {
+	contents, err := ValidateArchive(ctx, s.Pubspec, data)
+	if err != nil {
+		slog.Warn("Not caching invalid upstream archive", "package", name, "version", version, "error", err)
+		return
+	}
+	pubspec := contents.Pubspec
+	if pubspec.Name != name || pubspec.Version != version {
+		slog.Warn("Not caching upstream archive for another version", "package", name, "version", version, "archive", pubspec.Name+"@"+pubspec.Version)
+		return
+	}
+
+	pkg, err := s.Package.GetOrCreatePackage(ctx, name, false)
+	if err != nil {
+		slog.Warn("Failed to cache upstream archive", "package", name, "version", version, "error", err)
+		return
+	}
+	// A first-party version published meanwhile takes the package over
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		slog.Warn("Failed to cache upstream archive", "package", name, "version", version, "error", err)
+		return
+	}
+	if !mirrored(versions) || slices.ContainsFunc(versions, func(v *domain.PackageVersion) bool { return v.Version == version }) {
+		return
+	}
+
+	path, err := s.Storage.Store(ctx, name, version, data)
+	if err != nil {
+		slog.Warn("Failed to cache upstream archive", "package", name, "version", version, "error", err)
+		return
+	}
+
+	sha256Hash := s.calculateSHA256(data)
+	sizeBytes := int64(len(data))
+	created, err := s.Package.CreateVersion(ctx, &domain.PackageVersion{
+		PackageID:     pkg.ID,
+		Version:       version,
+		Description:   &pubspec.Description,
+		PubspecYaml:   contents.PubspecYAML,
+		Readme:        contents.Readme,
+		Changelog:     contents.Changelog,
+		ArchivePath:   path,
+		ArchiveSha256: &sha256Hash,
+		Platforms:     pubspec.SupportedPlatforms(),
+		SizeBytes:     &sizeBytes,
+		Funding:       pubspec.Funding,
+		Screenshots:   archiveScreenshots(data, pubspec.Screenshots),
+		Proxied:       true,
+	})
+	if err != nil {
+		_ = s.Storage.Delete(context.WithoutCancel(ctx), path)
+		slog.Warn("Failed to record cached upstream version", "package", name, "version", version, "error", err)
+		return
+	}
+	s.prerenderDocs(ctx, created)
+}
+
+// upstreamArchiveReader is upstreamArchive for ranged reads
+func (s *packageService) upstreamArchiveReader(ctx context.Context, name, version string) (io.ReadSeekCloser, bool, error) {
+	data, ok, err := s.upstreamArchive(ctx, name, version)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, true, nil
+}
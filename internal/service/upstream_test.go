@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUpstream is a pub server hosting one version of each of its packages
+type fakeUpstream struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	archives map[string][]byte
+	requests map[string]int
+}
+
+func newFakeUpstream(t *testing.T, packages ...string) *fakeUpstream {
+	t.Helper()
+	u := &fakeUpstream{archives: make(map[string][]byte), requests: make(map[string]int)}
+	for _, name := range packages {
+		u.archives[name] = testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: " + name + "\nversion: 1.0.0",
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/packages/{package}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("package")
+		u.record(r.URL.Path)
+		archive, ok := u.archives[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		sum := sha256.Sum256(archive)
+		version := domain.VersionResponse{
+			Version:       "1.0.0",
+			ArchiveURL:    u.URL + "/archives/" + name + "-1.0.0.tar.gz",
+			ArchiveSha256: hex.EncodeToString(sum[:]),
+			Pubspec:       map[string]any{"name": name, "version": "1.0.0"},
+		}
+		w.Header().Set("Content-Type", "application/vnd.pub.v2+json")
+		_ = json.NewEncoder(w).Encode(domain.PackageResponse{Name: name, Latest: version, Versions: []domain.VersionResponse{version}})
+	})
+	mux.HandleFunc("GET /archives/{file}", func(w http.ResponseWriter, r *http.Request) {
+		u.record(r.URL.Path)
+		for name, archive := range u.archives {
+			if r.PathValue("file") == name+"-1.0.0.tar.gz" {
+				_, _ = w.Write(archive)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	u.Server = httptest.NewServer(mux)
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *fakeUpstream) record(path string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests[path]++
+}
+
+func (u *fakeUpstream) count(path string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[path]
+}
+
+func TestPubService_Upstream(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	upstream := newFakeUpstream(t, "remote", "shadowed", "squatted")
+	proxy := NewUpstreamProxy(UpstreamConfig{URL: upstream.URL, CacheArchives: true})
+	t.Cleanup(proxy.Close)
+	svc := NewPubService(PackageDependencies{
+		Package:  repos.DB.Repo,
+		Storage:  repos.StorageSvc,
+		Pubspec:  repos.PubspecSvc,
+		BaseURL:  "http://localhost:8080",
+		Upstream: proxy,
+	})
+	ctx := context.Background()
+
+	t.Run("cache miss is proxied", func(t *testing.T) {
+		pkg, err := svc.GetPackage(ctx, "remote")
+		if err != nil {
+			t.Fatalf("GetPackage failed: %v", err)
+		}
+		if pkg == nil || pkg.Latest.Version != "1.0.0" {
+			t.Fatalf("Expected the upstream package, got %+v", pkg)
+		}
+		// Archives are downloaded through this server, not from upstream directly
+		if expected := "http://localhost:8080/packages/remote/versions/1.0.0/download"; pkg.Latest.ArchiveURL != expected || pkg.Versions[0].ArchiveURL != expected {
+			t.Errorf("Expected archive URL %s, got %s", expected, pkg.Latest.ArchiveURL)
+		}
+		if !pkg.Latest.Proxied || !pkg.Versions[0].Proxied {
+			t.Error("Expected upstream versions to be marked as proxied")
+		}
+
+		for range 2 {
+			data, err := svc.DownloadPackage(ctx, "remote", "1.0.0")
+			if err != nil {
+				t.Fatalf("DownloadPackage failed: %v", err)
+			}
+			if string(data) != string(upstream.archives["remote"]) {
+				t.Error("Expected the upstream archive")
+			}
+			// Archives are cached in the background
+			proxy.waitCached()
+		}
+		// The second download is served from the local cache
+		if n := upstream.count("/archives/remote-1.0.0.tar.gz"); n != 1 {
+			t.Errorf("Expected 1 upstream archive download, got %d", n)
+		}
+
+		// The cached archive is recorded as a proxied version
+		cached, err := repos.DB.Repo.GetPackage(ctx, "remote")
+		if err != nil || cached == nil {
+			t.Fatalf("Expected a package row for the cached archive, got %v", err)
+		}
+		versions, err := repos.DB.Repo.GetPackageVersions(ctx, cached.ID)
+		if err != nil {
+			t.Fatalf("GetPackageVersions failed: %v", err)
+		}
+		if len(versions) != 1 || !versions[0].Proxied || versions[0].Uploader != nil {
+			t.Errorf("Expected one proxied version without an uploader, got %+v", versions)
+		}
+
+		archive, err := svc.OpenPackageArchive(ctx, "remote", "1.0.0", true)
+		if err != nil {
+			t.Fatalf("OpenPackageArchive failed: %v", err)
+		}
+		defer func() { _ = archive.Close() }()
+		if data, _ := io.ReadAll(archive); string(data) != string(upstream.archives["remote"]) {
+			t.Error("Expected the cached upstream archive")
+		}
+	})
+
+	t.Run("local hit is not proxied", func(t *testing.T) {
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: shadowed\nversion: 2.0.0",
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+
+		pkg, err := svc.GetPackage(ctx, "shadowed")
+		if err != nil {
+			t.Fatalf("GetPackage failed: %v", err)
+		}
+		if pkg == nil || pkg.Latest.Version != "2.0.0" || len(pkg.Versions) != 1 {
+			t.Fatalf("Expected only the local package, got %+v", pkg)
+		}
+		// Versions only upstream has are not merged into local packages
+		if _, err := svc.DownloadPackage(ctx, "shadowed", "1.0.0"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a version only upstream has, got %v", err)
+		}
+		if n := upstream.count("/api/packages/shadowed"); n != 0 {
+			t.Errorf("Expected no upstream requests for a local package, got %d", n)
+		}
+	})
+
+	t.Run("unknown upstream", func(t *testing.T) {
+		pkg, err := svc.GetPackage(ctx, "nowhere")
+		if err != nil || pkg != nil {
+			t.Errorf("Expected no package, got %+v, %v", pkg, err)
+		}
+		if _, err := svc.DownloadPackage(ctx, "nowhere", "1.0.0"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("publishing takes over a proxied package", func(t *testing.T) {
+		if _, err := svc.DownloadPackage(ctx, "squatted", "1.0.0"); err != nil {
+			t.Fatalf("DownloadPackage failed: %v", err)
+		}
+		proxy.waitCached()
+		archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+			"pubspec.yaml": "name: squatted\nversion: 2.0.0",
+		})
+		if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+			t.Fatalf("PublishPackage failed: %v", err)
+		}
+
+		pkg, err := repos.DB.Repo.GetPackage(ctx, "squatted")
+		if err != nil || pkg == nil {
+			t.Fatalf("GetPackage failed: %v", err)
+		}
+		versions, err := repos.DB.Repo.GetPackageVersions(ctx, pkg.ID)
+		if err != nil {
+			t.Fatalf("GetPackageVersions failed: %v", err)
+		}
+		if len(versions) != 1 || versions[0].Version != "2.0.0" || versions[0].Proxied {
+			t.Errorf("Expected only the published version, got %+v", versions)
+		}
+		if _, err := svc.DownloadPackage(ctx, "squatted", "1.0.0"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for the dropped proxied version, got %v", err)
+		}
+	})
+
+	t.Run("upstream down", func(t *testing.T) {
+		down := NewPubService(PackageDependencies{
+			Package:  repos.DB.Repo,
+			Storage:  repos.StorageSvc,
+			Pubspec:  repos.PubspecSvc,
+			Upstream: NewUpstreamProxy(UpstreamConfig{URL: "http://127.0.0.1:1"}),
+		})
+		if _, err := down.GetPackage(ctx, "shadowed-elsewhere"); !errors.Is(err, ErrUpstreamUnavailable) {
+			t.Errorf("Expected ErrUpstreamUnavailable, got %v", err)
+		}
+
+		// Cached versions are still served
+		pkg, err := down.GetPackage(ctx, "remote")
+		if err != nil {
+			t.Fatalf("GetPackage failed: %v", err)
+		}
+		if pkg == nil || len(pkg.Versions) != 1 || !pkg.Latest.Proxied {
+			t.Fatalf("Expected the cached proxied version, got %+v", pkg)
+		}
+		data, err := down.DownloadPackage(ctx, "remote", "1.0.0")
+		if err != nil {
+			t.Fatalf("DownloadPackage failed: %v", err)
+		}
+		if string(data) != string(upstream.archives["remote"]) {
+			t.Error("Expected the cached upstream archive")
+		}
+	})
+}
+
+func TestUpstreamProxy_MetadataTTL(t *testing.T) {
+	upstream := newFakeUpstream(t, "remote")
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	proxy := NewUpstreamProxy(UpstreamConfig{URL: upstream.URL, MetadataTTL: time.Minute, Clock: clk})
+	ctx := context.Background()
+
+	for range 2 {
+		if pkg, err := proxy.fetchPackage(ctx, "remote"); err != nil || pkg == nil {
+			t.Fatalf("Expected the upstream package, got %+v, %v", pkg, err)
+		}
+		if pkg, err := proxy.fetchPackage(ctx, "missing"); err != nil || pkg != nil {
+			t.Fatalf("Expected no package, got %+v, %v", pkg, err)
+		}
+	}
+	if n := upstream.count("/api/packages/remote"); n != 1 {
+		t.Errorf("Expected 1 upstream request for a cached package, got %d", n)
+	}
+	if n := upstream.count("/api/packages/missing"); n != 1 {
+		t.Errorf("Expected 1 upstream request for a cached miss, got %d", n)
+	}
+
+	clk.Advance(time.Minute + time.Second)
+	if _, err := proxy.fetchPackage(ctx, "remote"); err != nil {
+		t.Fatalf("fetchPackage failed: %v", err)
+	}
+	if n := upstream.count("/api/packages/remote"); n != 2 {
+		t.Errorf("Expected an expired entry to be fetched again, got %d requests", n)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"repub/internal/domain"
+	"repub/internal/repository/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VerifyVersion re-extracts the pubspec from a version's stored archive and
+// reports where it disagrees with the version row: the declared name and
+// version, the stored pubspec.yaml and the archive checksum.
+func (s *packageService) VerifyVersion(ctx context.Context, name, version string) (*domain.VersionVerification, error) {
+	pkg, row, err := s.versionRow(ctx, name, version)
+	if err != nil || row == nil {
+		return nil, err
+	}
+
+	result := &domain.VersionVerification{Package: name, Version: version}
+	result.Mismatches, err = s.archiveMismatches(ctx, pkg.Name, row)
+	if err != nil {
+		return nil, err
+	}
+	result.Consistent = len(result.Mismatches) == 0
+	return result, nil
+}
+
+// GetVersionRecord returns a version's row as stored, private packages
+// included, for operators inspecting its state
+func (s *packageService) GetVersionRecord(ctx context.Context, name, version string) (*domain.PackageVersion, error) {
+	_, row, err := s.versionRow(ctx, name, version)
+	return row, err
+}
+
+// versionRow looks up a version's row regardless of visibility, nil if the
+// package or version doesn't exist
+func (s *packageService) versionRow(ctx context.Context, name, version string) (*domain.Package, *domain.PackageVersion, error) {
+	pkg, err := s.Package.GetPackage(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if pkg == nil {
+		return nil, nil, nil
+	}
+
+	versions, err := s.Package.GetPackageVersions(ctx, pkg.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return pkg, v, nil
+		}
+	}
+	return pkg, nil, nil
+}
+
+// archiveMismatches describes each way the archive of v contradicts its row
+func (s *packageService) archiveMismatches(ctx context.Context, packageName string, v *domain.PackageVersion) ([]string, error) {
+	data, err := s.Storage.Get(ctx, v.ArchivePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []string{fmt.Sprintf("archive %s not found in storage", v.ArchivePath)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get archive: %w", ErrStorageUnavailable, err)
+	}
+
+	var mismatches []string
+	if v.ArchiveSha256 != nil {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); actual != *v.ArchiveSha256 {
+			mismatches = append(mismatches, fmt.Sprintf("archive sha256 is %s, recorded %s", actual, *v.ArchiveSha256))
+		}
+	}
+
+	pubspecContent, _, _, err := extractFilesFromArchive(data)
+	if err != nil {
+		return append(mismatches, fmt.Sprintf("archive is unreadable: %v", err)), nil
+	}
+	var declared struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal([]byte(pubspecContent), &declared); err != nil {
+		return append(mismatches, fmt.Sprintf("archive pubspec.yaml is invalid: %v", err)), nil
+	}
+
+	if declared.Name != packageName {
+		mismatches = append(mismatches, fmt.Sprintf("archive declares package %q, recorded %q", declared.Name, packageName))
+	}
+	if declared.Version != v.Version {
+		mismatches = append(mismatches, fmt.Sprintf("archive declares version %q, recorded %q", declared.Version, v.Version))
+	}
+	if pubspecContent != v.PubspecYaml {
+		mismatches = append(mismatches, "stored pubspec.yaml differs from the archive's")
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"repub/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the configured secret, as "sha256=<hex>"
+const WebhookSignatureHeader = "X-Repub-Signature"
+
+// PublishNotifier is told about every successful publish. Implementations
+// must not block, publishing waits for NotifyPublished to return.
+type PublishNotifier interface {
+	NotifyPublished(event domain.PublishEvent)
+}
+
+// ReportNotifier is told about every new abuse report, with the same
+// non-blocking requirement as PublishNotifier
+type ReportNotifier interface {
+	NotifyReported(event domain.ReportEvent)
+}
+
+// WebhookConfig configures a WebhookNotifier; zero values select the defaults
+type WebhookConfig struct {
+	URL string
+	// Secret signs payloads when set, see WebhookSignatureHeader
+	Secret string
+	// QueueSize bounds the pending events, further events are dropped (default 100)
+	QueueSize int
+	// MaxAttempts is the number of deliveries tried per event (default 3)
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled after each attempt (default 1s)
+	Backoff time.Duration
+	// Transport is used by the default Client, nil for http.DefaultTransport
+	Transport http.RoundTripper
+	Client    *http.Client
+}
+
+// WebhookNotifier POSTs publish and report events to a URL from a background
+// worker
+type WebhookNotifier struct {
+	cfg   WebhookConfig
+	queue chan webhookEvent
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// webhookEvent is a queued payload with the package and version it concerns,
+// for logging
+type webhookEvent struct {
+	payload       any
+	name, version string
+}
+
+// NewWebhookNotifier starts a notifier; Close stops it after draining the queue
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second, Transport: cfg.Transport}
+	}
+
+	n := &WebhookNotifier{
+		cfg:   cfg,
+		queue: make(chan webhookEvent, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// NotifyPublished queues event for delivery, dropping it if the queue is full
+func (n *WebhookNotifier) NotifyPublished(event domain.PublishEvent) {
+	n.enqueue(webhookEvent{payload: event, name: event.Package, version: event.Version})
+}
+
+// NotifyReported queues event for delivery, dropping it if the queue is full
+func (n *WebhookNotifier) NotifyReported(event domain.ReportEvent) {
+	n.enqueue(webhookEvent{payload: event, name: event.Package, version: event.Version})
+}
+
+func (n *WebhookNotifier) enqueue(event webhookEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		slog.Warn("Publish webhook queue is full, dropping event", "package", event.name, "version", event.version)
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered
+func (n *WebhookNotifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+	<-n.done
+}
+
+func (n *WebhookNotifier) run() {
+	defer close(n.done)
+	for event := range n.queue {
+		if err := n.deliver(event); err != nil {
+			slog.Error("Failed to deliver publish webhook", "package", event.name, "version", event.version, "error", err)
+		}
+	}
+}
+
+// deliver sends event, retrying failed requests and 5xx/429 responses
+func (n *WebhookNotifier) deliver(event webhookEvent) error {
+	body, err := json.Marshal(event.payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	backoff := n.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.cfg.MaxAttempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		slog.Warn("Publish webhook failed, retrying", "attempt", attempt, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one request, reporting whether a failure is worth retrying
+func (n *WebhookNotifier) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signPayload(n.cfg.Secret, body))
+	}
+
+	resp, err := n.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+}
+
+// signPayload returns the hex HMAC-SHA256 of body keyed with secret
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"repub/internal/clock"
+	"repub/internal/domain"
+	"repub/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRecorder is a webhook endpoint answering with the queued statuses, then 200
+type webhookRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rec *webhookRecorder) received() ([]*http.Request, [][]byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.requests, rec.bodies
+}
+
+func TestWebhookNotifier_Publish(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	rec := &webhookRecorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: "s3cret"})
+	publishedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewPubService(PackageDependencies{
+		Package:  repos.DB.Repo,
+		Storage:  repos.StorageSvc,
+		Pubspec:  repos.PubspecSvc,
+		BaseURL:  "http://localhost:8080",
+		Clock:    clock.NewFake(publishedAt),
+		Notifier: notifier,
+	})
+
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{
+		"pubspec.yaml": "name: hooked\nversion: 1.0.0\ndescription: A webhook test package",
+	})
+	if _, err := svc.PublishPackage(context.Background(), &domain.PublishRequest{Archive: archive, Uploader: "ci@example.com"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+	notifier.Close()
+
+	requests, bodies := rec.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 webhook request, got %d", len(requests))
+	}
+
+	req, body := requests[0], bodies[0]
+	if req.Method != http.MethodPost {
+		t.Errorf("Expected POST, got %s", req.Method)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected JSON content type, got %s", contentType)
+	}
+	if signature := req.Header.Get(WebhookSignatureHeader); signature != "sha256="+signPayload("s3cret", body) {
+		t.Errorf("Unexpected signature %q", signature)
+	}
+
+	var event domain.PublishEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Failed to decode webhook body: %v", err)
+	}
+	expected := domain.PublishEvent{Event: "publish", Package: "hooked", Version: "1.0.0", Uploader: "ci@example.com", PublishedAt: publishedAt}
+	if event != expected {
+		t.Errorf("Expected event %+v, got %+v", expected, event)
+	}
+}
+
+func TestWebhookNotifier_Report(t *testing.T) {
+	repos := testutil.SetupTestRepositories(t)
+	defer repos.Close()
+
+	rec := &webhookRecorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL})
+	reportedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewPubService(PackageDependencies{
+		Package:        repos.DB.Repo,
+		Storage:        repos.StorageSvc,
+		Pubspec:        repos.PubspecSvc,
+		Clock:          clock.NewFake(reportedAt),
+		ReportNotifier: notifier,
+	})
+
+	ctx := context.Background()
+	archive := testutil.CreateTestTarGzArchive(t, map[string]string{"pubspec.yaml": "name: flagged\nversion: 1.0.0"})
+	if _, err := svc.PublishPackage(ctx, &domain.PublishRequest{Archive: archive, Uploader: "ci"}); err != nil {
+		t.Fatalf("PublishPackage failed: %v", err)
+	}
+	if _, err := svc.ReportVersion(ctx, "flagged", "1.0.0", "moderator@example.com", "Typosquats a popular package"); err != nil {
+		t.Fatalf("ReportVersion failed: %v", err)
+	}
+	notifier.Close()
+
+	_, bodies := rec.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 webhook request, got %d", len(bodies))
+	}
+	var event domain.ReportEvent
+	if err := json.Unmarshal(bodies[0], &event); err != nil {
+		t.Fatalf("Failed to decode webhook body: %v", err)
+	}
+	expected := domain.ReportEvent{
+		Event:      "report",
+		Package:    "flagged",
+		Version:    "1.0.0",
+		Reporter:   "moderator@example.com",
+		Reason:     "Typosquats a popular package",
+		ReportedAt: reportedAt,
+	}
+	if event != expected {
+		t.Errorf("Expected event %+v, got %+v", expected, event)
+	}
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		expectedAttempts int
+	}{
+		{"retries server errors", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3},
+		{"gives up after max attempts", []int{500, 500, 500, 500}, 3},
+		{"retries rate limiting", []int{http.StatusTooManyRequests}, 2},
+		{"does not retry client errors", []int{http.StatusBadRequest}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &webhookRecorder{statuses: tt.statuses}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, Backoff: time.Millisecond})
+			notifier.NotifyPublished(domain.PublishEvent{Package: "retried", Version: "1.0.0"})
+			notifier.Close()
+
+			requests, _ := rec.received()
+			if len(requests) != tt.expectedAttempts {
+				t.Fatalf("Expected %d attempts, got %d", tt.expectedAttempts, len(requests))
+			}
+			if sig := requests[0].Header.Get(WebhookSignatureHeader); sig != "" {
+				t.Errorf("Expected no signature without a secret, got %q", sig)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, QueueSize: 1})
+
+	// A stalled webhook must not block publishing; events beyond the queue are dropped
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			notifier.NotifyPublished(domain.PublishEvent{Package: "flood", Version: "1.0.0"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("NotifyPublished blocked on a stalled webhook")
+	}
+
+	close(release)
+	notifier.Close()
+
+	// At most one event in flight plus one queued
+	mu.Lock()
+	defer mu.Unlock()
+	if received == 0 || received > 2 {
+		t.Errorf("Expected 1 or 2 delivered events, got %d", received)
+	}
+
+	// Events after Close are ignored rather than panicking
+	notifier.NotifyPublished(domain.PublishEvent{Package: "late", Version: "1.0.0"})
+}
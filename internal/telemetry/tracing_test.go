@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupTracing_Disabled(t *testing.T) {
+	shutdown, err := SetupTracing(context.Background(), "")
+	if err != nil {
+		t.Fatalf("SetupTracing failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no-op shutdown, got: %v", err)
+	}
+}
+
+func TestEndSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	EndSpan(ok, nil)
+
+	_, failed := tracer.Start(context.Background(), "failed")
+	EndSpan(failed, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 ended spans, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("Expected unset status for successful span, got %v", spans[0].Status().Code)
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Errorf("Expected error status for failed span, got %v", spans[1].Status().Code)
+	}
+}
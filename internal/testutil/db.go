@@ -26,7 +26,7 @@ type TestDatabase struct {
 }
 
 // SetupTestDatabase creates an in-memory SQLite database for testing
-func SetupTestDatabase(t *testing.T) *TestDatabase {
+func SetupTestDatabase(t testing.TB) *TestDatabase {
 	t.Helper()
 
 	// Create in-memory SQLite database
@@ -34,6 +34,9 @@ func SetupTestDatabase(t *testing.T) *TestDatabase {
 	if err != nil {
 		t.Fatalf("Failed to open SQLite database: %v", err)
 	}
+	// Every connection to :memory: opens a separate empty database, so
+	// concurrent callers must share a single connection
+	db.SetMaxOpenConns(1)
 
 	// Apply schema using helper
 	schema, err := schemaFS.ReadFile("schema_sqlite.sql")
@@ -47,7 +50,7 @@ func SetupTestDatabase(t *testing.T) *TestDatabase {
 
 	// Create queries and repository
 	queries := sqlite.New(db)
-	repo := newSQLitePackageRepository(queries)
+	repo := newSQLitePackageRepository(db, queries)
 
 	return &TestDatabase{
 		DB:      db,
@@ -73,7 +76,7 @@ type TestRepositories struct {
 }
 
 // SetupTestRepositories creates a complete test environment with all repositories
-func SetupTestRepositories(t *testing.T) *TestRepositories {
+func SetupTestRepositories(t testing.TB) *TestRepositories {
 	t.Helper()
 
 	// Setup database
@@ -81,7 +84,7 @@ func SetupTestRepositories(t *testing.T) *TestRepositories {
 
 	// Create temporary directory for storage
 	tmpDir := t.TempDir()
-	storageRepo := storage.NewLocalRepository(tmpDir)
+	storageRepo := storage.NewLocalRepository(tmpDir, storage.DefaultKeyTemplate)
 	pubspecRepo := pubspec.NewParserRepository()
 
 	return &TestRepositories{
@@ -192,7 +195,7 @@ type CreateVersionRequest struct {
 func (tr *TestRepositories) CreateTestArchive(t *testing.T, name, version string, content []byte) string {
 	t.Helper()
 
-	archivePath, err := tr.StorageSvc.Store(name, version, content)
+	archivePath, err := tr.StorageSvc.Store(context.Background(), name, version, content)
 	if err != nil {
 		t.Fatalf("Failed to create test archive: %v", err)
 	}
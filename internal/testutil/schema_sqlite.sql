@@ -7,7 +7,9 @@ CREATE TABLE packages (
     repository TEXT,
     documentation TEXT,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    like_count INTEGER NOT NULL DEFAULT 0,
+    download_count INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE TABLE package_versions (
@@ -23,6 +25,13 @@ CREATE TABLE package_versions (
     uploader TEXT,
     retracted BOOLEAN NOT NULL DEFAULT 0,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    platforms TEXT NOT NULL DEFAULT '[]',
+    size_bytes INTEGER,
+    funding TEXT NOT NULL DEFAULT '[]',
+    screenshots TEXT NOT NULL DEFAULT '[]',
+    proxied BOOLEAN NOT NULL DEFAULT 0,
+    readme_html TEXT, -- README rendered to sanitized HTML
+    changelog_html TEXT, -- CHANGELOG rendered to sanitized HTML
     UNIQUE(package_id, version)
 );
 
@@ -32,5 +41,48 @@ CREATE TABLE package_uploaders (
     PRIMARY KEY (package_id, uploader)
 );
 
+-- The first uploader of a package, so concurrent first publishes can't both
+-- become its owner
+CREATE TABLE package_claims (
+    package_id INTEGER PRIMARY KEY REFERENCES packages(id) ON DELETE CASCADE,
+    uploader TEXT NOT NULL
+);
+
+CREATE TABLE package_likes (
+    package_id INTEGER NOT NULL REFERENCES packages(id) ON DELETE CASCADE,
+    liker TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (package_id, liker)
+);
+
+CREATE TABLE download_events (
+    package_id INTEGER NOT NULL REFERENCES packages(id) ON DELETE CASCADE,
+    version TEXT NOT NULL,
+    day TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (package_id, version, day)
+);
+
+CREATE TABLE tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    scope TEXT NOT NULL,
+    expires_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    package_id INTEGER NOT NULL REFERENCES packages(id) ON DELETE CASCADE,
+    version TEXT NOT NULL,
+    reporter TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE INDEX idx_packages_name ON packages(name);
-CREATE INDEX idx_package_versions_package_id ON package_versions(package_id);
\ No newline at end of file
+CREATE INDEX idx_package_versions_package_id ON package_versions(package_id);
+CREATE INDEX idx_package_versions_created ON package_versions(package_id, created_at DESC, id DESC);
+CREATE UNIQUE INDEX idx_reports_reporter ON reports(package_id, version, reporter);
\ No newline at end of file
@@ -3,18 +3,41 @@ package testutil
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"repub/internal/domain"
+	"repub/internal/repository/pkg"
 	"repub/internal/repository/pkg/sqlite"
+
+	moderncsqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 // sqlitePackageRepository implements pkg.Repository using SQLite
 type sqlitePackageRepository struct {
+	db      *sql.DB
 	queries *sqlite.Queries
 }
 
-func newSQLitePackageRepository(queries *sqlite.Queries) *sqlitePackageRepository {
-	return &sqlitePackageRepository{queries: queries}
+func newSQLitePackageRepository(db *sql.DB, queries *sqlite.Queries) *sqlitePackageRepository {
+	return &sqlitePackageRepository{db: db, queries: queries}
+}
+
+// inTx runs fn with queries bound to a transaction, committed when fn
+// succeeds and rolled back otherwise
+func (r *sqlitePackageRepository) inTx(ctx context.Context, fn func(queries *sqlite.Queries) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(r.queries.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *sqlitePackageRepository) GetPackage(ctx context.Context, name string) (*domain.Package, error) {
@@ -34,6 +57,8 @@ func (r *sqlitePackageRepository) GetPackage(ctx context.Context, name string) (
 		Homepage:      sqliteNullStringToPtr(pkg.Homepage),
 		Repository:    sqliteNullStringToPtr(pkg.Repository),
 		Documentation: sqliteNullStringToPtr(pkg.Documentation),
+		LikeCount:     pkg.LikeCount,
+		DownloadCount: pkg.DownloadCount,
 		CreatedAt:     pkg.CreatedAt,
 		UpdatedAt:     pkg.UpdatedAt,
 	}, nil
@@ -56,11 +81,57 @@ func (r *sqlitePackageRepository) CreatePackage(ctx context.Context, name string
 		Homepage:      sqliteNullStringToPtr(pkg.Homepage),
 		Repository:    sqliteNullStringToPtr(pkg.Repository),
 		Documentation: sqliteNullStringToPtr(pkg.Documentation),
+		LikeCount:     pkg.LikeCount,
+		DownloadCount: pkg.DownloadCount,
 		CreatedAt:     pkg.CreatedAt,
 		UpdatedAt:     pkg.UpdatedAt,
 	}, nil
 }
 
+func (r *sqlitePackageRepository) GetOrCreatePackage(ctx context.Context, name string, private bool) (*domain.Package, error) {
+	_, err := r.queries.CreatePackageIfNotExists(ctx, sqlite.CreatePackageIfNotExistsParams{
+		Name:    name,
+		Private: private,
+	})
+	// No row is returned when the package already exists
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	pkg, err := r.GetPackage(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("package %s not found after create", name)
+	}
+	return pkg, nil
+}
+
+func (r *sqlitePackageRepository) SetPackagePrivate(ctx context.Context, packageID int32, private bool) error {
+	return r.queries.SetPackagePrivate(ctx, sqlite.SetPackagePrivateParams{
+		Private: private,
+		ID:      int64(packageID),
+	})
+}
+
+func (r *sqlitePackageRepository) TouchPackage(ctx context.Context, packageID int32, at time.Time) error {
+	return r.queries.TouchPackage(ctx, sqlite.TouchPackageParams{
+		// Stored as text SQLite's datetime() can parse, like CURRENT_TIMESTAMP
+		UpdatedAt: at.UTC().Format(time.DateTime),
+		ID:        int64(packageID),
+	})
+}
+
+func (r *sqlitePackageRepository) RestorePackageHistory(ctx context.Context, packageID int32, createdAt time.Time, likeCount, downloadCount int64) error {
+	return r.queries.RestorePackageHistory(ctx, sqlite.RestorePackageHistoryParams{
+		CreatedAt:     createdAt.UTC().Format(time.DateTime),
+		LikeCount:     likeCount,
+		DownloadCount: downloadCount,
+		ID:            int64(packageID),
+	})
+}
+
 func (r *sqlitePackageRepository) ListPackages(ctx context.Context, limit, offset int32) ([]*domain.Package, error) {
 	packages, err := r.queries.ListPackages(ctx, sqlite.ListPackagesParams{
 		Limit:  int64(limit),
@@ -69,7 +140,72 @@ func (r *sqlitePackageRepository) ListPackages(ctx context.Context, limit, offse
 	if err != nil {
 		return nil, err
 	}
+	return sqlitePackagesToDomain(packages), nil
+}
+
+func (r *sqlitePackageRepository) ListPackagesSorted(ctx context.Context, sort domain.PackageSort, limit, offset int32) ([]*domain.Package, error) {
+	var packages []sqlite.Package
+	var err error
+	switch sort {
+	case domain.PackageSortName:
+		return r.ListPackages(ctx, limit, offset)
+	case domain.PackageSortUpdated:
+		packages, err = r.queries.ListPackagesByUpdated(ctx, sqlite.ListPackagesByUpdatedParams{Limit: int64(limit), Offset: int64(offset)})
+	case domain.PackageSortDownloads:
+		packages, err = r.queries.ListPackagesByDownloads(ctx, sqlite.ListPackagesByDownloadsParams{Limit: int64(limit), Offset: int64(offset)})
+	default:
+		return nil, fmt.Errorf("unknown package sort %q", sort)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sqlitePackagesToDomain(packages), nil
+}
+
+func (r *sqlitePackageRepository) ListPackagesUpdatedSince(ctx context.Context, since time.Time, limit, offset int32) ([]*domain.Package, error) {
+	packages, err := r.queries.ListPackagesUpdatedSince(ctx, sqlite.ListPackagesUpdatedSinceParams{
+		Since:  since.UTC().Format(time.DateTime),
+		Limit:  int64(limit),
+		Offset: int64(offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sqlitePackagesToDomain(packages), nil
+}
+
+func (r *sqlitePackageRepository) ListPackagesPage(ctx context.Context, since time.Time, after *domain.Package, limit int32) ([]*domain.Package, error) {
+	lastUpdated, lastID := pkg.PackagePageCursor(since, after)
+	packages, err := r.queries.ListPackagesPage(ctx, sqlite.ListPackagesPageParams{
+		LastUpdated: lastUpdated.UTC().Format(time.DateTime),
+		LastID:      int64(lastID),
+		Limit:       int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sqlitePackagesToDomain(packages), nil
+}
 
+func (r *sqlitePackageRepository) ListSitemapPackages(ctx context.Context) ([]*domain.SitemapPackage, error) {
+	rows, err := r.queries.ListPublicVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var packages []*domain.SitemapPackage
+	for _, row := range rows {
+		if len(packages) == 0 || packages[len(packages)-1].Name != row.Name {
+			packages = append(packages, &domain.SitemapPackage{Name: row.Name, UpdatedAt: row.UpdatedAt})
+		}
+		if row.Version.Valid {
+			pkg := packages[len(packages)-1]
+			pkg.Versions = append(pkg.Versions, domain.SitemapVersion{Version: row.Version.String, CreatedAt: row.VersionCreatedAt.Time})
+		}
+	}
+	return packages, nil
+}
+
+func sqlitePackagesToDomain(packages []sqlite.Package) []*domain.Package {
 	result := make([]*domain.Package, len(packages))
 	for i, pkg := range packages {
 		result[i] = &domain.Package{
@@ -80,12 +216,13 @@ func (r *sqlitePackageRepository) ListPackages(ctx context.Context, limit, offse
 			Homepage:      sqliteNullStringToPtr(pkg.Homepage),
 			Repository:    sqliteNullStringToPtr(pkg.Repository),
 			Documentation: sqliteNullStringToPtr(pkg.Documentation),
+			LikeCount:     pkg.LikeCount,
+			DownloadCount: pkg.DownloadCount,
 			CreatedAt:     pkg.CreatedAt,
 			UpdatedAt:     pkg.UpdatedAt,
 		}
 	}
-
-	return result, nil
+	return result
 }
 
 func (r *sqlitePackageRepository) GetPackageVersions(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error) {
@@ -94,25 +231,23 @@ func (r *sqlitePackageRepository) GetPackageVersions(ctx context.Context, packag
 		return nil, err
 	}
 
-	result := make([]*domain.PackageVersion, len(versions))
-	for i, v := range versions {
-		result[i] = &domain.PackageVersion{
-			ID:            int32(v.ID),
-			PackageID:     int32(v.PackageID),
-			Version:       v.Version,
-			Description:   sqliteNullStringToPtr(v.Description),
-			PubspecYaml:   v.PubspecYaml,
-			Readme:        sqliteNullStringToPtr(v.Readme),
-			Changelog:     sqliteNullStringToPtr(v.Changelog),
-			ArchivePath:   v.ArchivePath,
-			ArchiveSha256: sqliteNullStringToPtr(v.ArchiveSha256),
-			Uploader:      sqliteNullStringToPtr(v.Uploader),
-			Retracted:     v.Retracted,
-			CreatedAt:     v.CreatedAt,
-		}
+	return sqliteVersionsFromRows(versions), nil
+}
+
+func (r *sqlitePackageRepository) GetPackageVersionsPage(ctx context.Context, packageID int32, after *domain.PackageVersion, limit int32) ([]*domain.PackageVersion, error) {
+	lastCreated, lastID := pkg.VersionPageCursor(after)
+	versions, err := r.queries.GetPackageVersionsPage(ctx, sqlite.GetPackageVersionsPageParams{
+		PackageID: int64(packageID),
+		// Bound as text SQLite's datetime() can parse, like the stored values
+		LastCreated: lastCreated.UTC().Format(time.DateTime),
+		LastID:      int64(lastID),
+		Limit:       int64(limit),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return sqliteVersionsFromRows(versions), nil
 }
 
 func (r *sqlitePackageRepository) GetLatestVersion(ctx context.Context, packageID int32) (*domain.PackageVersion, error) {
@@ -137,6 +272,74 @@ func (r *sqlitePackageRepository) GetLatestVersion(ctx context.Context, packageI
 		Uploader:      sqliteNullStringToPtr(version.Uploader),
 		Retracted:     version.Retracted,
 		CreatedAt:     version.CreatedAt,
+		Platforms:     sqliteListFromJSON[string](version.Platforms),
+		SizeBytes:     sqliteNullInt64ToPtr(version.SizeBytes),
+		Funding:       sqliteListFromJSON[string](version.Funding),
+		Screenshots:   sqliteListFromJSON[domain.Screenshot](version.Screenshots),
+		Proxied:       version.Proxied,
+		ReadmeHTML:    sqliteNullStringToPtr(version.ReadmeHtml),
+		ChangelogHTML: sqliteNullStringToPtr(version.ChangelogHtml),
+	}, nil
+}
+
+func (r *sqlitePackageRepository) GetVersion(ctx context.Context, packageID int32, version string) (*domain.PackageVersion, error) {
+	row, err := r.queries.GetPackageVersion(ctx, sqlite.GetPackageVersionParams{
+		PackageID: int64(packageID),
+		Version:   version,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sqliteVersionsFromRows([]sqlite.PackageVersion{row})[0], nil
+}
+
+func (r *sqlitePackageRepository) GetVersionNumbers(ctx context.Context, packageID int32) ([]*domain.PackageVersion, error) {
+	rows, err := r.queries.ListPackageVersionNumbers(ctx, int64(packageID))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*domain.PackageVersion, len(rows))
+	for i, row := range rows {
+		versions[i] = &domain.PackageVersion{ID: int32(row.ID), PackageID: packageID, Version: row.Version, Retracted: row.Retracted}
+	}
+	return versions, nil
+}
+
+func (r *sqlitePackageRepository) GetVersionByArchiveSha256(ctx context.Context, packageID int32, sha256 string) (*domain.PackageVersion, error) {
+	version, err := r.queries.GetVersionByArchiveSha256(ctx, sqlite.GetVersionByArchiveSha256Params{
+		PackageID:     int64(packageID),
+		ArchiveSha256: sql.NullString{String: sha256, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &domain.PackageVersion{
+		ID:            int32(version.ID),
+		PackageID:     int32(version.PackageID),
+		Version:       version.Version,
+		Description:   sqliteNullStringToPtr(version.Description),
+		PubspecYaml:   version.PubspecYaml,
+		Readme:        sqliteNullStringToPtr(version.Readme),
+		Changelog:     sqliteNullStringToPtr(version.Changelog),
+		ArchivePath:   version.ArchivePath,
+		ArchiveSha256: sqliteNullStringToPtr(version.ArchiveSha256),
+		Uploader:      sqliteNullStringToPtr(version.Uploader),
+		Retracted:     version.Retracted,
+		CreatedAt:     version.CreatedAt,
+		Platforms:     sqliteListFromJSON[string](version.Platforms),
+		SizeBytes:     sqliteNullInt64ToPtr(version.SizeBytes),
+		Funding:       sqliteListFromJSON[string](version.Funding),
+		Screenshots:   sqliteListFromJSON[domain.Screenshot](version.Screenshots),
+		Proxied:       version.Proxied,
+		ReadmeHTML:    sqliteNullStringToPtr(version.ReadmeHtml),
+		ChangelogHTML: sqliteNullStringToPtr(version.ChangelogHtml),
 	}, nil
 }
 
@@ -166,6 +369,11 @@ func (r *sqlitePackageRepository) CreateVersion(ctx context.Context, version *do
 		uploader = sql.NullString{String: *version.Uploader, Valid: true}
 	}
 
+	var sizeBytes sql.NullInt64
+	if version.SizeBytes != nil {
+		sizeBytes = sql.NullInt64{Int64: *version.SizeBytes, Valid: true}
+	}
+
 	created, err := r.queries.CreatePackageVersion(ctx, sqlite.CreatePackageVersionParams{
 		PackageID:     int64(version.PackageID),
 		Version:       version.Version,
@@ -176,8 +384,17 @@ func (r *sqlitePackageRepository) CreateVersion(ctx context.Context, version *do
 		ArchivePath:   version.ArchivePath,
 		ArchiveSha256: archiveSha256,
 		Uploader:      uploader,
+		Platforms:     sqliteListToJSON(version.Platforms),
+		SizeBytes:     sizeBytes,
+		Funding:       sqliteListToJSON(version.Funding),
+		Screenshots:   sqliteListToJSON(version.Screenshots),
+		Proxied:       version.Proxied,
 	})
 	if err != nil {
+		var sqliteErr *moderncsqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, fmt.Errorf("%w: %s", pkg.ErrVersionExists, version.Version)
+		}
 		return nil, err
 	}
 
@@ -194,9 +411,56 @@ func (r *sqlitePackageRepository) CreateVersion(ctx context.Context, version *do
 		Uploader:      sqliteNullStringToPtr(created.Uploader),
 		Retracted:     created.Retracted,
 		CreatedAt:     created.CreatedAt,
+		Platforms:     sqliteListFromJSON[string](created.Platforms),
+		SizeBytes:     sqliteNullInt64ToPtr(created.SizeBytes),
+		Funding:       sqliteListFromJSON[string](created.Funding),
+		Screenshots:   sqliteListFromJSON[domain.Screenshot](created.Screenshots),
+		Proxied:       created.Proxied,
+		ReadmeHTML:    sqliteNullStringToPtr(created.ReadmeHtml),
+		ChangelogHTML: sqliteNullStringToPtr(created.ChangelogHtml),
 	}, nil
 }
 
+func (r *sqlitePackageRepository) SetVersionRetracted(ctx context.Context, versionID int32, retracted bool) error {
+	return r.queries.SetPackageVersionRetracted(ctx, sqlite.SetPackageVersionRetractedParams{
+		Retracted: retracted,
+		ID:        int64(versionID),
+	})
+}
+
+func (r *sqlitePackageRepository) DeleteVersion(ctx context.Context, versionID int32) error {
+	return r.queries.DeletePackageVersion(ctx, int64(versionID))
+}
+
+func (r *sqlitePackageRepository) SetVersionSize(ctx context.Context, versionID int32, sizeBytes int64) error {
+	return r.queries.SetPackageVersionSize(ctx, sqlite.SetPackageVersionSizeParams{
+		SizeBytes: sql.NullInt64{Int64: sizeBytes, Valid: true},
+		ID:        int64(versionID),
+	})
+}
+
+func (r *sqlitePackageRepository) SetVersionArchivePath(ctx context.Context, versionID int32, archivePath string) error {
+	return r.queries.SetPackageVersionArchivePath(ctx, sqlite.SetPackageVersionArchivePathParams{
+		ArchivePath: archivePath,
+		ID:          int64(versionID),
+	})
+}
+
+func (r *sqlitePackageRepository) SetVersionCreatedAt(ctx context.Context, versionID int32, createdAt time.Time) error {
+	return r.queries.SetPackageVersionCreatedAt(ctx, sqlite.SetPackageVersionCreatedAtParams{
+		CreatedAt: createdAt.UTC().Format(time.DateTime),
+		ID:        int64(versionID),
+	})
+}
+
+func (r *sqlitePackageRepository) SetVersionDocsHTML(ctx context.Context, versionID int32, readmeHTML, changelogHTML string) error {
+	return r.queries.SetPackageVersionDocsHTML(ctx, sqlite.SetPackageVersionDocsHTMLParams{
+		ReadmeHtml:    sql.NullString{String: readmeHTML, Valid: true},
+		ChangelogHtml: sql.NullString{String: changelogHTML, Valid: true},
+		ID:            int64(versionID),
+	})
+}
+
 func (r *sqlitePackageRepository) GetUploaders(ctx context.Context, packageID int32) ([]string, error) {
 	return r.queries.GetPackageUploaders(ctx, sql.NullInt64{Int64: int64(packageID), Valid: true})
 }
@@ -208,9 +472,286 @@ func (r *sqlitePackageRepository) AddUploader(ctx context.Context, packageID int
 	})
 }
 
+func (r *sqlitePackageRepository) ClaimPackage(ctx context.Context, packageID int32, uploader string) (bool, error) {
+	claimant, err := r.queries.ClaimPackage(ctx, sqlite.ClaimPackageParams{
+		PackageID: int64(packageID),
+		Uploader:  uploader,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return claimant == uploader, nil
+}
+
+// LikePackage records the like and counts it in one transaction, SQLite
+// can't write two tables in one statement
+func (r *sqlitePackageRepository) LikePackage(ctx context.Context, packageID int32, liker string) (bool, error) {
+	var liked bool
+	err := r.inTx(ctx, func(queries *sqlite.Queries) error {
+		inserted, err := queries.AddPackageLike(ctx, sqlite.AddPackageLikeParams{
+			PackageID: int64(packageID),
+			Liker:     liker,
+		})
+		if err != nil || inserted == 0 {
+			return err
+		}
+		liked = true
+		return queries.IncrementLikeCount(ctx, int64(packageID))
+	})
+	return liked, err
+}
+
+func (r *sqlitePackageRepository) IncrementDownloadCount(ctx context.Context, packageID int32) error {
+	return r.queries.IncrementDownloadCount(ctx, int64(packageID))
+}
+
+// sqliteDayLayout is how download days are stored, so that they sort and compare as text
+const sqliteDayLayout = "2006-01-02"
+
+func (r *sqlitePackageRepository) RecordDownload(ctx context.Context, packageID int32, version string, day time.Time) error {
+	return r.queries.IncrementDownloadEvent(ctx, sqlite.IncrementDownloadEventParams{
+		PackageID: int64(packageID),
+		Version:   version,
+		Day:       day.Format(sqliteDayLayout),
+	})
+}
+
+// AddDownloadCounts writes each key's bucket and package total in one
+// transaction, SQLite has no data-modifying CTEs
+func (r *sqlitePackageRepository) AddDownloadCounts(ctx context.Context, counts map[domain.DownloadKey]int64) error {
+	return r.inTx(ctx, func(queries *sqlite.Queries) error {
+		for key, count := range counts {
+			err := queries.AddDownloadEvents(ctx, sqlite.AddDownloadEventsParams{
+				PackageID: int64(key.PackageID),
+				Version:   key.Version,
+				Day:       key.Day.Format(sqliteDayLayout),
+				Count:     count,
+			})
+			if err != nil {
+				return err
+			}
+			err = queries.AddDownloadCount(ctx, sqlite.AddDownloadCountParams{DownloadCount: count, ID: int64(key.PackageID)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *sqlitePackageRepository) GetDownloadHistory(ctx context.Context, packageID int32, since time.Time) ([]*domain.VersionDownloads, error) {
+	rows, err := r.queries.GetDownloadEvents(ctx, sqlite.GetDownloadEventsParams{
+		PackageID: int64(packageID),
+		Day:       since.Format(sqliteDayLayout),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.VersionDownloads, len(rows))
+	for i, row := range rows {
+		day, err := time.Parse(sqliteDayLayout, row.Day)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = &domain.VersionDownloads{
+			Day:     day,
+			Version: row.Version,
+			Count:   row.Count,
+		}
+	}
+	return result, nil
+}
+
+func (r *sqlitePackageRepository) GetInstanceStats(ctx context.Context) (*domain.InstanceStats, error) {
+	row, err := r.queries.GetInstanceStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.InstanceStats{
+		Packages:     row.PackageCount,
+		Versions:     row.VersionCount,
+		StorageBytes: row.StorageBytes,
+		Downloads:    row.DownloadCount,
+		Publishers:   row.PublisherCount,
+	}, nil
+}
+
+func (r *sqlitePackageRepository) CreateToken(ctx context.Context, token *domain.Token) (*domain.Token, error) {
+	created, err := r.queries.CreateToken(ctx, sqlite.CreateTokenParams{
+		Name:      token.Name,
+		TokenHash: token.TokenHash,
+		Scope:     string(token.Scope),
+		ExpiresAt: sqlitePtrToNullTime(token.ExpiresAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sqliteTokenFromRow(created), nil
+}
+
+func (r *sqlitePackageRepository) GetTokenByHash(ctx context.Context, tokenHash string) (*domain.Token, error) {
+	token, err := r.queries.GetTokenByHash(ctx, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sqliteTokenFromRow(token), nil
+}
+
+func (r *sqlitePackageRepository) ListTokens(ctx context.Context) ([]*domain.Token, error) {
+	tokens, err := r.queries.ListTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Token, len(tokens))
+	for i, token := range tokens {
+		result[i] = sqliteTokenFromRow(token)
+	}
+	return result, nil
+}
+
+func (r *sqlitePackageRepository) RevokeToken(ctx context.Context, tokenID int32) (bool, error) {
+	revoked, err := r.queries.RevokeToken(ctx, int64(tokenID))
+	if err != nil {
+		return false, err
+	}
+	return revoked > 0, nil
+}
+
+func (r *sqlitePackageRepository) CreateReport(ctx context.Context, report *domain.Report) (*domain.Report, error) {
+	created, err := r.queries.CreateReport(ctx, sqlite.CreateReportParams{
+		PackageID: int64(report.PackageID),
+		Version:   report.Version,
+		Reporter:  report.Reporter,
+		Reason:    report.Reason,
+	})
+	if err != nil {
+		var sqliteErr *moderncsqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, fmt.Errorf("%w: %s %s by %s", pkg.ErrReportExists, report.Package, report.Version, report.Reporter)
+		}
+		return nil, err
+	}
+	return &domain.Report{
+		ID:        int32(created.ID),
+		PackageID: int32(created.PackageID),
+		Package:   report.Package,
+		Version:   created.Version,
+		Reporter:  created.Reporter,
+		Reason:    created.Reason,
+		CreatedAt: created.CreatedAt,
+	}, nil
+}
+
+func (r *sqlitePackageRepository) ListReports(ctx context.Context, before, limit int32) ([]*domain.Report, error) {
+	rows, err := r.queries.ListReports(ctx, sqlite.ListReportsParams{Before: int64(before), MaxReports: int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Report, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.Report{
+			ID:        int32(row.ID),
+			Package:   row.PackageName,
+			Version:   row.Version,
+			Reporter:  row.Reporter,
+			Reason:    row.Reason,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+func sqliteVersionsFromRows(versions []sqlite.PackageVersion) []*domain.PackageVersion {
+	result := make([]*domain.PackageVersion, len(versions))
+	for i, v := range versions {
+		result[i] = &domain.PackageVersion{
+			ID:            int32(v.ID),
+			PackageID:     int32(v.PackageID),
+			Version:       v.Version,
+			Description:   sqliteNullStringToPtr(v.Description),
+			PubspecYaml:   v.PubspecYaml,
+			Readme:        sqliteNullStringToPtr(v.Readme),
+			Changelog:     sqliteNullStringToPtr(v.Changelog),
+			ArchivePath:   v.ArchivePath,
+			ArchiveSha256: sqliteNullStringToPtr(v.ArchiveSha256),
+			Uploader:      sqliteNullStringToPtr(v.Uploader),
+			Retracted:     v.Retracted,
+			CreatedAt:     v.CreatedAt,
+			Platforms:     sqliteListFromJSON[string](v.Platforms),
+			SizeBytes:     sqliteNullInt64ToPtr(v.SizeBytes),
+			Funding:       sqliteListFromJSON[string](v.Funding),
+			Screenshots:   sqliteListFromJSON[domain.Screenshot](v.Screenshots),
+			Proxied:       v.Proxied,
+			ReadmeHTML:    sqliteNullStringToPtr(v.ReadmeHtml),
+			ChangelogHTML: sqliteNullStringToPtr(v.ChangelogHtml),
+		}
+	}
+
+	return result
+}
+
+func sqliteTokenFromRow(token sqlite.Token) *domain.Token {
+	return &domain.Token{
+		ID:        int32(token.ID),
+		Name:      token.Name,
+		TokenHash: token.TokenHash,
+		Scope:     domain.TokenScope(token.Scope),
+		ExpiresAt: sqliteNullTimeToPtr(token.ExpiresAt),
+		RevokedAt: sqliteNullTimeToPtr(token.RevokedAt),
+		CreatedAt: token.CreatedAt,
+	}
+}
+
 func sqliteNullStringToPtr(ns sql.NullString) *string {
 	if ns.Valid {
 		return &ns.String
 	}
 	return nil
 }
+
+func sqliteNullInt64ToPtr(ni sql.NullInt64) *int64 {
+	if ni.Valid {
+		return &ni.Int64
+	}
+	return nil
+}
+
+func sqliteNullTimeToPtr(nt sql.NullTime) *time.Time {
+	if nt.Valid {
+		return &nt.Time
+	}
+	return nil
+}
+
+func sqlitePtrToNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func sqliteListFromJSON[T any](data string) []T {
+	var items []T
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		return nil
+	}
+	return items
+}
+
+func sqliteListToJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return "[]"
+	}
+	// Marshalling strings and structs of strings cannot fail
+	data, _ := json.Marshal(items)
+	return string(data)
+}
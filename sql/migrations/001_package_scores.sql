@@ -0,0 +1,10 @@
+-- Adds like and download counters used by the package score endpoint
+ALTER TABLE packages ADD COLUMN like_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE packages ADD COLUMN download_count BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS package_likes (
+    package_id INTEGER NOT NULL REFERENCES packages(id) ON DELETE CASCADE,
+    liker TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (package_id, liker)
+);
@@ -0,0 +1,2 @@
+-- Stores the platforms declared by each version (Flutter plugins and pubspec platforms)
+ALTER TABLE package_versions ADD COLUMN platforms JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- Stores the archive size of each version; existing rows are backfilled lazily from storage
+ALTER TABLE package_versions ADD COLUMN size_bytes BIGINT;
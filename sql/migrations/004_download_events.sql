@@ -0,0 +1,8 @@
+-- Daily download counts per version, backing the package metrics endpoint
+CREATE TABLE IF NOT EXISTS download_events (
+    package_id INTEGER NOT NULL REFERENCES packages(id) ON DELETE CASCADE,
+    version TEXT NOT NULL,
+    day DATE NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (package_id, version, day)
+);
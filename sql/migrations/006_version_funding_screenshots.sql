@@ -0,0 +1,3 @@
+-- Stores the funding links and screenshots declared by each version
+ALTER TABLE package_versions ADD COLUMN funding JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE package_versions ADD COLUMN screenshots JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- Marks versions cached from the upstream registry rather than published here
+ALTER TABLE package_versions ADD COLUMN proxied BOOLEAN NOT NULL DEFAULT FALSE;
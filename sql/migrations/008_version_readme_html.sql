@@ -0,0 +1,2 @@
+-- Sanitized README HTML rendered at publish time, NULL until rendered
+ALTER TABLE package_versions ADD COLUMN readme_html TEXT;
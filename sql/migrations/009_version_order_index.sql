@@ -0,0 +1,2 @@
+-- Serves a package's versions newest first, a page at a time, without sorting
+CREATE INDEX idx_package_versions_created ON package_versions(package_id, created_at DESC, id DESC);
@@ -0,0 +1,6 @@
+-- The first uploader of a package, so concurrent first publishes can't both
+-- become its owner
+CREATE TABLE IF NOT EXISTS package_claims (
+    package_id INTEGER PRIMARY KEY REFERENCES packages(id) ON DELETE CASCADE,
+    uploader TEXT NOT NULL
+);
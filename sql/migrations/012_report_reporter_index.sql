@@ -0,0 +1,7 @@
+-- One report per reporter and version, so a token can't flood moderators
+-- with the same report; keeps the first of any existing duplicates
+DELETE FROM reports r
+USING reports older
+WHERE older.package_id = r.package_id AND older.version = r.version
+  AND older.reporter = r.reporter AND older.id < r.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_reporter ON reports(package_id, version, reporter);
@@ -0,0 +1,2 @@
+-- Sanitized CHANGELOG HTML rendered at publish time, NULL until rendered
+ALTER TABLE package_versions ADD COLUMN changelog_html TEXT;
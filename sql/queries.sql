@@ -3,6 +3,12 @@ INSERT INTO packages (name, private, description, homepage, repository, document
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
+-- name: CreatePackageIfNotExists :one
+INSERT INTO packages (name, private)
+VALUES ($1, $2)
+ON CONFLICT (name) DO NOTHING
+RETURNING *;
+
 -- name: GetPackage :one
 SELECT * FROM packages WHERE name = $1;
 
@@ -11,17 +17,52 @@ SELECT * FROM packages
 ORDER BY name
 LIMIT $1 OFFSET $2;
 
+-- name: ListPackagesByUpdated :many
+SELECT * FROM packages
+ORDER BY COALESCE((SELECT MAX(created_at) FROM package_versions WHERE package_id = packages.id), updated_at) DESC, name
+LIMIT $1 OFFSET $2;
+
+-- name: ListPackagesByDownloads :many
+SELECT * FROM packages
+ORDER BY download_count DESC, name
+LIMIT $1 OFFSET $2;
+
+-- name: ListPackagesUpdatedSince :many
+SELECT * FROM packages
+WHERE updated_at > $1
+ORDER BY updated_at, name
+LIMIT $2 OFFSET $3;
+
+-- name: ListPackagesPage :many
+SELECT * FROM packages
+WHERE (updated_at, id) > (sqlc.arg(last_updated)::timestamptz, sqlc.arg(last_id)::integer)
+ORDER BY updated_at, id
+LIMIT $3;
+
+-- name: ListPublicVersions :many
+SELECT p.name, p.updated_at, v.version, v.created_at AS version_created_at
+FROM packages p
+LEFT JOIN package_versions v ON v.package_id = p.id
+WHERE NOT p.private
+ORDER BY p.name, v.created_at DESC, v.id DESC;
+
 -- name: CreatePackageVersion :one
 INSERT INTO package_versions (
     package_id, version, description, pubspec_yaml, readme, changelog,
-    archive_path, archive_sha256, uploader
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    archive_path, archive_sha256, uploader, platforms, size_bytes, funding, screenshots, proxied
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 RETURNING *;
 
 -- name: GetPackageVersions :many
 SELECT * FROM package_versions 
 WHERE package_id = $1 
-ORDER BY created_at DESC;
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetPackageVersionsPage :many
+SELECT * FROM package_versions
+WHERE package_id = $1 AND (created_at, id) < (sqlc.arg(last_created)::timestamptz, sqlc.arg(last_id)::integer)
+ORDER BY created_at DESC, id DESC
+LIMIT $4;
 
 -- name: GetLatestPackageVersion :one
 SELECT * FROM package_versions 
@@ -29,6 +70,20 @@ WHERE package_id = $1 AND retracted = false
 ORDER BY created_at DESC 
 LIMIT 1;
 
+-- name: GetPackageVersion :one
+SELECT * FROM package_versions
+WHERE package_id = $1 AND version = $2;
+
+-- name: ListPackageVersionNumbers :many
+SELECT id, version, retracted FROM package_versions
+WHERE package_id = $1;
+
+-- name: GetVersionByArchiveSha256 :one
+SELECT * FROM package_versions
+WHERE package_id = $1 AND archive_sha256 = $2
+ORDER BY created_at
+LIMIT 1;
+
 -- name: AddPackageUploader :exec
 INSERT INTO package_uploaders (package_id, uploader)
 VALUES ($1, $2)
@@ -37,7 +92,117 @@ ON CONFLICT (package_id, uploader) DO NOTHING;
 -- name: GetPackageUploaders :many
 SELECT uploader FROM package_uploaders WHERE package_id = $1;
 
+-- name: ClaimPackage :one
+INSERT INTO package_claims (package_id, uploader)
+SELECT $1, $2
+WHERE NOT EXISTS (SELECT 1 FROM package_uploaders WHERE package_id = $1)
+ON CONFLICT (package_id) DO UPDATE SET uploader = package_claims.uploader
+RETURNING uploader;
+
 -- name: UpdatePackageMetadata :exec
 UPDATE packages 
 SET description = $2, homepage = $3, repository = $4, documentation = $5, updated_at = NOW()
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: AddPackageLike :execrows
+WITH inserted AS (
+    INSERT INTO package_likes (package_id, liker)
+    VALUES ($1, $2)
+    ON CONFLICT (package_id, liker) DO NOTHING
+    RETURNING package_id
+)
+UPDATE packages SET like_count = like_count + 1
+WHERE id IN (SELECT package_id FROM inserted);
+
+-- name: IncrementDownloadCount :exec
+UPDATE packages SET download_count = download_count + 1 WHERE id = $1;
+
+-- name: SetPackagePrivate :exec
+UPDATE packages SET private = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: TouchPackage :exec
+UPDATE packages SET updated_at = $2 WHERE id = $1;
+
+-- name: RestorePackageHistory :exec
+UPDATE packages
+SET created_at = LEAST(created_at, sqlc.arg(created_at)::timestamptz),
+    like_count = GREATEST(like_count, sqlc.arg(like_count)::bigint),
+    download_count = GREATEST(download_count, sqlc.arg(download_count)::bigint)
+WHERE id = sqlc.arg(id);
+
+-- name: SetPackageVersionRetracted :exec
+UPDATE package_versions SET retracted = $2 WHERE id = $1;
+
+-- name: SetPackageVersionSize :exec
+UPDATE package_versions SET size_bytes = $2 WHERE id = $1;
+
+-- name: SetPackageVersionArchivePath :exec
+UPDATE package_versions SET archive_path = $2 WHERE id = $1;
+
+-- name: SetPackageVersionCreatedAt :exec
+UPDATE package_versions SET created_at = $2 WHERE id = $1;
+
+-- name: SetPackageVersionDocsHTML :exec
+UPDATE package_versions SET readme_html = $2, changelog_html = $3 WHERE id = $1;
+
+-- name: DeletePackageVersion :exec
+DELETE FROM package_versions WHERE id = $1;
+
+-- name: IncrementDownloadEvent :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + 1;
+
+-- name: AddDownloadCounts :exec
+WITH counts AS (
+    SELECT c.package_id, c.version, c.day, c.count
+    FROM jsonb_to_recordset(sqlc.arg(counts)::jsonb) AS c(package_id INTEGER, version TEXT, day DATE, count BIGINT)
+    JOIN packages ON packages.id = c.package_id
+), buckets AS (
+    INSERT INTO download_events (package_id, version, day, count)
+    SELECT package_id, version, day, count FROM counts
+    ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + EXCLUDED.count
+)
+UPDATE packages SET download_count = download_count + totals.count
+FROM (SELECT package_id, SUM(count)::BIGINT AS count FROM counts GROUP BY package_id) AS totals
+WHERE packages.id = totals.package_id;
+
+-- name: GetDownloadEvents :many
+SELECT day, version, count FROM download_events
+WHERE package_id = $1 AND day >= $2
+ORDER BY day, version;
+
+-- name: GetInstanceStats :one
+SELECT
+    (SELECT COUNT(*) FROM packages)::BIGINT AS package_count,
+    (SELECT COUNT(*) FROM package_versions)::BIGINT AS version_count,
+    (SELECT COALESCE(SUM(size_bytes), 0) FROM package_versions)::BIGINT AS storage_bytes,
+    (SELECT COALESCE(SUM(download_count), 0) FROM packages)::BIGINT AS download_count,
+    (SELECT COUNT(DISTINCT uploader) FROM package_versions)::BIGINT AS publisher_count;
+
+-- name: CreateToken :one
+INSERT INTO tokens (name, token_hash, scope, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetTokenByHash :one
+SELECT * FROM tokens WHERE token_hash = $1;
+
+-- name: ListTokens :many
+SELECT * FROM tokens ORDER BY id;
+
+-- name: RevokeToken :execrows
+UPDATE tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: CreateReport :one
+INSERT INTO reports (package_id, version, reporter, reason)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListReports :many
+SELECT r.id, p.name AS package_name, r.version, r.reporter, r.reason, r.created_at
+FROM reports r
+JOIN packages p ON p.id = r.package_id
+WHERE r.id < sqlc.arg(before)::integer
+ORDER BY r.id DESC
+LIMIT sqlc.arg(max_reports);
\ No newline at end of file
@@ -1,43 +1,200 @@
 -- name: CreatePackage :one
 INSERT INTO packages (name, private, description, homepage, repository, documentation)
 VALUES (?, ?, ?, ?, ?, ?)
-RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at;
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count;
+
+-- name: CreatePackageIfNotExists :one
+INSERT INTO packages (name, private)
+VALUES (?, ?)
+ON CONFLICT (name) DO NOTHING
+RETURNING id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count;
 
 -- name: GetPackage :one
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages WHERE name = ?;
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages WHERE name = ?;
 
 -- name: ListPackages :many
-SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at FROM packages 
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages 
 ORDER BY name
 LIMIT ? OFFSET ?;
 
+-- name: ListPackagesByUpdated :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY COALESCE((SELECT MAX(created_at) FROM package_versions WHERE package_id = packages.id), updated_at) DESC, name
+LIMIT ? OFFSET ?;
+
+-- name: ListPackagesByDownloads :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+ORDER BY download_count DESC, name
+LIMIT ? OFFSET ?;
+
+-- name: ListPackagesUpdatedSince :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE datetime(updated_at) > datetime(sqlc.arg(since))
+ORDER BY updated_at, name
+LIMIT ? OFFSET ?;
+
+-- name: ListPackagesPage :many
+SELECT id, name, private, description, homepage, repository, documentation, created_at, updated_at, like_count, download_count FROM packages
+WHERE (datetime(updated_at), id) > (datetime(sqlc.arg(last_updated)), sqlc.arg(last_id))
+ORDER BY datetime(updated_at), id
+LIMIT ?;
+
 -- name: UpdatePackageMetadata :exec
 UPDATE packages 
 SET description = ?, homepage = ?, repository = ?, documentation = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
 
+-- name: ListPublicVersions :many
+SELECT p.name, p.updated_at, v.version, v.created_at AS version_created_at
+FROM packages p
+LEFT JOIN package_versions v ON v.package_id = p.id
+WHERE p.private = FALSE
+ORDER BY p.name, v.created_at DESC, v.id DESC;
+
 -- name: CreatePackageVersion :one
 INSERT INTO package_versions (
     package_id, version, description, pubspec_yaml, readme, changelog,
-    archive_path, archive_sha256, uploader
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at;
+    archive_path, archive_sha256, uploader, platforms, size_bytes, funding, screenshots, proxied
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html;
 
 -- name: GetPackageVersions :many
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = ? 
-ORDER BY created_at DESC;
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetPackageVersionsPage :many
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND (datetime(created_at), id) < (datetime(sqlc.arg(last_created)), sqlc.arg(last_id))
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
 
 -- name: GetLatestPackageVersion :one
-SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at FROM package_versions 
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions 
 WHERE package_id = ? AND retracted = false
 ORDER BY created_at DESC 
 LIMIT 1;
 
+-- name: GetPackageVersion :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND version = ?;
+
+-- name: ListPackageVersionNumbers :many
+SELECT id, version, retracted FROM package_versions
+WHERE package_id = ?;
+
+-- name: GetVersionByArchiveSha256 :one
+SELECT id, package_id, version, description, pubspec_yaml, readme, changelog, archive_path, archive_sha256, uploader, retracted, created_at, platforms, size_bytes, funding, screenshots, proxied, readme_html, changelog_html FROM package_versions
+WHERE package_id = ? AND archive_sha256 = ?
+ORDER BY created_at
+LIMIT 1;
+
 -- name: AddPackageUploader :exec
 INSERT INTO package_uploaders (package_id, uploader)
 VALUES (?, ?)
 ON CONFLICT (package_id, uploader) DO NOTHING;
 
 -- name: GetPackageUploaders :many
-SELECT uploader FROM package_uploaders WHERE package_id = ?;
\ No newline at end of file
+SELECT uploader FROM package_uploaders WHERE package_id = ?;
+
+-- name: ClaimPackage :one
+INSERT INTO package_claims (package_id, uploader)
+SELECT ?1, ?2
+WHERE NOT EXISTS (SELECT 1 FROM package_uploaders WHERE package_id = ?1)
+ON CONFLICT (package_id) DO UPDATE SET uploader = package_claims.uploader
+RETURNING uploader;
+
+-- name: AddPackageLike :execrows
+INSERT INTO package_likes (package_id, liker)
+VALUES (?, ?)
+ON CONFLICT (package_id, liker) DO NOTHING;
+
+-- name: IncrementLikeCount :exec
+UPDATE packages SET like_count = like_count + 1 WHERE id = ?;
+
+-- name: IncrementDownloadCount :exec
+UPDATE packages SET download_count = download_count + 1 WHERE id = ?;
+
+-- name: SetPackagePrivate :exec
+UPDATE packages SET private = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?;
+
+-- name: TouchPackage :exec
+UPDATE packages SET updated_at = datetime(sqlc.arg(updated_at)) WHERE id = ?;
+
+-- name: RestorePackageHistory :exec
+UPDATE packages
+SET created_at = MIN(datetime(created_at), datetime(sqlc.arg(created_at))),
+    like_count = MAX(like_count, sqlc.arg(like_count)),
+    download_count = MAX(download_count, sqlc.arg(download_count))
+WHERE id = sqlc.arg(id);
+
+-- name: SetPackageVersionRetracted :exec
+UPDATE package_versions SET retracted = ? WHERE id = ?;
+
+-- name: SetPackageVersionSize :exec
+UPDATE package_versions SET size_bytes = ? WHERE id = ?;
+
+-- name: SetPackageVersionArchivePath :exec
+UPDATE package_versions SET archive_path = ? WHERE id = ?;
+
+-- name: SetPackageVersionCreatedAt :exec
+UPDATE package_versions SET created_at = datetime(?) WHERE id = ?;
+
+-- name: SetPackageVersionDocsHTML :exec
+UPDATE package_versions SET readme_html = ?, changelog_html = ? WHERE id = ?;
+
+-- name: DeletePackageVersion :exec
+DELETE FROM package_versions WHERE id = ?;
+
+-- name: IncrementDownloadEvent :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES (?, ?, ?, 1)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + 1;
+
+-- name: AddDownloadEvents :exec
+INSERT INTO download_events (package_id, version, day, count)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (package_id, version, day) DO UPDATE SET count = download_events.count + excluded.count;
+
+-- name: AddDownloadCount :exec
+UPDATE packages SET download_count = download_count + ? WHERE id = ?;
+
+-- name: GetDownloadEvents :many
+SELECT day, version, count FROM download_events
+WHERE package_id = ? AND day >= ?
+ORDER BY day, version;
+
+-- name: GetInstanceStats :one
+SELECT
+    (SELECT COUNT(*) FROM packages) AS package_count,
+    (SELECT COUNT(*) FROM package_versions) AS version_count,
+    CAST((SELECT COALESCE(SUM(size_bytes), 0) FROM package_versions) AS INTEGER) AS storage_bytes,
+    CAST((SELECT COALESCE(SUM(download_count), 0) FROM packages) AS INTEGER) AS download_count,
+    (SELECT COUNT(DISTINCT uploader) FROM package_versions) AS publisher_count;
+
+-- name: CreateToken :one
+INSERT INTO tokens (name, token_hash, scope, expires_at)
+VALUES (?, ?, ?, ?)
+RETURNING id, name, token_hash, scope, expires_at, revoked_at, created_at;
+
+-- name: GetTokenByHash :one
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens WHERE token_hash = ?;
+
+-- name: ListTokens :many
+SELECT id, name, token_hash, scope, expires_at, revoked_at, created_at FROM tokens ORDER BY id;
+
+-- name: RevokeToken :execrows
+UPDATE tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL;
+
+-- name: CreateReport :one
+INSERT INTO reports (package_id, version, reporter, reason)
+VALUES (?, ?, ?, ?)
+RETURNING id, package_id, version, reporter, reason, created_at;
+
+-- name: ListReports :many
+SELECT r.id, p.name AS package_name, r.version, r.reporter, r.reason, r.created_at
+FROM reports r
+JOIN packages p ON p.id = r.package_id
+WHERE r.id < sqlc.arg(before)
+ORDER BY r.id DESC
+LIMIT sqlc.arg(max_reports);
\ No newline at end of file
@@ -44,6 +44,11 @@ func TestIntegration(t *testing.T) {
 	pubCache := t.TempDir()
 	t.Setenv("PUB_CACHE", pubCache)
 
+	// A dry run validates without publishing, so it must not create the package
+	t.Run("dry-run publish hello_world", func(t *testing.T) {
+		testDryRunPublish(t, "hello_world")
+	})
+
 	// Test publishing hello_world package
 	t.Run("publish hello_world", func(t *testing.T) {
 		publishPackage(t, "hello_world")
@@ -54,6 +59,11 @@ func TestIntegration(t *testing.T) {
 		publishPackage(t, "math_utils")
 	})
 
+	// Test that an invalid stored token is rejected with a clean auth error
+	t.Run("reject publish with invalid token", func(t *testing.T) {
+		testInvalidTokenRejected(t)
+	})
+
 	// Test browsing packages via web interface
 	t.Run("browse packages", func(t *testing.T) {
 		testWebInterface(t)
@@ -258,6 +268,112 @@ func publishPackage(t *testing.T, packageName string) {
 	t.Logf("Output: %s", output)
 }
 
+func testDryRunPublish(t *testing.T, packageName string) {
+	t.Helper()
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(originalDir); err != nil {
+			t.Errorf("Failed to restore working directory: %v", err)
+		}
+	}()
+	if err := os.Chdir(filepath.Join("packages", packageName)); err != nil {
+		t.Fatalf("Failed to change to package directory: %v", err)
+	}
+
+	addToken(t, authToken)
+	cmd := exec.Command("dart", "pub", "publish", "--dry-run")
+	cmd.Env = append(os.Environ(), "PUB_HOSTED_URL="+serverURL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Dry-run publish of %s failed: %v\nOutput: %s", packageName, err, output)
+	}
+
+	// The package is still unknown to the server
+	status, err := exec.Command("curl", "-s", "-o", "/dev/null", "-w", "%{http_code}",
+		"-H", "Authorization: Bearer "+authToken, serverURL+"/api/packages/"+packageName).Output()
+	if err != nil {
+		t.Fatalf("Failed to request package metadata: %v", err)
+	}
+	if string(status) != "404" {
+		t.Errorf("Expected 404 for %s after a dry run, got %s", packageName, status)
+	}
+
+	t.Logf("✅ Dry run of %s created nothing", packageName)
+}
+
+// addToken stores token for the test server in the dart pub credential store,
+// replacing any token previously stored for it
+func addToken(t *testing.T, token string) {
+	t.Helper()
+
+	tokenCmd := exec.Command("dart", "pub", "token", "add", serverURL)
+	tokenCmd.Stdin = strings.NewReader(token + "\n")
+	if output, err := tokenCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to add token: %v\nOutput: %s", err, output)
+	}
+}
+
+func testInvalidTokenRejected(t *testing.T) {
+	t.Helper()
+
+	const invalidToken = "invalid-integration-token"
+	const challengeMessage = "A write token is required to publish to this repository."
+
+	// The server must answer with a 401 and a Bearer challenge the Dart client understands
+	cmd := exec.Command("curl", "-s", "-D", "-", "-o", "/dev/null",
+		"-H", "Authorization: Bearer "+invalidToken, serverURL+"/api/packages/versions/new")
+	headers, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to request upload URL: %v", err)
+	}
+	headerStr := string(headers)
+	if !strings.Contains(headerStr, " 401") {
+		t.Errorf("Expected 401 for invalid token, got headers:\n%s", headerStr)
+	}
+	// Header names are case-insensitive
+	if !strings.Contains(strings.ToLower(headerStr), "www-authenticate: bearer realm=") {
+		t.Errorf("Expected WWW-Authenticate Bearer challenge, got headers:\n%s", headerStr)
+	}
+
+	// Publish through the real credential path with the invalid token stored
+	addToken(t, invalidToken)
+	defer addToken(t, authToken)
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(originalDir); err != nil {
+			t.Errorf("Failed to restore working directory: %v", err)
+		}
+	}()
+	if err := os.Chdir(filepath.Join("packages", "hello_world")); err != nil {
+		t.Fatalf("Failed to change to package directory: %v", err)
+	}
+
+	publishCmd := exec.Command("dart", "pub", "publish", "--force")
+	publishCmd.Env = append(os.Environ(), "PUB_HOSTED_URL="+serverURL)
+	output, err := publishCmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected publish with invalid token to fail\nOutput: %s", output)
+	}
+
+	// The client reports the auth failure and relays the server's challenge message
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "pub token add") {
+		t.Errorf("Expected client to suggest adding a token\nOutput: %s", outputStr)
+	}
+	if !strings.Contains(outputStr, challengeMessage) {
+		t.Errorf("Expected client to show the server message %q\nOutput: %s", challengeMessage, outputStr)
+	}
+
+	t.Log("✅ Invalid token rejected with an authentication challenge")
+}
+
 func updatePubspecForTesting(t *testing.T) {
 	t.Helper()
 	
@@ -1,34 +1,38 @@
 package templates
 
 import (
-	"bytes"
+	"fmt"
 	"html/template"
-
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/extension"
-	"github.com/yuin/goldmark/renderer/html"
+	"net/url"
+	"repub/internal/markdown"
+	"strings"
 )
 
-var markdownParser = goldmark.New(
-	goldmark.WithExtensions(extension.GFM),
-	goldmark.WithRendererOptions(
-		html.WithHardWraps(),
-		html.WithXHTML(),
-		html.WithUnsafe(), // Allow raw HTML in markdown
-	),
-)
+// RenderMarkdown converts markdown text to sanitized HTML
+func RenderMarkdown(text string) template.HTML {
+	return template.HTML(markdown.Render(text))
+}
 
-// RenderMarkdown converts markdown text to HTML
-func RenderMarkdown(markdown string) template.HTML {
-	if markdown == "" {
-		return template.HTML("")
+// FormatBytes renders a byte count in human-readable binary units, e.g. "1.5 KiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-
-	var buf bytes.Buffer
-	if err := markdownParser.Convert([]byte(markdown), &buf); err != nil {
-		// Return the raw markdown if parsing fails
-		return template.HTML("<pre>" + template.HTMLEscapeString(markdown) + "</pre>")
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-	return template.HTML(buf.String())
-}
\ No newline at end of file
+// ScreenshotURL links a screenshot of a version, escaping each segment of
+// its in-archive path
+func ScreenshotURL(packageName, version, path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/packages/" + packageName + "/versions/" + version + "/screenshots/" + strings.Join(segments, "/")
+}
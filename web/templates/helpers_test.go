@@ -0,0 +1,54 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown_Sanitizes(t *testing.T) {
+	readme := "# My Package\n\n" +
+		"Some **bold** text and a [link](https://example.com).\n\n" +
+		"<script>alert('xss')</script>\n\n" +
+		"<img src=\"logo.png\" onerror=\"alert('xss')\">\n\n" +
+		"<iframe src=\"https://evil.example.com\"></iframe>\n\n" +
+		"```go\nfmt.Println(\"hi\")\n```\n"
+
+	html := string(RenderMarkdown(readme))
+
+	for _, forbidden := range []string{"<script", "alert(", "onerror", "<iframe"} {
+		if strings.Contains(html, forbidden) {
+			t.Errorf("Expected %q to be removed, got: %s", forbidden, html)
+		}
+	}
+
+	for _, kept := range []string{"<h1", "<strong>bold</strong>", `href="https://example.com"`, `src="logo.png"`, `class="language-go"`} {
+		if !strings.Contains(html, kept) {
+			t.Errorf("Expected %q to be kept, got: %s", kept, html)
+		}
+	}
+}
+
+func TestRenderMarkdown_Empty(t *testing.T) {
+	if got := RenderMarkdown(""); got != "" {
+		t.Errorf("Expected empty output, got %q", got)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.expected {
+			t.Errorf("FormatBytes(%d) = %q, expected %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
@@ -8,7 +8,12 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
-func Index() templ.Component {
+import (
+	"fmt"
+	"repub/internal/domain"
+)
+
+func Index(instance Instance, stats *domain.InstanceStats) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -29,7 +34,7 @@ func Index() templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = Base("Home", IndexContent()).Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = Base("Home", IndexContent(instance, stats)).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -37,7 +42,7 @@ func Index() templ.Component {
 	})
 }
 
-func IndexContent() templ.Component {
+func IndexContent(instance Instance, stats *domain.InstanceStats) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -58,7 +63,98 @@ func IndexContent() templ.Component {
 			templ_7745c5c3_Var2 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"min-h-screen bg-gradient-to-b from-blue-50 to-white\"><!-- Hero Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 pt-20 pb-16\"><div class=\"text-center\"><div class=\"mb-8\"><div class=\"inline-flex items-center space-x-3 mb-6\"><div class=\"w-16 h-16 bg-gradient-to-br from-blue-500 to-blue-600 rounded-xl flex items-center justify-center\"><svg class=\"w-8 h-8 text-white\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M20 13V6a2 2 0 00-2-2H6a2 2 0 00-2 2v7m16 0v5a2 2 0 01-2 2H6a2 2 0 01-2-2v-5m16 0h-2M4 13h2m8-8v2m0 0V3m0 2h2m-2 0H8\"></path></svg></div><h1 class=\"text-5xl font-bold text-gray-900\">Repub</h1></div><p class=\"text-xl text-gray-600 mb-8 max-w-2xl mx-auto\">A self-hosted Dart package repository. Fast, reliable, and fully compatible with pub.dev.</p><div class=\"flex flex-col sm:flex-row gap-4 justify-center\"><a href=\"/packages\" class=\"inline-flex items-center px-6 py-3 border border-transparent text-base font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 transition-colors\">Browse Packages <svg class=\"ml-2 w-4 h-4\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M9 5l7 7-7 7\"></path></svg></a> <a href=\"#getting-started\" class=\"inline-flex items-center px-6 py-3 border border-gray-300 text-base font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 transition-colors\">Get Started</a></div></div></div></div><!-- Features Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"text-center mb-16\"><h2 class=\"text-3xl font-bold text-gray-900 mb-4\">Why Choose Repub?</h2><p class=\"text-lg text-gray-600\">Because it's the best thing since sliced bread. Trust me bro.</p></div><div class=\"grid md:grid-cols-3 gap-8\"><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-blue-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-blue-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Fast & Reliable</h3><p class=\"text-gray-600\">Built with Go for optimal performance. Handle thousands of packages with ease and lightning-fast response times.</p></div><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-green-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-green-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M9 12l2 2 4-4m6 2a9 9 0 11-18 0 9 9 0 0118 0z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Pub Compatible</h3><p class=\"text-gray-600\">Fully compatible with Dart pub specification v2. Use all your existing pub commands without any changes.</p></div><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-purple-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-purple-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Self-Hosted</h3><p class=\"text-gray-600\">Complete control over your packages. Host private packages on your own infrastructure with enterprise security.</p></div></div></div><!-- Getting Started Section --><div id=\"getting-started\" class=\"bg-gray-50\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"text-center mb-12\"><h2 class=\"text-3xl font-bold text-gray-900 mb-4\">Getting Started</h2><p class=\"text-lg text-gray-600\">Set up your Dart package repository in minutes</p></div><div class=\"grid lg:grid-cols-2 gap-8 max-w-6xl mx-auto\"><!-- Authentication --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">1</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Add Authentication</h3></div><p class=\"text-gray-600 mb-4\">Configure pub to authenticate with your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub token add http://localhost:8080</code></pre></div></div><!-- Publishing --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">2</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Publish Packages</h3></div><p class=\"text-gray-600 mb-4\">Publish your packages to the repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub publish --server=http://localhost:8080</code></pre></div></div><!-- Using Packages --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">3</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Use Packages</h3></div><p class=\"text-gray-600 mb-4\">Add packages from your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub add your_package --hosted-url=http://localhost:8080</code></pre></div></div><!-- Publishing Packages --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">4</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Get Packages</h3></div><p class=\"text-gray-600 mb-4\">Install dependencies from your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub get</code></pre></div></div></div></div></div><!-- Stats Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"bg-gradient-to-r from-blue-600 to-blue-700 rounded-2xl p-8 text-center text-white\"><h2 class=\"text-2xl font-bold mb-8\">Repository Statistics</h2><div class=\"grid grid-cols-1 md:grid-cols-3 gap-8\"><div><div class=\"text-3xl font-bold mb-2\">0</div><div class=\"text-blue-100\">Packages Published</div></div><div><div class=\"text-3xl font-bold mb-2\">0</div><div class=\"text-blue-100\">Total Downloads</div></div><div><div class=\"text-3xl font-bold mb-2\">0</div><div class=\"text-blue-100\">Active Publishers</div></div></div></div></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"min-h-screen bg-gradient-to-b from-blue-50 to-white\"><!-- Hero Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 pt-20 pb-16\"><div class=\"text-center\"><div class=\"mb-8\"><div class=\"inline-flex items-center space-x-3 mb-6\"><div class=\"w-16 h-16 bg-gradient-to-br from-blue-500 to-blue-600 rounded-xl flex items-center justify-center\"><svg class=\"w-8 h-8 text-white\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M20 13V6a2 2 0 00-2-2H6a2 2 0 00-2 2v7m16 0v5a2 2 0 01-2 2H6a2 2 0 01-2-2v-5m16 0h-2M4 13h2m8-8v2m0 0V3m0 2h2m-2 0H8\"></path></svg></div><h1 class=\"text-5xl font-bold text-gray-900\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var3 string
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(instance.displayName())
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 24, Col: 75}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</h1></div><p class=\"text-xl text-gray-600 mb-8 max-w-2xl mx-auto\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var4 string
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(instance.displayDescription())
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 27, Col: 37}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</p><div class=\"flex flex-col sm:flex-row gap-4 justify-center\"><a href=\"/packages\" class=\"inline-flex items-center px-6 py-3 border border-transparent text-base font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 transition-colors\">Browse Packages <svg class=\"ml-2 w-4 h-4\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M9 5l7 7-7 7\"></path></svg></a> <a href=\"#getting-started\" class=\"inline-flex items-center px-6 py-3 border border-gray-300 text-base font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 transition-colors\">Get Started</a></div></div></div></div><!-- Features Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"text-center mb-16\"><h2 class=\"text-3xl font-bold text-gray-900 mb-4\">Why Choose Repub?</h2><p class=\"text-lg text-gray-600\">Because it's the best thing since sliced bread. Trust me bro.</p></div><div class=\"grid md:grid-cols-3 gap-8\"><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-blue-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-blue-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Fast & Reliable</h3><p class=\"text-gray-600\">Built with Go for optimal performance. Handle thousands of packages with ease and lightning-fast response times.</p></div><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-green-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-green-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M9 12l2 2 4-4m6 2a9 9 0 11-18 0 9 9 0 0118 0z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Pub Compatible</h3><p class=\"text-gray-600\">Fully compatible with Dart pub specification v2. Use all your existing pub commands without any changes.</p></div><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200 hover:shadow-md transition-shadow\"><div class=\"w-12 h-12 bg-purple-100 rounded-lg flex items-center justify-center mb-6\"><svg class=\"w-6 h-6 text-purple-600\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z\"></path></svg></div><h3 class=\"text-xl font-semibold text-gray-900 mb-3\">Self-Hosted</h3><p class=\"text-gray-600\">Complete control over your packages. Host private packages on your own infrastructure with enterprise security.</p></div></div></div><!-- Getting Started Section --><div id=\"getting-started\" class=\"bg-gray-50\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"text-center mb-12\"><h2 class=\"text-3xl font-bold text-gray-900 mb-4\">Getting Started</h2><p class=\"text-lg text-gray-600\">Set up your Dart package repository in minutes</p></div><div class=\"grid lg:grid-cols-2 gap-8 max-w-6xl mx-auto\"><!-- Authentication --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">1</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Add Authentication</h3></div><p class=\"text-gray-600 mb-4\">Configure pub to authenticate with your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub token add http://localhost:8080</code></pre></div></div><!-- Publishing --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">2</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Publish Packages</h3></div><p class=\"text-gray-600 mb-4\">Publish your packages to the repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub publish --server=http://localhost:8080</code></pre></div></div><!-- Using Packages --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">3</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Use Packages</h3></div><p class=\"text-gray-600 mb-4\">Add packages from your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub add your_package --hosted-url=http://localhost:8080</code></pre></div></div><!-- Publishing Packages --><div class=\"bg-white rounded-xl p-8 shadow-sm border border-gray-200\"><div class=\"flex items-center mb-6\"><div class=\"w-8 h-8 bg-blue-100 rounded-lg flex items-center justify-center mr-3\"><span class=\"text-blue-600 font-bold text-sm\">4</span></div><h3 class=\"text-xl font-semibold text-gray-900\">Get Packages</h3></div><p class=\"text-gray-600 mb-4\">Install dependencies from your repository:</p><div class=\"bg-gray-900 rounded-lg p-4 overflow-x-auto\"><pre class=\"text-sm text-green-400\"><code>dart pub get</code></pre></div></div></div></div></div><!-- Stats Section --><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-16\"><div class=\"bg-gradient-to-r from-blue-600 to-blue-700 rounded-2xl p-8 text-center text-white\"><h2 class=\"text-2xl font-bold mb-8\">Repository Statistics</h2><div class=\"grid grid-cols-2 md:grid-cols-5 gap-8\"><div><div class=\"text-3xl font-bold mb-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var5 string
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d", stats.Packages))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 148, Col: 78}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</div><div class=\"text-blue-100\">Packages Published</div></div><div><div class=\"text-3xl font-bold mb-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var6 string
+		templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d", stats.Versions))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 152, Col: 78}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div><div class=\"text-blue-100\">Versions</div></div><div><div class=\"text-3xl font-bold mb-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var7 string
+		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d", stats.Downloads))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 156, Col: 79}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div><div class=\"text-blue-100\">Total Downloads</div></div><div><div class=\"text-3xl font-bold mb-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var8 string
+		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d", stats.Publishers))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 160, Col: 80}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</div><div class=\"text-blue-100\">Active Publishers</div></div><div><div class=\"text-3xl font-bold mb-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var9 string
+		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(FormatBytes(stats.StorageBytes))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index.templ`, Line: 164, Col: 76}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</div><div class=\"text-blue-100\">Storage Used</div></div></div></div></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
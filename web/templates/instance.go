@@ -0,0 +1,26 @@
+package templates
+
+const (
+	defaultInstanceName        = "Repub"
+	defaultInstanceDescription = "A self-hosted Dart package repository. Fast, reliable, and fully compatible with pub.dev."
+)
+
+// Instance brands the landing page; empty fields keep the Repub defaults
+type Instance struct {
+	Name        string
+	Description string
+}
+
+func (i Instance) displayName() string {
+	if i.Name == "" {
+		return defaultInstanceName
+	}
+	return i.Name
+}
+
+func (i Instance) displayDescription() string {
+	if i.Description == "" {
+		return defaultInstanceDescription
+	}
+	return i.Description
+}
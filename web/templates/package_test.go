@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"context"
+	"repub/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionHistory(t *testing.T) {
+	detail := &domain.PackageDetail{
+		Package: &domain.Package{Name: "history"},
+		Versions: []*domain.PackageVersion{
+			{Version: "1.1.0", Retracted: true, CreatedAt: time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)},
+			{Version: "1.0.0", CreatedAt: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		},
+		VersionDownloads: map[string]int64{"1.0.0": 42},
+	}
+
+	var buf strings.Builder
+	if err := VersionHistory(detail).Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	html := buf.String()
+
+	for _, expected := range []string{
+		`href="/packages/history/versions/1.1.0"`,
+		`href="/packages/history/versions/1.0.0"`,
+		"Mar 15, 2024",
+		"Jan 2, 2024",
+		">42<",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected %q in version history, got: %s", expected, html)
+		}
+	}
+
+	// Only the retracted version carries the badge
+	if count := strings.Count(html, "Retracted"); count != 1 {
+		t.Errorf("Expected 1 retracted badge, got %d", count)
+	}
+}
+
+func TestVersionHistory_Proxied(t *testing.T) {
+	detail := &domain.PackageDetail{
+		Package: &domain.Package{Name: "mirrored"},
+		Versions: []*domain.PackageVersion{
+			{Version: "2.0.0", Proxied: true},
+			{Version: "1.0.0"},
+		},
+	}
+
+	var buf strings.Builder
+	if err := VersionHistory(detail).Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if count := strings.Count(buf.String(), "Proxied"); count != 1 {
+		t.Errorf("Expected 1 proxied badge, got %d", count)
+	}
+}
+
+func TestFundingAndScreenshots(t *testing.T) {
+	version := &domain.PackageVersion{
+		Version: "1.0.0",
+		Funding: []string{"https://github.com/sponsors/dev"},
+		Screenshots: []domain.Screenshot{
+			{Description: "Home screen", Path: "screenshots/home screen.png"},
+		},
+	}
+
+	var buf strings.Builder
+	if err := Funding(version).Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if err := Screenshots("pictured", version).Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	html := buf.String()
+
+	for _, expected := range []string{
+		`href="https://github.com/sponsors/dev"`,
+		`src="/packages/pictured/versions/1.0.0/screenshots/screenshots/home%20screen.png"`,
+		`alt="Home screen"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected %q in rendered HTML, got: %s", expected, html)
+		}
+	}
+
+	// Versions without either render nothing
+	buf.Reset()
+	empty := &domain.PackageVersion{Version: "1.0.0"}
+	_ = Funding(empty).Render(context.Background(), &buf)
+	_ = Screenshots("pictured", empty).Render(context.Background(), &buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output without funding or screenshots, got: %s", buf.String())
+	}
+}